@@ -0,0 +1,79 @@
+package main
+
+/*
+	The parts of offline_geocode shared by the command line and the
+	JavaScript builds.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/rgglez/geonames-loader/examples/go/pkg/offline"
+)
+
+// output is the answer of one lookup.
+type output struct {
+	Latitude  float64         `json:"latitude"`
+	Longitude float64         `json:"longitude"`
+	Places    []offline.Place `json:"places"`
+}
+
+// checkPoint reports coordinates out of range.
+func checkPoint(lat, lon float64) error {
+	switch {
+	case lat < -90 || lat > 90:
+		return fmt.Errorf("latitude must be between -90 and 90")
+	case lon < -180 || lon > 180:
+		return fmt.Errorf("longitude must be between -180 and 180")
+	}
+	return nil
+}
+
+// reverse returns the places of idx nearest to (lat, lon).
+func reverse(idx *offline.Index, lat, lon float64, opts offline.Options) output {
+	return output{Latitude: lat, Longitude: lon, Places: idx.Nearest(lat, lon, opts)}
+}
+
+// isZip reports whether name is a zip archive, by its extension.
+func isZip(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".zip")
+}
+
+// readZip reads the geoname file of a zip archive of the dumps, the first
+// .txt member other than readme.txt.
+func readZip(r io.ReaderAt, size int64) (*offline.Index, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range zr.File {
+		if strings.HasSuffix(f.Name, ".txt") && f.Name != "readme.txt" {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer rc.Close()
+			return offline.Read(rc)
+		}
+	}
+	return nil, fmt.Errorf("no geoname file in the archive")
+}
@@ -0,0 +1,124 @@
+//go:build !js
+
+package main
+
+/*
+	offline_geocode
+	Reverse geocoding without a database: the nearest places of a point,
+	from a GeoNames dump file or the dataset embedded in the binary.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    go run . --data cities15000.zip --lat 19.4326 --lon -99.1332
+	    go run . --data MX.txt --lat 20.6767 --lon -103.3475 --results 5
+
+	Build, with the dataset inside (see pkg/offline/gen.go):
+	    go generate ../../pkg/offline
+	    CGO_ENABLED=0 go build -tags geonames_embed -o offline_geocode .
+	    GOOS=wasip1 GOARCH=wasm go build -tags geonames_embed -o geocode.wasm .
+	    wasmtime geocode.wasm --lat 19.4326 --lon -99.1332
+
+	    GOOS=js GOARCH=wasm go build -tags geonames_embed -o geocode.wasm .
+
+	The js/wasm build registers a JavaScript API instead of reading flags;
+	see main_js.go. The library side is in pkg/offline/offline.go. A
+	--data file is a GeoNames dump — zipped, gzipped or plain text — read
+	in full at start-up; without --data the embedded dataset is used.
+*/
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+
+	"github.com/rgglez/geonames-loader/examples/go/pkg/offline"
+)
+
+func main() {
+	lat := flag.Float64(
+		"lat", math.NaN(),
+		"Latitude in decimal degrees (required, e.g. 19.4326)",
+	)
+	lon := flag.Float64(
+		"lon", math.NaN(),
+		"Longitude in decimal degrees (required, e.g. -99.1332)",
+	)
+	data := flag.String(
+		"data", "",
+		"GeoNames dump file (.zip, .gz or .txt); default: the embedded dataset",
+	)
+	nRes := flag.Int(
+		"results", 3,
+		"Number of nearest places to return (default: 3)",
+	)
+	country := flag.String(
+		"country", "",
+		"Restrict results to this ISO 3166-1 alpha-2 country code",
+	)
+	featureClass := flag.String(
+		"feature-class", "",
+		"Restrict results to this GeoNames feature class (e.g. P)",
+	)
+	minPopulation := flag.Int64(
+		"min-population", 0,
+		"Only list places with at least this many inhabitants",
+	)
+	flag.Parse()
+
+	if math.IsNaN(*lat) || math.IsNaN(*lon) {
+		fmt.Fprintln(os.Stderr, "ERROR: --lat and --lon are required.")
+		flag.Usage()
+		os.Exit(1)
+	}
+	if err := checkPoint(*lat, *lon); err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		os.Exit(1)
+	}
+
+	idx, err := loadIndex(*data)
+	if err != nil {
+		log.Fatalf("dataset: %v", err)
+	}
+	res := reverse(idx, *lat, *lon, offline.Options{
+		Limit: *nRes, Country: *country, FeatureClass: *featureClass,
+		MinPopulation: *minPopulation,
+	})
+	out, _ := json.MarshalIndent(res, "", "  ")
+	fmt.Println(string(out))
+}
+
+// loadIndex reads the dump file at path, or the embedded dataset when
+// path is empty.
+func loadIndex(path string) (*offline.Index, error) {
+	if path == "" {
+		return offline.Embedded()
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if st, err := f.Stat(); err == nil && isZip(path) {
+		return readZip(f, st.Size())
+	}
+	return offline.Read(f)
+}
@@ -0,0 +1,131 @@
+//go:build js && wasm
+
+package main
+
+/*
+	JavaScript API of the js/wasm build of offline_geocode.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage (with wasm_exec.js from $(go env GOROOT)/lib/wasm or misc/wasm):
+	    const go = new Go();
+	    const { instance } = await WebAssembly.instantiateStreaming(
+	        fetch("geocode.wasm"), go.importObject);
+	    go.run(instance);
+
+	    // Without the embedded dataset, load one first:
+	    const data = new Uint8Array(await (await fetch("cities15000.zip")).arrayBuffer());
+	    geonames.load(data, "cities15000.zip");
+
+	    geonames.reverse(19.4326, -99.1332, { limit: 3, country: "MX" });
+	    // → { latitude, longitude, places: [{ name, country, distance_km, ... }] }
+
+	main registers the global object geonames and keeps running, so its
+	functions stay callable. Every function returns a plain object, with
+	an error property instead of its result when it fails; reverse uses
+	the embedded dataset until load replaces it.
+*/
+
+import (
+	"bytes"
+	"encoding/json"
+	"syscall/js"
+
+	"github.com/rgglez/geonames-loader/examples/go/pkg/offline"
+)
+
+// index is the dataset of reverse: the embedded one until load is called.
+var index *offline.Index
+
+func main() {
+	index, _ = offline.Embedded()
+	js.Global().Set("geonames", map[string]any{
+		"load":    js.FuncOf(jsLoad),
+		"reverse": js.FuncOf(jsReverse),
+	})
+	select {}
+}
+
+// jsLoad is geonames.load(data Uint8Array, name string): it reads a dump
+// file, a zip archive when name ends in .zip, and returns {places: N}.
+func jsLoad(_ js.Value, args []js.Value) any {
+	if len(args) < 1 || args[0].Type() != js.TypeObject {
+		return jsError("load: want a Uint8Array")
+	}
+	data := make([]byte, args[0].Get("length").Int())
+	js.CopyBytesToGo(data, args[0])
+	var (
+		idx *offline.Index
+		err error
+	)
+	if len(args) > 1 && isZip(args[1].String()) {
+		idx, err = readZip(bytes.NewReader(data), int64(len(data)))
+	} else {
+		idx, err = offline.Read(bytes.NewReader(data))
+	}
+	if err != nil {
+		return jsError("load: " + err.Error())
+	}
+	index = idx
+	return map[string]any{"places": idx.Len()}
+}
+
+// jsReverse is geonames.reverse(lat, lon, options): options has limit
+// (default 3), country, featureClass and minPopulation.
+func jsReverse(_ js.Value, args []js.Value) any {
+	if index == nil {
+		return jsError("reverse: no dataset; call geonames.load first")
+	}
+	if len(args) < 2 || args[0].Type() != js.TypeNumber || args[1].Type() != js.TypeNumber {
+		return jsError("reverse: want (lat, lon[, options])")
+	}
+	lat, lon := args[0].Float(), args[1].Float()
+	if err := checkPoint(lat, lon); err != nil {
+		return jsError("reverse: " + err.Error())
+	}
+	opts := offline.Options{Limit: 3}
+	if len(args) > 2 && args[2].Type() == js.TypeObject {
+		o := args[2]
+		if v := o.Get("limit"); v.Type() == js.TypeNumber {
+			opts.Limit = v.Int()
+		}
+		if v := o.Get("country"); v.Type() == js.TypeString {
+			opts.Country = v.String()
+		}
+		if v := o.Get("featureClass"); v.Type() == js.TypeString {
+			opts.FeatureClass = v.String()
+		}
+		if v := o.Get("minPopulation"); v.Type() == js.TypeNumber {
+			opts.MinPopulation = int64(v.Int())
+		}
+	}
+	return jsValue(reverse(index, lat, lon, opts))
+}
+
+// jsValue converts v to a JavaScript object through its JSON.
+func jsValue(v any) any {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return jsError(err.Error())
+	}
+	return js.Global().Get("JSON").Call("parse", string(b))
+}
+
+func jsError(msg string) any {
+	return map[string]any{"error": msg}
+}
@@ -0,0 +1,361 @@
+package main
+
+/*
+	API-key authentication and per-key usage accounting for the HTTP server.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Keys come from the server.auth.keys list in the config YAML and, when
+	server.auth.table is set, from a database table with the columns
+
+	    api_key  TEXT     -- the secret sent by the client
+	    name     TEXT     -- label used in usage reports, unique across
+	                      -- the config and the table
+	    quota    BIGINT   -- requests per quota period (0 or NULL = unlimited)
+	    is_admin BOOLEAN  -- may read every key's usage
+
+	Usage counters are kept in memory and reset when the server restarts.
+
+	Unknown keys are counted per remote IP: an address that sent
+	authFailureBurst of them gets 429 for any key, valid or not, until its
+	bucket refills at authFailureRate per second. This throttles key
+	guessing whether or not the rate limit is enabled.
+*/
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rgglez/geonames-loader/examples/go/internal/sqlbuild"
+	"gorm.io/gorm"
+)
+
+// authFailureRate and authFailureBurst bound the unknown keys an IP may
+// send: 10 at once, then one every 6 seconds.
+const (
+	authFailureRate  = 1.0 / 6
+	authFailureBurst = 10
+)
+
+// ---------------------------------------------------------------------------
+// Configuration
+// ---------------------------------------------------------------------------
+
+type apiKeyConfig struct {
+	Key   string `yaml:"key"`
+	Name  string `yaml:"name"`
+	Quota int64  `yaml:"quota"`
+	Admin bool   `yaml:"admin"`
+}
+
+type authConfig struct {
+	// Required rejects requests without a key. When false, keys are still
+	// checked and accounted if sent, and anonymous requests are allowed.
+	Required    bool           `yaml:"required"`
+	Keys        []apiKeyConfig `yaml:"keys"`
+	Table       string         `yaml:"table"`
+	QuotaPeriod time.Duration  `yaml:"quota_period"`
+}
+
+func (c authConfig) enabled() bool {
+	return c.Required || len(c.Keys) > 0 || c.Table != ""
+}
+
+// ---------------------------------------------------------------------------
+// Key store
+// ---------------------------------------------------------------------------
+
+type apiKey struct {
+	name  string
+	quota int64
+	admin bool
+}
+
+// keyUsage is the accounting record for one key.
+type keyUsage struct {
+	Name        string    `json:"name"`
+	Quota       int64     `json:"quota,omitempty"`
+	Requests    int64     `json:"requests"`
+	Rejected    int64     `json:"rejected"`
+	WindowStart time.Time `json:"window_start"`
+	WindowUsed  int64     `json:"window_used"`
+}
+
+type keyStore struct {
+	mu     sync.Mutex
+	period time.Duration
+	keys   map[string]*apiKey
+	usage  map[string]*keyUsage // by key name
+}
+
+type apiKeyRow struct {
+	APIKey  string `gorm:"column:api_key"`
+	Name    string `gorm:"column:name"`
+	Quota   *int64 `gorm:"column:quota"`
+	IsAdmin *bool  `gorm:"column:is_admin"`
+}
+
+// loadKeyStore builds the key set from the config and, if configured, the
+// keys table.
+func loadKeyStore(ctx context.Context, db *gorm.DB, cfg authConfig) (*keyStore, error) {
+	ks := &keyStore{
+		period: cfg.QuotaPeriod,
+		keys:   make(map[string]*apiKey),
+		usage:  make(map[string]*keyUsage),
+	}
+	if ks.period <= 0 {
+		ks.period = 24 * time.Hour
+	}
+	for _, k := range cfg.Keys {
+		if k.Key == "" {
+			return nil, fmt.Errorf("auth: key %q has an empty secret", k.Name)
+		}
+		if err := ks.add(k.Key, k.Name, k.Quota, k.Admin); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Table != "" {
+		if !sqlbuild.Identifier.MatchString(cfg.Table) {
+			return nil, fmt.Errorf("auth: invalid table name %q", cfg.Table)
+		}
+		var rows []apiKeyRow
+		err := db.WithContext(ctx).Raw(fmt.Sprintf(
+			"SELECT api_key, name, quota, is_admin FROM %s", cfg.Table,
+		)).Scan(&rows).Error
+		if err != nil {
+			return nil, fmt.Errorf("auth: reading %s: %w", cfg.Table, err)
+		}
+		for _, r := range rows {
+			if r.APIKey == "" {
+				continue
+			}
+			var quota int64
+			if r.Quota != nil {
+				quota = *r.Quota
+			}
+			if err := ks.add(r.APIKey, r.Name, quota, r.IsAdmin != nil && *r.IsAdmin); err != nil {
+				return nil, fmt.Errorf("%w (in %s)", err, cfg.Table)
+			}
+		}
+	}
+	return ks, nil
+}
+
+// add adds a key. Names must be unique: usage is accounted by name, so two
+// keys sharing one would share a quota. Secrets must be unique too, or
+// the later key would replace the earlier one.
+func (ks *keyStore) add(secret, name string, quota int64, admin bool) error {
+	if name == "" {
+		name = fmt.Sprintf("key-%d", len(ks.keys)+1)
+	}
+	if _, ok := ks.usage[name]; ok {
+		return fmt.Errorf("auth: duplicate key name %q", name)
+	}
+	if k, ok := ks.keys[secret]; ok {
+		return fmt.Errorf("auth: duplicate key secret (names %q and %q)", k.name, name)
+	}
+	ks.keys[secret] = &apiKey{name: name, quota: quota, admin: admin}
+	ks.usage[name] = &keyUsage{Name: name, Quota: quota}
+	return nil
+}
+
+func (ks *keyStore) lookup(secret string) (*apiKey, bool) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	k, ok := ks.keys[secret]
+	return k, ok
+}
+
+// named returns the key called name, or nil when there is none.
+func (ks *keyStore) named(name string) *apiKey {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	for _, k := range ks.keys {
+		if k.name == name {
+			return k
+		}
+	}
+	return nil
+}
+
+// charge records one request for k at time now. It reports false, with the
+// time left until the quota window resets, when the quota is exhausted.
+func (ks *keyStore) charge(k *apiKey, now time.Time) (bool, time.Duration) {
+	return ks.chargeN(k, 1, now)
+}
+
+// chargeN records n requests for k at time now, or none when fewer than n
+// are left in the quota window; n = 0 only checks that one is left. It
+// reports false, with the time left until the window resets, when the
+// requests do not fit.
+func (ks *keyStore) chargeN(k *apiKey, n int64, now time.Time) (bool, time.Duration) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	u := ks.usage[k.name]
+	if now.Sub(u.WindowStart) >= ks.period {
+		u.WindowStart = now
+		u.WindowUsed = 0
+	}
+	if k.quota > 0 && u.WindowUsed+max(n, 1) > k.quota {
+		u.Rejected++
+		return false, u.WindowStart.Add(ks.period).Sub(now)
+	}
+	u.Requests += n
+	u.WindowUsed += n
+	return true, 0
+}
+
+// refund gives back n requests charged to k by chargeN, when the work
+// they paid for was not done.
+func (ks *keyStore) refund(k *apiKey, n int64) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	u := ks.usage[k.name]
+	u.Requests -= n
+	u.WindowUsed = max(0, u.WindowUsed-n)
+}
+
+// inherit carries the usage counters of old over to the keys of ks with
+// the same names, so that reloading the keys does not reset quotas.
+func (ks *keyStore) inherit(old *keyStore) {
+	old.mu.Lock()
+	defer old.mu.Unlock()
+	for name, u := range old.usage {
+		if nu, ok := ks.usage[name]; ok {
+			quota := nu.Quota
+			*nu = *u
+			nu.Quota = quota
+		}
+	}
+}
+
+// snapshot returns a copy of the usage records, for one key name or, when
+// name is "", for every key.
+func (ks *keyStore) snapshot(name string) []keyUsage {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	var out []keyUsage
+	for n, u := range ks.usage {
+		if name == "" || n == name {
+			out = append(out, *u)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// ---------------------------------------------------------------------------
+// Middleware and handlers
+// ---------------------------------------------------------------------------
+
+type ctxKey int
+
+const apiKeyCtxKey ctxKey = iota
+
+// requestAPIKey returns the authenticated key stored by authenticate, if any.
+func requestAPIKey(r *http.Request) *apiKey {
+	k, _ := r.Context().Value(apiKeyCtxKey).(*apiKey)
+	return k
+}
+
+// authenticate validates the request's API key and stores it in the request
+// context.
+func (s *server) authenticate(next http.Handler) http.Handler {
+	if s.keys == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret := requestToken(r)
+		if secret == "" {
+			if s.cfg.Auth.Required {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="geonames"`)
+				writeError(w, http.StatusUnauthorized, "API key required")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		ip, now := s.remoteIP(r), time.Now()
+		if blocked, wait := s.authFailures.blocked(ip, now); blocked {
+			secs := int(math.Ceil(wait.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(max(secs, 1)))
+			writeError(w, http.StatusTooManyRequests, "too many invalid API keys")
+			return
+		}
+		k, ok := s.keys.lookup(secret)
+		if !ok {
+			s.authFailures.allow(ip, now)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="geonames"`)
+			writeError(w, http.StatusUnauthorized, "invalid API key")
+			return
+		}
+		ctx := context.WithValue(r.Context(), apiKeyCtxKey, k)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// quota counts the request against the authenticated key's quota. It runs
+// after rate limiting so throttled requests are not charged.
+func (s *server) quota(next http.Handler) http.Handler {
+	if s.keys == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if k := requestAPIKey(r); k != nil {
+			if ok, reset := s.keys.charge(k, time.Now()); !ok {
+				secs := int(math.Ceil(reset.Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(max(secs, 1)))
+				writeError(w, http.StatusTooManyRequests, "quota exceeded")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleUsage reports the caller's usage, or every key's usage for admin
+// keys.
+func (s *server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	if s.keys == nil {
+		writeError(w, http.StatusNotFound, "authentication is not enabled")
+		return
+	}
+	k := requestAPIKey(r)
+	if k == nil {
+		writeError(w, http.StatusUnauthorized, "API key required")
+		return
+	}
+	name := k.name
+	if k.admin {
+		name = r.URL.Query().Get("name")
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"quota_period": s.keys.period.String(),
+		"keys":         s.keys.snapshot(name),
+	})
+}
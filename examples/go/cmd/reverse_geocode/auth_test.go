@@ -0,0 +1,240 @@
+package main
+
+/*
+	Tests of the API keys and their quotas.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func testKeyStore(period time.Duration) *keyStore {
+	ks := &keyStore{
+		period: period,
+		keys:   make(map[string]*apiKey),
+		usage:  make(map[string]*keyUsage),
+	}
+	for _, k := range []apiKeyConfig{
+		{Key: "secret-a", Name: "a", Quota: 3},
+		{Key: "secret-b", Name: "b", Admin: true},
+	} {
+		if err := ks.add(k.Key, k.Name, k.Quota, k.Admin); err != nil {
+			panic(err)
+		}
+	}
+	return ks
+}
+
+func TestLoadKeyStoreDuplicates(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "keys.db")),
+		&gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE api_keys (api_key TEXT, name TEXT, quota INTEGER, is_admin BOOLEAN)",
+		"INSERT INTO api_keys VALUES ('secret-c', 'c', 10, 0), ('secret-d', 'd', NULL, NULL)",
+		"CREATE TABLE dup_keys (api_key TEXT, name TEXT, quota INTEGER, is_admin BOOLEAN)",
+		"INSERT INTO dup_keys VALUES ('secret-c', 'c', 10, 0), ('secret-e', 'c', NULL, NULL)",
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		cfg  authConfig
+		// err is the error, or "" for none.
+		err string
+	}{
+		{"config", authConfig{Keys: []apiKeyConfig{
+			{Key: "secret-a", Name: "a"}, {Key: "secret-b", Name: "b"},
+		}}, ""},
+		{"config and table", authConfig{Table: "api_keys", Keys: []apiKeyConfig{
+			{Key: "secret-a", Name: "a"},
+		}}, ""},
+		{"unnamed", authConfig{Keys: []apiKeyConfig{
+			{Key: "secret-a"}, {Key: "secret-b"},
+		}}, ""},
+		{"duplicate in config", authConfig{Keys: []apiKeyConfig{
+			{Key: "secret-a", Name: "a"}, {Key: "secret-b", Name: "a"},
+		}}, `auth: duplicate key name "a"`},
+		{"duplicate in table", authConfig{Table: "dup_keys"}, `auth: duplicate key name "c" (in dup_keys)`},
+		{"config and table clash", authConfig{Table: "api_keys", Keys: []apiKeyConfig{
+			{Key: "secret-x", Name: "c"},
+		}}, `auth: duplicate key name "c" (in api_keys)`},
+		{"generated name", authConfig{Keys: []apiKeyConfig{
+			{Key: "secret-a", Name: "key-2"}, {Key: "secret-b"},
+		}}, `auth: duplicate key name "key-2"`},
+		{"duplicate secret", authConfig{Keys: []apiKeyConfig{
+			{Key: "secret-a", Name: "a"}, {Key: "secret-a", Name: "b"},
+		}}, `auth: duplicate key secret (names "a" and "b")`},
+		{"duplicate secret in table", authConfig{Table: "api_keys", Keys: []apiKeyConfig{
+			{Key: "secret-c", Name: "x"},
+		}}, `auth: duplicate key secret (names "x" and "c") (in api_keys)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadKeyStore(context.Background(), db, tt.cfg)
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tt.err {
+				t.Errorf("loadKeyStore() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestChargeN(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	ks := testKeyStore(time.Hour)
+	a, _ := ks.lookup("secret-a")
+
+	if ok, _ := ks.chargeN(a, 2, now); !ok {
+		t.Fatal("2 of 3 requests refused")
+	}
+	if ok, _ := ks.chargeN(a, 0, now); !ok {
+		t.Fatal("check with one request left refused")
+	}
+	ok, reset := ks.chargeN(a, 2, now.Add(10*time.Minute))
+	if ok {
+		t.Fatal("requests over the quota accepted")
+	}
+	if reset != 50*time.Minute {
+		t.Errorf("reset = %v, want 50m", reset)
+	}
+	if ok, _ := ks.charge(a, now.Add(10*time.Minute)); !ok {
+		t.Fatal("last request of the quota refused")
+	}
+	if ok, _ := ks.chargeN(a, 0, now.Add(10*time.Minute)); ok {
+		t.Fatal("check with the quota spent accepted")
+	}
+
+	// The window resets after the period.
+	if ok, _ := ks.chargeN(a, 3, now.Add(time.Hour)); !ok {
+		t.Fatal("requests refused after the window reset")
+	}
+	u := ks.snapshot("a")[0]
+	if u.Requests != 6 || u.Rejected != 2 || u.WindowUsed != 3 {
+		t.Errorf("usage = %+v, want 6 requests, 2 rejected, 3 in the window", u)
+	}
+
+	// Keys without a quota are never refused.
+	b, _ := ks.lookup("secret-b")
+	if ok, _ := ks.chargeN(b, 1_000_000, now); !ok {
+		t.Error("key without a quota refused")
+	}
+}
+
+func TestInherit(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	old := testKeyStore(time.Hour)
+	a, _ := old.lookup("secret-a")
+	old.chargeN(a, 3, now)
+
+	ks := &keyStore{
+		period: time.Hour,
+		keys:   make(map[string]*apiKey),
+		usage:  make(map[string]*keyUsage),
+	}
+	if err := ks.add("rotated", "a", 5, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.add("secret-c", "c", 1, false); err != nil {
+		t.Fatal(err)
+	}
+	ks.inherit(old)
+
+	u := ks.snapshot("a")[0]
+	if u.WindowUsed != 3 || u.Quota != 5 {
+		t.Errorf("inherited usage = %+v, want 3 used of the new quota 5", u)
+	}
+	a, _ = ks.lookup("rotated")
+	if ok, _ := ks.chargeN(a, 2, now); !ok {
+		t.Error("requests within the new quota refused")
+	}
+	if ok, _ := ks.charge(a, now); ok {
+		t.Error("inherited usage not counted against the new quota")
+	}
+	if u := ks.snapshot("c")[0]; u.WindowUsed != 0 {
+		t.Errorf("new key starts with %d used", u.WindowUsed)
+	}
+}
+
+func TestAuthenticateFailures(t *testing.T) {
+	s := &server{
+		keys:         testKeyStore(time.Hour),
+		authFailures: newRateLimiter(authFailureRate, authFailureBurst),
+	}
+	h := s.authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requestAPIKey(r) == nil && r.Header.Get("X-API-Key") != "" {
+			t.Error("authenticated request without its key")
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	do := func(ip, key string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/usage", nil)
+		r.RemoteAddr = ip + ":1234"
+		if key != "" {
+			r.Header.Set("X-API-Key", key)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := do("192.0.2.1", ""); w.Code != http.StatusNoContent {
+		t.Errorf("anonymous request: %d, want 204", w.Code)
+	}
+	if w := do("192.0.2.1", "secret-a"); w.Code != http.StatusNoContent {
+		t.Errorf("valid key: %d, want 204", w.Code)
+	}
+	for i := range authFailureBurst {
+		if w := do("192.0.2.1", "guess"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("invalid key %d: %d, want 401", i+1, w.Code)
+		}
+	}
+	w := do("192.0.2.1", "guess")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("invalid key over the burst: %d, Retry-After %q; want 429 with Retry-After",
+			w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := do("192.0.2.1", "secret-a"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("valid key from a throttled IP: %d, want 429", w.Code)
+	}
+	if w := do("192.0.2.2", "secret-a"); w.Code != http.StatusNoContent {
+		t.Errorf("valid key from another IP: %d, want 204", w.Code)
+	}
+
+	s.cfg.Auth.Required = true
+	if w := do("192.0.2.2", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous request with keys required: %d, want 401", w.Code)
+	}
+}
@@ -0,0 +1,370 @@
+package main
+
+/*
+	Query benchmark ("bench" subcommand).
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    go run . bench [--config CONFIG] [--url URL]
+	        [--queries 1000] [--concurrency 4] [--warmup 20]
+	        [--strategy auto|postgis,earthdistance,memory,...]
+	        [--lookup reverse|postal|places] [--results 3]
+	        [--weighted] [--country MX] [--seed 1] [--json]
+
+	Generates --queries random points and looks each one up with
+	--concurrency workers, once per strategy listed in --strategy, then
+	reports throughput (QPS) and latency percentiles. Every strategy gets
+	the same points in the same order, and the same --seed gives the same
+	points on every run, so results are comparable across strategies,
+	databases and machines.
+
+	Points are drawn uniformly from the bounding box of the loaded places
+	(of --country, when given). With --weighted they are drawn near
+	populated places instead, in proportion to their population — closer
+	to real traffic, which clusters in cities, and free of the ocean
+	points a bounding box of a coastal country is full of.
+
+	A lookup is what /reverse does, nearest postal codes then nearest
+	places (--lookup reverse), or either query alone. Lookups that find
+	nothing count as answered; other errors are counted and reported.
+	--warmup lookups run first and are not measured, which also loads the
+	in-memory index of the memory strategy.
+*/
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/rand/v2"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rgglez/geonames-loader/examples/go/pkg/geocoder"
+	"gorm.io/gorm"
+)
+
+// benchOptions configures a benchmark run.
+type benchOptions struct {
+	Queries     int
+	Concurrency int
+	Warmup      int
+	Lookup      string
+	Results     int
+	Country     string
+	Weighted    bool
+	Seed        uint64
+}
+
+// benchPoint is a query point.
+type benchPoint struct{ Lat, Lon float64 }
+
+// BenchReport is the outcome of benchmarking one strategy.
+type BenchReport struct {
+	Strategy    string        `json:"strategy"`
+	Description string        `json:"description"`
+	Queries     int           `json:"queries"`
+	Errors      int           `json:"errors"`
+	Concurrency int           `json:"concurrency"`
+	Elapsed     time.Duration `json:"elapsed_ns"`
+	QPS         float64       `json:"qps"`
+	Min         time.Duration `json:"min_ns"`
+	P50         time.Duration `json:"p50_ns"`
+	P95         time.Duration `json:"p95_ns"`
+	P99         time.Duration `json:"p99_ns"`
+	Max         time.Duration `json:"max_ns"`
+	// FirstError is the first error other than "no result", if any.
+	FirstError string `json:"first_error,omitempty"`
+}
+
+// benchPoints draws o.Queries + o.Warmup query points from db.
+func benchPoints(db *gorm.DB, o benchOptions) ([]benchPoint, error) {
+	rng := rand.New(rand.NewPCG(o.Seed, o.Seed^0x9e3779b97f4a7c15))
+	n := o.Queries + o.Warmup
+	points := make([]benchPoint, n)
+
+	where, args := "", []interface{}{}
+	if o.Country != "" {
+		where, args = " AND country = ?", append(args, o.Country)
+	}
+
+	if !o.Weighted {
+		var box struct {
+			MinLat, MaxLat, MinLon, MaxLon *float64
+		}
+		err := db.Raw(`
+			SELECT MIN(latitude) AS min_lat, MAX(latitude) AS max_lat,
+			       MIN(longitude) AS min_lon, MAX(longitude) AS max_lon
+			FROM `+geocoder.TableName(db, "geoname")+`
+			WHERE latitude IS NOT NULL`+where, args...).Scan(&box).Error
+		if err != nil {
+			return nil, err
+		}
+		if box.MinLat == nil {
+			return nil, fmt.Errorf("no places loaded%s", forCountry(o.Country))
+		}
+		for i := range points {
+			points[i] = benchPoint{
+				Lat: *box.MinLat + rng.Float64()*(*box.MaxLat-*box.MinLat),
+				Lon: *box.MinLon + rng.Float64()*(*box.MaxLon-*box.MinLon),
+			}
+		}
+		return points, nil
+	}
+
+	// The most populous places carry nearly all the weight; 100,000 of
+	// them keep the sample small on a planet load.
+	var places []struct {
+		Latitude   float64
+		Longitude  float64
+		Population int64
+	}
+	err := db.Raw(`
+		SELECT latitude, longitude, population
+		FROM `+geocoder.TableName(db, "geoname")+`
+		WHERE fclass = 'P' AND population > 0`+where+`
+		ORDER BY population DESC, geonameid
+		LIMIT 100000`, args...).Scan(&places).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(places) == 0 {
+		return nil, fmt.Errorf("no populated places with a population%s "+
+			"(drop --weighted)", forCountry(o.Country))
+	}
+	cum := make([]float64, len(places))
+	var total float64
+	for i, p := range places {
+		total += float64(p.Population)
+		cum[i] = total
+	}
+	for i := range points {
+		p := places[sort.SearchFloat64s(cum, rng.Float64()*total)]
+		// Jitter up to ~5 km around the place.
+		dLat := (rng.Float64()*2 - 1) * 5 / 111.32
+		dLon := (rng.Float64()*2 - 1) * 5 / 111.32 /
+			math.Max(math.Cos(p.Latitude*math.Pi/180), 0.01)
+		points[i] = benchPoint{
+			Lat: math.Max(-90, math.Min(90, p.Latitude+dLat)),
+			Lon: math.Max(-180, math.Min(180, p.Longitude+dLon)),
+		}
+	}
+	return points, nil
+}
+
+func forCountry(country string) string {
+	if country == "" {
+		return ""
+	}
+	return " for " + country
+}
+
+// benchLookup runs one lookup of kind o.Lookup.
+func benchLookup(geo *geocoder.Geocoder, p benchPoint, o benchOptions) error {
+	opts := geocoder.QueryOptions{Limit: o.Results, Country: o.Country}
+	if o.Lookup != "places" {
+		if _, err := geo.NearestPostal(p.Lat, p.Lon, opts); err != nil && !geocoder.IsNoResult(err) {
+			return err
+		}
+	}
+	if o.Lookup != "postal" {
+		if _, err := geo.NearestPlaces(p.Lat, p.Lon, opts); err != nil && !geocoder.IsNoResult(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// benchStrategy benchmarks strategy on points.
+func benchStrategy(db *gorm.DB, strategy string, points []benchPoint, o benchOptions) BenchReport {
+	geo := geocoder.New(db, geocoder.WithStrategy(strategy))
+	rep := BenchReport{
+		Strategy:    geocoder.ResolveStrategy(db, strategy),
+		Description: geocoder.DescribeStrategy(db, strategy),
+		Queries:     o.Queries,
+		Concurrency: o.Concurrency,
+	}
+	for _, p := range points[:o.Warmup] {
+		_ = benchLookup(geo, p, o)
+	}
+	points = points[o.Warmup:]
+
+	latencies := make([]time.Duration, len(points))
+	next := make(chan int)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := 0; w < o.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				t := time.Now()
+				err := benchLookup(geo, points[i], o)
+				latencies[i] = time.Since(t)
+				if err != nil {
+					mu.Lock()
+					if rep.Errors++; rep.FirstError == "" {
+						rep.FirstError = err.Error()
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range points {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	rep.Elapsed = time.Since(start)
+
+	rep.QPS = float64(len(points)) / rep.Elapsed.Seconds()
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	pct := func(p float64) time.Duration {
+		return latencies[int(math.Ceil(p*float64(len(latencies))))-1]
+	}
+	rep.Min, rep.Max = latencies[0], latencies[len(latencies)-1]
+	rep.P50, rep.P95, rep.P99 = pct(0.50), pct(0.95), pct(0.99)
+	return rep
+}
+
+func printBench(reports []BenchReport) {
+	ms := func(d time.Duration) string {
+		return fmt.Sprintf("%.2f", float64(d)/float64(time.Millisecond))
+	}
+	fmt.Printf("  %-14s %9s %7s %8s %8s %8s %8s %8s\n",
+		"Strategy", "QPS", "Errors", "min ms", "p50 ms", "p95 ms", "p99 ms", "max ms")
+	for _, r := range reports {
+		fmt.Printf("  %-14s %9.1f %7d %8s %8s %8s %8s %8s\n",
+			r.Strategy, r.QPS, r.Errors,
+			ms(r.Min), ms(r.P50), ms(r.P95), ms(r.P99), ms(r.Max))
+	}
+	fmt.Println()
+	for _, r := range reports {
+		fmt.Printf("  %-14s %s, %s wall time\n", r.Strategy, r.Description,
+			r.Elapsed.Round(time.Millisecond))
+		if r.FirstError != "" {
+			fmt.Printf("  %-14s first error: %s\n", "", r.FirstError)
+		}
+	}
+}
+
+func runBench(args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	cfgPath := fs.String(
+		"config", "../../../../config/config.yaml",
+		"Path to config YAML file (default: ../../../../config/config.yaml)",
+	)
+	rawURL := fs.String(
+		"url", "",
+		"Connection URL — overrides --config",
+	)
+	var o benchOptions
+	fs.IntVar(&o.Queries, "queries", 1000, "Measured lookups per strategy")
+	fs.IntVar(&o.Concurrency, "concurrency", 4, "Concurrent lookups")
+	fs.IntVar(&o.Warmup, "warmup", 20, "Unmeasured lookups run first")
+	fs.StringVar(&o.Lookup, "lookup", "reverse",
+		"What a lookup runs: reverse (postal codes and places), postal or places")
+	fs.IntVar(&o.Results, "results", 3, "Results per query")
+	fs.StringVar(&o.Country, "country", "",
+		"Restrict points and queries to this ISO 3166-1 alpha-2 country")
+	fs.BoolVar(&o.Weighted, "weighted", false,
+		"Draw points near populated places, weighted by population")
+	fs.Uint64Var(&o.Seed, "seed", 1, "Random seed; the same seed gives the same points")
+	strategies := fs.String("strategy", geocoder.StrategyAuto,
+		"Comma-separated strategies to compare: auto, postgis, earthdistance, "+
+			"haversine, memory or rtree")
+	asJSON := fs.Bool("json", false, "Print the reports as JSON")
+	_ = fs.Parse(args)
+
+	switch {
+	case o.Queries < 1 || o.Concurrency < 1 || o.Warmup < 0 || o.Results < 1:
+		log.Fatal("bench: --queries, --concurrency and --results must be " +
+			"positive, --warmup not negative")
+	case o.Lookup != "reverse" && o.Lookup != "postal" && o.Lookup != "places":
+		log.Fatalf("bench: unknown --lookup %q (want reverse, postal or places)", o.Lookup)
+	}
+	o.Country = strings.ToUpper(o.Country)
+
+	cfg, err := configFor(*cfgPath, *rawURL)
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	db, err := openQueryDB(cfg, *rawURL)
+	if err != nil {
+		log.Fatalf("database: %v", err)
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.SetMaxOpenConns(o.Concurrency)
+		sqlDB.SetMaxIdleConns(o.Concurrency)
+	}
+	list := splitList(*strategies, false)
+	if len(list) == 0 {
+		list = []string{geocoder.StrategyAuto}
+	}
+	for _, s := range list {
+		if err := geocoder.CheckStrategy(db, s); err != nil {
+			log.Fatalf("bench: --strategy: %v", err)
+		}
+	}
+
+	points, err := benchPoints(db, o)
+	if err != nil {
+		log.Fatalf("bench: %v", err)
+	}
+
+	if !*asJSON {
+		fmt.Println(strings.Repeat("=", 60))
+		fmt.Println("GeoNames query benchmark")
+		fmt.Printf("  Dialect    : %s\n", db.Dialector.Name())
+		fmt.Printf("  Lookups    : %d × %s (+%d warm-up), %d concurrent\n",
+			o.Queries, o.Lookup, o.Warmup, o.Concurrency)
+		points := "uniform over the loaded area"
+		if o.Weighted {
+			points = "weighted by population"
+		}
+		fmt.Printf("  Points     : %s%s, seed %d\n", points, forCountry(o.Country), o.Seed)
+		fmt.Println(strings.Repeat("=", 60))
+		fmt.Println()
+	}
+
+	var reports []BenchReport
+	for _, s := range list {
+		if !*asJSON {
+			fmt.Printf("Running %s ...\n", geocoder.DescribeStrategy(db, s))
+		}
+		reports = append(reports, benchStrategy(db, s, points, o))
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(reports); err != nil {
+			log.Fatalf("bench: %v", err)
+		}
+		return
+	}
+	fmt.Println()
+	printBench(reports)
+}
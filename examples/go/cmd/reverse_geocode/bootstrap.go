@@ -0,0 +1,253 @@
+package main
+
+/*
+	Self-bootstrapping server ("geonames-serve").
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    go build -tags sqlite_math_functions -o geonames-serve .
+	    ./geonames-serve [serve flags]
+	    go run . geonames-serve [serve flags]
+
+	    docker build -t geonames-serve .
+	    docker run -p 8080:8080 -v geonames:/data \
+	        -e GEONAMES_COUNTRIES=MX,GT geonames-serve
+
+	The same binary behaves as "serve" preceded by a one-time database
+	bootstrap when it is invoked as geonames-serve. Everything is configured
+	from the environment:
+
+	  GEONAMES_DB_URL           connection URL (required), e.g.
+	                            sqlite:///data/geonames.db or
+	                            postgres://user:pass@db:5432/geonames
+	  GEONAMES_DB_WAIT          how long to wait for the database to accept
+	                            connections (default: 60s)
+	  GEONAMES_DATA_DIR         download directory (default: data)
+	  GEONAMES_COUNTRIES        ISO 3166-1 alpha-2 codes to load (default: all)
+	  GEONAMES_FEATURE_CLASSES  feature classes to load (default: all)
+	  GEONAMES_ALTERNATE_NAMES  load alternate names (default: true)
+	  GEONAMES_SKIP_INDEXES     skip indexes (default: false; testing only)
+	  GEONAMES_KEEP_DOWNLOADS   keep the downloaded files (default: false)
+	  GEONAMES_STREAM           unzip the archives while downloading them,
+	                            without storing them (default: false)
+	  GEONAMES_WORKERS          files downloaded and loaded at a time
+	                            (default: 4)
+	  GEONAMES_URL_DATA         dump directory mirror
+	  GEONAMES_URL_POSTAL       postal code directory mirror
+	  GEONAMES_LISTEN           listen address (default: :8080)
+	  GEONAMES_STRATEGY         distance strategy (default: auto)
+
+	On start it waits for the database, then looks for a completed load
+	(the meta row the loader writes last). A database without GeoNames
+	tables is bootstrapped with the loader (see loader.go), plus the prefix
+	indexes of the /suggest endpoint; one holding an interrupted load —
+	tables but no meta row — resumes it from the loader's state file in
+	the data directory (see loadstate.go) or, without one, is dropped and
+	loaded again. A loaded database
+	is used as is: to reload, drop its tables or point at a new volume.
+
+	The server then starts with --url, --listen and --strategy taken from
+	the environment, followed by the command-line arguments, which are
+	passed to "serve" unchanged (e.g. --rate 5).
+
+	SQLite files are created, with their directory, on first start.
+	PostgreSQL and MySQL databases must exist; the official images create
+	one from POSTGRES_DB / MYSQL_DATABASE.
+*/
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rgglez/geonames-loader/examples/go/pkg/geocoder"
+	"gorm.io/gorm"
+)
+
+// bootstrapConfig is the environment of geonames-serve.
+type bootstrapConfig struct {
+	DBURL         string
+	DBWait        time.Duration
+	Load          loadOptions
+	KeepDownloads bool
+	Listen        string
+	Strategy      string
+}
+
+// bootstrapConfigFromEnv reads the GEONAMES_* variables.
+func bootstrapConfigFromEnv() (bootstrapConfig, error) {
+	c := bootstrapConfig{
+		DBURL:    os.Getenv("GEONAMES_DB_URL"),
+		DBWait:   time.Minute,
+		Listen:   os.Getenv("GEONAMES_LISTEN"),
+		Strategy: os.Getenv("GEONAMES_STRATEGY"),
+		Load: loadOptions{
+			Download: downloadConfig{
+				DataDir:   os.Getenv("GEONAMES_DATA_DIR"),
+				URLData:   os.Getenv("GEONAMES_URL_DATA"),
+				URLPostal: os.Getenv("GEONAMES_URL_POSTAL"),
+			},
+			AlternateNames: true,
+		},
+	}
+	if c.DBURL == "" {
+		return c, fmt.Errorf("GEONAMES_DB_URL is not set")
+	}
+	if c.Listen == "" {
+		c.Listen = ":8080"
+	}
+
+	var err error
+	if c.Load.Countries, err = parseCodeList(
+		os.Getenv("GEONAMES_COUNTRIES"), 2, ""); err != nil {
+		return c, fmt.Errorf("GEONAMES_COUNTRIES: %w", err)
+	}
+	if c.Load.FeatureClasses, err = parseCodeList(
+		os.Getenv("GEONAMES_FEATURE_CLASSES"), 1, featureClasses); err != nil {
+		return c, fmt.Errorf("GEONAMES_FEATURE_CLASSES: %w", err)
+	}
+	if v := os.Getenv("GEONAMES_WORKERS"); v != "" {
+		if c.Load.Workers, err = strconv.Atoi(v); err != nil {
+			return c, fmt.Errorf("GEONAMES_WORKERS: %w", err)
+		}
+	}
+	if v := os.Getenv("GEONAMES_DB_WAIT"); v != "" {
+		if c.DBWait, err = time.ParseDuration(v); err != nil {
+			return c, fmt.Errorf("GEONAMES_DB_WAIT: %w", err)
+		}
+	}
+	for name, dst := range map[string]*bool{
+		"GEONAMES_ALTERNATE_NAMES": &c.Load.AlternateNames,
+		"GEONAMES_SKIP_INDEXES":    &c.Load.SkipIndexes,
+		"GEONAMES_KEEP_DOWNLOADS":  &c.KeepDownloads,
+		"GEONAMES_STREAM":          &c.Load.Stream,
+	} {
+		if v := os.Getenv(name); v != "" {
+			if *dst, err = strconv.ParseBool(v); err != nil {
+				return c, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	return c, nil
+}
+
+// waitForDB opens the database, retrying until it accepts connections or
+// wait has passed — a database container may still be starting.
+func waitForDB(rawURL string, wait time.Duration) (*gorm.DB, error) {
+	if strings.HasPrefix(rawURL, "sqlite://") {
+		if err := os.MkdirAll(filepath.Dir(sqlitePath(rawURL)), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	deadline := time.Now().Add(wait)
+	for {
+		db, err := openDB(new(Config), rawURL)
+		if err == nil {
+			if err = pingDB(db); err == nil {
+				return db, nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return nil, err
+		}
+		log.Printf("waiting for database: %v", err)
+		time.Sleep(2 * time.Second)
+	}
+}
+
+func pingDB(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Ping()
+}
+
+// bootstrap loads db unless it already holds a completed load.
+func bootstrap(db *gorm.DB, c bootstrapConfig) error {
+	loaded, partial, err := databaseLoaded(db)
+	if err != nil {
+		return err
+	}
+	if loaded {
+		log.Printf("database already loaded, skipping bootstrap")
+		return nil
+	}
+	o := c.Load
+	switch {
+	case partial && o.resumable(db):
+		log.Printf("database holds an incomplete load, resuming")
+	case partial:
+		log.Printf("database holds an incomplete load, reloading")
+		o.Overwrite = true
+	}
+	start := time.Now()
+	log.Printf("bootstrapping database (countries: %s)",
+		orAll(strings.Join(o.Countries, ",")))
+	if err := loadDatabase(db, o); err != nil {
+		return err
+	}
+	if !o.SkipIndexes {
+		if err := geocoder.CreateSuggestIndexes(db); err != nil {
+			return fmt.Errorf("suggest indexes: %w", err)
+		}
+	}
+	if !c.KeepDownloads {
+		if err := removeDownloads(o); err != nil {
+			log.Printf("removing downloads: %v", err)
+		}
+	}
+	log.Printf("bootstrap complete in %s", time.Since(start).Round(time.Second))
+	return nil
+}
+
+// orAll returns s, or "all" when s is empty.
+func orAll(s string) string {
+	if s == "" {
+		return "all"
+	}
+	return s
+}
+
+func runGeonamesServe(args []string) {
+	c, err := bootstrapConfigFromEnv()
+	if err != nil {
+		log.Fatalf("geonames-serve: %v", err)
+	}
+	db, err := waitForDB(c.DBURL, c.DBWait)
+	if err != nil {
+		log.Fatalf("database: %v", err)
+	}
+	if err := bootstrap(db, c); err != nil {
+		log.Fatalf("bootstrap: %v", err)
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+
+	serveArgs := []string{"--url", c.DBURL, "--listen", c.Listen}
+	if c.Strategy != "" {
+		serveArgs = append(serveArgs, "--strategy", c.Strategy)
+	}
+	runServe(append(serveArgs, args...))
+}
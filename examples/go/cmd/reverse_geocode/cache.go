@@ -0,0 +1,126 @@
+package main
+
+/*
+	Persistent reverse-lookup cache ("cache" subcommand).
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    go run . serve --cache geocache.db
+	    go run . enrich --table customers --cache geocache.db
+	    go run . cache stats --cache geocache.db [--json]
+	    go run . cache clear --cache geocache.db
+
+	The library side, and how it works, is in pkg/geocoder/cache.go.
+
+	Lookups are cached in the scope of cacheScope: the connection settings
+	without the password, and the table names. A file shared by databases,
+	or by one server before and after a reload onto another, keeps their
+	entries apart.
+*/
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"maps"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/rgglez/geonames-loader/examples/go/pkg/geocoder"
+)
+
+// cacheScope returns the cache scope of the database of cfg, or of rawURL
+// when given: a hash of its settings except the password, and of its table
+// names.
+func cacheScope(cfg dbConfig, rawURL string) string {
+	if rawURL != "" {
+		cfg = dbConfig{URL: rawURL, Tables: cfg.Tables}
+	}
+	if u, err := url.Parse(cfg.URL); err == nil && u.User != nil {
+		u.User = url.User(u.User.Username())
+		cfg.URL = u.String()
+	}
+	cfg.Password = ""
+	sum := sha256.Sum256(fmt.Appendf(nil, "%+v", cfg))
+	return hex.EncodeToString(sum[:8])
+}
+
+func runCache(args []string) {
+	usage := "usage: cache stats|clear --cache FILE [--json]"
+	if len(args) == 0 || args[0] != "stats" && args[0] != "clear" {
+		log.Fatal(usage)
+	}
+	fs := flag.NewFlagSet("cache "+args[0], flag.ExitOnError)
+	path := fs.String("cache", "", "Cache file (required)")
+	asJSON := fs.Bool("json", false, "Print the statistics as JSON")
+	_ = fs.Parse(args[1:])
+	if *path == "" {
+		fmt.Fprintln(os.Stderr, "ERROR: --cache is required.")
+		fs.Usage()
+		os.Exit(1)
+	}
+	if _, err := os.Stat(*path); err != nil {
+		log.Fatalf("cache: %v", err)
+	}
+
+	c, err := geocoder.OpenReverseCache(*path)
+	if err != nil {
+		log.Fatalf("cache: %v", err)
+	}
+	defer c.Close()
+
+	if args[0] == "clear" {
+		if err := c.Clear(); err != nil {
+			log.Fatalf("cache: %v", err)
+		}
+		fmt.Printf("Cleared %s.\n", *path)
+		return
+	}
+
+	st, err := c.Stats()
+	if err != nil {
+		log.Fatalf("cache: %v", err)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(st); err != nil {
+			log.Fatalf("cache: %v", err)
+		}
+		return
+	}
+	fmt.Println(strings.Repeat("=", 60))
+	fmt.Println("GeoNames reverse cache")
+	fmt.Println(strings.Repeat("=", 60))
+	fmt.Printf("  File       : %s (%.1f MB)\n", st.Path, float64(st.SizeBytes)/(1<<20))
+	if st.Created != "" {
+		fmt.Printf("  Created    : %s\n", st.Created)
+	}
+	for _, name := range slices.Sorted(maps.Keys(st.Entries)) {
+		fmt.Printf("  %-11s: %d entries\n", name, st.Entries[name])
+	}
+	fmt.Printf("  Lookups    : %d (%d hits, %d misses)\n",
+		st.Hits+st.Misses, st.Hits, st.Misses)
+	fmt.Printf("  Hit ratio  : %.1f%%\n", 100*st.HitRatio)
+}
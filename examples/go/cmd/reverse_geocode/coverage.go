@@ -0,0 +1,267 @@
+package main
+
+/*
+	Country coverage report ("coverage" subcommand).
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    go run . coverage [--config CONFIG] [--url URL]
+	        [--country MX,US] [--json]
+
+	Reports, per country of countryinfo, the number of geoname and
+	postalcodes rows and the newest geoname modification date (moddate),
+	followed by the load date recorded in the meta table. Postal codes
+	carry no timestamp of their own.
+
+	Countries with geoname rows but no postal codes are listed as
+	warnings, except those without a postal code system (empty "postal"
+	format in countryinfo). Countries with no rows at all were most likely
+	left out of the load and are counted separately.
+
+	The server serves the countries with places and with postal codes at
+	GET /coverage, from the geocoder's cached coverage (see
+	pkg/geocoder/coverage.go) rather than from these counts.
+*/
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/rgglez/geonames-loader/examples/go/pkg/geocoder"
+	"gorm.io/gorm"
+)
+
+// CountryCoverage is one line of the coverage report.
+type CountryCoverage struct {
+	Country string `json:"country"`
+	Name    string `json:"name"`
+	Places  int64  `json:"places"`
+	Postal  int64  `json:"postal_codes"`
+	// LastModified is the newest geoname moddate (YYYY-MM-DD), if any.
+	LastModified string `json:"last_modified,omitempty"`
+	// HasPostalSystem is false when countryinfo gives no postal format.
+	HasPostalSystem bool `json:"has_postal_system"`
+}
+
+// CoverageReport describes what a database contains.
+type CoverageReport struct {
+	Countries []CountryCoverage `json:"countries"`
+	// DataVersion and LoadedAt come from the meta table.
+	DataVersion string `json:"data_version,omitempty"`
+	LoadedAt    string `json:"loaded_at,omitempty"`
+	// MissingPostal lists countries with places but no postal codes even
+	// though they have a postal system.
+	MissingPostal []string `json:"missing_postal"`
+	// NotLoaded lists countries of countryinfo with no rows at all.
+	NotLoaded []string `json:"not_loaded"`
+}
+
+// coverageReport builds the report, restricted to countries when given.
+func coverageReport(db *gorm.DB, countries []string) (*CoverageReport, error) {
+	var info []struct {
+		Code   string `gorm:"column:iso_alpha2"`
+		Name   string `gorm:"column:country"`
+		Postal string `gorm:"column:postal"`
+	}
+	if err := db.Raw(
+		"SELECT iso_alpha2, country, postal FROM " + geocoder.TableName(db, "countryinfo"),
+	).Scan(&info).Error; err != nil {
+		return nil, err
+	}
+
+	var places []struct {
+		Country string `gorm:"column:country"`
+		N       int64  `gorm:"column:n"`
+		Last    string `gorm:"column:last_modified"`
+	}
+	if err := db.Raw(`
+		SELECT country, count(*) AS n,
+		       CAST(MAX(moddate) AS CHAR(10)) AS last_modified
+		FROM ` + geocoder.TableName(db, "geoname") + `
+		GROUP BY country`).Scan(&places).Error; err != nil {
+		return nil, err
+	}
+
+	var postal []struct {
+		Country string `gorm:"column:countrycode"`
+		N       int64  `gorm:"column:n"`
+	}
+	if err := db.Raw(`
+		SELECT countrycode, count(*) AS n
+		FROM ` + geocoder.TableName(db, "postalcodes") + `
+		GROUP BY countrycode`).Scan(&postal).Error; err != nil {
+		return nil, err
+	}
+
+	byCode := map[string]*CountryCoverage{}
+	get := func(code string) *CountryCoverage {
+		c, ok := byCode[code]
+		if !ok {
+			c = &CountryCoverage{Country: code}
+			byCode[code] = c
+		}
+		return c
+	}
+	for _, r := range info {
+		c := get(r.Code)
+		c.Name, c.HasPostalSystem = r.Name, r.Postal != ""
+	}
+	for _, r := range places {
+		c := get(r.Country)
+		c.Places, c.LastModified = r.N, r.Last
+	}
+	for _, r := range postal {
+		get(r.Country).Postal = r.N
+	}
+
+	want := map[string]bool{}
+	for _, c := range countries {
+		want[c] = true
+	}
+	rep := &CoverageReport{
+		Countries:     []CountryCoverage{},
+		MissingPostal: []string{},
+		NotLoaded:     []string{},
+	}
+	for code, c := range byCode {
+		if code == "" || len(want) > 0 && !want[code] {
+			continue
+		}
+		rep.Countries = append(rep.Countries, *c)
+	}
+	sort.Slice(rep.Countries, func(i, j int) bool {
+		return rep.Countries[i].Country < rep.Countries[j].Country
+	})
+	for _, c := range rep.Countries {
+		switch {
+		case c.Places == 0 && c.Postal == 0:
+			rep.NotLoaded = append(rep.NotLoaded, c.Country)
+		case c.Postal == 0 && c.HasPostalSystem:
+			rep.MissingPostal = append(rep.MissingPostal, c.Country)
+		}
+	}
+
+	// The meta table is optional: older loads did not write it.
+	var meta []struct {
+		Version string `gorm:"column:data_version"`
+		Date    string `gorm:"column:date_accessed"`
+	}
+	if db.Raw(`
+		SELECT data_version, CAST(date_accessed AS CHAR(19)) AS date_accessed
+		FROM `+geocoder.TableName(db, "meta")).Scan(&meta).Error == nil && len(meta) > 0 {
+		rep.DataVersion, rep.LoadedAt = meta[len(meta)-1].Version, meta[len(meta)-1].Date
+	}
+	return rep, nil
+}
+
+func printCoverage(rep *CoverageReport) {
+	fmt.Printf("  %-2s  %-28s %10s %10s  %s\n",
+		"CC", "Country", "Places", "Postal", "Modified")
+	var places, postal int64
+	for _, c := range rep.Countries {
+		if c.Places == 0 && c.Postal == 0 {
+			continue
+		}
+		name := c.Name
+		if len([]rune(name)) > 28 {
+			name = string([]rune(name)[:27]) + "…"
+		}
+		fmt.Printf("  %-2s  %-28s %10d %10d  %s\n",
+			c.Country, name, c.Places, c.Postal, c.LastModified)
+		places += c.Places
+		postal += c.Postal
+	}
+	fmt.Printf("  %-2s  %-28s %10d %10d\n", "", "Total", places, postal)
+	fmt.Println()
+
+	if rep.LoadedAt != "" || rep.DataVersion != "" {
+		fmt.Printf("Loaded: %s (data version %q)\n\n", rep.LoadedAt, rep.DataVersion)
+	}
+	if len(rep.MissingPostal) > 0 {
+		fmt.Printf("WARNING: %d country(ies) have places but no postal codes:\n",
+			len(rep.MissingPostal))
+		fmt.Printf("  %s\n\n", strings.Join(rep.MissingPostal, ", "))
+	}
+	if len(rep.NotLoaded) > 0 {
+		fmt.Printf("%d country(ies) of countryinfo have no rows at all.\n",
+			len(rep.NotLoaded))
+	}
+}
+
+func runCoverage(args []string) {
+	fs := flag.NewFlagSet("coverage", flag.ExitOnError)
+	cfgPath := fs.String(
+		"config", "../../../../config/config.yaml",
+		"Path to config YAML file (default: ../../../../config/config.yaml)",
+	)
+	rawURL := fs.String(
+		"url", "",
+		"Connection URL — overrides --config",
+	)
+	country := fs.String("country", "",
+		"Comma-separated ISO 3166-1 alpha-2 codes to report (default: all)")
+	asJSON := fs.Bool("json", false, "Print the report as JSON")
+	_ = fs.Parse(args)
+
+	cfg, err := configFor(*cfgPath, *rawURL)
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	db, err := openQueryDB(cfg, *rawURL)
+	if err != nil {
+		log.Fatalf("database: %v", err)
+	}
+
+	rep, err := coverageReport(db, splitList(*country, true))
+	if err != nil {
+		log.Fatalf("coverage: %v", err)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(rep); err != nil {
+			log.Fatalf("coverage: %v", err)
+		}
+		return
+	}
+
+	fmt.Println(strings.Repeat("=", 60))
+	fmt.Println("GeoNames coverage report")
+	fmt.Println(strings.Repeat("=", 60))
+	fmt.Println()
+	printCoverage(rep)
+}
+
+// handleCoverage serves GET /coverage: the countries with places and with
+// postal codes, as the geocoder sees them (see pkg/geocoder/coverage.go).
+func (s *server) handleCoverage(w http.ResponseWriter, r *http.Request) {
+	cov, err := s.geo.WithContext(r.Context()).Coverage()
+	if err != nil {
+		log.Printf("coverage: %v", err)
+		s.writeQueryError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, cov)
+}
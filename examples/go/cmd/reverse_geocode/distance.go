@@ -0,0 +1,88 @@
+package main
+
+/*
+	Distance units and ellipsoidal (geodesic) distance recomputation.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	The library side, and how it works, is in pkg/geocoder/distance.go.
+*/
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/rgglez/geonames-loader/examples/go/pkg/geocoder"
+)
+
+// distanceUnits maps a unit name to its size in kilometres.
+var distanceUnits = map[string]float64{
+	"km":  1,
+	"mi":  1.609344,
+	"nmi": 1.852,
+}
+
+// checkUnits validates a unit name.
+func checkUnits(units string) error {
+	if _, ok := distanceUnits[units]; !ok {
+		return fmt.Errorf("unknown unit %q (want km, mi or nmi)", units)
+	}
+	return nil
+}
+
+// fromKm converts km to units, which must be valid.
+func fromKm(km float64, units string) float64 {
+	return km / distanceUnits[units]
+}
+
+// geodesicPostal recomputes DistanceKm of rows from (lat, lon) with
+// VincentyKm and re-sorts them by distance.
+func geodesicPostal(lat, lon float64, rows []geocoder.PostalCode) {
+	for i := range rows {
+		if km, ok := geocoder.VincentyKm(lat, lon, rows[i].Latitude, rows[i].Longitude); ok {
+			rows[i].DistanceKm = km
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].DistanceKm < rows[j].DistanceKm
+	})
+}
+
+// geodesicGeoname is geodesicPostal for geoname rows.
+func geodesicGeoname(lat, lon float64, rows []geocoder.Place) {
+	for i := range rows {
+		if km, ok := geocoder.VincentyKm(lat, lon, rows[i].Latitude, rows[i].Longitude); ok {
+			rows[i].DistanceKm = km
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].DistanceKm < rows[j].DistanceKm
+	})
+}
+
+// geodesicPOI is geodesicPostal for POI rows.
+func geodesicPOI(lat, lon float64, rows []geocoder.POI) {
+	for i := range rows {
+		if km, ok := geocoder.VincentyKm(lat, lon, rows[i].Latitude, rows[i].Longitude); ok {
+			rows[i].DistanceKm = km
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].DistanceKm < rows[j].DistanceKm
+	})
+}
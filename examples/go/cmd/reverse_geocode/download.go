@@ -0,0 +1,580 @@
+package main
+
+/*
+	Downloads of the loader: resumable transfers, checksums and streaming
+	unzip.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    go run . load --countries MX --stream
+	    docker run -e GEONAMES_STREAM=true ... geonames-serve
+
+	Every transfer survives dropped connections: when the body breaks off,
+	it is requested again with a Range header from the last byte received
+	(up to downloadRetries times), guarded by If-Range so that a file
+	replaced on the server in the meantime is not spliced. A server that
+	ignores the range sends the whole file again and the bytes already
+	received are skipped. A plain download interrupted for good leaves
+	NAME.part and NAME.part.stamp (the size and Last-Modified of the
+	remote file); the next run resumes it if the remote file is still the
+	same, and starts over otherwise.
+
+	Each transfer is checked before it is used: its length must be the one
+	the server announced, and when an MD5 is published — a Content-MD5
+	header, or a NAME.md5 file next to it in md5sum format — the digest
+	must match. geonames.org publishes neither today, so the size and the
+	CRC-32 that every zip member carries are what catch a corrupted dump
+	there; mirrors that add .md5 files get the full check.
+
+	With --stream (GEONAMES_STREAM) the zip archives are not written to
+	disk: the member is inflated from the response as it arrives and only
+	the text file is stored, so allCountries needs its 1.5 GB once instead
+	of twice. The archive is read to its end so that its size and MD5 can
+	still be verified. Since there is no archive left to compare, the size
+	and Last-Modified of the one the text file came from are kept in
+	NAME.txt.stamp and decide whether the next run downloads it again. A
+	streamed file interrupted for good starts over on the next run.
+*/
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// errNotFound is returned by fetch when the server has no such file.
+var errNotFound = errors.New("not found")
+
+// downloadRetries is how many times one transfer is resumed after the
+// connection breaks.
+const downloadRetries = 5
+
+// ---------------------------------------------------------------------------
+// Remote files
+// ---------------------------------------------------------------------------
+
+// remoteFile describes a file on the server.
+type remoteFile struct {
+	URL  string
+	Size int64 // -1 when the server does not say
+	// Validator is the Last-Modified date, or else the ETag, used for
+	// If-Range and in stamps.
+	Validator string
+	// MD5 is the published digest, or nil.
+	MD5 []byte
+}
+
+// stat asks the server for the size, date and MD5 of url.
+func stat(url string) (*remoteFile, error) {
+	head, err := http.Head(url)
+	if err != nil {
+		return nil, err
+	}
+	head.Body.Close()
+	if head.StatusCode == http.StatusNotFound {
+		return nil, errNotFound
+	}
+	rf := &remoteFile{URL: url, Size: -1}
+	if head.StatusCode == http.StatusOK {
+		rf.Size = head.ContentLength
+		rf.Validator = validator(head.Header)
+		if v := head.Header.Get("Content-MD5"); v != "" {
+			rf.MD5, _ = base64.StdEncoding.DecodeString(v)
+		}
+	}
+	if rf.MD5 == nil {
+		if rf.MD5, err = publishedMD5(url); err != nil {
+			return nil, err
+		}
+	}
+	return rf, nil
+}
+
+func validator(h http.Header) string {
+	if v := h.Get("Last-Modified"); v != "" {
+		return v
+	}
+	return h.Get("ETag")
+}
+
+// publishedMD5 reads url.md5, "HEXDIGEST  name" as md5sum writes it. A
+// missing or malformed file means no digest.
+func publishedMD5(url string) ([]byte, error) {
+	resp, err := http.Get(url + ".md5")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil
+	}
+	line, _ := bufio.NewReader(io.LimitReader(resp.Body, 1024)).ReadString('\n')
+	f := strings.Fields(line)
+	if len(f) == 0 {
+		return nil, nil
+	}
+	sum, err := hex.DecodeString(f[0])
+	if err != nil || len(sum) != md5.Size {
+		return nil, nil
+	}
+	return sum, nil
+}
+
+// Stamps record the remote file a local one was made from.
+
+func stampPath(path string) string { return path + ".stamp" }
+
+func writeStamp(path string, rf *remoteFile) error {
+	return os.WriteFile(stampPath(path),
+		[]byte(fmt.Sprintf("%d %s\n", rf.Size, rf.Validator)), 0o644)
+}
+
+// stampMatches reports whether path was made from the current rf. Files
+// of unknown size or date never match.
+func stampMatches(path string, rf *remoteFile) bool {
+	if rf.Size < 0 || rf.Validator == "" {
+		return false
+	}
+	b, err := os.ReadFile(stampPath(path))
+	return err == nil &&
+		strings.TrimSpace(string(b)) == fmt.Sprintf("%d %s", rf.Size, rf.Validator)
+}
+
+// ---------------------------------------------------------------------------
+// Transfers
+// ---------------------------------------------------------------------------
+
+// transfer is the body of a GET of a remoteFile. It reconnects with a
+// Range request when the connection breaks and, at the end of the body,
+// checks the size and MD5 of everything read.
+type transfer struct {
+	rf      *remoteFile
+	body    io.ReadCloser
+	offset  int64
+	md5     hash.Hash
+	retries int
+	task    *progressTask
+}
+
+// openTransfer starts reading rf after the bytes of prefix, which hold
+// its beginning (from an earlier, interrupted download).
+func openTransfer(rf *remoteFile, prefix io.Reader, task *progressTask) (*transfer, error) {
+	t := &transfer{rf: rf, md5: md5.New(), task: task}
+	if prefix != nil {
+		n, err := io.Copy(t.md5, prefix)
+		if err != nil {
+			return nil, err
+		}
+		t.offset = n
+		task.add(n)
+	}
+	return t, t.connect()
+}
+
+// connect requests the file from t.offset.
+func (t *transfer) connect() error {
+	req, err := http.NewRequest(http.MethodGet, t.rf.URL, nil)
+	if err != nil {
+		return err
+	}
+	if t.offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", t.offset))
+		if t.rf.Validator != "" {
+			req.Header.Set("If-Range", t.rf.Validator)
+		}
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return errNotFound
+	case resp.StatusCode == http.StatusPartialContent && t.offset > 0:
+		var start int64 = -1
+		fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-", &start)
+		if start != t.offset {
+			resp.Body.Close()
+			return fmt.Errorf("GET %s: asked for byte %d, got %q", t.rf.URL, t.offset,
+				resp.Header.Get("Content-Range"))
+		}
+	case resp.StatusCode == http.StatusOK:
+		if v := validator(resp.Header); t.offset > 0 && v != "" &&
+			t.rf.Validator != "" && v != t.rf.Validator {
+			resp.Body.Close()
+			return fmt.Errorf("GET %s: file changed on the server during the download", t.rf.URL)
+		}
+		// No range support: skip what we already have.
+		if _, err := io.CopyN(io.Discard, resp.Body, t.offset); err != nil {
+			resp.Body.Close()
+			return fmt.Errorf("GET %s: %w", t.rf.URL, err)
+		}
+	default:
+		resp.Body.Close()
+		return fmt.Errorf("GET %s: %s", t.rf.URL, resp.Status)
+	}
+	t.body = resp.Body
+	return nil
+}
+
+func (t *transfer) Read(p []byte) (int, error) {
+	for {
+		n, err := t.body.Read(p)
+		t.md5.Write(p[:n])
+		t.offset += int64(n)
+		t.task.add(int64(n))
+		if err == io.EOF && t.rf.Size >= 0 && t.offset < t.rf.Size {
+			err = io.ErrUnexpectedEOF
+		}
+		switch {
+		case err == nil:
+			return n, nil
+		case err == io.EOF:
+			return n, t.verify()
+		case t.retries >= downloadRetries:
+			return n, fmt.Errorf("GET %s: %w (gave up after %d retries)", t.rf.URL, err, t.retries)
+		}
+		t.body.Close()
+		t.retries++
+		t.task.Printf("  %s: %v at byte %d, resuming (%d/%d) ...\n",
+			filepath.Base(t.rf.URL), err, t.offset, t.retries, downloadRetries)
+		time.Sleep(time.Duration(t.retries) * time.Second)
+		if err := t.connect(); err != nil {
+			return n, err
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// verify checks the file read against the size and MD5 of the server.
+func (t *transfer) verify() error {
+	if t.rf.Size >= 0 && t.offset != t.rf.Size {
+		return fmt.Errorf("GET %s: got %d bytes, expected %d", t.rf.URL, t.offset, t.rf.Size)
+	}
+	if sum := t.md5.Sum(nil); t.rf.MD5 != nil && !bytes.Equal(sum, t.rf.MD5) {
+		return fmt.Errorf("GET %s: MD5 %x, expected %x", t.rf.URL, sum, t.rf.MD5)
+	}
+	return io.EOF
+}
+
+func (t *transfer) Close() error { return t.body.Close() }
+
+// fetch downloads rf to dest unless dest already has the remote size,
+// counting the bytes on task. It reports whether dest was (re)written.
+func fetch(rf *remoteFile, dest string, task *progressTask) (bool, error) {
+	if st, err := os.Stat(dest); err == nil && rf.Size > 0 && st.Size() == rf.Size {
+		task.Printf("  %s: already up to date, skipping.\n", filepath.Base(dest))
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return false, err
+	}
+
+	tmp := dest + ".part"
+	f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	var prefix io.Reader
+	if st, err := f.Stat(); err == nil && st.Size() > 0 && stampMatches(tmp, rf) {
+		task.Printf("  Resuming %s at byte %d ...\n", rf.URL, st.Size())
+		prefix = f
+	} else {
+		task.Printf("  Downloading %s ...\n", rf.URL)
+		if err := f.Truncate(0); err != nil {
+			return false, err
+		}
+		if err := writeStamp(tmp, rf); err != nil {
+			return false, err
+		}
+	}
+
+	t, err := openTransfer(rf, prefix, task)
+	if err != nil {
+		return false, err
+	}
+	defer t.Close()
+	if _, err := io.Copy(f, t); err != nil {
+		return false, err
+	}
+	if err := f.Close(); err != nil {
+		return false, err
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		return false, err
+	}
+	return true, os.Remove(stampPath(tmp))
+}
+
+// ---------------------------------------------------------------------------
+// Zip archives
+// ---------------------------------------------------------------------------
+
+// extract writes the archive member name of zipPath to dest.
+func extract(zipPath, name, dest string, task *progressTask) error {
+	task.Printf("  Extracting %s from %s ...\n", name, filepath.Base(zipPath))
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	src, err := zr.Open(name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", zipPath, err)
+	}
+	defer src.Close()
+
+	tmp := dest + ".part"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dest)
+}
+
+// streamZip downloads the zip archive rf and writes its member name to
+// dest without storing the archive.
+func streamZip(rf *remoteFile, name, dest string, task *progressTask) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+	task.Printf("  Streaming %s from %s ...\n", name, rf.URL)
+	t, err := openTransfer(rf, nil, task)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+	r := bufio.NewReaderSize(t, 1<<20)
+
+	tmp := dest + ".part"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := unzipMember(r, name, out); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	// Read the rest of the archive for the size and MD5 checks.
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		return err
+	}
+	return writeStamp(dest, rf)
+}
+
+// Zip record signatures.
+const (
+	zipLocalHeader    = 0x04034b50
+	zipDataDescriptor = 0x08074b50
+	zipCentralDir     = 0x02014b50
+)
+
+// unzipMember copies the member name of the zip stream r to w, checking
+// its CRC-32 and size. It reads the local records from the start of the
+// archive, so the central directory at its end is never needed, and
+// stops right after the member.
+func unzipMember(r *bufio.Reader, name string, w io.Writer) error {
+	le := binary.LittleEndian
+	for {
+		var sig [4]byte
+		if _, err := io.ReadFull(r, sig[:]); err != nil {
+			return fmt.Errorf("zip: %w", err)
+		}
+		switch le.Uint32(sig[:]) {
+		case zipLocalHeader:
+		case zipCentralDir:
+			return fmt.Errorf("zip: no member %s", name)
+		default:
+			return fmt.Errorf("zip: bad record signature %x", sig)
+		}
+
+		var h [26]byte
+		if _, err := io.ReadFull(r, h[:]); err != nil {
+			return fmt.Errorf("zip: %w", err)
+		}
+		flags, method := le.Uint16(h[2:]), le.Uint16(h[4:])
+		crc := le.Uint32(h[10:])
+		csize, usize := int64(le.Uint32(h[14:])), int64(le.Uint32(h[18:]))
+		meta := make([]byte, int(le.Uint16(h[22:]))+int(le.Uint16(h[24:])))
+		if _, err := io.ReadFull(r, meta); err != nil {
+			return fmt.Errorf("zip: %w", err)
+		}
+		member := string(meta[:le.Uint16(h[22:])])
+		zip64 := false
+		for extra := meta[len(member):]; len(extra) >= 4; {
+			id, size := le.Uint16(extra), int(le.Uint16(extra[2:]))
+			if size > len(extra)-4 {
+				break
+			}
+			if field := extra[4 : 4+size]; id == 0x0001 {
+				zip64 = true
+				if usize == 0xffffffff && len(field) >= 8 {
+					usize, field = int64(le.Uint64(field)), field[8:]
+				}
+				if csize == 0xffffffff && len(field) >= 8 {
+					csize = int64(le.Uint64(field))
+				}
+			}
+			extra = extra[4+size:]
+		}
+		described := flags&0x8 != 0
+
+		var data io.Reader
+		switch method {
+		case 0:
+			if described {
+				return fmt.Errorf("zip: %s: stored member of unknown size", member)
+			}
+			data = io.LimitReader(r, csize)
+		case 8:
+			// r is an io.ByteReader, so flate stops at the end of the
+			// member instead of reading ahead.
+			data = flate.NewReader(r)
+		default:
+			return fmt.Errorf("zip: %s: unsupported compression method %d", member, method)
+		}
+
+		dst, sum := io.Discard, crc32.NewIEEE()
+		if member == name {
+			dst = io.MultiWriter(w, sum)
+		}
+		n, err := io.Copy(dst, data)
+		if err != nil {
+			return fmt.Errorf("zip: %s: %w", member, err)
+		}
+		if described {
+			if crc, usize, err = dataDescriptor(r, zip64); err != nil {
+				return fmt.Errorf("zip: %s: %w", member, err)
+			}
+		}
+		if member != name {
+			continue
+		}
+		if n != usize {
+			return fmt.Errorf("zip: %s: %d bytes, expected %d", member, n, usize)
+		}
+		if sum.Sum32() != crc {
+			return fmt.Errorf("zip: %s: checksum error", member)
+		}
+		return nil
+	}
+}
+
+// dataDescriptor reads the CRC-32 and uncompressed size following a
+// member written as a stream.
+func dataDescriptor(r *bufio.Reader, zip64 bool) (uint32, int64, error) {
+	le := binary.LittleEndian
+	if sig, err := r.Peek(4); err == nil && le.Uint32(sig) == zipDataDescriptor {
+		r.Discard(4)
+	}
+	size := 12
+	if zip64 {
+		size = 20
+	}
+	b := make([]byte, size)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return 0, 0, err
+	}
+	if zip64 {
+		return le.Uint32(b), int64(le.Uint64(b[12:])), nil
+	}
+	return le.Uint32(b), int64(le.Uint32(b[8:])), nil
+}
+
+// ---------------------------------------------------------------------------
+// Data files
+// ---------------------------------------------------------------------------
+
+// download fetches the data file f, unpacking it when it is a zip: from
+// the archive kept next to it or, with stream, from the response. It
+// returns errNotFound for a missing optional file. Transfers are shown
+// on the board p, which may be nil.
+func download(f dataFile, stream bool, p *progress) error {
+	rf, err := stat(f.URL)
+	if err != nil {
+		return err
+	}
+	task := p.start(f.Table+" "+filepath.Base(f.URL), rf.Size)
+	defer task.finish()
+	if f.Member == "" {
+		_, err := fetch(rf, f.Path, task)
+		return err
+	}
+	if stream {
+		if _, err := os.Stat(f.Path); err == nil && stampMatches(f.Path, rf) {
+			task.Printf("  %s: already up to date, skipping.\n", filepath.Base(f.Path))
+			return nil
+		}
+		return streamZip(rf, f.Member, f.Path, task)
+	}
+	// Keep each archive next to its text file; per-country archives of
+	// different directories share names.
+	zipPath := strings.TrimSuffix(f.Path, ".txt") + ".zip"
+	changed, err := fetch(rf, zipPath, task)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(f.Path); changed || err != nil {
+		return extract(zipPath, f.Member, f.Path, task)
+	}
+	return nil
+}
+
+// downloadedFiles lists the files download may leave for path: the
+// file itself, its archive, stamps and partial downloads.
+func downloadedFiles(path string) []string {
+	zipPath := strings.TrimSuffix(path, ".txt") + ".zip"
+	out := []string{path, zipPath, stampPath(path)}
+	for _, p := range []string{path, zipPath} {
+		out = append(out, p+".part", stampPath(p+".part"))
+	}
+	return out
+}
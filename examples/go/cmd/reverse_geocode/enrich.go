@@ -0,0 +1,358 @@
+package main
+
+/*
+	Bulk enrichment of an existing table ("enrich" subcommand).
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    go run . enrich --table customers [--id-column id]
+	        [--lat-column latitude] [--lon-column longitude]
+	        [--target-url URL] [--prefix geo_] [--batch 500] [--workers 4]
+	        [--restart] [--cache FILE]
+
+	For every row of --table the nearest populated place is looked up and
+	written back into these columns, which are added if missing:
+
+	    <prefix>place        nearest populated place (feature class P)
+	    <prefix>country      ISO country code of that place
+	    <prefix>admin1       first-level division name
+	    <prefix>postalcode   nearest postal code to the row's coordinates
+	    <prefix>distance_km  distance from the row to the place
+
+	The table may live in the GeoNames database (the default) or in another
+	one given by --target-url. Rows are processed in --id-column order in
+	batches of --batch, with --workers lookups running concurrently.
+
+	The run is resumable: only rows whose <prefix>country is still NULL are
+	processed, so an interrupted job picks up where it stopped. Rows with no
+	place nearby get an empty country and are not retried. --restart clears
+	the columns first and enriches every row again.
+
+	--cache keeps the lookups in a persistent cache keyed by geohash (see
+	cache.go), so rows in blocks seen before — in this run or an earlier
+	one — do not hit the database.
+*/
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rgglez/geonames-loader/examples/go/internal/sqlbuild"
+	"github.com/rgglez/geonames-loader/examples/go/pkg/geocoder"
+	"gorm.io/gorm"
+)
+
+type enrichOptions struct {
+	table, idCol, latCol, lonCol string
+	prefix                       string
+	batch, workers               int
+	strategy                     string
+	cache                        *geocoder.ReverseCache
+}
+
+// enrichRow is a source row as read from the target table.
+type enrichRow struct {
+	ID  int64    `gorm:"column:id"`
+	Lat *float64 `gorm:"column:lat"`
+	Lon *float64 `gorm:"column:lon"`
+}
+
+// enrichResult holds the values written back for one row.
+type enrichResult struct {
+	id         int64
+	place      string
+	country    string
+	admin1     string
+	postalcode string
+	distanceKm *float64
+}
+
+// enrichColumns returns the output column names and their SQL types for
+// the target dialect.
+func enrichColumns(dialect, prefix string) [][2]string {
+	float := "DOUBLE PRECISION"
+	switch dialect {
+	case "mysql":
+		float = "DOUBLE"
+	case "sqlite":
+		float = "REAL"
+	}
+	return [][2]string{
+		{prefix + "place", "VARCHAR(200)"},
+		{prefix + "country", "VARCHAR(3)"},
+		{prefix + "admin1", "VARCHAR(200)"},
+		{prefix + "postalcode", "VARCHAR(20)"},
+		{prefix + "distance_km", float},
+	}
+}
+
+// prepareEnrichColumns adds missing output columns and, on restart, clears
+// previous results.
+func prepareEnrichColumns(tdb *gorm.DB, o enrichOptions, restart bool) error {
+	cols := enrichColumns(tdb.Dialector.Name(), o.prefix)
+	for _, c := range cols {
+		if tdb.Migrator().HasColumn(o.table, c[0]) {
+			continue
+		}
+		err := tdb.Exec(fmt.Sprintf(
+			"ALTER TABLE %s ADD COLUMN %s %s", o.table, c[0], c[1],
+		)).Error
+		if err != nil {
+			return fmt.Errorf("adding column %s: %w", c[0], err)
+		}
+	}
+	if restart {
+		set := ""
+		for i, c := range cols {
+			if i > 0 {
+				set += ", "
+			}
+			set += c[0] + " = NULL"
+		}
+		return tdb.Exec(fmt.Sprintf("UPDATE %s SET %s", o.table, set)).Error
+	}
+	return nil
+}
+
+// enrichPoint looks up one row.
+func enrichPoint(geo *geocoder.Geocoder, row enrichRow) (enrichResult, error) {
+	res := enrichResult{id: row.ID}
+	if row.Lat == nil || row.Lon == nil {
+		return res, nil
+	}
+	lat, lon := *row.Lat, *row.Lon
+
+	places, err := geo.NearestPlaces(lat, lon, geocoder.QueryOptions{Limit: 1, FeatureClass: "P"})
+	if geocoder.IsNoResult(err) {
+		return res, nil
+	}
+	if err != nil {
+		return res, err
+	}
+	g := places.Rows[0]
+	res.place, res.country = g.Name, g.Country
+	res.distanceKm = &g.DistanceKm
+
+	names, err := geo.AdminNames(g.Country, g.Admin1, "")
+	if err != nil {
+		return res, err
+	}
+	res.admin1 = names.Admin1
+
+	postal, err := geo.NearestPostal(lat, lon, geocoder.QueryOptions{Limit: 1, Country: g.Country})
+	switch {
+	case geocoder.IsNoResult(err):
+	case err != nil:
+		return res, err
+	default:
+		res.postalcode = postal.Rows[0].Postalcode
+	}
+	return res, nil
+}
+
+// runEnrichJob processes every pending row and returns the number enriched.
+func runEnrichJob(geo *geocoder.Geocoder, tdb *gorm.DB, o enrichOptions) (int64, error) {
+	cols := enrichColumns(tdb.Dialector.Name(), o.prefix)
+	pending := fmt.Sprintf("%s IS NULL", cols[1][0])
+
+	var total int64
+	if err := tdb.Table(o.table).Where(pending).Count(&total).Error; err != nil {
+		return 0, err
+	}
+	fmt.Printf("Rows to enrich: %d\n", total)
+	if total == 0 {
+		return 0, nil
+	}
+
+	selectSQL := fmt.Sprintf(
+		"SELECT %s AS id, %s AS lat, %s AS lon FROM %s"+
+			" WHERE %s AND %s > ? ORDER BY %s LIMIT ?",
+		o.idCol, o.latCol, o.lonCol, o.table, pending, o.idCol, o.idCol,
+	)
+	updateSQL := fmt.Sprintf(
+		"UPDATE %s SET %s = ?, %s = ?, %s = ?, %s = ?, %s = ? WHERE %s = ?",
+		o.table, cols[0][0], cols[1][0], cols[2][0], cols[3][0], cols[4][0],
+		o.idCol,
+	)
+
+	var (
+		done, lastID int64
+		start        = time.Now()
+	)
+	lastID = -1 << 63
+	for {
+		var rows []enrichRow
+		if err := tdb.Raw(selectSQL, lastID, o.batch).Scan(&rows).Error; err != nil {
+			return done, err
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		// Look the batch up concurrently, keeping results in row order.
+		results := make([]enrichResult, len(rows))
+		errs := make(chan error, len(rows))
+		sem := make(chan struct{}, o.workers)
+		var wg sync.WaitGroup
+		for i, row := range rows {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int, row enrichRow) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				res, err := enrichPoint(geo, row)
+				if err != nil {
+					errs <- fmt.Errorf("row %d: %w", row.ID, err)
+					return
+				}
+				results[i] = res
+			}(i, row)
+		}
+		wg.Wait()
+		close(errs)
+		if err := <-errs; err != nil {
+			return done, err
+		}
+
+		err := tdb.Transaction(func(tx *gorm.DB) error {
+			for _, r := range results {
+				if err := tx.Exec(updateSQL,
+					r.place, r.country, r.admin1, r.postalcode, r.distanceKm, r.id,
+				).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return done, err
+		}
+
+		done += int64(len(rows))
+		lastID = rows[len(rows)-1].ID
+		elapsed := time.Since(start).Seconds()
+		fmt.Printf("\r  %d / %d rows (%.1f%%), %.0f rows/s   ",
+			done, total, 100*float64(done)/float64(total), float64(done)/elapsed)
+	}
+	fmt.Println()
+	return done, nil
+}
+
+func runEnrich(args []string) {
+	fs := flag.NewFlagSet("enrich", flag.ExitOnError)
+	cfgPath := fs.String(
+		"config", "../../../../config/config.yaml",
+		"Path to config YAML file (default: ../../../../config/config.yaml)",
+	)
+	rawURL := fs.String(
+		"url", "",
+		"GeoNames connection URL — overrides --config",
+	)
+	targetURL := fs.String(
+		"target-url", "",
+		"Connection URL of the database holding --table "+
+			"(default: the GeoNames database)",
+	)
+	var o enrichOptions
+	fs.StringVar(&o.table, "table", "", "Table to enrich (required)")
+	fs.StringVar(&o.idCol, "id-column", "id",
+		"Integer primary-key column used to page through the table")
+	fs.StringVar(&o.latCol, "lat-column", "latitude", "Latitude column")
+	fs.StringVar(&o.lonCol, "lon-column", "longitude", "Longitude column")
+	fs.StringVar(&o.prefix, "prefix", "geo_", "Prefix for the output columns")
+	fs.IntVar(&o.batch, "batch", 500, "Rows read and written per batch")
+	fs.IntVar(&o.workers, "workers", 4, "Concurrent lookups per batch")
+	fs.StringVar(&o.strategy, "strategy", geocoder.StrategyAuto,
+		"Distance strategy: auto, postgis, earthdistance, haversine, memory or rtree")
+	restart := fs.Bool("restart", false,
+		"Clear previous results and enrich every row again")
+	cachePath := fs.String("cache", "",
+		"Persistent lookup cache file, created if missing (see cache.go)")
+	_ = fs.Parse(args)
+
+	if o.table == "" {
+		fmt.Fprintln(fs.Output(), "ERROR: --table is required.")
+		fs.Usage()
+		os.Exit(1)
+	}
+	for _, id := range []string{o.table, o.idCol, o.latCol, o.lonCol, o.prefix + "x"} {
+		if !sqlbuild.Identifier.MatchString(id) {
+			log.Fatalf("enrich: invalid identifier %q", id)
+		}
+	}
+	if o.batch < 1 || o.workers < 1 {
+		log.Fatal("enrich: --batch and --workers must be positive")
+	}
+
+	cfg, err := configFor(*cfgPath, *rawURL)
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	db, err := openQueryDB(cfg, *rawURL)
+	if err != nil {
+		log.Fatalf("database: %v", err)
+	}
+	if err := geocoder.CheckStrategy(db, o.strategy); err != nil {
+		log.Fatalf("enrich: --strategy: %v", err)
+	}
+	tdb := db
+	if *targetURL != "" {
+		if tdb, err = openDB(new(Config), *targetURL); err != nil {
+			log.Fatalf("target database: %v", err)
+		}
+	}
+
+	if *cachePath != "" {
+		if o.cache, err = geocoder.OpenReverseCache(*cachePath); err != nil {
+			log.Fatalf("enrich: %v", err)
+		}
+	}
+
+	fmt.Println(strings.Repeat("=", 60))
+	fmt.Println("GeoNames bulk enrichment")
+	fmt.Printf("  Table     : %s (%s, %s)\n", o.table, o.latCol, o.lonCol)
+	fmt.Printf("  Columns   : %s*\n", o.prefix)
+	fmt.Printf("  Strategy  : %s\n", geocoder.DescribeStrategy(db, o.strategy))
+	fmt.Println(strings.Repeat("=", 60))
+
+	if err := prepareEnrichColumns(tdb, o, *restart); err != nil {
+		log.Fatalf("enrich: %v", err)
+	}
+	geo := geocoder.New(db, geocoder.WithStrategy(o.strategy), geocoder.WithReverseCache(o.cache),
+		geocoder.WithCacheScope(cacheScope(cfg.Database, *rawURL)))
+	n, err := runEnrichJob(geo, tdb, o)
+	if o.cache != nil {
+		if st, err := o.cache.Stats(); err == nil {
+			fmt.Printf("Cache: %d hits, %d misses (%.1f%% hit ratio overall).\n",
+				st.Hits, st.Misses, 100*st.HitRatio)
+		}
+		if err := o.cache.Close(); err != nil {
+			log.Printf("cache: %v", err)
+		}
+	}
+	if err != nil {
+		log.Fatalf("enrich: %v (after %d rows; rerun to resume)", err, n)
+	}
+	fmt.Printf("Enriched %d rows.\n", n)
+}
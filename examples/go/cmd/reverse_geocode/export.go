@@ -0,0 +1,479 @@
+package main
+
+/*
+	Export of geoname / postalcodes subsets to CSV or Parquet ("export"
+	subcommand).
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    go run . export --table geoname --country MX,GT --fclass P \
+	        --out places.parquet
+	    go run . export --table postalcodes \
+	        --bbox -99.4,19.1,-98.9,19.7 --format csv > cdmx.csv
+
+	--table is geoname (default) or postalcodes. Filters are combined with
+	AND:
+	    --country  comma-separated ISO 3166-1 alpha-2 codes
+	    --bbox     minlon,minlat,maxlon,maxlat (minlon > maxlon crosses the
+	               antimeridian)
+	    --fclass   comma-separated feature classes (geoname only)
+
+	--format is csv or parquet; when omitted it is taken from the --out
+	extension, falling back to csv. --out defaults to standard output.
+
+	Rows are streamed from a database cursor straight into the writer, so
+	memory use does not grow with the size of the export. Parquet files are
+	Snappy-compressed and split into row groups of --row-group rows; every
+	column is optional, matching the nullable columns of the loader schema.
+*/
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/rgglez/geonames-loader/examples/go/pkg/geocoder"
+	"gorm.io/gorm"
+)
+
+// exportKind is the value type of an exported column.
+type exportKind int
+
+const (
+	exportString exportKind = iota
+	exportInt
+	exportFloat
+)
+
+type exportColumn struct {
+	Name string
+	Kind exportKind
+}
+
+// exportTables lists the exportable tables and their columns, in output
+// order. countryCol is the column --country filters on.
+var exportTables = map[string]struct {
+	countryCol string
+	columns    []exportColumn
+}{
+	"geoname": {"country", []exportColumn{
+		{"geonameid", exportInt},
+		{"name", exportString},
+		{"asciiname", exportString},
+		{"alternatenames", exportString},
+		{"latitude", exportFloat},
+		{"longitude", exportFloat},
+		{"fclass", exportString},
+		{"fcode", exportString},
+		{"country", exportString},
+		{"cc2", exportString},
+		{"admin1", exportString},
+		{"admin2", exportString},
+		{"admin3", exportString},
+		{"admin4", exportString},
+		{"population", exportInt},
+		{"elevation", exportInt},
+		{"gtopo30", exportInt},
+		{"timezone", exportString},
+	}},
+	"postalcodes": {"countrycode", []exportColumn{
+		{"countrycode", exportString},
+		{"postalcode", exportString},
+		{"placename", exportString},
+		{"admin1name", exportString},
+		{"admin1code", exportString},
+		{"admin2name", exportString},
+		{"admin2code", exportString},
+		{"admin3name", exportString},
+		{"admin3code", exportString},
+		{"latitude", exportFloat},
+		{"longitude", exportFloat},
+		{"accuracy", exportInt},
+	}},
+}
+
+// exportFilter selects the rows to export. Empty fields do not restrict.
+type exportFilter struct {
+	Countries    []string
+	FeatureClass []string
+	BBox         []float64 // minlon, minlat, maxlon, maxlat
+}
+
+// exportQuery builds the SELECT for table under f, reading it from the
+// table from (its name in the database, see pkg/geocoder/tables.go).
+func exportQuery(table, from string, f exportFilter) (string, []interface{}, error) {
+	t, ok := exportTables[table]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown table %q (want geoname or postalcodes)", table)
+	}
+	names := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		names[i] = c.Name
+	}
+
+	conds := []string{"latitude IS NOT NULL", "longitude IS NOT NULL"}
+	var args []interface{}
+	in := func(col string, vals []string) {
+		conds = append(conds, col+" IN (?"+strings.Repeat(", ?", len(vals)-1)+")")
+		for _, v := range vals {
+			args = append(args, v)
+		}
+	}
+	if len(f.Countries) > 0 {
+		in(t.countryCol, f.Countries)
+	}
+	if len(f.FeatureClass) > 0 {
+		if table != "geoname" {
+			return "", nil, fmt.Errorf("--fclass only applies to the geoname table")
+		}
+		in("fclass", f.FeatureClass)
+	}
+	if b := f.BBox; b != nil {
+		conds = append(conds, "latitude BETWEEN ? AND ?")
+		args = append(args, b[1], b[3])
+		if b[0] <= b[2] {
+			conds = append(conds, "longitude BETWEEN ? AND ?")
+		} else {
+			conds = append(conds, "(longitude >= ? OR longitude <= ?)")
+		}
+		args = append(args, b[0], b[2])
+	}
+
+	return fmt.Sprintf("SELECT %s FROM %s WHERE %s",
+		strings.Join(names, ", "), from, strings.Join(conds, " AND "),
+	), args, nil
+}
+
+// parseBBox parses "minlon,minlat,maxlon,maxlat".
+func parseBBox(s string) ([]float64, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("want minlon,minlat,maxlon,maxlat")
+	}
+	b := make([]float64, 4)
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", p)
+		}
+		b[i] = v
+	}
+	if b[0] < -180 || b[2] > 180 || b[0] > 180 || b[2] < -180 ||
+		b[1] < -90 || b[3] > 90 || b[1] > b[3] {
+		return nil, fmt.Errorf("coordinates out of range")
+	}
+	return b, nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(s string, upper bool) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			if upper {
+				p = strings.ToUpper(p)
+			}
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// ---------------------------------------------------------------------------
+// Writers
+// ---------------------------------------------------------------------------
+
+// rowWriter receives exported rows one at a time. Values are nil for NULL,
+// otherwise string, int64 or float64 according to the column kind.
+type rowWriter interface {
+	Write(values []any) error
+	Close() error
+}
+
+type csvRowWriter struct {
+	w   *csv.Writer
+	rec []string
+}
+
+func newCSVRowWriter(out io.Writer, cols []exportColumn) (*csvRowWriter, error) {
+	w := csv.NewWriter(out)
+	header := make([]string, len(cols))
+	for i, c := range cols {
+		header[i] = c.Name
+	}
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &csvRowWriter{w: w, rec: make([]string, len(cols))}, nil
+}
+
+func (c *csvRowWriter) Write(values []any) error {
+	for i, v := range values {
+		switch v := v.(type) {
+		case nil:
+			c.rec[i] = ""
+		case string:
+			c.rec[i] = v
+		case int64:
+			c.rec[i] = strconv.FormatInt(v, 10)
+		case float64:
+			c.rec[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+	}
+	return c.w.Write(c.rec)
+}
+
+func (c *csvRowWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type parquetRowWriter struct {
+	w      *parquet.Writer
+	leaf   []int // column index in the Parquet schema, by export position
+	buf    []parquet.Row
+	bufMax int
+}
+
+func newParquetRowWriter(
+	out io.Writer, table string, cols []exportColumn, rowGroup int64,
+) *parquetRowWriter {
+	group := parquet.Group{}
+	for _, c := range cols {
+		var node parquet.Node
+		switch c.Kind {
+		case exportInt:
+			node = parquet.Int(64)
+		case exportFloat:
+			node = parquet.Leaf(parquet.DoubleType)
+		default:
+			node = parquet.String()
+		}
+		group[c.Name] = parquet.Optional(node)
+	}
+	schema := parquet.NewSchema(table, group)
+
+	// Group orders its leaves by name; remember where each column landed.
+	leaf := make([]int, len(cols))
+	for i, c := range cols {
+		lc, _ := schema.Lookup(c.Name)
+		leaf[i] = lc.ColumnIndex
+	}
+	return &parquetRowWriter{
+		w: parquet.NewWriter(out, schema,
+			parquet.Compression(&parquet.Snappy),
+			parquet.MaxRowsPerRowGroup(rowGroup),
+		),
+		leaf:   leaf,
+		bufMax: 1024,
+	}
+}
+
+func (p *parquetRowWriter) Write(values []any) error {
+	row := make(parquet.Row, len(values))
+	for i, v := range values {
+		col := p.leaf[i]
+		if v == nil {
+			row[col] = parquet.NullValue().Level(0, 0, col)
+		} else {
+			row[col] = parquet.ValueOf(v).Level(0, 1, col)
+		}
+	}
+	p.buf = append(p.buf, row)
+	if len(p.buf) >= p.bufMax {
+		return p.flush()
+	}
+	return nil
+}
+
+func (p *parquetRowWriter) flush() error {
+	_, err := p.w.WriteRows(p.buf)
+	p.buf = p.buf[:0]
+	return err
+}
+
+func (p *parquetRowWriter) Close() error {
+	if err := p.flush(); err != nil {
+		return err
+	}
+	return p.w.Close()
+}
+
+// ---------------------------------------------------------------------------
+// Export
+// ---------------------------------------------------------------------------
+
+// exportRows streams the rows of table matching f into w and returns the
+// number written. progress, if non-nil, is called every 100 000 rows.
+func exportRows(
+	db *gorm.DB, table string, f exportFilter, w rowWriter,
+	progress func(n int64),
+) (int64, error) {
+	query, args, err := exportQuery(table, geocoder.TableName(db, table), f)
+	if err != nil {
+		return 0, err
+	}
+	cols := exportTables[table].columns
+
+	rows, err := db.Raw(query, args...).Rows()
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	dest := make([]any, len(cols))
+	for i, c := range cols {
+		switch c.Kind {
+		case exportInt:
+			dest[i] = new(sql.NullInt64)
+		case exportFloat:
+			dest[i] = new(sql.NullFloat64)
+		default:
+			dest[i] = new(sql.NullString)
+		}
+	}
+	values := make([]any, len(cols))
+
+	var n int64
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return n, err
+		}
+		for i, d := range dest {
+			values[i] = nil
+			switch d := d.(type) {
+			case *sql.NullInt64:
+				if d.Valid {
+					values[i] = d.Int64
+				}
+			case *sql.NullFloat64:
+				if d.Valid {
+					values[i] = d.Float64
+				}
+			case *sql.NullString:
+				if d.Valid {
+					values[i] = d.String
+				}
+			}
+		}
+		if err := w.Write(values); err != nil {
+			return n, err
+		}
+		n++
+		if progress != nil && n%100_000 == 0 {
+			progress(n)
+		}
+	}
+	return n, rows.Err()
+}
+
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	cfgPath := fs.String(
+		"config", "../../../../config/config.yaml",
+		"Path to config YAML file (default: ../../../../config/config.yaml)",
+	)
+	rawURL := fs.String(
+		"url", "",
+		"Connection URL — overrides --config",
+	)
+	table := fs.String("table", "geoname", "Table to export: geoname or postalcodes")
+	format := fs.String("format", "",
+		"Output format: csv or parquet (default: from --out extension, else csv)")
+	outPath := fs.String("out", "-", "Output file (default: standard output)")
+	countries := fs.String("country", "",
+		"Comma-separated ISO 3166-1 alpha-2 codes (e.g. MX,US,CA)")
+	bbox := fs.String("bbox", "", "Bounding box minlon,minlat,maxlon,maxlat")
+	fclass := fs.String("fclass", "",
+		"Comma-separated feature classes, geoname only (e.g. P,A)")
+	rowGroup := fs.Int64("row-group", 100_000, "Rows per Parquet row group")
+	_ = fs.Parse(args)
+
+	f := exportFilter{
+		Countries:    splitList(*countries, true),
+		FeatureClass: splitList(*fclass, true),
+	}
+	if *bbox != "" {
+		b, err := parseBBox(*bbox)
+		if err != nil {
+			log.Fatalf("export: --bbox: %v", err)
+		}
+		f.BBox = b
+	}
+	if *format == "" {
+		*format = "csv"
+		if strings.EqualFold(filepath.Ext(*outPath), ".parquet") {
+			*format = "parquet"
+		}
+	}
+	if *format != "csv" && *format != "parquet" {
+		log.Fatalf("export: --format must be csv or parquet")
+	}
+	// Validate the table and filters before touching the output file.
+	if _, _, err := exportQuery(*table, *table, f); err != nil {
+		log.Fatalf("export: %v", err)
+	}
+
+	cfg, err := configFor(*cfgPath, *rawURL)
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	db, err := openQueryDB(cfg, *rawURL)
+	if err != nil {
+		log.Fatalf("database: %v", err)
+	}
+
+	var out io.Writer = os.Stdout
+	if *outPath != "-" {
+		file, err := os.Create(*outPath)
+		if err != nil {
+			log.Fatalf("export: %v", err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	cols := exportTables[*table].columns
+	var w rowWriter
+	if *format == "parquet" {
+		w = newParquetRowWriter(out, *table, cols, *rowGroup)
+	} else if w, err = newCSVRowWriter(out, cols); err != nil {
+		log.Fatalf("export: %v", err)
+	}
+
+	// Progress goes to stderr so that stdout can carry the data.
+	n, err := exportRows(db, *table, f, w, func(n int64) {
+		fmt.Fprintf(os.Stderr, "\r  %d rows", n)
+	})
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		log.Fatalf("export: %v (after %d rows)", err, n)
+	}
+	fmt.Fprintf(os.Stderr, "\rExported %d rows from %s as %s.\n", n, *table, *format)
+}
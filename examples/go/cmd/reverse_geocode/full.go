@@ -0,0 +1,57 @@
+package main
+
+/*
+	Composite reverse geocoding: everything known about a point in one call.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	The library side, and how it works, is in pkg/geocoder/full.go.
+*/
+
+import (
+	"log"
+	"net/http"
+)
+
+func (s *server) handleReverseFull(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	lat, err := parseCoord(q.Get("lat"), 90)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "lat: "+err.Error())
+		return
+	}
+	lon, err := parseCoord(q.Get("lon"), 180)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "lon: "+err.Error())
+		return
+	}
+
+	release, ok := s.acquire(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+
+	res, err := s.geo.WithContext(r.Context()).ReverseGeocodeFull(lat, lon)
+	if err != nil {
+		log.Printf("full reverse: %v", err)
+		s.writeQueryError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,63 @@
+package main
+
+/*
+	Geometry input: points in WKT, WKB or GeoJSON, and GeoJSON features.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    go run . --point "POINT(-99.1332 19.4326)"
+	    go run . --point "SRID=4326;POINT(-99.1332 19.4326)"
+	    go run . --point 0101000000f1f44a5986c858c0e63fa4dfbe6e3340   # WKB hex
+	    go run . --point '{"type":"Point","coordinates":[-99.1332,19.4326]}'
+	    go run . --point 19.4326,-99.1332
+	    go run . --features stops.geojson > stops-geocoded.geojson
+	    ogr2ogr -f GeoJSON /vsistdout/ stops.shp | go run . --features -
+
+	The library side, and how it works, is in pkg/geocoder/geometry.go.
+*/
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rgglez/geonames-loader/examples/go/pkg/geocoder"
+)
+
+// runFeatures is the --features mode of the reverse-geocode command.
+func runFeatures(geo *geocoder.Geocoder, path string, opts geocoder.QueryOptions) error {
+	in := os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	done, skipped, err := geo.ReverseFeatures(in, os.Stdout, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Geocoded %d feature(s)", done)
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "; %d without a Point geometry left as is", skipped)
+	}
+	fmt.Fprintln(os.Stderr, ".")
+	return nil
+}
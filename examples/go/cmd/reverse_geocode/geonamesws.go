@@ -0,0 +1,400 @@
+package main
+
+/*
+	GeoNames web-service compatible endpoints for the HTTP server.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	These mirror the JSON flavour of the api.geonames.org services, so
+	self-hosters can point existing clients at this server instead:
+
+	    GET /findNearbyPlaceNameJSON?lat=..&lng=..[&radius=km][&maxRows=1]
+	    GET /findNearbyPostalCodesJSON?lat=..&lng=..[&radius=km][&maxRows=5][&country=CC]
+	    GET /searchJSON?q=..|name=..|name_equals=..|name_startsWith=..
+	        [&country=CC][&featureClass=P][&featureCode=PPLC]
+	        [&maxRows=100][&startRow=0][&fuzzy=1]
+
+	As on geonames.org, fuzzy below 1 tolerates misspellings: places are
+	ranked by similarity to the search term and those scoring under fuzzy
+	are dropped (see pkg/geocoder/fuzzy.go). Each result then carries its
+	score, and totalResultsCount is the number of results returned.
+
+	The username parameter is accepted and ignored (use API keys instead).
+	Errors are reported like the original service: HTTP 200 with a
+	{"status": {"message": ..., "value": ...}} body.
+*/
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rgglez/geonames-loader/examples/go/pkg/geocoder"
+	"gorm.io/gorm"
+)
+
+// GeoNames web-service error codes used here.
+const (
+	wsErrInvalidParameter = 14
+	wsErrServer           = 13
+	wsErrOverloaded       = 22
+)
+
+// wsFeatureClassNames are the descriptions geonames.org returns as fclName.
+var wsFeatureClassNames = map[string]string{
+	"A": "country, state, region,...",
+	"H": "stream, lake, ...",
+	"L": "parks,area, ...",
+	"P": "city, village,...",
+	"R": "road, railroad ",
+	"S": "spot, building, farm",
+	"T": "mountain,hill,rock,... ",
+	"U": "undersea",
+	"V": "forest,heath,...",
+}
+
+// wsPlace is one entry of the "geonames" array.
+type wsPlace struct {
+	GeonameID   int64   `json:"geonameId"`
+	Name        string  `json:"name"`
+	ToponymName string  `json:"toponymName"`
+	Lat         string  `json:"lat"`
+	Lng         string  `json:"lng"`
+	CountryCode string  `json:"countryCode"`
+	CountryName string  `json:"countryName,omitempty"`
+	CountryID   string  `json:"countryId,omitempty"`
+	AdminCode1  string  `json:"adminCode1,omitempty"`
+	AdminName1  string  `json:"adminName1,omitempty"`
+	Fcl         string  `json:"fcl"`
+	FclName     string  `json:"fclName,omitempty"`
+	Fcode       string  `json:"fcode"`
+	FcodeName   string  `json:"fcodeName,omitempty"`
+	Population  int64   `json:"population"`
+	Distance    string  `json:"distance,omitempty"`
+	Score       float64 `json:"score,omitempty"`
+}
+
+// wsPostalCode is one entry of the "postalCodes" array.
+type wsPostalCode struct {
+	PostalCode  string  `json:"postalCode"`
+	PlaceName   string  `json:"placeName"`
+	CountryCode string  `json:"countryCode"`
+	Lat         float64 `json:"lat"`
+	Lng         float64 `json:"lng"`
+	AdminName1  string  `json:"adminName1,omitempty"`
+	AdminName2  string  `json:"adminName2,omitempty"`
+	AdminName3  string  `json:"adminName3,omitempty"`
+	Distance    string  `json:"distance"`
+}
+
+func writeWSError(w http.ResponseWriter, value int, msg string) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"status": map[string]any{"message": msg, "value": value},
+	})
+}
+
+// writeWSQueryError reports a failed lookup in the web-service format.
+func writeWSQueryError(w http.ResponseWriter, err error) {
+	if errors.Is(err, geocoder.ErrBackendUnavailable) {
+		writeWSError(w, wsErrOverloaded, "database unavailable")
+		return
+	}
+	writeWSError(w, wsErrServer, "query failed")
+}
+
+// wsInt parses an optional integer parameter.
+func wsInt(q map[string][]string, name string, def int) (int, bool) {
+	v := ""
+	if vs := q[name]; len(vs) > 0 {
+		v = vs[0]
+	}
+	if v == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(v)
+	return n, err == nil && n >= 0
+}
+
+// wsRadius parses the optional radius parameter (km); 0 means unlimited.
+func wsRadius(v string) (float64, bool) {
+	if v == "" {
+		return 0, true
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	return f, err == nil && f >= 0
+}
+
+// wsDistance formats a distance the way geonames.org does.
+func wsDistance(km float64) string {
+	return strconv.FormatFloat(km, 'f', 5, 64)
+}
+
+// ---------------------------------------------------------------------------
+// Feature code and admin name lookups
+// ---------------------------------------------------------------------------
+
+// featureCodeNames caches the featurecodes table ("P.PPLC" → "capital of
+// a political entity"); it is small and never changes while serving.
+var featureCodeNames struct {
+	once  sync.Once
+	names map[string]string
+}
+
+func featureCodeName(db *gorm.DB, fclass, fcode string) string {
+	featureCodeNames.once.Do(func() {
+		var rows []struct {
+			Code string `gorm:"column:code"`
+			Name string `gorm:"column:name"`
+		}
+		if err := db.Raw("SELECT code, name FROM " + geocoder.TableName(db, "featurecodes")).Scan(&rows).Error; err != nil {
+			log.Printf("featurecodes: %v", err)
+		}
+		featureCodeNames.names = make(map[string]string, len(rows))
+		for _, r := range rows {
+			featureCodeNames.names[strings.TrimSpace(r.Code)] = r.Name
+		}
+	})
+	return featureCodeNames.names[fclass+"."+fcode]
+}
+
+// toWSPlaces converts geoname rows, resolving country and admin1 names once
+// per distinct code.
+func toWSPlaces(db *gorm.DB, rows []geocoder.Place, withDistance bool) ([]wsPlace, error) {
+	names := map[string]geocoder.AdminNames{}
+	out := make([]wsPlace, 0, len(rows))
+	for _, g := range rows {
+		key := g.Country + "." + g.Admin1
+		n, ok := names[key]
+		if !ok {
+			var err error
+			if n, err = geocoder.LookupAdminNames(db, g.Country, g.Admin1, ""); err != nil {
+				return nil, err
+			}
+			names[key] = n
+		}
+		p := wsPlace{
+			GeonameID:   g.Geonameid,
+			Name:        g.Name,
+			ToponymName: g.Name,
+			Lat:         strconv.FormatFloat(g.Latitude, 'f', -1, 64),
+			Lng:         strconv.FormatFloat(g.Longitude, 'f', -1, 64),
+			CountryCode: g.Country,
+			CountryName: n.Country,
+			AdminCode1:  g.Admin1,
+			AdminName1:  n.Admin1,
+			Fcl:         g.Fclass,
+			FclName:     wsFeatureClassNames[g.Fclass],
+			Fcode:       g.Fcode,
+			FcodeName:   featureCodeName(db, g.Fclass, g.Fcode),
+			Population:  g.Population,
+			Score:       g.Score,
+		}
+		if n.CountryID != 0 {
+			p.CountryID = strconv.FormatInt(n.CountryID, 10)
+		}
+		if withDistance {
+			p.Distance = wsDistance(g.DistanceKm)
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// ---------------------------------------------------------------------------
+// Handlers
+// ---------------------------------------------------------------------------
+
+// wsPoint parses lat/lng, radius and maxRows, writing the error response
+// on failure.
+func (s *server) wsPoint(
+	w http.ResponseWriter, r *http.Request, defRows int,
+) (lat, lng, radius float64, maxRows int, ok bool) {
+	q := r.URL.Query()
+	var err error
+	if lat, err = parseCoord(q.Get("lat"), 90); err != nil {
+		writeWSError(w, wsErrInvalidParameter, "invalid lat: "+err.Error())
+		return 0, 0, 0, 0, false
+	}
+	if lng, err = parseCoord(q.Get("lng"), 180); err != nil {
+		writeWSError(w, wsErrInvalidParameter, "invalid lng: "+err.Error())
+		return 0, 0, 0, 0, false
+	}
+	if radius, ok = wsRadius(q.Get("radius")); !ok {
+		writeWSError(w, wsErrInvalidParameter, "invalid radius")
+		return 0, 0, 0, 0, false
+	}
+	if maxRows, ok = wsInt(q, "maxRows", defRows); !ok || maxRows == 0 {
+		writeWSError(w, wsErrInvalidParameter, "invalid maxRows")
+		return 0, 0, 0, 0, false
+	}
+	return lat, lng, radius, min(maxRows, s.cfg.MaxResults), true
+}
+
+func (s *server) handleFindNearbyPlaceName(w http.ResponseWriter, r *http.Request) {
+	lat, lng, radius, maxRows, ok := s.wsPoint(w, r, 1)
+	if !ok {
+		return
+	}
+	release, ok := s.acquire(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+
+	db := s.db.WithContext(r.Context())
+	res, err := s.geo.WithContext(r.Context()).NearestPlaces(lat, lng, geocoder.QueryOptions{
+		Limit: maxRows, FeatureClass: "P", MaxDistanceKm: radius,
+	})
+	if err != nil && !geocoder.IsNoResult(err) {
+		log.Printf("findNearbyPlaceName: %v", err)
+		writeWSQueryError(w, err)
+		return
+	}
+	places, err := toWSPlaces(db, res.Rows, true)
+	if err != nil {
+		log.Printf("findNearbyPlaceName: %v", err)
+		writeWSError(w, wsErrServer, "query failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"geonames": places})
+}
+
+func (s *server) handleFindNearbyPostalCodes(w http.ResponseWriter, r *http.Request) {
+	lat, lng, radius, maxRows, ok := s.wsPoint(w, r, 5)
+	if !ok {
+		return
+	}
+	release, ok := s.acquire(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+
+	res, err := s.geo.WithContext(r.Context()).NearestPostal(lat, lng, geocoder.QueryOptions{
+		Limit:         maxRows,
+		Country:       strings.ToUpper(r.URL.Query().Get("country")),
+		MaxDistanceKm: radius,
+	})
+	if err != nil && !geocoder.IsNoResult(err) {
+		log.Printf("findNearbyPostalCodes: %v", err)
+		writeWSQueryError(w, err)
+		return
+	}
+	out := make([]wsPostalCode, 0, len(res.Rows))
+	for _, p := range res.Rows {
+		out = append(out, wsPostalCode{
+			PostalCode:  p.Postalcode,
+			PlaceName:   p.Placename,
+			CountryCode: p.Countrycode,
+			Lat:         p.Latitude,
+			Lng:         p.Longitude,
+			AdminName1:  p.Admin1name,
+			AdminName2:  p.Admin2name,
+			AdminName3:  p.Admin3name,
+			Distance:    wsDistance(p.DistanceKm),
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"postalCodes": out})
+}
+
+func (s *server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	opts := geocoder.SearchOptions{
+		Query:          q.Get("q"),
+		Name:           q.Get("name"),
+		NameEquals:     q.Get("name_equals"),
+		NameStartsWith: q.Get("name_startsWith"),
+		Country:        strings.ToUpper(q.Get("country")),
+		FeatureClass:   strings.ToUpper(q.Get("featureClass")),
+		FeatureCode:    strings.ToUpper(q.Get("featureCode")),
+	}
+	if opts.Query == "" && opts.Name == "" &&
+		opts.NameEquals == "" && opts.NameStartsWith == "" {
+		writeWSError(w, wsErrInvalidParameter,
+			"one of q, name, name_equals or name_startsWith is required")
+		return
+	}
+	var ok bool
+	if opts.Limit, ok = wsInt(q, "maxRows", 100); !ok || opts.Limit == 0 {
+		writeWSError(w, wsErrInvalidParameter, "invalid maxRows")
+		return
+	}
+	opts.Limit = min(opts.Limit, s.cfg.MaxResults)
+	if opts.Offset, ok = wsInt(q, "startRow", 0); !ok {
+		writeWSError(w, wsErrInvalidParameter, "invalid startRow")
+		return
+	}
+	fuzzy := 1.0
+	if v := q.Get("fuzzy"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 || f > 1 {
+			writeWSError(w, wsErrInvalidParameter, "fuzzy must be between 0 and 1")
+			return
+		}
+		fuzzy = f
+	}
+
+	release, ok := s.acquire(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+
+	fail := func(err error) {
+		log.Printf("search: %v", err)
+		writeWSError(w, wsErrServer, "query failed")
+	}
+	db := s.db.WithContext(r.Context())
+	var (
+		rows  []geocoder.Place
+		total int64
+		err   error
+	)
+	if fuzzy < 1 {
+		var term string
+		for _, t := range []string{
+			opts.Query, opts.Name, opts.NameEquals, opts.NameStartsWith,
+		} {
+			if term == "" {
+				term = t
+			}
+		}
+		rows, err = geocoder.FuzzySearchPlaces(db, opts, term, fuzzy)
+		total = int64(len(rows))
+	} else {
+		if total, err = geocoder.CountPlaces(db, opts); err == nil {
+			rows, err = geocoder.SearchPlaces(db, opts)
+		}
+	}
+	if err != nil {
+		fail(err)
+		return
+	}
+	places, err := toWSPlaces(db, rows, false)
+	if err != nil {
+		fail(err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"totalResultsCount": total,
+		"geonames":          places,
+	})
+}
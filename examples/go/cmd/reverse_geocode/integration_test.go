@@ -0,0 +1,588 @@
+//go:build integration
+
+package main
+
+/*
+	Integration tests: the same lookups against every dialect and strategy.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Each backend is loaded with the sample places and postal codes of
+	testdata/integration through the loader's own schema, file import and
+	index steps, then every strategy it supports runs the lookups of
+	integrationLookups, which must return the same rows in the same order
+	everywhere:
+
+	    sqlite         haversine, memory (in a temporary file)
+	    snapshot       rtree, haversine (a snapshot of the SQLite database)
+	    postgres       earthdistance, haversine, memory (postgres image)
+	    postgis        postgis, earthdistance, haversine (postgis image)
+	    mysql          haversine, memory (mysql image)
+
+	The PostgreSQL and MySQL servers run in containers started with
+	testcontainers-go, and are skipped when no Docker daemon is reachable.
+	The SQLite cases need the sqlite_math_functions tag as well, and are
+	skipped, saying so, without it:
+
+	    go test -tags integration,sqlite_math_functions ./cmd/reverse_geocode
+	    go test -tags integration,sqlite_math_functions ./cmd/reverse_geocode -run 'Integration/sqlite'
+
+	Distances are checked against the ellipsoidal (Vincenty) distance of
+	each row within 1%, which covers the spherical strategies; the sample
+	points are far enough apart that no strategy can order them
+	differently.
+*/
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rgglez/geonames-loader/examples/go/pkg/geocoder"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/mysql"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"gorm.io/gorm"
+)
+
+// integrationLookup is a query and the rows every strategy must return.
+type integrationLookup struct {
+	name     string
+	lat, lon float64
+	opts     geocoder.QueryOptions
+	// postal runs NearestPostal instead of NearestPlaces.
+	postal bool
+	// want lists the postal codes found, or "geonameid postalcode" for
+	// each place.
+	want []string
+}
+
+var integrationLookups = []integrationLookup{
+	{
+		name: "places near Mexico City", lat: 19.4326, lon: -99.1332,
+		opts: geocoder.QueryOptions{Limit: 4},
+		want: []string{"3530597 06000", "3532624 15620", "3523183 53000", "3530589 57000"},
+	},
+	{
+		name: "populous places near Mexico City", lat: 19.4326, lon: -99.1332,
+		opts: geocoder.QueryOptions{Limit: 3, FeatureClass: "P", MinPopulation: 1_000_000},
+		want: []string{"3530597 06000", "3530589 57000", "3527646 55000"},
+	},
+	{
+		name: "postal codes near Mexico City", lat: 19.4326, lon: -99.1332, postal: true,
+		opts: geocoder.QueryOptions{Limit: 3},
+		want: []string{"06000", "06700", "15620"},
+	},
+	{
+		name: "places near Berlin", lat: 52.52, lon: 13.405,
+		opts: geocoder.QueryOptions{Limit: 3, Country: "DE"},
+		want: []string{"2950159 10178", "2823533 14513", "6301485 12529"},
+	},
+	{
+		name: "postal codes near Berlin", lat: 52.52, lon: 13.405, postal: true,
+		opts: geocoder.QueryOptions{Limit: 3},
+		want: []string{"10178", "10117", "14513"},
+	},
+	{
+		name: "postal codes near Teltow", lat: 52.45, lon: 13.3, postal: true,
+		opts: geocoder.QueryOptions{Limit: 2, Country: "DE"},
+		want: []string{"14513", "10117"},
+	},
+	{
+		// Every MX postal code is more than degRadius away.
+		name: "places near Cancún", lat: 21.16, lon: -86.85,
+		opts: geocoder.QueryOptions{Limit: 1},
+		want: []string{"3531673 "},
+	},
+	{
+		name: "places across the antimeridian", lat: -16.9, lon: 179.95,
+		opts: geocoder.QueryOptions{Limit: 4},
+		want: []string{"2195950 ", "2196582 ", "2204582 ", "2198477 "},
+	},
+}
+
+// integrationBackend is a database the lookups run against.
+type integrationBackend struct {
+	name       string
+	strategies []string
+	// open returns the URL of an empty database, or skips t.
+	open func(t *testing.T) string
+	// snapshot queries a snapshot of the database instead.
+	snapshot bool
+}
+
+var integrationBackends = []integrationBackend{
+	{
+		name:       "sqlite",
+		strategies: []string{geocoder.StrategyHaversine, geocoder.StrategyMemory},
+		open:       sqliteURL,
+	},
+	{
+		name:       "snapshot",
+		strategies: []string{geocoder.StrategyRTree, geocoder.StrategyHaversine},
+		open:       sqliteURL,
+		snapshot:   true,
+	},
+	{
+		name: "postgres",
+		strategies: []string{
+			geocoder.StrategyEarthdistance, geocoder.StrategyHaversine, geocoder.StrategyMemory,
+		},
+		open: postgresURL("postgres:16-alpine"),
+	},
+	{
+		name: "postgis",
+		strategies: []string{
+			geocoder.StrategyPostGIS, geocoder.StrategyEarthdistance, geocoder.StrategyHaversine,
+		},
+		open: postgresURL("postgis/postgis:16-3.4-alpine"),
+	},
+	{
+		name:       "mysql",
+		strategies: []string{geocoder.StrategyHaversine, geocoder.StrategyMemory},
+		open:       mysqlURL,
+	},
+}
+
+func TestIntegration(t *testing.T) {
+	for _, b := range integrationBackends {
+		t.Run(b.name, func(t *testing.T) {
+			db := seedDatabase(t, b.open(t))
+			if b.snapshot {
+				path := filepath.Join(t.TempDir(), "snapshot.db")
+				if err := buildSnapshot(db, path, snapshotOptions{}); err != nil {
+					t.Fatalf("building the snapshot: %v", err)
+				}
+				db = openTestDB(t, "sqlite://"+path)
+			}
+			if err := geocoder.CheckSchema(db); err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range b.strategies {
+				t.Run(s, func(t *testing.T) {
+					if err := geocoder.CheckStrategy(db, s); err != nil {
+						t.Fatal(err)
+					}
+					g := geocoder.New(db, geocoder.WithStrategy(s))
+					for _, l := range integrationLookups {
+						t.Run(l.name, func(t *testing.T) {
+							checkLookup(t, g, l)
+						})
+					}
+				})
+			}
+		})
+	}
+}
+
+// TestPartialDatabase checks that lookups and feature annotation skip
+// the postal codes of a SQLite database without them instead of failing.
+func TestPartialDatabase(t *testing.T) {
+	for _, c := range []struct{ problem, sql string }{
+		{"empty", "DELETE FROM postalcodes"},
+		{"missing", "DROP TABLE postalcodes"},
+	} {
+		t.Run(c.problem, func(t *testing.T) {
+			db := seedDatabase(t, sqliteURL(t))
+			if err := db.Exec(c.sql).Error; err != nil {
+				t.Fatal(err)
+			}
+			g := geocoder.New(db)
+			ws := g.DataStatus().Warnings()
+			if len(ws) == 0 || ws[0].Table != "postalcodes" || ws[0].Problem != c.problem {
+				t.Errorf("warnings = %v, want postalcodes %s first", ws, c.problem)
+			}
+			_, err := g.NearestPostal(19.4326, -99.1332, geocoder.QueryOptions{Limit: 1})
+			if !errors.Is(err, geocoder.ErrNoData) || !geocoder.IsNoResult(err) {
+				t.Errorf("NearestPostal error = %v, want ErrNoData", err)
+			}
+			res, err := g.NearestPlaces(19.4326, -99.1332, geocoder.QueryOptions{Limit: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Rows) != 1 || res.Rows[0].Geonameid != 3530597 || res.Rows[0].Postalcode != "" {
+				t.Errorf("NearestPlaces = %+v, want 3530597 with no postal code", res.Rows)
+			}
+			var out bytes.Buffer
+			in := `{"type":"Feature","geometry":{"type":"Point","coordinates":[-99.1332,19.4326]}}`
+			if _, _, err := g.ReverseFeatures(strings.NewReader(in), &out, geocoder.QueryOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(out.String(), `"geo_postalcode":null`) ||
+				!strings.Contains(out.String(), `"geo_country":"MX"`) {
+				t.Errorf("ReverseFeatures wrote %s, want MX with a null postal code", out.String())
+			}
+		})
+	}
+}
+
+// TestCoverage checks the countries the geocoder finds loaded, read from
+// the tables and then probed for the countries of countryinfo, and that
+// lookups in other countries fail before querying.
+func TestCoverage(t *testing.T) {
+	db := seedDatabase(t, sqliteURL(t))
+	check := func(g *geocoder.Geocoder, places, postal, noPostal string) {
+		t.Helper()
+		cov, err := g.Coverage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range []struct {
+			name string
+			got  []string
+			want string
+		}{
+			{"Places", cov.Places, places}, {"Postal", cov.Postal, postal}, {"NoPostal", cov.NoPostal, noPostal},
+		} {
+			if strings.Join(c.got, ",") != c.want {
+				t.Errorf("%s = %v, want %s", c.name, c.got, c.want)
+			}
+		}
+	}
+	check(geocoder.New(db), "DE,FJ,MX", "DE,MX", "FJ")
+
+	err := db.Exec(`INSERT INTO countryinfo (iso_alpha2, country, postal)
+		VALUES ('MX', 'Mexico', '#####'), ('FJ', 'Fiji', ''), ('FR', 'France', '#####')`).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	geocoder.Release(db)
+	g := geocoder.New(db)
+	check(g, "FJ,MX", "MX", "FJ")
+
+	_, err = g.NearestPostal(-18.14, 178.44, geocoder.QueryOptions{Limit: 1, Country: "FJ"})
+	if nc := (*geocoder.CountryNotCoveredError)(nil); !errors.As(err, &nc) || !nc.NoPostalSystem {
+		t.Errorf("NearestPostal in FJ: error = %v, want not covered with no postal system", err)
+	}
+	_, err = g.NearestPlaces(48.86, 2.35, geocoder.QueryOptions{Limit: 1, Country: "FR"})
+	if !errors.Is(err, geocoder.ErrCountryNotCovered) {
+		t.Errorf("NearestPlaces in FR: error = %v, want ErrCountryNotCovered", err)
+	}
+	res, err := g.NearestPlaces(-18.14, 178.44, geocoder.QueryOptions{Limit: 1, Country: "FJ"})
+	if err != nil || len(res.Rows) != 1 || res.Rows[0].Postalcode != "" {
+		t.Errorf("NearestPlaces in FJ = %+v, %v, want one place without postal code", res.Rows, err)
+	}
+	// DE is not in countryinfo: its lookups run.
+	if _, err := g.NearestPostal(52.52, 13.40, geocoder.QueryOptions{Limit: 1, Country: "DE"}); err != nil {
+		t.Errorf("NearestPostal in DE: %v", err)
+	}
+}
+
+// TestJobs runs a job whose points the server fetches, checks the signed
+// callback, that the results need the job's token, and that the job
+// survives reopening the store.
+func TestJobs(t *testing.T) {
+	db := seedDatabase(t, sqliteURL(t))
+	points := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "id,lat,lon\nmx,19.4326,-99.1332\nde,52.52,13.40\n")
+	}))
+	defer points.Close()
+	type delivery struct {
+		body      []byte
+		signature string
+	}
+	hooks := make(chan delivery, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		hooks <- delivery{body, r.Header.Get("X-Geonames-Signature")}
+	}))
+	defer hook.Close()
+
+	cfg := serverConfig{Jobs: jobsConfig{
+		Store:        filepath.Join(t.TempDir(), "jobs.db"),
+		AllowedHosts: []string{"127.0.0.1"}, WebhookSecret: "s3cret",
+	}}.withDefaults()
+	ctx, cancel := context.WithCancel(context.Background())
+	s, err := newServer(ctx, db, cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.jobs, err = openJobs(cfg.Jobs); err != nil {
+		t.Fatal(err)
+	}
+	s.jobs.start(ctx, func() (*server, func()) { return s, func() {} })
+	api := httptest.NewServer(s.routes())
+	defer api.Close()
+
+	// Without 127.0.0.1 among the allowed hosts, the server refuses to
+	// reach it, when submitting and when dialling a name resolving to it.
+	open := jobsConfig{AllowURLs: true}
+	if err := open.checkJobURL(hook.URL); err == nil {
+		t.Errorf("checkJobURL(%s) with no allowed hosts: no error", hook.URL)
+	}
+	localhost := strings.Replace(points.URL, "127.0.0.1", "localhost", 1)
+	if _, err := jobClient(open, time.Second).Get(localhost); err == nil ||
+		!strings.Contains(err.Error(), "not a public address") {
+		t.Errorf("GET %s with no allowed hosts: %v, want not a public address", localhost, err)
+	}
+
+	resp, err := http.Post(api.URL+"/jobs?results=1&callback="+url.QueryEscape(hook.URL),
+		"application/json", strings.NewReader(`{"url": "`+points.URL+`"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var submitted struct{ Token string }
+	err = json.NewDecoder(resp.Body).Decode(&submitted)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted || err != nil || submitted.Token == "" {
+		t.Fatalf("POST /jobs: %s, %v, token %q", resp.Status, err, submitted.Token)
+	}
+
+	var d delivery
+	select {
+	case d = <-hooks:
+	case <-time.After(30 * time.Second):
+		t.Fatal("no callback")
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(d.body)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); d.signature != want {
+		t.Errorf("signature = %q, want %q", d.signature, want)
+	}
+	var j job
+	if err := json.Unmarshal(d.body, &j); err != nil {
+		t.Fatal(err)
+	}
+	if j.Status != jobDone || j.Points != 2 || j.Processed != 2 {
+		t.Errorf("callback = %s, want done with 2 of 2 points", d.body)
+	}
+
+	for _, path := range []string{"/jobs/" + j.ID, "/jobs/" + j.ID + "?token=" + strings.Repeat("0", 32)} {
+		resp, err = http.Get(api.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("GET %s: %s, want 404", path, resp.Status)
+		}
+	}
+	resp, err = http.Get(api.URL + "/jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if strings.TrimSpace(string(body)) != `{"jobs":[]}` {
+		t.Errorf("anonymous GET /jobs = %s, want no jobs", body)
+	}
+
+	resp, err = http.Get(api.URL + "/jobs/" + j.ID + "/results?format=ndjson&token=" + submitted.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var res jobResult
+		if err := dec.Decode(&res); err != nil {
+			break
+		}
+		if len(res.Places) != 1 {
+			t.Fatalf("result %d has %d places, want 1", res.Index, len(res.Places))
+		}
+		got = append(got, fmt.Sprintf("%s %d", res.ID, res.Places[0].Geonameid))
+	}
+	resp.Body.Close()
+	if want := `"mx" 3530597,"de" 2950159`; strings.Join(got, ",") != want {
+		t.Errorf("results = %v, want %s", got, want)
+	}
+
+	cancel()
+	if err := s.jobs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	m, err := openJobs(cfg.Jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if r, err := m.get(j.ID); err != nil || r == nil || r.Status != jobDone || !r.Webhook.Delivered {
+		t.Errorf("reopened job = %+v, %v, want done and delivered", r, err)
+	}
+}
+
+// checkLookup runs l with g and compares the rows with l.want.
+func checkLookup(t *testing.T, g *geocoder.Geocoder, l integrationLookup) {
+	var got []string
+	var dists []float64
+	var coords [][2]float64
+	if l.postal {
+		res, err := g.NearestPostal(l.lat, l.lon, l.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range res.Rows {
+			got = append(got, r.Postalcode)
+			dists = append(dists, r.DistanceKm)
+			coords = append(coords, [2]float64{r.Latitude, r.Longitude})
+		}
+	} else {
+		res, err := g.NearestPlaces(l.lat, l.lon, l.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range res.Rows {
+			got = append(got, fmt.Sprintf("%d %s", r.Geonameid, r.Postalcode))
+			dists = append(dists, r.DistanceKm)
+			coords = append(coords, [2]float64{r.Latitude, r.Longitude})
+		}
+	}
+	if fmt.Sprint(got) != fmt.Sprint(l.want) {
+		t.Fatalf("rows = %q, want %q", got, l.want)
+	}
+	for i, c := range coords {
+		want, ok := geocoder.VincentyKm(l.lat, l.lon, c[0], c[1])
+		if !ok {
+			continue
+		}
+		if math.Abs(dists[i]-want) > 0.01*want {
+			t.Errorf("%s: distance %.3f km, want %.3f km", got[i], dists[i], want)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Fixtures
+// ---------------------------------------------------------------------------
+
+// seedDatabase loads the sample data into the empty database at rawURL
+// the way "load" does: schema, data files, indexes, schema version.
+func seedDatabase(t *testing.T, rawURL string) *gorm.DB {
+	t.Helper()
+	db := openTestDB(t, rawURL)
+	if err := runMigration(db, "schema.sql"); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []dataFile{
+		{Path: "testdata/integration/geoname.txt", Table: "geoname"},
+		{Path: "testdata/integration/postalcodes.txt", Table: "postalcodes", Derive: postalDeriver()},
+	} {
+		if _, err := loadFile(db, f, false, nil); err != nil {
+			t.Fatalf("loading %s: %v", f.Path, err)
+		}
+	}
+	if err := runMigration(db, "indexes.sql"); err != nil {
+		t.Fatal(err)
+	}
+	if geocoder.IsPostgres(db) {
+		createSpatialIndexes(db)
+	}
+	if err := setSchemaVersion(db, geocoder.SchemaVersion); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// openTestDB opens rawURL like the commands do, closing it at the end of
+// the test.
+func openTestDB(t *testing.T, rawURL string) *gorm.DB {
+	t.Helper()
+	db, err := openDB(new(Config), rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { closeDB(db) })
+	return db
+}
+
+// sqliteURL returns the URL of an empty SQLite database, or skips t when
+// SQLite was built without its math functions.
+func sqliteURL(t *testing.T) string {
+	t.Helper()
+	if err := sqliteMath(); err != nil {
+		t.Skipf("SQLite lacks the math functions the haversine strategy needs (%v): "+
+			"run with -tags integration,sqlite_math_functions", err)
+	}
+	return "sqlite://" + filepath.Join(t.TempDir(), "geonames.db")
+}
+
+// sqliteMath reports whether SQLite has SQRT and the other math
+// functions, which go-sqlite3 compiles in only with the
+// sqlite_math_functions tag.
+var sqliteMath = sync.OnceValue(func() error {
+	db, err := openDB(new(Config), "sqlite://:memory:")
+	if err != nil {
+		return err
+	}
+	defer closeDB(db)
+	var v float64
+	return db.Raw("SELECT SQRT(4.0)").Scan(&v).Error
+})
+
+// postgresURL returns the open function of a PostgreSQL container of
+// image.
+func postgresURL(image string) func(t *testing.T) string {
+	return func(t *testing.T) string {
+		testcontainers.SkipIfProviderIsNotHealthy(t)
+		ctx := context.Background()
+		ctr, err := postgres.Run(ctx, image,
+			postgres.WithDatabase("geonames"),
+			postgres.WithUsername("geonames"),
+			postgres.WithPassword("geonames"),
+			postgres.BasicWaitStrategies(),
+		)
+		testcontainers.CleanupContainer(t, ctr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dsn, err := ctr.ConnectionString(ctx, "sslmode=disable")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dsn
+	}
+}
+
+func mysqlURL(t *testing.T) string {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+	ctx := context.Background()
+	ctr, err := mysql.Run(ctx, "mysql:8.4",
+		mysql.WithDatabase("geonames"),
+		mysql.WithUsername("geonames"),
+		mysql.WithPassword("geonames"),
+	)
+	testcontainers.CleanupContainer(t, ctr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, err := ctr.Host(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := ctr.MappedPort(ctx, "3306/tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprintf("mysql://geonames:geonames@%s:%s/geonames", host, port.Port())
+}
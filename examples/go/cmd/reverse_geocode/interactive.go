@@ -0,0 +1,277 @@
+package main
+
+/*
+	Interactive mode (--interactive).
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    go run . --interactive
+	    go run . --interactive --url sqlite:///tmp/mx.db --results 5 --country MX
+	    go run . --interactive --format json < queries.txt
+
+	The database is opened, its schema checked and its strategy detected
+	once; then each line typed at the prompt is answered over the same
+	connection until :quit or end of input (Ctrl-D). A line is one of
+
+	    19.4326, -99.1332        coordinates: lat,lon or lat lon
+	    POINT(-99.1332 19.4326)  any point --point accepts
+	    3530597                  a geonameid: reverse geocodes that place
+	    guadalajara              a place name: lists the matching places,
+	                             whose geonameids can be typed next
+
+	or a command:
+
+	    :set results 5           change a setting: results, country
+	                             (- for any), units, format
+	    :show                    print the settings
+	    :help                    list the commands
+	    :quit                    leave
+
+	The other flags (--no-postal, --geodesic, --poi, --strategy, ...) apply
+	to every lookup. Lines are edited with the usual readline keys, and the
+	history is kept across sessions in ~/.reverse_geocode_history. When
+	standard input is not a terminal there is no prompt, so a file of
+	queries can be piped in; an error is printed and the next line read.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/chzyer/readline"
+	"github.com/rgglez/geonames-loader/examples/go/pkg/geocoder"
+)
+
+// interactiveHistory is the history file, in the home directory.
+const interactiveHistory = ".reverse_geocode_history"
+
+// interactiveHelp is printed by :help.
+const interactiveHelp = `Type coordinates (19.4326, -99.1332), a point in any --point format,
+a geonameid (3530597) or a place name (guadalajara), or a command:
+
+  :set results N     number of results
+  :set country CC    restrict to a country (- for any)
+  :set units U       km, mi or nmi
+  :set format F      text, json, geojson or csv
+  :show              print the settings
+  :help              this list
+  :quit              leave (also Ctrl-D)
+`
+
+// session is the state of an interactive run.
+type session struct {
+	geo    *geocoder.Geocoder
+	lookup lookupOptions
+	format string
+	render renderer
+	out    io.Writer
+}
+
+// runInteractive answers the lines read from a prompt with geo until end
+// of input or :quit.
+func runInteractive(geo *geocoder.Geocoder, lo lookupOptions, format, tmpl string) error {
+	render, err := newRenderer(format, tmpl)
+	if err != nil {
+		return err
+	}
+	s := &session{geo: geo, lookup: lo, format: format, render: render, out: os.Stdout}
+	if tmpl != "" {
+		s.format = "template"
+	}
+
+	cfg := &readline.Config{
+		Prompt:            "geonames> ",
+		HistorySearchFold: true,
+		InterruptPrompt:   "^C",
+		EOFPrompt:         ":quit",
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		cfg.HistoryFile = filepath.Join(home, interactiveHistory)
+	}
+	rl, err := readline.NewEx(cfg)
+	if err != nil {
+		return err
+	}
+	defer rl.Close()
+	if readline.DefaultIsTerminal() {
+		fmt.Fprintf(s.out, "Connected; strategy %s. Type :help for the commands.\n", lo.Strategy)
+	}
+
+	for {
+		line, err := rl.Readline()
+		switch {
+		case errors.Is(err, readline.ErrInterrupt):
+			continue // Ctrl-C discards the line
+		case errors.Is(err, io.EOF):
+			return nil
+		case err != nil:
+			return err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		quit, err := s.handle(line)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "ERROR:", err)
+		}
+		if quit {
+			return nil
+		}
+	}
+}
+
+// handle answers one input line, reporting whether it asked to quit.
+func (s *session) handle(line string) (quit bool, err error) {
+	if cmd, ok := strings.CutPrefix(line, ":"); ok {
+		return s.command(strings.Fields(cmd))
+	}
+	if id, err := strconv.ParseInt(line, 10, 64); err == nil {
+		return false, s.place(id)
+	}
+	p, err := parseInputPoint(line)
+	if err == nil {
+		return false, s.reverse(p.Lat, p.Lon)
+	}
+	if !strings.ContainsFunc(line, unicode.IsLetter) {
+		return false, err // a mistyped point rather than a name
+	}
+	return false, s.search(line)
+}
+
+// parseInputPoint parses line as a point, also accepting "lat lon".
+func parseInputPoint(line string) (geocoder.Point, error) {
+	p, err := geocoder.ParsePoint(line)
+	if f := strings.Fields(line); err != nil && len(f) == 2 {
+		if p, err2 := geocoder.ParsePoint(f[0] + "," + f[1]); err2 == nil {
+			return p, nil
+		}
+	}
+	return p, err
+}
+
+// reverse prints the lookup of (lat, lon).
+func (s *session) reverse(lat, lon float64) error {
+	out, err := reverseLookup(s.geo, lat, lon, s.lookup)
+	if err != nil {
+		return err
+	}
+	return s.render.Render(s.out, out)
+}
+
+// place prints the lookup of the coordinates of the place geonameid.
+func (s *session) place(geonameid int64) error {
+	p, err := geocoder.PlaceByID(s.geo.DB(), geonameid)
+	if err != nil {
+		return err
+	}
+	if s.format == "text" {
+		fmt.Fprintf(s.out, "%s, %s (%s/%s)\n", p.Name, p.Country, p.Fclass, p.Fcode)
+	}
+	return s.reverse(p.Latitude, p.Longitude)
+}
+
+// search lists the places named like name, most populous first.
+func (s *session) search(name string) error {
+	rows, err := geocoder.SearchPlaces(s.geo.DB(), geocoder.SearchOptions{
+		Name:    name,
+		Country: s.lookup.Query.Country,
+		Limit:   max(10, s.lookup.Query.Limit),
+	})
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		fmt.Fprintf(s.out, "No place named like %q.\n", name)
+		return nil
+	}
+	for _, r := range rows {
+		fmt.Fprintf(s.out, "  %10d  %-30s %-2s  %s/%-5s  pop %-9d  %.5f, %.5f\n",
+			r.Geonameid, r.Name, r.Country, r.Fclass, r.Fcode, r.Population,
+			r.Latitude, r.Longitude)
+	}
+	fmt.Fprintln(s.out, "Type a geonameid to look around that place.")
+	return nil
+}
+
+// command runs a ":" command.
+func (s *session) command(args []string) (quit bool, err error) {
+	if len(args) == 0 {
+		return false, errors.New("missing command; type :help")
+	}
+	switch args[0] {
+	case "quit", "q", "exit":
+		return true, nil
+	case "help", "h", "?":
+		fmt.Fprint(s.out, interactiveHelp)
+	case "show":
+		q := s.lookup.Query
+		country := q.Country
+		if country == "" {
+			country = "-"
+		}
+		fmt.Fprintf(s.out, "results   %d\ncountry   %s\nunits     %s\nformat    %s\nstrategy  %s\n",
+			q.Limit, country, s.lookup.Units, s.format, s.lookup.Strategy)
+	case "set":
+		if len(args) != 3 {
+			return false, errors.New("usage: :set results|country|units|format VALUE")
+		}
+		return false, s.set(args[1], args[2])
+	default:
+		return false, fmt.Errorf("unknown command :%s; type :help", args[0])
+	}
+	return false, nil
+}
+
+// set changes one setting.
+func (s *session) set(name, value string) error {
+	switch name {
+	case "results":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return fmt.Errorf("results: %q is not a positive number", value)
+		}
+		s.lookup.Query.Limit = n
+	case "country":
+		if value == "-" {
+			value = ""
+		}
+		s.lookup.Query.Country = strings.ToUpper(value)
+	case "units":
+		if err := checkUnits(value); err != nil {
+			return fmt.Errorf("units: %w", err)
+		}
+		s.lookup.Units = value
+	case "format":
+		render, err := newRenderer(value, "")
+		if err != nil {
+			return fmt.Errorf("format: %w", err)
+		}
+		s.format, s.render = value, render
+	default:
+		return fmt.Errorf("unknown setting %q (results, country, units or format)", name)
+	}
+	return nil
+}
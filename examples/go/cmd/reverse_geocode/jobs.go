@@ -0,0 +1,1466 @@
+package main
+
+/*
+	Asynchronous batch jobs of the server: POST /jobs and friends.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    go run . serve --jobs /var/lib/geonames/jobs.db
+	    curl -X POST -H 'Content-Type: text/csv' --data-binary @points.csv \
+	        'http://localhost:8080/jobs?results=1&country=MX&callback=https://example.com/hook'
+	    curl -X POST -H 'Content-Type: application/json' \
+	        -d '{"points": [{"id": "a1", "lat": 19.4326, "lon": -99.1332}], "results": 3}' \
+	        http://localhost:8080/jobs
+	    curl -H 'X-Geonames-Job-Token: 9b2e7f4a1c8d03e6b5a7f9c2d4e1b806' \
+	        http://localhost:8080/jobs/6f1c0d9e2b7a4e58a3c1f0b2d4e6a8c0
+	    curl 'http://localhost:8080/jobs/6f1c0d9e2b7a4e58a3c1f0b2d4e6a8c0/results?format=csv&token=9b2e7f4a1c8d03e6b5a7f9c2d4e1b806'
+
+	Config (server section):
+	    jobs:
+	      store: /var/lib/geonames/jobs.db
+	      workers: 1
+	      max_points: 1000000
+	      max_jobs: 10000
+	      max_pending: 4
+	      retention: 168h
+	      allow_urls: false
+	      allowed_hosts: [hooks.example.com]
+	      webhook_secret: s3cret
+
+	Endpoints:
+	    POST /jobs                  submit a job; 202 with its status and a
+	                                Location header
+	    GET /jobs                   the caller's jobs; every job for admin keys
+	    GET /jobs/{id}              the status of a job
+	    GET /jobs/{id}/results[?format=json|ndjson|csv]
+	                                the results so far
+	    DELETE /jobs/{id}           cancel a job and drop its results
+
+	A job reverse geocodes more points than a request should wait for: up
+	to max_points, read from the body of POST /jobs — a JSON object with a
+	"points" list of {"id", "lat", "lon"}, lat and lon required, or CSV
+	(Content-Type text/csv) with lat/latitude and lon/lng/longitude
+	columns and an optional id column, or with no header the latitude
+	and longitude first — or fetched by the server from the CSV at
+	"url". The results, country, min_population, confidence and callback
+	options are taken from the query string or from the JSON object.
+	Each point gets its nearest places, with their nearest postal code,
+	in the order given; the id, any JSON value, is passed through.
+
+	The jobs, their points and their results are kept in a bbolt file
+	(store), which makes them survive restarts: a job is processed in
+	chunks of jobChunk points by one of the workers, and each chunk's
+	results are saved with the job's progress in one transaction, so a job
+	interrupted by a shutdown resumes at its first unsaved chunk on the
+	next start. The lookups share the query queue with the requests (see
+	limits.go) and wait out full queues and an open circuit breaker; any
+	other error fails the job.
+
+	A finished job (done or failed) is POSTed as its status to its
+	callback, an http or https URL, with X-Geonames-Signature set to
+	sha256=HMAC-SHA256(webhook_secret, body) in hex when a secret is
+	configured. Deliveries that fail or get no 2xx answer are retried
+	with backoff, webhookAttempts times in all, also after a restart.
+	Each attempt is cut off after webhookTimeout, and the fetch of a CSV
+	after jobFetchTimeout, which fails its job. Clients without a
+	callback poll GET /jobs/{id}. Finished jobs are deleted retention
+	after they finish.
+
+	Since a URL or callback makes the server send a request wherever the
+	client says, both are refused unless allow_urls or allowed_hosts is
+	set. With allowed_hosts, they may only name those hosts, on any
+	address; otherwise the server connects to public addresses only —
+	checked when dialling, after DNS and at every redirect — so a job
+	cannot reach the loopback, private or link-local networks of the
+	server.
+
+	With authentication, a job belongs to the key that submitted it and
+	only that key and admin keys see it. An anonymous job belongs to a
+	random token instead, returned once as "token" by POST /jobs and
+	required, in X-Geonames-Job-Token or ?token=, to read or delete the
+	job; the store keeps only its SHA-256. GET /jobs lists no jobs to
+	anonymous callers.
+
+	The store keeps at most max_jobs jobs, finished ones included; a job
+	submitted to a full store is refused with 503 until old jobs expire
+	or are deleted. A client — the API key, or the client address for
+	anonymous jobs — may have at most max_pending jobs queued or running,
+	and is refused with 429 beyond that.
+
+	A job counts one request per point against the key's quota, charged
+	on submission: a job with more points than the key has left in its
+	quota window is refused with 429, or 413 when it exceeds the whole
+	quota. A job with a URL needs one request left to be accepted and is
+	charged once its CSV is read, failing when the points do not fit.
+
+	The jobs section is read at start-up only: a reload keeps the store
+	and the workers running.
+*/
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rgglez/geonames-loader/examples/go/pkg/geocoder"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// jobChunk is the number of points looked up and saved at once.
+	jobChunk = 500
+	// jobMaxBody caps the body of POST /jobs and a fetched CSV.
+	jobMaxBody = 64 << 20
+	// jobSweep is how often expired jobs are deleted.
+	jobSweep = time.Hour
+	// jobRetry is how long a worker waits for a query slot or a closed
+	// circuit before trying a chunk again.
+	jobRetry = time.Second
+	// jobFetchTimeout caps the fetch of a job's CSV, body included.
+	jobFetchTimeout = 5 * time.Minute
+	// webhookAttempts is how many times a callback is tried, the n-th
+	// retry webhookBackoff << (n-1) after the one before.
+	webhookAttempts = 8
+	webhookBackoff  = 10 * time.Second
+	// webhookTimeout caps one callback attempt.
+	webhookTimeout = 10 * time.Second
+)
+
+// The states of a job.
+const (
+	jobQueued  = "queued"
+	jobRunning = "running"
+	jobDone    = "done"
+	jobFailed  = "failed"
+)
+
+var (
+	jobsBucket    = []byte("jobs")
+	pointsBucket  = []byte("points")
+	resultsBucket = []byte("results")
+)
+
+// errJobDeleted stops the processing of a job deleted meanwhile.
+var errJobDeleted = errors.New("job deleted")
+
+// Errors of submit when a limit of jobsConfig is reached.
+var (
+	errJobStoreFull   = errors.New("the job store is full")
+	errTooManyPending = errors.New("too many unfinished jobs")
+)
+
+// jobsConfig is the "jobs" part of the server section.
+type jobsConfig struct {
+	// Store is the bbolt file of the jobs; empty disables them.
+	Store string `yaml:"store"`
+	// Workers is the number of jobs processed at once, 1 when unset.
+	Workers int `yaml:"workers"`
+	// MaxPoints caps the points of a job, 1,000,000 when unset.
+	MaxPoints int `yaml:"max_points"`
+	// MaxJobs caps the jobs kept in the store, 10,000 when unset.
+	MaxJobs int `yaml:"max_jobs"`
+	// MaxPending caps the queued and running jobs of one client (API key
+	// or address), 4 when unset.
+	MaxPending int `yaml:"max_pending"`
+	// Retention is how long finished jobs are kept, 7 days when unset.
+	Retention time.Duration `yaml:"retention"`
+	// AllowURLs lets jobs name URLs for the server to request: a CSV to
+	// fetch and a callback. Addresses that are not public are refused.
+	AllowURLs bool `yaml:"allow_urls"`
+	// AllowedHosts, when set, are the only hosts those URLs may name, and
+	// also lets jobs name them without AllowURLs. They may be private.
+	AllowedHosts []string `yaml:"allowed_hosts"`
+	// WebhookSecret signs the callbacks.
+	WebhookSecret string `yaml:"webhook_secret"`
+}
+
+// withDefaults fills in unset fields.
+func (c jobsConfig) withDefaults() jobsConfig {
+	if c.Workers <= 0 {
+		c.Workers = 1
+	}
+	if c.MaxPoints <= 0 {
+		c.MaxPoints = 1_000_000
+	}
+	if c.MaxJobs <= 0 {
+		c.MaxJobs = 10_000
+	}
+	if c.MaxPending <= 0 {
+		c.MaxPending = 4
+	}
+	if c.Retention <= 0 {
+		c.Retention = 7 * 24 * time.Hour
+	}
+	return c
+}
+
+// ---------------------------------------------------------------------------
+// Jobs
+// ---------------------------------------------------------------------------
+
+// jobOptions are the lookup options of a job.
+type jobOptions struct {
+	Results       int    `json:"results"`
+	Country       string `json:"country,omitempty"`
+	MinPopulation int64  `json:"min_population,omitempty"`
+	Confidence    bool   `json:"confidence,omitempty"`
+}
+
+// job is the record of a job in the store.
+type job struct {
+	ID    string `json:"id"`
+	Owner string `json:"owner,omitempty"`
+	// TokenHash is the SHA-256, in hex, of the token of an anonymous job.
+	TokenHash string `json:"token_hash,omitempty"`
+	// Client is the submitter as the rate limiter keys it (see
+	// server.clientKey), counted against MaxPending.
+	Client string `json:"client,omitempty"`
+	Status string `json:"status"`
+	// Points is 0 until the CSV of URL has been read.
+	Points    int        `json:"points"`
+	Processed int        `json:"processed"`
+	Options   jobOptions `json:"options"`
+	URL       string     `json:"url,omitempty"`
+	Callback  string     `json:"callback,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	Created   time.Time  `json:"created"`
+	Started   *time.Time `json:"started,omitempty"`
+	Finished  *time.Time `json:"finished,omitempty"`
+	Expires   *time.Time `json:"expires,omitempty"`
+	// Webhook is the delivery of the callback, once the job finished.
+	Webhook *webhookState `json:"webhook,omitempty"`
+}
+
+type webhookState struct {
+	Delivered bool   `json:"delivered"`
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// jobView is a job as the endpoints and callbacks show it.
+type jobView struct {
+	*job
+	// Results is the path of the job's results.
+	Results string `json:"results"`
+	// Token is the token of an anonymous job, in the answer to POST /jobs
+	// only.
+	Token string `json:"token,omitempty"`
+}
+
+func (j *job) view() jobView {
+	c := *j
+	c.TokenHash, c.Client = "", ""
+	return jobView{job: &c, Results: "/jobs/" + j.ID + "/results"}
+}
+
+// hashJobToken returns the SHA-256 of an anonymous job's token in hex.
+func hashJobToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// jobPoint is a point of a job.
+type jobPoint struct {
+	ID  json.RawMessage `json:"id,omitempty"`
+	Lat float64         `json:"lat"`
+	Lon float64         `json:"lon"`
+}
+
+// UnmarshalJSON reads a point, which must have both lat and lon: a
+// missing coordinate would otherwise read as 0.
+func (p *jobPoint) UnmarshalJSON(data []byte) error {
+	var v struct {
+		ID  json.RawMessage `json:"id"`
+		Lat *float64        `json:"lat"`
+		Lon *float64        `json:"lon"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Lat == nil || v.Lon == nil {
+		return fmt.Errorf("point %s: lat and lon are required", data)
+	}
+	*p = jobPoint{ID: v.ID, Lat: *v.Lat, Lon: *v.Lon}
+	return nil
+}
+
+// jobResult is the answer for one point.
+type jobResult struct {
+	Index     int              `json:"index"`
+	ID        json.RawMessage  `json:"id,omitempty"`
+	Latitude  float64          `json:"latitude"`
+	Longitude float64          `json:"longitude"`
+	Places    []geocoder.Place `json:"places"`
+}
+
+// chunkKey is the key of the n-th chunk of points and results.
+func chunkKey(n int) []byte {
+	return binary.BigEndian.AppendUint32(nil, uint32(n))
+}
+
+// ---------------------------------------------------------------------------
+// Store and workers
+// ---------------------------------------------------------------------------
+
+// jobManager keeps the jobs and runs their workers.
+type jobManager struct {
+	cfg jobsConfig
+	db  *bolt.DB
+	// fetcher reads the CSVs of jobs and client posts the callbacks.
+	fetcher, client *http.Client
+	// wake tells an idle worker that a job was queued.
+	wake chan struct{}
+	wg   sync.WaitGroup
+}
+
+// openJobs opens or creates the store of cfg and queues again the jobs
+// that were running when the server stopped.
+func openJobs(cfg jobsConfig) (*jobManager, error) {
+	db, err := bolt.Open(cfg.Store, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening job store %s: %w", cfg.Store, err)
+	}
+	requeued := 0
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{jobsBucket, pointsBucket, resultsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return forEachJob(tx, func(j *job) error {
+			if j.Status != jobRunning {
+				return nil
+			}
+			j.Status = jobQueued
+			requeued++
+			return putJob(tx, j)
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("opening job store %s: %w", cfg.Store, err)
+	}
+	if requeued > 0 {
+		log.Printf("jobs: resuming %d interrupted jobs", requeued)
+	}
+	return &jobManager{
+		cfg:     cfg,
+		db:      db,
+		fetcher: jobClient(cfg, jobFetchTimeout),
+		client:  jobClient(cfg, webhookTimeout),
+		wake:    make(chan struct{}, 1),
+	}, nil
+}
+
+// start runs the workers, the sweeper of expired jobs and the pending
+// callbacks until ctx is done. acquire returns the server to look up
+// with, and the function to call once done with it.
+func (m *jobManager) start(ctx context.Context, acquire func() (*server, func())) {
+	for range m.cfg.Workers {
+		m.wg.Add(1)
+		go m.work(ctx, acquire)
+	}
+	m.wg.Add(1)
+	go m.sweep(ctx)
+
+	var pending []string
+	err := m.db.View(func(tx *bolt.Tx) error {
+		return forEachJob(tx, func(j *job) error {
+			if j.Webhook != nil && !j.Webhook.Delivered && j.Webhook.Attempts < webhookAttempts {
+				pending = append(pending, j.ID)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		log.Printf("jobs: %v", err)
+	}
+	for _, id := range pending {
+		m.wg.Add(1)
+		go m.deliver(ctx, id)
+	}
+}
+
+// Close waits for the workers and deliveries, which stop once the context
+// of start is done, and closes the store.
+func (m *jobManager) Close() error {
+	m.wg.Wait()
+	return m.db.Close()
+}
+
+// notify wakes an idle worker.
+func (m *jobManager) notify() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// work processes the queued jobs, oldest first.
+func (m *jobManager) work(ctx context.Context, acquire func() (*server, func())) {
+	defer m.wg.Done()
+	for ctx.Err() == nil {
+		j, err := m.claim()
+		if err != nil {
+			log.Printf("jobs: %v", err)
+		}
+		if j == nil {
+			select {
+			case <-ctx.Done():
+			case <-m.wake:
+			}
+			continue
+		}
+		// Another job may be waiting for another worker.
+		m.notify()
+		m.run(ctx, j, acquire)
+	}
+}
+
+// claim marks the oldest queued job running and returns it, or nil when
+// there is none.
+func (m *jobManager) claim() (*job, error) {
+	var next *job
+	err := m.db.Update(func(tx *bolt.Tx) error {
+		err := forEachJob(tx, func(j *job) error {
+			if j.Status == jobQueued && (next == nil || j.Created.Before(next.Created)) {
+				next = j
+			}
+			return nil
+		})
+		if err != nil || next == nil {
+			return err
+		}
+		now := time.Now().UTC()
+		next.Status, next.Started = jobRunning, &now
+		return putJob(tx, next)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+// run processes j and records how it ended. A job interrupted by the
+// shutdown is left running, to be queued again on the next start.
+func (m *jobManager) run(ctx context.Context, j *job, acquire func() (*server, func())) {
+	err := m.process(ctx, j, acquire)
+	switch {
+	case ctx.Err() != nil, errors.Is(err, errJobDeleted):
+		return
+	case err != nil:
+		log.Printf("jobs: %s failed: %v", j.ID, err)
+	}
+	finished, ferr := m.finish(j.ID, err)
+	if ferr != nil {
+		log.Printf("jobs: %s: %v", j.ID, ferr)
+		return
+	}
+	if finished != nil && finished.Webhook != nil {
+		m.wg.Add(1)
+		go m.deliver(ctx, j.ID)
+	}
+}
+
+// process reads the points of a job with a URL, then looks up every chunk
+// not saved yet.
+func (m *jobManager) process(ctx context.Context, j *job, acquire func() (*server, func())) error {
+	if j.URL != "" && j.Points == 0 {
+		points, err := m.fetch(ctx, j.URL)
+		if err != nil {
+			return err
+		}
+		if err := chargePoints(j.Owner, len(points), acquire); err != nil {
+			return err
+		}
+		err = m.db.Update(func(tx *bolt.Tx) error {
+			cur, err := getJob(tx, j.ID)
+			if err != nil {
+				return err
+			}
+			cur.Points = len(points)
+			if err := putPoints(tx, cur.ID, points); err != nil {
+				return err
+			}
+			*j = *cur
+			return putJob(tx, cur)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	opts := geocoder.QueryOptions{
+		Limit: j.Options.Results, Country: j.Options.Country,
+		MinPopulation: j.Options.MinPopulation, Rate: j.Options.Confidence,
+	}
+	for n := j.Processed / jobChunk; n*jobChunk < j.Points; n++ {
+		var points []jobPoint
+		err := m.db.View(func(tx *bolt.Tx) error {
+			b := tx.Bucket(pointsBucket).Bucket([]byte(j.ID))
+			if b == nil {
+				return errJobDeleted
+			}
+			return json.Unmarshal(b.Get(chunkKey(n)), &points)
+		})
+		if err != nil {
+			return err
+		}
+		rows, err := m.lookup(ctx, points, opts, acquire)
+		if err != nil {
+			return err
+		}
+		results := make([]jobResult, len(points))
+		for i, p := range points {
+			results[i] = jobResult{
+				Index: n*jobChunk + i, ID: p.ID,
+				Latitude: p.Lat, Longitude: p.Lon, Places: rows[i],
+			}
+		}
+		value, err := json.Marshal(results)
+		if err != nil {
+			return err
+		}
+		err = m.db.Update(func(tx *bolt.Tx) error {
+			cur, err := getJob(tx, j.ID)
+			if err != nil {
+				return err
+			}
+			b, err := tx.Bucket(resultsBucket).CreateBucketIfNotExists([]byte(j.ID))
+			if err != nil {
+				return err
+			}
+			if err := b.Put(chunkKey(n), value); err != nil {
+				return err
+			}
+			cur.Processed = n*jobChunk + len(points)
+			return putJob(tx, cur)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookup returns the nearest places of points, waiting for a query slot
+// and for the circuit breaker to close as long as it takes.
+func (m *jobManager) lookup(
+	ctx context.Context, points []jobPoint, opts geocoder.QueryOptions,
+	acquire func() (*server, func()),
+) ([][]geocoder.Place, error) {
+	pts := make([]geocoder.Point, len(points))
+	for i, p := range points {
+		pts[i] = geocoder.Point{Lat: p.Lat, Lon: p.Lon}
+	}
+	for {
+		s, done := acquire()
+		release, err := s.queue.acquire(ctx)
+		var res geocoder.Result[[]geocoder.Place]
+		if err == nil {
+			res, err = s.geo.WithContext(ctx).NearestPlacesBatch(pts, opts)
+			release()
+		}
+		done()
+		switch {
+		case err == nil:
+			return res.Rows, nil
+		case errors.Is(err, errQueueFull), errors.Is(err, errQueueTimeout),
+			errors.Is(err, geocoder.ErrBackendUnavailable):
+		default:
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(jobRetry):
+		}
+	}
+}
+
+// fetch reads the points of the CSV at rawURL, within jobFetchTimeout.
+func (m *jobManager) fetch(ctx context.Context, rawURL string) ([]jobPoint, error) {
+	ctx, cancel := context.WithTimeout(ctx, jobFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := m.fetcher.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching the points: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching the points: %s", resp.Status)
+	}
+	points, err := readJobPoints(http.MaxBytesReader(nil, resp.Body, jobMaxBody), m.cfg.MaxPoints)
+	if err != nil {
+		return nil, fmt.Errorf("reading the points: %w", err)
+	}
+	return points, nil
+}
+
+// chargePoints charges the n points read for a job of owner, "" when
+// anonymous, to its key's quota.
+func chargePoints(owner string, n int, acquire func() (*server, func())) error {
+	if owner == "" {
+		return nil
+	}
+	s, done := acquire()
+	defer done()
+	if s.keys == nil {
+		return nil
+	}
+	k := s.keys.named(owner)
+	if k == nil {
+		return fmt.Errorf("key %s no longer exists", owner)
+	}
+	if ok, _ := s.keys.chargeN(k, int64(n), time.Now()); !ok {
+		return fmt.Errorf("quota exceeded: %d points", n)
+	}
+	return nil
+}
+
+// finish records the end of a job, failed when err is not nil. It returns
+// nil when the job was deleted meanwhile.
+func (m *jobManager) finish(id string, err error) (*job, error) {
+	var j *job
+	uerr := m.db.Update(func(tx *bolt.Tx) error {
+		var gerr error
+		if j, gerr = getJob(tx, id); gerr != nil {
+			return nil
+		}
+		now := time.Now().UTC()
+		expires := now.Add(m.cfg.Retention)
+		j.Status, j.Finished, j.Expires = jobDone, &now, &expires
+		if err != nil {
+			j.Status, j.Error = jobFailed, err.Error()
+		}
+		if j.Callback != "" {
+			j.Webhook = &webhookState{}
+		}
+		return putJob(tx, j)
+	})
+	return j, uerr
+}
+
+// sweep deletes the jobs past their expiry, now and every jobSweep.
+func (m *jobManager) sweep(ctx context.Context) {
+	defer m.wg.Done()
+	t := time.NewTicker(jobSweep)
+	defer t.Stop()
+	for {
+		var expired []string
+		err := m.db.Update(func(tx *bolt.Tx) error {
+			now := time.Now()
+			err := forEachJob(tx, func(j *job) error {
+				if j.Expires != nil && j.Expires.Before(now) {
+					expired = append(expired, j.ID)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, id := range expired {
+				if err := deleteJob(tx, id); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("jobs: deleting expired jobs: %v", err)
+		} else if len(expired) > 0 {
+			log.Printf("jobs: deleted %d expired jobs", len(expired))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Callbacks
+// ---------------------------------------------------------------------------
+
+// deliver posts the status of the finished job id to its callback until
+// it is accepted, the attempts run out or ctx is done.
+func (m *jobManager) deliver(ctx context.Context, id string) {
+	defer m.wg.Done()
+	for {
+		j, err := m.get(id)
+		if err != nil {
+			log.Printf("jobs: %s: %v", id, err)
+			return
+		}
+		if j == nil || j.Webhook == nil || j.Webhook.Delivered || j.Webhook.Attempts >= webhookAttempts {
+			return
+		}
+		if n := j.Webhook.Attempts; n > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(webhookBackoff << (n - 1)):
+			}
+		}
+		err = m.post(ctx, j)
+		if ctx.Err() != nil {
+			return
+		}
+		uerr := m.db.Update(func(tx *bolt.Tx) error {
+			cur, gerr := getJob(tx, id)
+			if gerr != nil || cur.Webhook == nil {
+				return nil
+			}
+			cur.Webhook.Attempts++
+			cur.Webhook.Delivered = err == nil
+			cur.Webhook.LastError = ""
+			if err != nil {
+				cur.Webhook.LastError = err.Error()
+			}
+			return putJob(tx, cur)
+		})
+		if uerr != nil {
+			log.Printf("jobs: %s: %v", id, uerr)
+			return
+		}
+		if err != nil {
+			log.Printf("jobs: %s: callback attempt %d: %v", id, j.Webhook.Attempts+1, err)
+		}
+	}
+}
+
+// post sends the status of j, without the state of its delivery, to its
+// callback, within webhookTimeout.
+func (m *jobManager) post(ctx context.Context, j *job) error {
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	c := *j
+	c.Webhook = nil
+	body, err := json.Marshal(c.view())
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, j.Callback, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Geonames-Job", j.ID)
+	if m.cfg.WebhookSecret != "" {
+		mac := hmac.New(sha256.New, []byte(m.cfg.WebhookSecret))
+		mac.Write(body)
+		req.Header.Set("X-Geonames-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("callback answered %s", resp.Status)
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Store helpers
+// ---------------------------------------------------------------------------
+
+func getJob(tx *bolt.Tx, id string) (*job, error) {
+	v := tx.Bucket(jobsBucket).Get([]byte(id))
+	if v == nil {
+		return nil, errJobDeleted
+	}
+	j := &job{}
+	if err := json.Unmarshal(v, j); err != nil {
+		return nil, fmt.Errorf("job %s: %w", id, err)
+	}
+	return j, nil
+}
+
+func putJob(tx *bolt.Tx, j *job) error {
+	v, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(jobsBucket).Put([]byte(j.ID), v)
+}
+
+// putPoints saves the points of job id in chunks of jobChunk.
+func putPoints(tx *bolt.Tx, id string, points []jobPoint) error {
+	b, err := tx.Bucket(pointsBucket).CreateBucketIfNotExists([]byte(id))
+	if err != nil {
+		return err
+	}
+	for n := 0; n*jobChunk < len(points); n++ {
+		v, err := json.Marshal(points[n*jobChunk : min(len(points), (n+1)*jobChunk)])
+		if err != nil {
+			return err
+		}
+		if err := b.Put(chunkKey(n), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteJob removes job id with its points and results.
+func deleteJob(tx *bolt.Tx, id string) error {
+	for _, name := range [][]byte{pointsBucket, resultsBucket} {
+		err := tx.Bucket(name).DeleteBucket([]byte(id))
+		if err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+			return err
+		}
+	}
+	return tx.Bucket(jobsBucket).Delete([]byte(id))
+}
+
+func forEachJob(tx *bolt.Tx, fn func(*job) error) error {
+	return tx.Bucket(jobsBucket).ForEach(func(k, v []byte) error {
+		j := &job{}
+		if err := json.Unmarshal(v, j); err != nil {
+			return fmt.Errorf("job %s: %w", k, err)
+		}
+		return fn(j)
+	})
+}
+
+// get returns job id, or nil when there is none.
+func (m *jobManager) get(id string) (*job, error) {
+	var j *job
+	err := m.db.View(func(tx *bolt.Tx) error {
+		var err error
+		j, err = getJob(tx, id)
+		if errors.Is(err, errJobDeleted) {
+			return nil
+		}
+		return err
+	})
+	return j, err
+}
+
+// admit checks, before a job of client is charged to its key, that
+// submit would take it.
+func (m *jobManager) admit(client string) error {
+	return m.db.View(func(tx *bolt.Tx) error { return m.checkLimits(tx, client) })
+}
+
+// checkLimits returns errJobStoreFull when the store holds MaxJobs jobs,
+// or errTooManyPending when client has MaxPending jobs unfinished.
+func (m *jobManager) checkLimits(tx *bolt.Tx, client string) error {
+	if tx.Bucket(jobsBucket).Stats().KeyN >= m.cfg.MaxJobs {
+		return errJobStoreFull
+	}
+	pending := 0
+	err := forEachJob(tx, func(j *job) error {
+		if j.Client == client && (j.Status == jobQueued || j.Status == jobRunning) {
+			pending++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if pending >= m.cfg.MaxPending {
+		return errTooManyPending
+	}
+	return nil
+}
+
+// submit saves a new job with its points and wakes a worker. It returns
+// errJobStoreFull or errTooManyPending when the store or j.Client is at
+// its limit.
+func (m *jobManager) submit(j *job, points []jobPoint) error {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	j.ID = hex.EncodeToString(id)
+	j.Status, j.Points, j.Created = jobQueued, len(points), time.Now().UTC()
+	err := m.db.Update(func(tx *bolt.Tx) error {
+		if err := m.checkLimits(tx, j.Client); err != nil {
+			return err
+		}
+		if err := putPoints(tx, j.ID, points); err != nil {
+			return err
+		}
+		return putJob(tx, j)
+	})
+	if err != nil {
+		return err
+	}
+	m.notify()
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Input
+// ---------------------------------------------------------------------------
+
+// readJobPoints reads at most maxPoints points from CSV. A header naming
+// the latitude and longitude columns is optional; without one they are
+// the first two.
+func readJobPoints(r io.Reader, maxPoints int) ([]jobPoint, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	latCol, lonCol, idCol := 0, 1, -1
+	var points []jobPoint
+	for first := true; ; first = false {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if first {
+			if lat, lon, id, ok := pointColumns(rec); ok {
+				latCol, lonCol, idCol = lat, lon, id
+				continue
+			}
+		}
+		line, _ := cr.FieldPos(0)
+		if len(rec) <= max(latCol, lonCol) {
+			return nil, fmt.Errorf("line %d: %d fields, want at least %d", line, len(rec), max(latCol, lonCol)+1)
+		}
+		if len(points) == maxPoints {
+			return nil, fmt.Errorf("more than %d points", maxPoints)
+		}
+		p := jobPoint{}
+		if p.Lat, err = parseCoord(strings.TrimSpace(rec[latCol]), 90); err != nil {
+			return nil, fmt.Errorf("line %d: latitude: %w", line, err)
+		}
+		if p.Lon, err = parseCoord(strings.TrimSpace(rec[lonCol]), 180); err != nil {
+			return nil, fmt.Errorf("line %d: longitude: %w", line, err)
+		}
+		if idCol >= 0 && idCol < len(rec) {
+			p.ID, _ = json.Marshal(rec[idCol])
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
+
+// pointColumns finds the latitude, longitude and id columns in a header;
+// id is -1 when there is none.
+func pointColumns(header []string) (lat, lon, id int, ok bool) {
+	lat, lon, id = -1, -1, -1
+	for i, h := range header {
+		switch strings.ToLower(strings.TrimSpace(h)) {
+		case "lat", "latitude":
+			lat = i
+		case "lon", "lng", "long", "longitude":
+			lon = i
+		case "id":
+			id = i
+		}
+	}
+	return lat, lon, id, lat >= 0 && lon >= 0
+}
+
+// ---------------------------------------------------------------------------
+// Outgoing requests
+// ---------------------------------------------------------------------------
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598, private
+// in practice although netip does not count it as such.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// urlsEnabled reports whether jobs may name a CSV URL or a callback.
+func (c jobsConfig) urlsEnabled() bool {
+	return c.AllowURLs || len(c.AllowedHosts) > 0
+}
+
+// hostAllowed reports whether host is one of AllowedHosts.
+func (c jobsConfig) hostAllowed(host string) bool {
+	return slices.ContainsFunc(c.AllowedHosts, func(h string) bool {
+		return strings.EqualFold(h, host)
+	})
+}
+
+// checkJobURL checks a callback or CSV URL of a job with checkURL.
+func (c jobsConfig) checkJobURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	return c.checkURL(u)
+}
+
+// checkURL checks that u is absolute http or https and names one of
+// AllowedHosts when they are set, or else no literal address that is not
+// public. Names are checked once resolved, by refusePrivate.
+func (c jobsConfig) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return errors.New("must be an http or https URL")
+	}
+	host := u.Hostname()
+	if c.hostAllowed(host) {
+		return nil
+	}
+	if len(c.AllowedHosts) > 0 {
+		return fmt.Errorf("host %s is not allowed", host)
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && !publicAddr(ip) {
+		return fmt.Errorf("%s is not a public address", ip)
+	}
+	return nil
+}
+
+// publicAddr reports whether ip is routable on the internet: not
+// loopback, private, link-local, multicast or unspecified.
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
+
+// refusePrivate is the Control of the dialer for the hosts not in
+// AllowedHosts: it refuses the connection when the name resolved to an
+// address that is not public, so neither a URL nor a redirect nor a DNS
+// answer can point the server at its own network.
+func refusePrivate(_, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !publicAddr(ap.Addr()) {
+		return fmt.Errorf("%s is not a public address", ap.Addr())
+	}
+	return nil
+}
+
+// jobClient returns the client of the job URLs of cfg, cut off after
+// timeout. It uses no proxy, so that the dialer sees the real address,
+// and checks each redirect like the URL itself.
+func jobClient(cfg jobsConfig, timeout time.Duration) *http.Client {
+	open := &net.Dialer{Timeout: 30 * time.Second}
+	guarded := &net.Dialer{Timeout: 30 * time.Second, Control: refusePrivate}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				host, _, err := net.SplitHostPort(addr)
+				if err == nil && cfg.hostAllowed(host) {
+					return open.DialContext(ctx, network, addr)
+				}
+				return guarded.DialContext(ctx, network, addr)
+			},
+			ForceAttemptHTTP2:   true,
+			TLSHandshakeTimeout: 10 * time.Second,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return cfg.checkURL(req.URL)
+		},
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Handlers
+// ---------------------------------------------------------------------------
+
+// jobRequest is the JSON body of POST /jobs.
+type jobRequest struct {
+	jobOptions
+	Points   []jobPoint `json:"points"`
+	URL      string     `json:"url"`
+	Callback string     `json:"callback"`
+}
+
+// handleJobSubmit answers POST /jobs.
+func (s *server) handleJobSubmit(w http.ResponseWriter, r *http.Request) {
+	if s.jobs == nil {
+		writeError(w, http.StatusNotFound, "jobs are not enabled")
+		return
+	}
+	q := r.URL.Query()
+	req := jobRequest{
+		jobOptions: jobOptions{
+			Results:    s.cfg.DefaultResults,
+			Country:    q.Get("country"),
+			Confidence: q.Get("confidence") == "1" || q.Get("confidence") == "true",
+		},
+		URL:      q.Get("url"),
+		Callback: q.Get("callback"),
+	}
+	// Malformed numbers are reported by the checks below.
+	if v := q.Get("results"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			n = -1
+		}
+		req.Results = n
+	}
+	if v := q.Get("min_population"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			n = -1
+		}
+		req.MinPopulation = n
+	}
+
+	var err error
+	body := http.MaxBytesReader(w, r.Body, jobMaxBody)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv", "text/plain":
+		if req.Points, err = readJobPoints(body, s.jobs.cfg.MaxPoints); err != nil {
+			writeError(w, http.StatusBadRequest, "points: "+err.Error())
+			return
+		}
+	case "application/json", "":
+		if err := json.NewDecoder(body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			writeError(w, http.StatusBadRequest, "body: "+err.Error())
+			return
+		}
+	default:
+		writeError(w, http.StatusUnsupportedMediaType, "the body must be application/json or text/csv")
+		return
+	}
+
+	req.Country = strings.ToUpper(req.Country)
+	switch {
+	case req.Results < 1 || req.Results > s.cfg.MaxResults:
+		err = fmt.Errorf("results must be an integer between 1 and %d", s.cfg.MaxResults)
+	case req.MinPopulation < 0:
+		err = errors.New("min_population must be a non-negative integer")
+	case req.URL != "" && len(req.Points) > 0:
+		err = errors.New("give either points or url, not both")
+	case req.URL != "" && !s.jobs.cfg.urlsEnabled():
+		err = errors.New("url: fetching points is not enabled")
+	case req.Callback != "" && !s.jobs.cfg.urlsEnabled():
+		err = errors.New("callback: callbacks are not enabled")
+	case req.URL == "" && len(req.Points) == 0:
+		err = errors.New("no points")
+	case len(req.Points) > s.jobs.cfg.MaxPoints:
+		err = fmt.Errorf("more than %d points", s.jobs.cfg.MaxPoints)
+	}
+	if err == nil && req.URL != "" {
+		if uerr := s.jobs.cfg.checkJobURL(req.URL); uerr != nil {
+			err = fmt.Errorf("url: %w", uerr)
+		}
+	}
+	if err == nil && req.Callback != "" {
+		if uerr := s.jobs.cfg.checkJobURL(req.Callback); uerr != nil {
+			err = fmt.Errorf("callback: %w", uerr)
+		}
+	}
+	for i, p := range req.Points {
+		if err != nil {
+			break
+		}
+		if p.Lat < -90 || p.Lat > 90 || p.Lon < -180 || p.Lon > 180 {
+			err = fmt.Errorf("point %d: coordinates out of range", i)
+		}
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	client := s.clientKey(r)
+	if !s.admitJob(w, s.jobs.admit(client)) {
+		return
+	}
+	k := requestAPIKey(r)
+	n := int64(len(req.Points))
+	if k != nil {
+		if ok, reset := s.keys.chargeN(k, n, time.Now()); !ok {
+			if k.quota < n {
+				writeError(w, http.StatusRequestEntityTooLarge,
+					fmt.Sprintf("%d points exceed the quota of %d", n, k.quota))
+				return
+			}
+			secs := int(math.Ceil(reset.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(max(secs, 1)))
+			writeError(w, http.StatusTooManyRequests, "quota exceeded")
+			return
+		}
+	}
+
+	j := &job{
+		Options: req.jobOptions, URL: req.URL, Callback: req.Callback, Client: client,
+	}
+	var token string
+	if k != nil {
+		j.Owner = k.name
+	} else {
+		b := make([]byte, 16)
+		_, _ = rand.Read(b)
+		token = hex.EncodeToString(b)
+		j.TokenHash = hashJobToken(token)
+	}
+	if err := s.jobs.submit(j, req.Points); err != nil {
+		// The job was not saved, so its points are not charged.
+		if k != nil {
+			s.keys.refund(k, n)
+		}
+		s.admitJob(w, err)
+		return
+	}
+	v := j.view()
+	v.Token = token
+	w.Header().Set("Location", "/jobs/"+j.ID)
+	writeJSON(w, http.StatusAccepted, v)
+}
+
+// admitJob writes the error response for err, the result of admitting or
+// submitting a job, and reports whether there was none.
+func (s *server) admitJob(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, errTooManyPending):
+		writeError(w, http.StatusTooManyRequests, fmt.Sprintf(
+			"at most %d unfinished jobs per client", s.jobs.cfg.MaxPending))
+	case errors.Is(err, errJobStoreFull):
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		log.Printf("jobs: %v", err)
+		writeError(w, http.StatusInternalServerError, "saving the job failed")
+	}
+	return false
+}
+
+// jobVisible reports whether the caller with key k (nil without one) and
+// job token token may see j.
+func jobVisible(k *apiKey, token string, j *job) bool {
+	if k != nil && (k.admin || k.name == j.Owner) {
+		return true
+	}
+	return j.Owner == "" && j.TokenHash != "" && token != "" &&
+		subtle.ConstantTimeCompare([]byte(hashJobToken(token)), []byte(j.TokenHash)) == 1
+}
+
+// requestJob returns the job of the request's {id}, writing the error
+// response when there is none the caller may see.
+func (s *server) requestJob(w http.ResponseWriter, r *http.Request) (*job, bool) {
+	if s.jobs == nil {
+		writeError(w, http.StatusNotFound, "jobs are not enabled")
+		return nil, false
+	}
+	j, err := s.jobs.get(r.PathValue("id"))
+	if err != nil {
+		log.Printf("jobs: %v", err)
+		writeError(w, http.StatusInternalServerError, "reading the job failed")
+		return nil, false
+	}
+	token := r.Header.Get("X-Geonames-Job-Token")
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	if j == nil || !jobVisible(requestAPIKey(r), token, j) {
+		writeError(w, http.StatusNotFound, "no such job")
+		return nil, false
+	}
+	return j, true
+}
+
+// handleJobs answers GET /jobs with the caller's jobs, oldest first. An
+// anonymous caller has none: its jobs are reached by id and token only.
+func (s *server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	if s.jobs == nil {
+		writeError(w, http.StatusNotFound, "jobs are not enabled")
+		return
+	}
+	k := requestAPIKey(r)
+	jobs := []jobView{}
+	err := s.jobs.db.View(func(tx *bolt.Tx) error {
+		if k == nil {
+			return nil
+		}
+		return forEachJob(tx, func(j *job) error {
+			if k.admin || j.Owner == k.name {
+				jobs = append(jobs, j.view())
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		log.Printf("jobs: %v", err)
+		writeError(w, http.StatusInternalServerError, "reading the jobs failed")
+		return
+	}
+	slices.SortFunc(jobs, func(a, b jobView) int { return a.Created.Compare(b.Created) })
+	writeJSON(w, http.StatusOK, map[string]any{"jobs": jobs})
+}
+
+// handleJob answers GET /jobs/{id}.
+func (s *server) handleJob(w http.ResponseWriter, r *http.Request) {
+	if j, ok := s.requestJob(w, r); ok {
+		writeJSON(w, http.StatusOK, j.view())
+	}
+}
+
+// handleJobDelete answers DELETE /jobs/{id}. A running job stops after
+// its current chunk.
+func (s *server) handleJobDelete(w http.ResponseWriter, r *http.Request) {
+	j, ok := s.requestJob(w, r)
+	if !ok {
+		return
+	}
+	err := s.jobs.db.Update(func(tx *bolt.Tx) error { return deleteJob(tx, j.ID) })
+	if err != nil {
+		log.Printf("jobs: %v", err)
+		writeError(w, http.StatusInternalServerError, "deleting the job failed")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleJobResults answers GET /jobs/{id}/results with the results saved
+// so far, in point order. The chunks are read one transaction each, so a
+// slow client does not hold the store.
+func (s *server) handleJobResults(w http.ResponseWriter, r *http.Request) {
+	j, ok := s.requestJob(w, r)
+	if !ok {
+		return
+	}
+	format := r.URL.Query().Get("format")
+	w.Header().Set("X-Geonames-Job-Status", j.Status)
+	var cw *csv.Writer
+	switch format {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, "[")
+	case "ndjson":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		cw = csv.NewWriter(w)
+		header := []string{"index", "id", "latitude", "longitude", "rank",
+			"geonameid", "name", "country", "admin1", "postalcode", "distance_km"}
+		if j.Options.Confidence {
+			header = append(header, "confidence")
+		}
+		_ = cw.Write(header)
+	default:
+		writeError(w, http.StatusBadRequest, "format must be json, ndjson or csv")
+		return
+	}
+
+	written := 0
+	for n := 0; ; n++ {
+		var value []byte
+		err := s.jobs.db.View(func(tx *bolt.Tx) error {
+			if b := tx.Bucket(resultsBucket).Bucket([]byte(j.ID)); b != nil {
+				value = slices.Clone(b.Get(chunkKey(n)))
+			}
+			return nil
+		})
+		if err != nil || value == nil {
+			break
+		}
+		var results []jobResult
+		if err := json.Unmarshal(value, &results); err != nil {
+			log.Printf("jobs: %s: %v", j.ID, err)
+			break
+		}
+		for _, res := range results {
+			switch format {
+			case "csv":
+				writeJobCSV(cw, res, j.Options.Confidence)
+			default:
+				line, _ := json.Marshal(res)
+				if format != "ndjson" && written > 0 {
+					_, _ = io.WriteString(w, ",")
+				}
+				_, _ = w.Write(line)
+				if format == "ndjson" {
+					_, _ = io.WriteString(w, "\n")
+				}
+			}
+			written++
+		}
+		if cw != nil {
+			cw.Flush()
+		}
+	}
+	if format == "" || format == "json" {
+		_, _ = io.WriteString(w, "]\n")
+	}
+}
+
+// writeJobCSV writes the rows of one result: one per place, or one with
+// empty place columns when there is none.
+func writeJobCSV(cw *csv.Writer, res jobResult, confidence bool) {
+	id := string(res.ID)
+	var s string
+	if json.Unmarshal(res.ID, &s) == nil {
+		id = s
+	}
+	point := []string{
+		strconv.Itoa(res.Index), id,
+		strconv.FormatFloat(res.Latitude, 'f', -1, 64),
+		strconv.FormatFloat(res.Longitude, 'f', -1, 64),
+	}
+	if len(res.Places) == 0 {
+		row := append(point, "", "", "", "", "", "", "")
+		if confidence {
+			row = append(row, "")
+		}
+		_ = cw.Write(row)
+		return
+	}
+	for i, p := range res.Places {
+		row := append(slices.Clone(point),
+			strconv.Itoa(i+1), strconv.FormatInt(p.Geonameid, 10),
+			p.Name, p.Country, p.Admin1, p.Postalcode,
+			strconv.FormatFloat(p.DistanceKm, 'f', 3, 64))
+		if confidence {
+			c := ""
+			if p.Rating != nil {
+				c = strconv.FormatFloat(p.Rating.Confidence, 'f', 2, 64)
+			}
+			row = append(row, c)
+		}
+		_ = cw.Write(row)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+/*
+	Tests of the job input.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestJobPointJSON(t *testing.T) {
+	for _, c := range []struct {
+		in string
+		// want is the point read, or "" for an error.
+		want string
+	}{
+		{`{"id": "a1", "lat": 19.4326, "lon": -99.1332}`, `"a1" 19.4326 -99.1332`},
+		{`{"lat": 0, "lon": 0}`, ` 0 0`},
+		{`{"id": 7, "lat": 19.4326}`, ""},
+		{`{"lon": -99.1332}`, ""},
+		{`{"lat": null, "lon": 0}`, ""},
+		{`{"lat": "19", "lon": 0}`, ""},
+	} {
+		var p jobPoint
+		err := json.Unmarshal([]byte(c.in), &p)
+		switch {
+		case c.want == "" && err == nil:
+			t.Errorf("%s: read %+v, want an error", c.in, p)
+		case c.want != "" && err != nil:
+			t.Errorf("%s: %v", c.in, err)
+		case c.want != "":
+			if got := fmt.Sprintf("%s %g %g", string(p.ID), p.Lat, p.Lon); got != c.want {
+				t.Errorf("%s: read %s, want %s", c.in, got, c.want)
+			}
+		}
+	}
+}
+
+// TestJobLimits checks the caps on the unfinished jobs of a client and
+// on the jobs of the store.
+func TestJobLimits(t *testing.T) {
+	m, err := openJobs(jobsConfig{
+		Store: filepath.Join(t.TempDir(), "jobs.db"), MaxJobs: 3, MaxPending: 2,
+	}.withDefaults())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	points := []jobPoint{{Lat: 19.4326, Lon: -99.1332}}
+	for i, c := range []struct {
+		client string
+		want   error
+	}{
+		{"ip:192.0.2.1", nil},
+		{"ip:192.0.2.1", nil},
+		{"ip:192.0.2.1", errTooManyPending},
+		{"key:ops", nil},
+		{"key:ops", errJobStoreFull},
+	} {
+		if err := m.admit(c.client); !errors.Is(err, c.want) {
+			t.Errorf("job %d of %s: admit: %v, want %v", i+1, c.client, err, c.want)
+		}
+		if err := m.submit(&job{Client: c.client}, points); !errors.Is(err, c.want) {
+			t.Errorf("job %d of %s: submit: %v, want %v", i+1, c.client, err, c.want)
+		}
+	}
+}
+
+// TestJobSubmitQuota checks that the points of a job are charged to the
+// key's quota only when the job is saved.
+func TestJobSubmitQuota(t *testing.T) {
+	store := filepath.Join(t.TempDir(), "jobs.db")
+	m, err := openJobs(jobsConfig{Store: store}.withDefaults())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &server{
+		jobs: m,
+		keys: testKeyStore(time.Hour),
+		cfg:  serverConfig{MaxResults: 50, DefaultResults: 1},
+	}
+	k, _ := s.keys.lookup("secret-a")
+	submit := func(body string) int {
+		r := httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(body))
+		r = r.WithContext(context.WithValue(r.Context(), apiKeyCtxKey, k))
+		w := httptest.NewRecorder()
+		s.handleJobSubmit(w, r)
+		return w.Code
+	}
+	used := func() int64 { return s.keys.snapshot("a")[0].WindowUsed }
+
+	if code := submit(`{"points":[{"lat":19.4326,"lon":-99.1332},{"lat":20.6597,"lon":-103.3496}]}`); code != http.StatusAccepted {
+		t.Fatalf("submit: %d, want 202", code)
+	}
+	if n := used(); n != 2 {
+		t.Fatalf("%d points charged, want 2", n)
+	}
+
+	// A store that cannot be written to: admission passes, saving fails.
+	if err := m.db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if m.db, err = bolt.Open(store, 0o600, &bolt.Options{ReadOnly: true}); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if code := submit(`{"points":[{"lat":19.4326,"lon":-99.1332}]}`); code != http.StatusInternalServerError {
+		t.Fatalf("submit to a read-only store: %d, want 500", code)
+	}
+	if n := used(); n != 2 {
+		t.Errorf("%d points charged after a failed submit, want 2", n)
+	}
+	if u := s.keys.snapshot("a")[0]; u.Requests != 2 {
+		t.Errorf("%d requests counted after a failed submit, want 2", u.Requests)
+	}
+}
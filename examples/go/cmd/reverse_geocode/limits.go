@@ -0,0 +1,171 @@
+package main
+
+/*
+	Request admission controls for the HTTP server: a per-client token-bucket
+	rate limiter and a bounded queue in front of the database pool.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Rate limiting
+// ---------------------------------------------------------------------------
+
+// rateLimiterSweepSize is the number of tracked clients above which idle
+// buckets are evicted, so a scan from many source addresses cannot grow the
+// map without bound.
+const rateLimiterSweepSize = 10_000
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter is a token-bucket limiter keyed by client (IP or API key).
+// Each key may issue burst requests at once and refills at rate per second.
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*tokenBucket
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// allow reports whether key may proceed at time now. When it may not, the
+// returned duration is how long until the next token becomes available.
+func (l *rateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= rateLimiterSweepSize {
+			l.sweep(now)
+		}
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// blocked reports whether key has no token left at time now, without
+// taking one, and if so how long until the next becomes available.
+func (l *rateLimiter) blocked(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		return false, 0
+	}
+	tokens := math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	if tokens >= 1 {
+		return false, 0
+	}
+	return true, time.Duration((1 - tokens) / l.rate * float64(time.Second))
+}
+
+// sweep drops buckets that would be full again by now; forgetting them is
+// indistinguishable from keeping them. The caller must hold l.mu.
+func (l *rateLimiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Query queue
+// ---------------------------------------------------------------------------
+
+var (
+	errQueueFull    = errors.New("too many queued queries")
+	errQueueTimeout = errors.New("timed out waiting for a query slot")
+)
+
+// queryQueue caps the number of requests querying the database at once.
+// Requests beyond that wait up to timeout for a slot; at most maxQueued may
+// wait at a time, the rest are rejected immediately.
+type queryQueue struct {
+	slots     chan struct{}
+	waiting   atomic.Int64
+	maxQueued int64
+	timeout   time.Duration
+}
+
+func newQueryQueue(maxConcurrent, maxQueued int, timeout time.Duration) *queryQueue {
+	return &queryQueue{
+		slots:     make(chan struct{}, maxConcurrent),
+		maxQueued: int64(maxQueued),
+		timeout:   timeout,
+	}
+}
+
+// acquire blocks until a query slot is free and returns the function that
+// releases it.
+func (q *queryQueue) acquire(ctx context.Context) (func(), error) {
+	select {
+	case q.slots <- struct{}{}:
+		return q.release, nil
+	default:
+	}
+
+	if q.waiting.Add(1) > q.maxQueued {
+		q.waiting.Add(-1)
+		return nil, errQueueFull
+	}
+	defer q.waiting.Add(-1)
+
+	timer := time.NewTimer(q.timeout)
+	defer timer.Stop()
+	select {
+	case q.slots <- struct{}{}:
+		return q.release, nil
+	case <-timer.C:
+		return nil, errQueueTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (q *queryQueue) release() {
+	<-q.slots
+}
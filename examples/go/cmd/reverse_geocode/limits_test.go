@@ -0,0 +1,149 @@
+package main
+
+/*
+	Tests of the rate limiter and the query queue.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := newRateLimiter(2, 3)
+
+	for i := range 3 {
+		if ok, _ := l.allow("a", now); !ok {
+			t.Fatalf("request %d within the burst was refused", i+1)
+		}
+	}
+	ok, wait := l.allow("a", now)
+	if ok {
+		t.Fatal("request over the burst was allowed")
+	}
+	if wait != 500*time.Millisecond {
+		t.Errorf("wait = %v, want 500ms", wait)
+	}
+	if ok, _ := l.allow("b", now); !ok {
+		t.Error("another key shares the bucket")
+	}
+
+	if ok, _ := l.allow("a", now.Add(500*time.Millisecond)); !ok {
+		t.Error("request after refilling one token was refused")
+	}
+	if ok, _ := l.allow("a", now.Add(500*time.Millisecond)); ok {
+		t.Error("second request after refilling one token was allowed")
+	}
+}
+
+func TestRateLimiterBlocked(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := newRateLimiter(1, 2)
+
+	if blocked, _ := l.blocked("a", now); blocked {
+		t.Fatal("unknown key is blocked")
+	}
+	l.allow("a", now)
+	if blocked, _ := l.blocked("a", now); blocked {
+		t.Fatal("key with a token left is blocked")
+	}
+	// blocked must not take the token.
+	if blocked, _ := l.blocked("a", now); blocked {
+		t.Fatal("blocked took a token")
+	}
+	l.allow("a", now)
+	blocked, wait := l.blocked("a", now)
+	if !blocked || wait != time.Second {
+		t.Errorf("blocked = %v, %v; want true, 1s", blocked, wait)
+	}
+	if blocked, _ := l.blocked("a", now.Add(time.Second)); blocked {
+		t.Error("key still blocked after refilling")
+	}
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := newRateLimiter(1, 2)
+	for i := range rateLimiterSweepSize {
+		l.allow(fmt.Sprint(i), now)
+	}
+	// Exhaust one key so it survives the sweep.
+	l.allow("0", now)
+
+	l.allow("new", now.Add(time.Second))
+	if n := len(l.buckets); n != 2 {
+		t.Fatalf("%d buckets after the sweep, want 2", n)
+	}
+	if b, ok := l.buckets["0"]; !ok {
+		t.Error("sweep dropped a bucket that is not full again")
+	} else if b.tokens != 0 {
+		t.Errorf("kept bucket has %v tokens, want 0", b.tokens)
+	}
+}
+
+func TestQueryQueue(t *testing.T) {
+	ctx := context.Background()
+	q := newQueryQueue(1, 1, 50*time.Millisecond)
+
+	release, err := q.acquire(ctx)
+	if err != nil {
+		t.Fatalf("first acquire: %v", err)
+	}
+
+	// One request may wait; it gets the slot once it is released.
+	got := make(chan error, 1)
+	go func() {
+		rel, err := q.acquire(ctx)
+		if err == nil {
+			rel()
+		}
+		got <- err
+	}()
+	for q.waiting.Load() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := q.acquire(ctx); !errors.Is(err, errQueueFull) {
+		t.Errorf("acquire with the queue full = %v, want errQueueFull", err)
+	}
+	release()
+	if err := <-got; err != nil {
+		t.Errorf("queued acquire: %v", err)
+	}
+
+	// A waiter gives up after the timeout or with its context.
+	release, err = q.acquire(ctx)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	defer release()
+	if _, err := q.acquire(ctx); !errors.Is(err, errQueueTimeout) {
+		t.Errorf("acquire past the timeout = %v, want errQueueTimeout", err)
+	}
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := q.acquire(canceled); !errors.Is(err, context.Canceled) {
+		t.Errorf("acquire with a canceled context = %v, want context.Canceled", err)
+	}
+	if n := q.waiting.Load(); n != 0 {
+		t.Errorf("%d waiters left", n)
+	}
+}
@@ -0,0 +1,1203 @@
+package main
+
+/*
+	GeoNames loader ("load" subcommand).
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    go run . load [--config CONFIG] [--url URL] [--data-dir DIR]
+	        [--countries MX,US] [--feature-classes P,A]
+	        [--no-alternate-names] [--skip-indexes] [--stream]
+	        [--workers 4] [--state FILE] [-o]
+
+	download_geonames.py followed by load_geonames.py, for environments
+	without Python (see bootstrap.go). It fetches the dumps, creates the
+	schema from the SQL embedded from migrations/<dialect>/, loads the rows
+	with COPY on PostgreSQL (see pgcopy.go) and batched multi-row INSERTs
+	elsewhere, writes the continent codes and builds
+	the indexes, leaving the same database load_geonames.py would.
+
+	Differences from the Python scripts:
+	  - With --countries only the per-country dumps are fetched
+	    (export/dump/CC.zip, export/dump/alternatenames/CC.zip and
+	    export/zip/CC.zip) instead of the multi-gigabyte allCountries files.
+	    Countries without postal codes are skipped with a note.
+	  - The derived columns (admin codes' countrycode, postal codes'
+	    admin*code_full and admin*nameascii) are computed while loading
+	    instead of by UPDATEs afterwards. Accents are stripped in Go on every
+	    dialect, also where load_geonames.py would use unaccent().
+	  - Foreign keys are left out when only some countries or feature
+	    classes are loaded, since countryinfo always lists every country.
+	  - The meta row is written last, after the indexes, so its presence
+	    marks a completed load (see databaseLoaded).
+
+	The download section of --config (data_dir, postal_subdir, url_data,
+	url_postal) and its meta section are honoured; the file list is not,
+	the loader knows which files it needs. Files already present with the
+	size the server reports are not downloaded again. Interrupted
+	downloads are resumed and every download is checked against its size,
+	and its MD5 where one is published; with --stream the archives are
+	unzipped on the fly instead of being stored (see download.go).
+
+	--workers files are downloaded, and then loaded, at a time, with a
+	progress bar each (see progress.go). Places load first, since the
+	alternate names of a feature-class selection depend on them; SQLite
+	takes one writer at a time, so there the files load one by one. An
+	interrupted load resumes where it stopped when run again (see
+	loadstate.go).
+*/
+
+import (
+	"bufio"
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/rgglez/geonames-loader/examples/go/internal/sqlbuild"
+	"github.com/rgglez/geonames-loader/examples/go/pkg/geocoder"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+	"gorm.io/gorm"
+)
+
+// migrations holds the schema and index DDL per dialect, named after
+// gorm's dialector (postgres, mysql, sqlite).
+//
+//go:embed migrations
+var migrations embed.FS
+
+// ---------------------------------------------------------------------------
+// Options
+// ---------------------------------------------------------------------------
+
+// downloadConfig mirrors the download section of the config YAML.
+type downloadConfig struct {
+	DataDir      string `yaml:"data_dir"`
+	PostalSubdir string `yaml:"postal_subdir"`
+	URLData      string `yaml:"url_data"`
+	URLPostal    string `yaml:"url_postal"`
+}
+
+// metaConfig mirrors the meta section of the config YAML.
+type metaConfig struct {
+	Version     string `yaml:"version"`
+	DataVersion string `yaml:"data_version"`
+}
+
+// loadOptions configures loadDatabase.
+type loadOptions struct {
+	Download downloadConfig
+	Meta     metaConfig
+	// Countries and FeatureClasses restrict the load; empty loads all.
+	Countries      []string
+	FeatureClasses []string
+	AlternateNames bool
+	// LinksOnly keeps, of the alternate names, only the Wikipedia links
+	// and Wikidata QIDs (see pkg/geocoder/links.go).
+	LinksOnly   bool
+	SkipIndexes bool
+	// Overwrite drops the tables before loading.
+	Overwrite bool
+	// Stream unzips the archives as they download instead of keeping
+	// them (see download.go).
+	Stream bool
+	// Workers is the number of files downloaded and loaded at a time.
+	Workers int
+	// StateFile records the progress of the load (see loadstate.go);
+	// default: load-state.json in the data directory.
+	StateFile string
+}
+
+// withDefaults fills in unset fields with the values of the sample config.
+func (o loadOptions) withDefaults() loadOptions {
+	if o.Download.DataDir == "" {
+		o.Download.DataDir = "data"
+	}
+	if o.Download.PostalSubdir == "" {
+		o.Download.PostalSubdir = "postalcodes"
+	}
+	if o.Download.URLData == "" {
+		o.Download.URLData = "https://download.geonames.org/export/dump"
+	}
+	if o.Download.URLPostal == "" {
+		o.Download.URLPostal = "https://download.geonames.org/export/zip"
+	}
+	o.Download.URLData = strings.TrimRight(o.Download.URLData, "/")
+	o.Download.URLPostal = strings.TrimRight(o.Download.URLPostal, "/")
+	if o.Meta.Version == "" {
+		o.Meta.Version = "1.0"
+	}
+	if o.Workers <= 0 {
+		o.Workers = 4
+	}
+	return o
+}
+
+// parseCodeList parses a comma-separated list of codes of the given length
+// ("mx, US") into upper-case codes. allowed, when not empty, lists the
+// accepted codes.
+func parseCodeList(s string, length int, allowed string) ([]string, error) {
+	codes := splitList(s, true)
+	for _, c := range codes {
+		ok := len(c) == length
+		for _, r := range c {
+			ok = ok && r >= 'A' && r <= 'Z'
+		}
+		if !ok || allowed != "" && !strings.Contains(allowed, c) {
+			return nil, fmt.Errorf("invalid code %q", c)
+		}
+	}
+	return codes, nil
+}
+
+// featureClasses are the GeoNames feature classes.
+const featureClasses = "AHLPRSTUV"
+
+// ---------------------------------------------------------------------------
+// Tables and data files
+// ---------------------------------------------------------------------------
+
+// loadKind is the value type of a loaded column.
+type loadKind int
+
+const (
+	loadString loadKind = iota
+	loadInt
+	loadFloat
+	loadBool
+	loadDate
+)
+
+type loadColumn struct {
+	Name string
+	Kind loadKind
+}
+
+// loadTables lists the columns of each loaded table in data-file order;
+// derived columns follow the ones read from the file.
+var loadTables = map[string][]loadColumn{
+	"geoname": {
+		{"geonameid", loadInt},
+		{"name", loadString},
+		{"asciiname", loadString},
+		{"alternatenames", loadString},
+		{"latitude", loadFloat},
+		{"longitude", loadFloat},
+		{"fclass", loadString},
+		{"fcode", loadString},
+		{"country", loadString},
+		{"cc2", loadString},
+		{"admin1", loadString},
+		{"admin2", loadString},
+		{"admin3", loadString},
+		{"admin4", loadString},
+		{"population", loadInt},
+		{"elevation", loadInt},
+		{"gtopo30", loadInt},
+		{"timezone", loadString},
+		{"moddate", loadDate},
+	},
+	"alternatename": {
+		{"alternatenameid", loadInt},
+		{"geonameid", loadInt},
+		{"isolanguage", loadString},
+		{"alternatename", loadString},
+		{"ispreferredname", loadBool},
+		{"isshortname", loadBool},
+		{"iscolloquial", loadBool},
+		{"ishistoric", loadBool},
+	},
+	"timezones": {
+		{"countrycode", loadString},
+		{"timezoneid", loadString},
+		{"gmt_offset", loadFloat},
+		{"dst_offset", loadFloat},
+		{"raw_offset", loadFloat},
+	},
+	"featurecodes": {
+		{"code", loadString},
+		{"name", loadString},
+		{"description", loadString},
+	},
+	"admin1codesascii": {
+		{"code", loadString},
+		{"name", loadString},
+		{"nameascii", loadString},
+		{"geonameid", loadInt},
+		{"countrycode", loadString}, // derived
+	},
+	"admin2codesascii": {
+		{"code", loadString},
+		{"name", loadString},
+		{"nameascii", loadString},
+		{"geonameid", loadInt},
+		{"countrycode", loadString}, // derived
+	},
+	"iso_languagecodes": {
+		{"iso_639_3", loadString},
+		{"iso_639_2", loadString},
+		{"iso_639_1", loadString},
+		{"language_name", loadString},
+	},
+	"countryinfo": {
+		{"iso_alpha2", loadString},
+		{"iso_alpha3", loadString},
+		{"iso_numeric", loadInt},
+		{"fips_code", loadString},
+		{"country", loadString},
+		{"capital", loadString},
+		{"areainsqkm", loadFloat},
+		{"population", loadInt},
+		{"continent", loadString},
+		{"tld", loadString},
+		{"currency_code", loadString},
+		{"currency_name", loadString},
+		{"phone", loadString},
+		{"postal", loadString},
+		{"postalregex", loadString},
+		{"languages", loadString},
+		{"geonameid", loadInt},
+		{"neighbours", loadString},
+		{"equivalent_fips_code", loadString},
+	},
+	"postalcodes": {
+		{"countrycode", loadString},
+		{"postalcode", loadString},
+		{"placename", loadString},
+		{"admin1name", loadString},
+		{"admin1code", loadString},
+		{"admin2name", loadString},
+		{"admin2code", loadString},
+		{"admin3name", loadString},
+		{"admin3code", loadString},
+		{"latitude", loadFloat},
+		{"longitude", loadFloat},
+		{"accuracy", loadInt},
+		{"admin1code_full", loadString}, // derived from here on
+		{"admin2code_full", loadString},
+		{"admin3code_full", loadString},
+		{"admin1nameascii", loadString},
+		{"admin2nameascii", loadString},
+		{"admin3nameascii", loadString},
+	},
+}
+
+// dropOrder drops dependent tables first.
+var dropOrder = []string{
+	"alternatename", "countryinfo", "geoname",
+	"postalcodes", "admin1codesascii", "admin2codesascii",
+	"iso_languagecodes", "featurecodes", "timezones",
+	"continentcodes", "meta", "schema_version",
+}
+
+// dataFile is a GeoNames dump to fetch and load into a table.
+type dataFile struct {
+	URL    string // file to download
+	Member string // file inside the zip archive at URL; "" if not a zip
+	Path   string // local text file
+	Table  string
+	// Country is the country of a per-country file.
+	Country string
+	// Header skips the first line; Optional tolerates a missing download.
+	Header   bool
+	Optional bool
+	// Keep, when set, filters rows on their raw fields.
+	Keep func(fields []string) bool
+	// Derive, when set, fills in derived columns of a row in place.
+	Derive func(row []string)
+}
+
+// loadSelection holds the countries and feature classes to keep.
+type loadSelection struct {
+	countries map[string]bool
+	classes   map[string]bool
+	// geonameids records the places kept when filtering by feature class,
+	// so that only their alternate names are loaded. Places files load
+	// concurrently, and before the alternate names.
+	mu         sync.Mutex
+	geonameids map[string]struct{}
+}
+
+func newLoadSelection(countries, classes []string) *loadSelection {
+	s := &loadSelection{
+		countries:  map[string]bool{},
+		classes:    map[string]bool{},
+		geonameids: map[string]struct{}{},
+	}
+	for _, c := range countries {
+		s.countries[c] = true
+	}
+	for _, c := range classes {
+		s.classes[c] = true
+	}
+	return s
+}
+
+func (s *loadSelection) countryOK(code string) bool {
+	return len(s.countries) == 0 || s.countries[code]
+}
+
+func (s *loadSelection) geoname(f []string) bool {
+	// geonameid, ..., fclass (6), fcode, country (8)
+	if len(f) < 9 || !s.countryOK(f[8]) {
+		return false
+	}
+	if len(s.classes) > 0 {
+		if !s.classes[f[6]] {
+			return false
+		}
+		s.mu.Lock()
+		s.geonameids[f[0]] = struct{}{}
+		s.mu.Unlock()
+	}
+	return true
+}
+
+func (s *loadSelection) alternatename(f []string) bool {
+	if len(s.classes) == 0 {
+		return true
+	}
+	if len(f) < 2 {
+		return false
+	}
+	_, ok := s.geonameids[f[1]]
+	return ok
+}
+
+// byCountry filters files whose first column is a country code.
+func (s *loadSelection) byCountry(f []string) bool {
+	return s.countryOK(f[0])
+}
+
+// byAdminCode filters admin code files ("CC.ADM1[.ADM2]").
+func (s *loadSelection) byAdminCode(f []string) bool {
+	cc, _, _ := strings.Cut(f[0], ".")
+	return s.countryOK(cc)
+}
+
+// dataFiles lists the files to load, in load order.
+func (o loadOptions) dataFiles(sel *loadSelection) []dataFile {
+	d := o.Download
+	dir, postalDir := d.DataDir, filepath.Join(d.DataDir, d.PostalSubdir)
+	plain := func(name, table string) dataFile {
+		return dataFile{
+			URL: d.URLData + "/" + name, Path: filepath.Join(dir, name),
+			Table: table,
+		}
+	}
+
+	var places, altNames, postal []dataFile
+	if len(o.Countries) == 0 {
+		places = []dataFile{{
+			URL: d.URLData + "/allCountries.zip", Member: "allCountries.txt",
+			Path: filepath.Join(dir, "allCountries.txt"), Table: "geoname",
+		}}
+		altNames = []dataFile{{
+			URL: d.URLData + "/alternateNamesV2.zip", Member: "alternateNamesV2.txt",
+			Path: filepath.Join(dir, "alternateNamesV2.txt"), Table: "alternatename",
+		}}
+		postal = []dataFile{{
+			URL: d.URLPostal + "/allCountries.zip", Member: "allCountries.txt",
+			Path: filepath.Join(postalDir, "allCountries.txt"), Table: "postalcodes",
+		}}
+	}
+	for _, cc := range o.Countries {
+		txt := cc + ".txt"
+		places = append(places, dataFile{
+			URL: d.URLData + "/" + cc + ".zip", Member: txt,
+			Path: filepath.Join(dir, txt), Table: "geoname", Country: cc,
+		})
+		altNames = append(altNames, dataFile{
+			URL: d.URLData + "/alternatenames/" + cc + ".zip", Member: txt,
+			Path:  filepath.Join(dir, "alternatenames", txt),
+			Table: "alternatename", Country: cc, Optional: true,
+		})
+		postal = append(postal, dataFile{
+			URL: d.URLPostal + "/" + cc + ".zip", Member: txt,
+			Path: filepath.Join(postalDir, txt), Table: "postalcodes",
+			Country: cc, Optional: true,
+		})
+	}
+	for i := range places {
+		places[i].Keep = sel.geoname
+	}
+	for i := range altNames {
+		altNames[i].Keep = sel.alternatename
+		if o.LinksOnly {
+			altNames[i].Keep = func(f []string) bool {
+				return len(f) > 2 && (f[2] == "link" || f[2] == "wkdt") && sel.alternatename(f)
+			}
+		}
+	}
+	for i := range postal {
+		// Each file gets its own deriver: they load concurrently.
+		postal[i].Keep, postal[i].Derive = sel.byCountry, postalDeriver()
+	}
+
+	timezones := plain("timeZones.txt", "timezones")
+	timezones.Header, timezones.Keep = true, sel.byCountry
+	admin1 := plain("admin1CodesASCII.txt", "admin1codesascii")
+	admin1.Keep, admin1.Derive = sel.byAdminCode, deriveAdminCode
+	admin2 := plain("admin2Codes.txt", "admin2codesascii")
+	admin2.Keep, admin2.Derive = sel.byAdminCode, deriveAdminCode
+	languages := plain("iso-languagecodes.txt", "iso_languagecodes")
+	languages.Header = true
+	countries := plain("countryInfo.txt", "countryinfo")
+	countries.Keep = currentCountry
+
+	files := places
+	if o.AlternateNames {
+		files = append(files, altNames...)
+	}
+	files = append(files,
+		timezones,
+		plain("featureCodes_en.txt", "featurecodes"),
+		admin1, admin2, languages,
+		countries,
+	)
+	return append(files, postal...)
+}
+
+// currentCountry drops the dissolved Netherlands Antilles and Serbia and
+// Montenegro, the last two lines of countryInfo.txt, which
+// download_geonames.py strips as well.
+func currentCountry(f []string) bool {
+	return f[0] != "AN" && f[0] != "CS"
+}
+
+// deriveAdminCode fills in an admin code row's countrycode and falls back
+// to the ASCII name when the name is missing.
+func deriveAdminCode(r []string) {
+	if r[1] == "" {
+		r[1] = r[2]
+	}
+	r[4] = r[0][:min(2, len(r[0]))]
+}
+
+// postalDeriver returns the Derive function of the postal code files,
+// which builds the full admin codes ("MX.09.015") and accent-free admin
+// names.
+func postalDeriver() func([]string) {
+	strip := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)))
+	cache := map[string]string{}
+	ascii := func(s string) string {
+		if v, ok := cache[s]; ok {
+			return v
+		}
+		v, _, err := transform.String(strip, s)
+		if err != nil {
+			v = s
+		}
+		cache[s] = v
+		return v
+	}
+	return func(r []string) {
+		// countrycode 0, admin1name 3, admin1code 4, admin2name 5,
+		// admin2code 6, admin3name 7, admin3code 8; derived 12–17.
+		if r[4] != "" {
+			r[12] = r[0] + "." + r[4]
+			if r[6] != "" {
+				r[13] = r[12] + "." + r[6]
+				if r[8] != "" {
+					r[14] = r[13] + "." + r[8]
+				}
+			}
+		}
+		r[15], r[16], r[17] = ascii(r[3]), ascii(r[5]), ascii(r[7])
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Schema
+// ---------------------------------------------------------------------------
+
+// runMigration executes the statements of migrations/<dialect>/name.
+func runMigration(db *gorm.DB, name string) error {
+	stmts, err := migrationStatements(db, name)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range stmts {
+		if err := db.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// migrationStatements returns the statements of migrations/<dialect>/name.
+// A dialect without that file has none.
+func migrationStatements(db *gorm.DB, name string) ([]string, error) {
+	src, err := migrations.ReadFile(
+		"migrations/" + db.Dialector.Name() + "/" + name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sqlStatements(string(src)), nil
+}
+
+// sqlStatements splits a migration file into statements, dropping "--"
+// comment lines.
+func sqlStatements(src string) []string {
+	var b strings.Builder
+	for _, line := range strings.Split(src, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+	}
+	var out []string
+	for _, stmt := range strings.Split(b.String(), ";") {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			out = append(out, stmt)
+		}
+	}
+	return out
+}
+
+// dropTables drops every table the loader creates.
+func dropTables(db *gorm.DB) error {
+	cascade := ""
+	if geocoder.IsPostgres(db) {
+		cascade = " CASCADE"
+	}
+	for _, t := range dropOrder {
+		if err := db.Exec("DROP TABLE IF EXISTS " + t + cascade).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// databaseLoaded reports whether db holds a completed load, i.e. a meta
+// row. partial is true when GeoNames tables exist without one.
+func databaseLoaded(db *gorm.DB) (loaded, partial bool, err error) {
+	m := db.Migrator()
+	if !m.HasTable("meta") {
+		return false, m.HasTable("geoname"), nil
+	}
+	var n int64
+	if err := db.Raw("SELECT count(*) FROM meta").Scan(&n).Error; err != nil {
+		return false, false, err
+	}
+	return n > 0, n == 0, nil
+}
+
+// ---------------------------------------------------------------------------
+// Loading
+// ---------------------------------------------------------------------------
+
+// convertValue turns a data-file field into the value bound for a column
+// of kind k; empty and unparsable fields become NULL.
+func convertValue(s string, k loadKind, dialect string) any {
+	if s == "" {
+		return nil
+	}
+	switch k {
+	case loadInt:
+		if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return v
+		}
+		return nil
+	case loadFloat:
+		if v, err := strconv.ParseFloat(s, 64); err == nil {
+			return v
+		}
+		return nil
+	case loadBool:
+		return s == "1" || s == "true"
+	case loadDate:
+		// The PostgreSQL driver wants a time.Time for a DATE column; the
+		// others store the ISO string as load_geonames.py does.
+		if dialect != "postgres" {
+			return s
+		}
+		if v, err := time.Parse("2006-01-02", s); err == nil {
+			return v
+		}
+		return nil
+	}
+	return s
+}
+
+// insertSQL returns a multi-row INSERT of n rows into table.
+func insertSQL(dialect, table string, cols []loadColumn, n int) string {
+	return sqlbuild.Insert(dialect, table, columnNames(cols), n)
+}
+
+// rowReader reads the rows of a data file kept by its Keep function, with
+// the derived columns filled in, as the values bound for its columns. It
+// is the pgx.CopyFromSource of the COPY path (see pgcopy.go).
+type rowReader struct {
+	f       dataFile
+	cols    []loadColumn
+	dialect string
+	r       *bufio.Reader
+	lineNo  int
+	row     []string
+	values  []any
+	err     error
+	eof     bool
+}
+
+func newRowReader(f dataFile, in io.Reader, dialect string) *rowReader {
+	cols := loadTables[f.Table]
+	return &rowReader{
+		f: f, cols: cols, dialect: dialect,
+		r:      bufio.NewReaderSize(in, 1<<20),
+		row:    make([]string, len(cols)),
+		values: make([]any, len(cols)),
+	}
+}
+
+// Next reads the next row kept, reporting false at the end of the file
+// or on a read error.
+func (rr *rowReader) Next() bool {
+	for !rr.eof && rr.err == nil {
+		line, err := rr.r.ReadString('\n')
+		rr.lineNo++
+		switch {
+		case err == io.EOF:
+			rr.eof = true
+		case err != nil:
+			rr.err = err
+			return false
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" || rr.lineNo == 1 && rr.f.Header ||
+			strings.HasPrefix(line, "#") { // countryInfo.txt comments
+			continue
+		}
+		fields := strings.Split(strings.ToValidUTF8(line, "�"), "\t")
+		if rr.f.Keep != nil && !rr.f.Keep(fields) {
+			continue
+		}
+		clear(rr.row)
+		copy(rr.row, fields)
+		if rr.f.Derive != nil {
+			rr.f.Derive(rr.row)
+		}
+		for i, c := range rr.cols {
+			rr.values[i] = convertValue(rr.row[i], c.Kind, rr.dialect)
+		}
+		return true
+	}
+	return false
+}
+
+// Values returns the row read by Next; the slice is reused by the next
+// call.
+func (rr *rowReader) Values() ([]any, error) { return rr.values, nil }
+
+// Err returns the read error that stopped Next, if any.
+func (rr *rowReader) Err() error { return rr.err }
+
+// loadFile inserts the rows of f in a single transaction and returns how
+// many were inserted: with COPY on PostgreSQL (see pgcopy.go), with
+// batched INSERTs elsewhere. With reset it first deletes the rows of f
+// already in the table (see loadstate.go). The bytes read are counted on
+// task.
+func loadFile(db *gorm.DB, f dataFile, reset bool, task *progressTask) (int64, error) {
+	cols := loadTables[f.Table]
+	dialect := db.Dialector.Name()
+	batch := sqlbuild.MaxParams / len(cols)
+
+	in, err := os.Open(f.Path)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	rows := newRowReader(f, progressReader{in, task}, dialect)
+
+	if geocoder.IsPostgres(db) {
+		if n, ok, err := copyFile(db, f, rows, reset); ok {
+			return n, err
+		}
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return 0, err
+	}
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	if reset {
+		stmt, args := f.resetStatement(dialect)
+		if _, err := tx.Exec(stmt, args...); err != nil {
+			return 0, err
+		}
+	}
+	full, err := tx.Prepare(insertSQL(dialect, f.Table, cols, batch))
+	if err != nil {
+		return 0, err
+	}
+	defer full.Close()
+
+	var n int64
+	args := make([]any, 0, batch*len(cols))
+	flush := func() error {
+		if len(args) == 0 {
+			return nil
+		}
+		var err error
+		if rows := len(args) / len(cols); rows == batch {
+			_, err = full.Exec(args...)
+		} else {
+			_, err = tx.Exec(insertSQL(dialect, f.Table, cols, rows), args...)
+		}
+		n += int64(len(args) / len(cols))
+		args = args[:0]
+		return err
+	}
+
+	for rows.Next() {
+		args = append(args, rows.values...)
+		if len(args) == cap(args) {
+			if err := flush(); err != nil {
+				return n, fmt.Errorf("%s line %d: %w", f.Path, rows.lineNo, err)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return n, err
+	}
+	if err := flush(); err != nil {
+		return n, fmt.Errorf("%s: %w", f.Path, err)
+	}
+	return n, tx.Commit()
+}
+
+// continentCodes are static and inserted directly.
+var continentCodes = []struct {
+	Code, Name string
+	GeonameID  int64
+}{
+	{"AF", "Africa", 6255146},
+	{"AS", "Asia", 6255147},
+	{"EU", "Europe", 6255148},
+	{"NA", "North America", 6255149},
+	{"OC", "Oceania", 6255150},
+	{"SA", "South America", 6255151},
+	{"AN", "Antarctica", 6255152},
+}
+
+// createSpatialIndexes builds the PostgreSQL GIST indexes of the
+// earthdistance and PostGIS/Ganos strategies. Each is optional: failures
+// are reported and skipped, as managed services often lack an extension.
+func createSpatialIndexes(db *gorm.DB) {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range []string{
+			"CREATE EXTENSION IF NOT EXISTS cube",
+			"CREATE EXTENSION IF NOT EXISTS earthdistance",
+			"CREATE INDEX geoname_geo_idx ON geoname" +
+				" USING GIST (ll_to_earth(latitude, longitude))",
+			"CREATE INDEX postalcodes_geo_idx ON postalcodes" +
+				" USING GIST (ll_to_earth(latitude, longitude))",
+		} {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("  [earthdistance GIST indexes skipped: %v]\n", err)
+	} else {
+		fmt.Println("  [PostgreSQL: GIST geospatial indexes created via cube + earthdistance]")
+	}
+
+	ganos, postgis := geocoder.HasGanos(db), geocoder.HasPostGIS(db)
+	if !ganos && !postgis {
+		fmt.Println("  [Ganos/PostGIS indexes skipped: neither extension is available]")
+		return
+	}
+	// Ganos has no geometry::geography cast; index plain SRID 4326 geometry.
+	label, expr := "PostGIS", "ST_MakePoint(longitude, latitude)::geography"
+	if ganos {
+		label = "Ganos/ganos_spatialref"
+		expr = "ST_SetSRID(ST_MakePoint(longitude, latitude), 4326)"
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, table := range []string{"geoname", "postalcodes"} {
+			if err := tx.Exec(fmt.Sprintf(
+				"CREATE INDEX IF NOT EXISTS %s_postgis_idx ON %s USING GIST (%s)",
+				table, table, expr,
+			)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("  [%s GIST indexes skipped: %v]\n", label, err)
+	} else {
+		fmt.Printf("  [PostgreSQL: %s GIST indexes created]\n", label)
+	}
+}
+
+// forEach calls fn for the items with up to workers goroutines. After
+// the first error it starts no more items, and returns that error once
+// the running ones are done.
+func forEach[T any](items []T, workers int, fn func(T) error) error {
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		first error
+	)
+	next := make(chan T)
+	for range min(workers, len(items)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for it := range next {
+				if err := fn(it); err != nil {
+					mu.Lock()
+					if first == nil {
+						first = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, it := range items {
+		mu.Lock()
+		failed := first != nil
+		mu.Unlock()
+		if failed {
+			break
+		}
+		next <- it
+	}
+	close(next)
+	wg.Wait()
+	return first
+}
+
+// loadDatabase downloads the data files and loads them into db.
+func loadDatabase(db *gorm.DB, o loadOptions) error {
+	o = o.withDefaults()
+	if err := geocoder.SupportedDialect(db); err != nil {
+		return err
+	}
+	sel := newLoadSelection(o.Countries, o.FeatureClasses)
+	files := o.dataFiles(sel)
+	accessed := time.Now().UTC()
+
+	state, err := openLoadState(db, o)
+	if err != nil {
+		return err
+	}
+	if state.resumed {
+		fmt.Printf("\nResuming the interrupted load recorded in %s\n", state.path)
+	}
+	board := newProgress()
+	defer board.close()
+
+	fmt.Printf("\nDownloading data files (%d at a time):\n", o.Workers)
+	var mu sync.Mutex
+	missing := map[string]bool{}
+	err = forEach(files, o.Workers, func(f dataFile) error {
+		if _, ok := state.loaded(f.Path); ok {
+			board.Printf("  %s: already loaded, skipping.\n", filepath.Base(f.Path))
+			return nil
+		}
+		err := download(f, o.Stream, board)
+		if errors.Is(err, errNotFound) && f.Optional {
+			mu.Lock()
+			missing[f.URL] = true
+			mu.Unlock()
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("downloading %s: %w", f.URL, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	kept := files[:0]
+	for _, f := range files {
+		if missing[f.URL] {
+			fmt.Printf("  [%s not published, skipped]\n", f.URL)
+			continue
+		}
+		kept = append(kept, f)
+	}
+	files = kept
+
+	if o.Overwrite {
+		fmt.Println("\nDropping and recreating tables ...")
+		if err := dropTables(db); err != nil {
+			return err
+		}
+	} else {
+		fmt.Println("\nCreating tables (if not exist) ...")
+	}
+	if geocoder.IsPostgres(db) {
+		// Not needed by the loader, but the Python tools use it.
+		db.Exec("CREATE EXTENSION IF NOT EXISTS unaccent")
+	}
+	if err := runMigration(db, "schema.sql"); err != nil {
+		return err
+	}
+
+	// SQLite has a single writer: concurrent transactions would only
+	// wait for each other's locks.
+	workers := o.Workers
+	if db.Dialector.Name() == "sqlite" {
+		workers = 1
+	}
+	fmt.Printf("\nLoading data (%d file(s) at a time):\n", workers)
+	load := func(f dataFile) error {
+		if n, ok := state.loaded(f.Path); ok {
+			board.Printf("  %-18s %12d rows  %-22s (loaded before)\n", f.Table, n,
+				filepath.Base(f.Path))
+			return nil
+		}
+		var size int64 = -1
+		if st, err := os.Stat(f.Path); err == nil {
+			size = st.Size()
+		}
+		task := board.start(f.Table+" "+filepath.Base(f.Path), size)
+		start := time.Now()
+		n, err := loadFile(db, f, state.resumed, task)
+		task.finish()
+		if err != nil {
+			return fmt.Errorf("loading %s: %w", f.Table, err)
+		}
+		board.Printf("  %-18s %12d rows  %-22s %s\n", f.Table, n,
+			filepath.Base(f.Path), time.Since(start).Round(time.Second))
+		return state.fileLoaded(f.Path, n)
+	}
+	// Places first: the alternate names of a feature-class selection
+	// keep the places loaded.
+	var places, rest []dataFile
+	for _, f := range files {
+		if f.Table == "geoname" {
+			places = append(places, f)
+		} else {
+			rest = append(rest, f)
+		}
+	}
+	if err := forEach(places, workers, load); err != nil {
+		return err
+	}
+	if state.resumed && len(o.FeatureClasses) > 0 && o.AlternateNames {
+		if err := sel.restoreGeonameids(db); err != nil {
+			return err
+		}
+	}
+	if err := forEach(rest, workers, load); err != nil {
+		return err
+	}
+
+	fmt.Print("  Loading continentcodes ... ")
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM continentcodes").Error; err != nil {
+			return err
+		}
+		for _, c := range continentCodes {
+			if err := tx.Exec(
+				"INSERT INTO continentcodes (code, name, geonameid) VALUES (?, ?, ?)",
+				c.Code, c.Name, c.GeonameID,
+			).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println("done")
+
+	if !o.SkipIndexes {
+		fmt.Println("\nBuilding indexes and constraints (this may take a while) ...")
+		if err := state.runMigration(db, "indexes.sql"); err != nil {
+			return err
+		}
+		if len(o.Countries) == 0 && len(o.FeatureClasses) == 0 {
+			if err := state.runMigration(db, "foreign_keys.sql"); err != nil {
+				return err
+			}
+		} else {
+			fmt.Println("  [Foreign key constraints skipped: partial load]")
+		}
+		switch {
+		case geocoder.IsPostgres(db):
+			if !state.stepDone("spatial_indexes") {
+				createSpatialIndexes(db)
+				if err := state.completeStep("spatial_indexes"); err != nil {
+					return err
+				}
+			}
+			fmt.Print("  Running VACUUM ANALYZE ... ")
+			if err := db.Exec("VACUUM ANALYZE").Error; err != nil {
+				return err
+			}
+			fmt.Println("done")
+		case db.Dialector.Name() == "sqlite":
+			if err := db.Exec("ANALYZE").Error; err != nil {
+				return err
+			}
+		}
+		fmt.Println("  Indexes created.")
+	} else {
+		fmt.Println("\n  [Skipping indexes as requested]")
+	}
+
+	fmt.Println("\nInserting metadata ...")
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM schema_version").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(insertSchemaVersion, geocoder.SchemaVersion, accessed).Error; err != nil {
+			return err
+		}
+		return tx.Exec(
+			"INSERT INTO meta (version, data_uri, data_version, date_accessed)"+
+				" VALUES (?, ?, ?, ?)",
+			o.Meta.Version, o.Download.URLData, o.Meta.DataVersion, accessed,
+		).Error
+	})
+	if err != nil {
+		return err
+	}
+	return state.remove()
+}
+
+// removeDownloads deletes the files loadDatabase downloaded.
+func removeDownloads(o loadOptions) error {
+	o = o.withDefaults()
+	for _, f := range o.dataFiles(newLoadSelection(nil, nil)) {
+		for _, p := range downloadedFiles(f.Path) {
+			if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func runLoad(args []string) {
+	fs := flag.NewFlagSet("load", flag.ExitOnError)
+	cfgPath := fs.String(
+		"config", "../../../../config/config.yaml",
+		"Path to config YAML file (default: ../../../../config/config.yaml)",
+	)
+	rawURL := fs.String(
+		"url", "",
+		"Connection URL — overrides the database section of --config",
+	)
+	dataDir := fs.String("data-dir", "",
+		"Download directory (default: download.data_dir of --config, or data)")
+	countries := fs.String("countries", "",
+		"Comma-separated ISO 3166-1 alpha-2 codes to load (e.g. MX,US,CA; default: all)")
+	classes := fs.String("feature-classes", "",
+		"Comma-separated GeoNames feature classes to load (e.g. P,A; default: all)")
+	noAltNames := fs.Bool("no-alternate-names", false,
+		"Do not load the alternatename table")
+	linksOnly := fs.Bool("links-only", false,
+		"Load only the Wikipedia links and Wikidata IDs of the alternatename table "+
+			"(for --with-links; overrides --no-alternate-names)")
+	skipIndexes := fs.Bool("skip-indexes", false,
+		"Skip creating indexes and constraints")
+	var overwrite bool
+	fs.BoolVar(&overwrite, "overwrite", false,
+		"Drop and recreate all tables before loading")
+	fs.BoolVar(&overwrite, "o", false, "Shorthand for --overwrite")
+	stream := fs.Bool("stream", false,
+		"Unzip the archives while downloading them, without keeping them on disk")
+	workers := fs.Int("workers", 4,
+		"Files downloaded and loaded at a time (default: 4; SQLite loads one at a time)")
+	statePath := fs.String("state", "",
+		"Progress file for resuming an interrupted load (default: DATA_DIR/load-state.json)")
+	_ = fs.Parse(args)
+
+	o := loadOptions{
+		AlternateNames: !*noAltNames || *linksOnly,
+		LinksOnly:      *linksOnly,
+		SkipIndexes:    *skipIndexes,
+		Overwrite:      overwrite,
+		Stream:         *stream,
+		Workers:        *workers,
+		StateFile:      *statePath,
+	}
+	var err error
+	if o.Countries, err = parseCodeList(*countries, 2, ""); err != nil {
+		log.Fatalf("--countries: %v", err)
+	}
+	if o.FeatureClasses, err = parseCodeList(*classes, 1, featureClasses); err != nil {
+		log.Fatalf("--feature-classes: %v", err)
+	}
+
+	// The download and meta sections are read even when --url is given.
+	cfg := new(Config)
+	if c, err := loadConfig(*cfgPath); err == nil {
+		cfg = c
+	} else if *rawURL == "" {
+		log.Fatalf("config: %v", err)
+	}
+	o.Download, o.Meta = cfg.Download, cfg.Meta
+	if *dataDir != "" {
+		o.Download.DataDir = *dataDir
+	}
+
+	db, err := openDB(cfg, *rawURL)
+	if err != nil {
+		log.Fatalf("database: %v", err)
+	}
+
+	o = o.withDefaults()
+	fmt.Println(strings.Repeat("=", 60))
+	fmt.Println("Geonames database loader")
+	fmt.Printf("  Engine  : %s\n", db.Dialector.Name())
+	fmt.Printf("  Data dir: %s\n", o.Download.DataDir)
+	if len(o.Countries) > 0 {
+		fmt.Printf("  Countries: %s\n", strings.Join(o.Countries, ", "))
+	}
+	if len(o.FeatureClasses) > 0 {
+		fmt.Printf("  Classes : %s\n", strings.Join(o.FeatureClasses, ", "))
+	}
+	fmt.Println(strings.Repeat("=", 60))
+
+	if err := loadDatabase(db, o); err != nil {
+		log.Fatalf("load: %v", err)
+	}
+	fmt.Println("\nLoad complete.")
+}
@@ -0,0 +1,278 @@
+package main
+
+/*
+	Resumable loads: the loader's state file.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    go run . load --countries US,CA,MX,GT --workers 8
+	    ^C
+	    go run . load --countries US,CA,MX,GT --workers 8   # resumes
+
+	The loader records its progress in a JSON file, by default
+	load-state.json in the data directory (--state): the data files
+	loaded, with their row counts, and the statements of indexes.sql and
+	foreign_keys.sql already run. A load started again with the same
+	selection — dialect, countries, feature classes, alternate names —
+	skips what the file lists, so an interrupted multi-hour load picks up
+	at the file or index it was working on. Downloads resume on their own
+	(see download.go).
+
+	Each data file is loaded in one transaction, so an interrupted file
+	leaves no rows behind. The state is written right after the commit; to
+	cover a crash between the two, a resumed load first deletes whatever
+	rows of the file are in the table, in the same transaction as the new
+	ones.
+
+	The state file is removed when the load completes. It is discarded
+	when the selection differs, with --overwrite, and when the database
+	has no geoname table — the state describes one database, so give
+	loads of different databases sharing a data directory their own
+	--state.
+*/
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// loadState is the progress of a load, kept in a file.
+type loadState struct {
+	path    string
+	resumed bool
+	mu      sync.Mutex
+
+	// Selection identifies the load; a state of another one is ignored.
+	Selection string `json:"selection"`
+	// Files maps the data files loaded to their row counts.
+	Files map[string]int64 `json:"files"`
+	// Statements counts the statements run of each migration file.
+	Statements map[string]int `json:"statements"`
+	// Steps records other completed steps, e.g. spatial_indexes.
+	Steps map[string]bool `json:"steps,omitempty"`
+}
+
+// statePath returns the state file of o.
+func (o loadOptions) statePath() string {
+	if o.StateFile != "" {
+		return o.StateFile
+	}
+	return filepath.Join(o.Download.DataDir, "load-state.json")
+}
+
+// selection describes what o loads into db.
+func (o loadOptions) selection(db *gorm.DB) string {
+	s := fmt.Sprintf("dialect=%s countries=%s classes=%s alternatenames=%t",
+		db.Dialector.Name(), strings.Join(o.Countries, ","),
+		strings.Join(o.FeatureClasses, ","), o.AlternateNames)
+	if o.LinksOnly {
+		// Only when set, so the state of earlier loads stays valid.
+		s += " linksonly=true"
+	}
+	return s
+}
+
+// openLoadState reads the state of the load of o into db, or starts a new
+// one when there is none to resume.
+func openLoadState(db *gorm.DB, o loadOptions) (*loadState, error) {
+	s := &loadState{
+		path:       o.statePath(),
+		Selection:  o.selection(db),
+		Files:      map[string]int64{},
+		Statements: map[string]int{},
+		Steps:      map[string]bool{},
+	}
+	if o.Overwrite {
+		return s, s.remove()
+	}
+	old, err := readLoadState(s.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return s, nil
+	case err != nil:
+		return nil, err
+	case old.Selection != s.Selection:
+		fmt.Printf("  [%s is for another load (%s), starting over]\n",
+			s.path, old.Selection)
+		return s, nil
+	case !db.Migrator().HasTable("geoname"):
+		fmt.Printf("  [%s ignored: the database has no GeoNames tables]\n", s.path)
+		return s, nil
+	}
+	for k, v := range old.Files {
+		s.Files[k] = v
+	}
+	for k, v := range old.Statements {
+		s.Statements[k] = v
+	}
+	for k, v := range old.Steps {
+		s.Steps[k] = v
+	}
+	s.resumed = true
+	return s, nil
+}
+
+func readLoadState(path string) (*loadState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s loadState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// resumable reports whether a load of o into db would resume from a state
+// file.
+func (o loadOptions) resumable(db *gorm.DB) bool {
+	s, err := readLoadState(o.withDefaults().statePath())
+	return err == nil && s.Selection == o.selection(db)
+}
+
+// save writes the state, replacing the file atomically; s.mu must be
+// held.
+func (s *loadState) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// remove deletes the state file, once the load is complete.
+func (s *loadState) remove() error {
+	if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// loaded returns the rows loaded from the data file at path, if it was.
+func (s *loadState) loaded(path string) (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.Files[path]
+	return n, ok
+}
+
+// fileLoaded records the data file at path as loaded.
+func (s *loadState) fileLoaded(path string, rows int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Files[path] = rows
+	return s.save()
+}
+
+// stepDone reports whether step was completed.
+func (s *loadState) stepDone(step string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Steps[step]
+}
+
+// completeStep records step as completed.
+func (s *loadState) completeStep(step string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Steps[step] = true
+	return s.save()
+}
+
+// runMigration runs the statements of the migration file name that an
+// earlier, interrupted load did not run.
+func (s *loadState) runMigration(db *gorm.DB, name string) error {
+	stmts, err := migrationStatements(db, name)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	from := s.Statements[name]
+	s.mu.Unlock()
+	if from > 0 && from < len(stmts) {
+		fmt.Printf("  [%s: resuming at statement %d of %d]\n", name, from+1, len(stmts))
+	}
+	for i := from; i < len(stmts); i++ {
+		if err := db.Exec(stmts[i]).Error; err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		s.mu.Lock()
+		s.Statements[name] = i + 1
+		err := s.save()
+		s.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreGeonameids fills in the places kept by a feature-class filter
+// from the geoname table, when a resumed load skipped loading them.
+func (sel *loadSelection) restoreGeonameids(db *gorm.DB) error {
+	rows, err := db.Raw("SELECT geonameid FROM geoname").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		sel.geonameids[strconv.FormatInt(id, 10)] = struct{}{}
+	}
+	return rows.Err()
+}
+
+// resetStatement returns the DELETE that removes the rows of f from its
+// table, for reloading it on resume.
+func (f dataFile) resetStatement(dialect string) (string, []any) {
+	p := "?"
+	if dialect == "postgres" {
+		p = "$1"
+	}
+	switch {
+	case f.Country == "":
+	case f.Table == "geoname":
+		return "DELETE FROM geoname WHERE country = " + p, []any{f.Country}
+	case f.Table == "postalcodes":
+		return "DELETE FROM postalcodes WHERE countrycode = " + p, []any{f.Country}
+	case f.Table == "alternatename":
+		return "DELETE FROM alternatename WHERE geonameid IN" +
+			" (SELECT geonameid FROM geoname WHERE country = " + p + ")", []any{f.Country}
+	}
+	return "DELETE FROM " + f.Table, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+/*
+	Distance and bearing matrix between the nearest places.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    curl 'http://localhost:8080/reverse/matrix?lat=19.4326&lon=-99.1332'
+	    curl 'http://localhost:8080/reverse/matrix?lat=19.4326&lon=-99.1332&results=5&feature_class=P&units=mi'
+
+	The library side, and how it works, is in pkg/geocoder/matrix.go.
+*/
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rgglez/geonames-loader/examples/go/pkg/geocoder"
+)
+
+// handleMatrix answers GET /reverse/matrix?lat=..&lon=..[&results=N]
+// [&country=CC][&feature_class=P][&min_population=N][&units=km|mi|nmi].
+func (s *server) handleMatrix(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	lat, err := parseCoord(q.Get("lat"), 90)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "lat: "+err.Error())
+		return
+	}
+	lon, err := parseCoord(q.Get("lon"), 180)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "lon: "+err.Error())
+		return
+	}
+	opts := geocoder.QueryOptions{
+		Limit:        s.cfg.DefaultResults,
+		Country:      strings.ToUpper(q.Get("country")),
+		FeatureClass: strings.ToUpper(q.Get("feature_class")),
+	}
+	if v := q.Get("results"); v != "" {
+		opts.Limit, err = strconv.Atoi(v)
+		if err != nil || opts.Limit < 1 || opts.Limit > s.cfg.MaxResults {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf(
+				"results must be an integer between 1 and %d", s.cfg.MaxResults,
+			))
+			return
+		}
+	}
+	if v := q.Get("min_population"); v != "" {
+		opts.MinPopulation, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || opts.MinPopulation < 0 {
+			writeError(w, http.StatusBadRequest, "min_population must be a non-negative integer")
+			return
+		}
+	}
+	units := q.Get("units")
+	if units == "" {
+		units = s.cfg.DefaultUnits
+	}
+	if units != "" {
+		if err := checkUnits(units); err != nil {
+			writeError(w, http.StatusBadRequest, "units: "+err.Error())
+			return
+		}
+	}
+
+	release, ok := s.acquire(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+
+	m, err := s.geo.WithContext(r.Context()).PlaceMatrix(lat, lon, opts)
+	if err != nil && !geocoder.IsNoResult(err) {
+		log.Printf("matrix: %v", err)
+		s.writeQueryError(w, err)
+		return
+	}
+	if m.Places == nil {
+		m = geocoder.MatrixResult{
+			Latitude: lat, Longitude: lon, Places: []geocoder.Place{},
+			From: []geocoder.Leg{}, DistancesKm: [][]float64{}, Bearings: [][]float64{},
+			Strategy: s.strategy,
+		}
+	}
+	if units != "" {
+		m.Units = units
+		for i := range m.Places {
+			m.Places[i].Distance = fromKm(m.Places[i].DistanceKm, units)
+			m.From[i].Distance = fromKm(m.From[i].DistanceKm, units)
+		}
+		m.Distances = make([][]float64, len(m.DistancesKm))
+		for i, row := range m.DistancesKm {
+			m.Distances[i] = make([]float64, len(row))
+			for j, km := range row {
+				m.Distances[i][j] = fromKm(km, units)
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, m)
+}
@@ -0,0 +1,296 @@
+package main
+
+/*
+	Prometheus metrics for the server.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    curl http://localhost:8080/metrics
+
+	    scrape_configs:                      # prometheus.yml
+	      - job_name: geonames
+	        static_configs:
+	          - targets: ["geocoder:8080"]
+
+	GET /metrics answers in the Prometheus text exposition format (0.0.4),
+	written by hand so the server needs no client library:
+
+	    geonames_http_requests_total{endpoint,code}        counter
+	    geonames_http_request_duration_seconds{endpoint,strategy}
+	                                                       histogram
+	    geonames_query_errors_total{reason}                counter
+	        reason: database, unavailable (circuit breaker open),
+	        queue_full, queue_timeout
+	    geonames_queries_in_flight, geonames_queries_waiting
+	                                                       gauges
+	    geonames_db_connections{state}                     gauge
+	        state: open, in_use, idle, max
+	    geonames_db_wait_total, geonames_db_wait_seconds_total
+	                                                       counters
+	    geonames_cache_hits_total, geonames_cache_misses_total
+	                                                       counters
+	    geonames_cache_hit_ratio                           gauge
+
+	endpoint is the route path (/reverse, /searchJSON, ...) and strategy
+	the resolved distance strategy (postgis, haversine, ...). Requests
+	rejected by authentication, rate limiting or the query queue are
+	counted with their status code. The cache metrics count lookups since
+	the cache was opened (see "cache stats" for the totals kept in the
+	file) and are left out without --cache.
+
+	The endpoint is not behind authentication or rate limiting; keep the
+	port off the public network or filter /metrics at the proxy. Metrics
+	survive a configuration reload (see reload.go).
+*/
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the request
+// duration histogram: the Prometheus client defaults.
+var latencyBuckets = []float64{
+	0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10,
+}
+
+type requestLabels struct {
+	endpoint string
+	code     int
+}
+
+type latencyLabels struct {
+	endpoint, strategy string
+}
+
+type histogram struct {
+	counts []int64 // per bucket, not cumulative; the last is +Inf
+	sum    float64
+	count  int64
+}
+
+func (h *histogram) observe(v float64) {
+	i, _ := slices.BinarySearch(latencyBuckets, v)
+	h.counts[i]++
+	h.sum += v
+	h.count++
+}
+
+// metrics holds the counters of a server and of the servers that replace
+// it on reload.
+type metrics struct {
+	mu          sync.Mutex
+	requests    map[requestLabels]int64
+	latency     map[latencyLabels]*histogram
+	queryErrors map[string]int64
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		requests:    map[requestLabels]int64{},
+		latency:     map[latencyLabels]*histogram{},
+		queryErrors: map[string]int64{},
+	}
+}
+
+func (m *metrics) observe(endpoint, strategy string, code int, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[requestLabels{endpoint, code}]++
+	k := latencyLabels{endpoint, strategy}
+	h := m.latency[k]
+	if h == nil {
+		h = &histogram{counts: make([]int64, len(latencyBuckets)+1)}
+		m.latency[k] = h
+	}
+	h.observe(d.Seconds())
+}
+
+func (m *metrics) queryError(reason string) {
+	m.mu.Lock()
+	m.queryErrors[reason]++
+	m.mu.Unlock()
+}
+
+// ---------------------------------------------------------------------------
+// Instrumentation
+// ---------------------------------------------------------------------------
+
+// statusRecorder remembers the status code written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// instrument counts and times the requests of the route pattern.
+func (s *server) instrument(pattern string, next http.Handler) http.Handler {
+	_, endpoint, _ := strings.Cut(pattern, " ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.code == 0 {
+			rec.code = http.StatusOK
+		}
+		s.metrics.observe(endpoint, s.strategyName, rec.code, time.Since(start))
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Exposition
+// ---------------------------------------------------------------------------
+
+func (s *server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	s.writeMetrics(w)
+}
+
+// writeMetrics writes the metrics of s in the text exposition format.
+func (s *server) writeMetrics(w io.Writer) {
+	m := s.metrics
+	m.mu.Lock()
+	metricHeader(w, "geonames_http_requests_total", "counter",
+		"HTTP requests by endpoint and status code.")
+	reqs := slices.SortedFunc(maps.Keys(m.requests), func(a, b requestLabels) int {
+		if c := strings.Compare(a.endpoint, b.endpoint); c != 0 {
+			return c
+		}
+		return a.code - b.code
+	})
+	for _, k := range reqs {
+		fmt.Fprintf(w, "geonames_http_requests_total{endpoint=%s,code=\"%d\"} %d\n",
+			quoteLabel(k.endpoint), k.code, m.requests[k])
+	}
+
+	metricHeader(w, "geonames_http_request_duration_seconds", "histogram",
+		"HTTP request latency by endpoint and distance strategy.")
+	lats := slices.SortedFunc(maps.Keys(m.latency), func(a, b latencyLabels) int {
+		if c := strings.Compare(a.endpoint, b.endpoint); c != 0 {
+			return c
+		}
+		return strings.Compare(a.strategy, b.strategy)
+	})
+	for _, k := range lats {
+		h := m.latency[k]
+		labels := fmt.Sprintf("endpoint=%s,strategy=%s",
+			quoteLabel(k.endpoint), quoteLabel(k.strategy))
+		var cum int64
+		for i, le := range latencyBuckets {
+			cum += h.counts[i]
+			fmt.Fprintf(w, "geonames_http_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n",
+				labels, formatFloat(le), cum)
+		}
+		fmt.Fprintf(w, "geonames_http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n",
+			labels, h.count)
+		fmt.Fprintf(w, "geonames_http_request_duration_seconds_sum{%s} %s\n",
+			labels, formatFloat(h.sum))
+		fmt.Fprintf(w, "geonames_http_request_duration_seconds_count{%s} %d\n",
+			labels, h.count)
+	}
+
+	metricHeader(w, "geonames_query_errors_total", "counter",
+		"Failed or rejected database queries by reason.")
+	for _, reason := range []string{"database", "unavailable", "queue_full", "queue_timeout"} {
+		fmt.Fprintf(w, "geonames_query_errors_total{reason=%q} %d\n",
+			reason, m.queryErrors[reason])
+	}
+	m.mu.Unlock()
+
+	metricHeader(w, "geonames_queries_in_flight", "gauge",
+		"Database queries running.")
+	fmt.Fprintf(w, "geonames_queries_in_flight %d\n", len(s.queue.slots))
+	metricHeader(w, "geonames_queries_waiting", "gauge",
+		"Requests waiting for a query slot.")
+	fmt.Fprintf(w, "geonames_queries_waiting %d\n", s.queue.waiting.Load())
+
+	if sqlDB, err := s.db.DB(); err == nil {
+		st := sqlDB.Stats()
+		metricHeader(w, "geonames_db_connections", "gauge",
+			"Database connections by state.")
+		for _, c := range []struct {
+			state string
+			n     int
+		}{
+			{"open", st.OpenConnections}, {"in_use", st.InUse},
+			{"idle", st.Idle}, {"max", st.MaxOpenConnections},
+		} {
+			fmt.Fprintf(w, "geonames_db_connections{state=%q} %d\n", c.state, c.n)
+		}
+		metricHeader(w, "geonames_db_wait_total", "counter",
+			"Waits for a free database connection.")
+		fmt.Fprintf(w, "geonames_db_wait_total %d\n", st.WaitCount)
+		metricHeader(w, "geonames_db_wait_seconds_total", "counter",
+			"Time spent waiting for a free database connection.")
+		fmt.Fprintf(w, "geonames_db_wait_seconds_total %s\n",
+			formatFloat(st.WaitDuration.Seconds()))
+	}
+
+	if s.cache != nil {
+		hits, misses := s.cache.Counters()
+		metricHeader(w, "geonames_cache_hits_total", "counter",
+			"Lookups answered by the lookup cache.")
+		fmt.Fprintf(w, "geonames_cache_hits_total %d\n", hits)
+		metricHeader(w, "geonames_cache_misses_total", "counter",
+			"Lookups not found in the lookup cache.")
+		fmt.Fprintf(w, "geonames_cache_misses_total %d\n", misses)
+		ratio := 0.0
+		if hits+misses > 0 {
+			ratio = float64(hits) / float64(hits+misses)
+		}
+		metricHeader(w, "geonames_cache_hit_ratio", "gauge",
+			"Share of lookups answered by the lookup cache.")
+		fmt.Fprintf(w, "geonames_cache_hit_ratio %s\n", formatFloat(ratio))
+	}
+}
+
+func metricHeader(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// quoteLabel quotes a label value, escaping backslashes, quotes and
+// newlines.
+func quoteLabel(v string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(v) + `"`
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,5 @@
+-- Foreign keys for MySQL / MariaDB. Skipped when only some countries or feature
+-- classes were loaded, since countryinfo always holds every country.
+
+ALTER TABLE countryinfo ADD CONSTRAINT countryinfo_geonameid_fkey FOREIGN KEY (geonameid) REFERENCES geoname(geonameid);
+ALTER TABLE alternatename ADD CONSTRAINT alternatename_geonameid_fkey FOREIGN KEY (geonameid) REFERENCES geoname(geonameid);
@@ -0,0 +1,135 @@
+-- GeoNames schema for MySQL / MariaDB, as created by load_geonames.py.
+-- Primary keys, foreign keys and indexes are added after the bulk load
+-- (indexes.sql, foreign_keys.sql).
+
+CREATE TABLE IF NOT EXISTS geoname (
+    geonameid      INTEGER,
+    name           VARCHAR(200),
+    asciiname      VARCHAR(200),
+    alternatenames TEXT,
+    latitude       FLOAT,
+    longitude      FLOAT,
+    fclass         CHAR(1),
+    fcode          VARCHAR(10),
+    country        VARCHAR(3),
+    cc2            TEXT,
+    admin1         VARCHAR(20),
+    admin2         VARCHAR(80),
+    admin3         VARCHAR(20),
+    admin4         VARCHAR(20),
+    population     BIGINT,
+    elevation      INTEGER,
+    gtopo30        INTEGER,
+    timezone       VARCHAR(40),
+    moddate        DATE
+) DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS alternatename (
+    alternatenameid INTEGER,
+    geonameid       INTEGER,
+    isolanguage     VARCHAR(7),
+    alternatename   VARCHAR(500),
+    ispreferredname BOOL,
+    isshortname     BOOL,
+    iscolloquial    BOOL,
+    ishistoric      BOOL
+) DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS countryinfo (
+    iso_alpha2           CHAR(2),
+    iso_alpha3           CHAR(3),
+    iso_numeric          INTEGER,
+    fips_code            VARCHAR(3),
+    country              VARCHAR(200),
+    capital              VARCHAR(200),
+    areainsqkm           FLOAT,
+    population           INTEGER,
+    continent            CHAR(3),
+    tld                  CHAR(10),
+    currency_code        CHAR(3),
+    currency_name        CHAR(25),
+    phone                VARCHAR(20),
+    postal               VARCHAR(60),
+    postalregex          VARCHAR(200),
+    languages            VARCHAR(200),
+    geonameid            INTEGER,
+    neighbours           VARCHAR(50),
+    equivalent_fips_code VARCHAR(3)
+) DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS iso_languagecodes (
+    iso_639_3     CHAR(4),
+    iso_639_2     VARCHAR(50),
+    iso_639_1     VARCHAR(50),
+    language_name VARCHAR(200)
+) DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS admin1codesascii (
+    code        CHAR(20),
+    name        TEXT,
+    nameascii   TEXT,
+    geonameid   INTEGER,
+    countrycode VARCHAR(25)
+) DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS admin2codesascii (
+    code        CHAR(80),
+    name        TEXT,
+    nameascii   TEXT,
+    geonameid   INTEGER,
+    countrycode VARCHAR(25)
+) DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS featurecodes (
+    code        CHAR(7),
+    name        VARCHAR(200),
+    description TEXT
+) DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS timezones (
+    countrycode CHAR(20),
+    timezoneid  VARCHAR(200),
+    gmt_offset  NUMERIC(3, 1),
+    dst_offset  NUMERIC(3, 1),
+    raw_offset  NUMERIC(3, 1)
+) DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS continentcodes (
+    code      CHAR(2),
+    name      VARCHAR(20),
+    geonameid INTEGER
+) DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS postalcodes (
+    countrycode     CHAR(2),
+    postalcode      VARCHAR(20),
+    placename       VARCHAR(180),
+    admin1name      VARCHAR(100),
+    admin1code      VARCHAR(20),
+    admin2name      VARCHAR(100),
+    admin2code      VARCHAR(20),
+    admin3name      VARCHAR(100),
+    admin3code      VARCHAR(20),
+    latitude        FLOAT,
+    longitude       FLOAT,
+    accuracy        SMALLINT,
+    admin1code_full VARCHAR(100),
+    admin2code_full VARCHAR(100),
+    admin3code_full VARCHAR(100),
+    admin1nameascii VARCHAR(100),
+    admin2nameascii VARCHAR(100),
+    admin3nameascii VARCHAR(100)
+) DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS meta (
+    version       TEXT,
+    data_uri      TEXT,
+    data_version  TEXT,
+    date_accessed DATETIME
+) DEFAULT CHARSET=utf8mb4;
+
+-- Version of this schema (see schema.go); one row.
+CREATE TABLE IF NOT EXISTS schema_version (
+    version    INTEGER NOT NULL,
+    applied_at DATETIME
+) DEFAULT CHARSET=utf8mb4;
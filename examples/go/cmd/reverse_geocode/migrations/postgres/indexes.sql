@@ -0,0 +1,50 @@
+-- Primary keys and indexes for PostgreSQL, applied after the bulk load.
+
+ALTER TABLE ONLY alternatename ADD CONSTRAINT alternatenameid_pkey PRIMARY KEY (alternatenameid);
+ALTER TABLE ONLY geoname ADD CONSTRAINT geonameid_pkey PRIMARY KEY (geonameid);
+ALTER TABLE ONLY countryinfo ADD CONSTRAINT iso_alpha2_pkey PRIMARY KEY (iso_alpha2);
+
+CREATE INDEX countryinfo_geonameid_idx ON countryinfo (geonameid);
+CREATE INDEX alternatename_geonameid_idx ON alternatename (geonameid);
+CREATE INDEX alternatename_isolanguage_idx ON alternatename (isolanguage);
+CREATE INDEX alternatename_alternatename_idx ON alternatename (alternatename);
+CREATE INDEX alternatename_ispreferredname_idx ON alternatename (ispreferredname);
+CREATE INDEX alternatename_isshortname_idx ON alternatename (isshortname);
+CREATE INDEX alternatename_iscolloquial_idx ON alternatename (iscolloquial);
+CREATE INDEX alternatename_ishistoric_idx ON alternatename (ishistoric);
+CREATE INDEX geoname_name_idx ON geoname (name);
+CREATE INDEX geoname_asciiname_idx ON geoname (asciiname);
+CREATE INDEX geoname_fclass_idx ON geoname (fclass);
+CREATE INDEX geoname_fcode_idx ON geoname (fcode);
+CREATE INDEX geoname_country_idx ON geoname (country);
+CREATE INDEX geoname_cc2_idx ON geoname (cc2);
+CREATE INDEX geoname_admin1_idx ON geoname (admin1);
+CREATE INDEX geoname_admin2_idx ON geoname (admin2);
+CREATE INDEX geoname_admin3_idx ON geoname (admin3);
+CREATE INDEX geoname_admin4_idx ON geoname (admin4);
+CREATE INDEX postalcodes_countrycode_idx ON postalcodes (countrycode);
+CREATE INDEX postalcodes_admin1name_idx ON postalcodes (admin1name);
+CREATE INDEX postalcodes_admin1code_idx ON postalcodes (admin1code);
+CREATE INDEX postalcodes_admin2name_idx ON postalcodes (admin2name);
+CREATE INDEX postalcodes_admin2code_idx ON postalcodes (admin2code);
+CREATE INDEX postalcodes_admin3name_idx ON postalcodes (admin3name);
+CREATE INDEX postalcodes_admin3code_idx ON postalcodes (admin3code);
+CREATE INDEX postalcodes_admin1code_full_idx ON postalcodes (admin1code_full);
+CREATE INDEX postalcodes_admin2code_full_idx ON postalcodes (admin2code_full);
+CREATE INDEX postalcodes_admin3code_full_idx ON postalcodes (admin3code_full);
+CREATE INDEX postalcodes_admin1nameascii_idx ON postalcodes (admin1nameascii);
+CREATE INDEX postalcodes_admin2nameascii_idx ON postalcodes (admin2nameascii);
+CREATE INDEX postalcodes_admin3nameascii_idx ON postalcodes (admin3nameascii);
+CREATE INDEX admin1codesascii_countrycode_idx ON admin1codesascii (countrycode);
+CREATE INDEX admin1codesascii_name_idx ON admin1codesascii (name);
+CREATE INDEX admin1codesascii_nameascii_idx ON admin1codesascii (nameascii);
+CREATE INDEX admin1codesascii_code_idx ON admin1codesascii (code);
+CREATE INDEX admin2codesascii_countrycode_idx ON admin2codesascii (countrycode);
+CREATE INDEX admin2codesascii_name_idx ON admin2codesascii (name);
+CREATE INDEX admin2codesascii_nameascii_idx ON admin2codesascii (nameascii);
+CREATE INDEX admin2codesascii_code_idx ON admin2codesascii (code);
+CREATE INDEX geoname_latitude_idx ON geoname (latitude);
+CREATE INDEX geoname_longitude_idx ON geoname (longitude);
+CREATE INDEX postalcodes_latitude_idx ON postalcodes (latitude);
+CREATE INDEX postalcodes_longitude_idx ON postalcodes (longitude);
+CREATE INDEX postalcodes_cc_lat_lon_idx ON postalcodes (countrycode, latitude, longitude);
@@ -0,0 +1,97 @@
+package main
+
+/*
+	Nearest feature of each kind (--nearby, GET /reverse/nearby).
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    go run . --lat 20.6767 --lon -103.3475 --nearby --units mi
+	    curl 'http://localhost:8080/reverse/nearby?lat=20.6767&lon=-103.3475'
+
+	The groups are those of the nearby list in the server section of the
+	config, or geocoder.DefaultFeatureGroups. The library side, and how it
+	works, is in pkg/geocoder/nearby.go.
+*/
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/rgglez/geonames-loader/examples/go/pkg/geocoder"
+)
+
+// nearbyGroups returns the option setting the configured groups, if any.
+func nearbyGroups(groups []geocoder.FeatureGroup) geocoder.Option {
+	if len(groups) == 0 {
+		return func(*geocoder.Geocoder) {}
+	}
+	return geocoder.WithFeatureGroups(groups)
+}
+
+// nearbyUnits fills in the distances of res in units other than km.
+func nearbyUnits(res *geocoder.NearbyResult, units string) {
+	if units == "" || units == "km" {
+		return
+	}
+	for _, f := range res.Features {
+		if f.Place != nil {
+			f.Place.Distance = fromKm(f.Place.DistanceKm, units)
+		}
+	}
+}
+
+// handleNearby answers GET /reverse/nearby?lat=..&lon=..[&units=km|mi|nmi].
+func (s *server) handleNearby(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	lat, err := parseCoord(q.Get("lat"), 90)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "lat: "+err.Error())
+		return
+	}
+	lon, err := parseCoord(q.Get("lon"), 180)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "lon: "+err.Error())
+		return
+	}
+	units := q.Get("units")
+	if units == "" {
+		units = s.cfg.DefaultUnits
+	}
+	if units != "" {
+		if err := checkUnits(units); err != nil {
+			writeError(w, http.StatusBadRequest, "units: "+err.Error())
+			return
+		}
+	}
+
+	release, ok := s.acquire(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+
+	res, err := s.geo.WithContext(r.Context()).Nearby(lat, lon)
+	if err != nil {
+		log.Printf("nearby: %v", err)
+		s.writeQueryError(w, err)
+		return
+	}
+	nearbyUnits(res, units)
+	writeJSON(w, http.StatusOK, res)
+}
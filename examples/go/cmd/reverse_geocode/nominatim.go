@@ -0,0 +1,352 @@
+package main
+
+/*
+	Nominatim-compatible reverse geocoding endpoint for the HTTP server.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	GET /nominatim/reverse answers in the shape of Nominatim's /reverse
+	(https://nominatim.org/release-docs/latest/api/Reverse/), so clients
+	written for Nominatim work after pointing their base URL at
+	http://host:port/nominatim.
+
+	Supported parameters: lat, lon, format (json, jsonv2, geojson; default
+	jsonv2), zoom (3 country, 5 state, 8 county, anything higher the nearest
+	place) and addressdetails (default 1). Other parameters are accepted and
+	ignored.
+
+	GeoNames fields are mapped onto the OSM-style ones:
+	  place_id, osm_id   ← geonameid      (osm_type "node"; "relation" for
+	                                        the divisions returned at low zoom)
+	  class / category   ← feature class  (P → place, A → boundary, ...)
+	  type               ← feature code   (PPLC → city, ADM1 → administrative, ...)
+	  address            ← admin1/admin2/country names and the nearest
+	                       postal code
+	The bounding box is a nominal box around the point, since GeoNames has
+	no extents.
+*/
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rgglez/geonames-loader/examples/go/internal/textutil"
+	"github.com/rgglez/geonames-loader/examples/go/pkg/geocoder"
+)
+
+const nominatimLicence = "Data © GeoNames (https://www.geonames.org), CC BY 4.0"
+
+// nominatimPlace is a single /reverse result in Nominatim's json/jsonv2
+// layout. Class and Category carry the same value; which one is emitted
+// depends on the requested format.
+type nominatimPlace struct {
+	PlaceID     int64             `json:"place_id"`
+	Licence     string            `json:"licence"`
+	OSMType     string            `json:"osm_type"`
+	OSMID       int64             `json:"osm_id"`
+	Lat         string            `json:"lat"`
+	Lon         string            `json:"lon"`
+	Class       string            `json:"class,omitempty"`
+	Category    string            `json:"category,omitempty"`
+	Type        string            `json:"type"`
+	PlaceRank   int               `json:"place_rank,omitempty"`
+	Importance  float64           `json:"importance"`
+	AddressType string            `json:"addresstype,omitempty"`
+	Name        string            `json:"name"`
+	DisplayName string            `json:"display_name"`
+	Address     map[string]string `json:"address,omitempty"`
+	BoundingBox []string          `json:"boundingbox"`
+}
+
+// nominatimClass maps a GeoNames feature class to an OSM-style class.
+var nominatimClass = map[string]string{
+	"A": "boundary",
+	"H": "natural",
+	"L": "landuse",
+	"P": "place",
+	"R": "highway",
+	"S": "amenity",
+	"T": "natural",
+	"U": "natural",
+	"V": "natural",
+}
+
+// nominatimType maps a GeoNames feature to an OSM-style type and its
+// place_rank.
+func nominatimType(fclass, fcode string, population int64) (string, int) {
+	switch {
+	case fcode == "PPLX":
+		return "suburb", 20
+	case fclass == "P" && (strings.HasPrefix(fcode, "PPLA") ||
+		fcode == "PPLC" || population >= 100_000):
+		return "city", 16
+	case fclass == "P" && population >= 10_000:
+		return "town", 18
+	case fclass == "P":
+		return "village", 19
+	case fclass == "A" && strings.HasPrefix(fcode, "PCL"):
+		return "country", 4
+	case fclass == "A":
+		return "administrative", 12
+	case fcode == "AIRP":
+		return "aerodrome", 30
+	}
+	return strings.ToLower(fcode), 30
+}
+
+func (s *server) handleNominatimReverse(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	format := q.Get("format")
+	if format == "" {
+		format = "jsonv2"
+	}
+	if format != "json" && format != "jsonv2" && format != "geojson" {
+		writeError(w, http.StatusBadRequest,
+			"format must be one of json, jsonv2, geojson")
+		return
+	}
+	lat, err := parseCoord(q.Get("lat"), 90)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "lat: "+err.Error())
+		return
+	}
+	lon, err := parseCoord(q.Get("lon"), 180)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "lon: "+err.Error())
+		return
+	}
+	zoom := 18
+	if v := q.Get("zoom"); v != "" {
+		if zoom, err = strconv.Atoi(v); err != nil {
+			writeError(w, http.StatusBadRequest, "zoom must be an integer")
+			return
+		}
+	}
+	addressDetails := q.Get("addressdetails") != "0"
+
+	release, ok := s.acquire(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+
+	place, err := nominatimLookup(s.geo.WithContext(r.Context()), lat, lon, zoom)
+	if err != nil {
+		log.Printf("nominatim reverse: %v", err)
+		s.writeQueryError(w, err)
+		return
+	}
+	if place == nil {
+		// Nominatim reports "nothing here" with 200 and an error member.
+		writeJSON(w, http.StatusOK, map[string]string{"error": "Unable to geocode"})
+		return
+	}
+	if !addressDetails {
+		place.Address = nil
+	}
+
+	switch format {
+	case "json":
+		place.Class = place.Category
+		place.Category, place.PlaceRank, place.AddressType = "", 0, ""
+		writeJSON(w, http.StatusOK, place)
+	case "jsonv2":
+		writeJSON(w, http.StatusOK, place)
+	case "geojson":
+		writeJSON(w, http.StatusOK, nominatimGeoJSON(place))
+	}
+}
+
+// nominatimLookup builds the result for (lat, lon) at the given zoom, or
+// returns nil when there is no place nearby.
+func nominatimLookup(
+	geo *geocoder.Geocoder, lat, lon float64, zoom int,
+) (*nominatimPlace, error) {
+	places, err := geo.NearestPlaces(lat, lon, geocoder.QueryOptions{Limit: 1})
+	if geocoder.IsNoResult(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	g := places.Rows[0]
+
+	names, err := geo.AdminNames(g.Country, g.Admin1, g.Admin2)
+	if err != nil {
+		return nil, err
+	}
+	postcode := g.Postalcode
+	if postcode == "" {
+		postal, err := geo.NearestPostal(lat, lon, geocoder.QueryOptions{
+			Limit: 1, Country: g.Country,
+		})
+		if err != nil && !geocoder.IsNoResult(err) {
+			return nil, err
+		}
+		if len(postal.Rows) > 0 {
+			postcode = postal.Rows[0].Postalcode
+		}
+	}
+
+	typ, rank := nominatimType(g.Fclass, g.Fcode, g.Population)
+	p := &nominatimPlace{
+		PlaceID:    g.Geonameid,
+		Licence:    nominatimLicence,
+		OSMType:    "node",
+		OSMID:      g.Geonameid,
+		Category:   nominatimClass[g.Fclass],
+		Type:       typ,
+		PlaceRank:  rank,
+		Importance: importance(g.Population),
+		Name:       g.Name,
+		Address:    map[string]string{},
+	}
+	placeLat, placeLon := g.Latitude, g.Longitude
+	if p.Category == "" {
+		p.Category = "place"
+	}
+
+	// Coarse zoom levels answer with the containing division instead of
+	// the nearest feature.
+	var areaID int64
+	switch {
+	case zoom <= 3 && names.Country != "":
+		p.Name, p.Type, p.PlaceRank, areaID = names.Country, "country", 4, names.CountryID
+	case zoom <= 5 && names.Admin1 != "":
+		p.Name, p.Type, p.PlaceRank, areaID = names.Admin1, "state", 8, names.Admin1ID
+	case zoom <= 8 && names.Admin2 != "":
+		p.Name, p.Type, p.PlaceRank, areaID = names.Admin2, "county", 12, names.Admin2ID
+	}
+	if areaID != 0 {
+		p.Category, p.Importance = "boundary", 0
+		p.PlaceID, p.OSMID, p.OSMType = areaID, areaID, "relation"
+		var coords []struct {
+			Latitude  float64 `gorm:"column:latitude"`
+			Longitude float64 `gorm:"column:longitude"`
+		}
+		err := geo.DB().Raw(
+			"SELECT latitude, longitude FROM "+geocoder.TableName(geo.DB(), "geoname")+" WHERE geonameid = ?",
+			areaID,
+		).Scan(&coords).Error
+		if err != nil {
+			return nil, err
+		}
+		if len(coords) > 0 {
+			placeLat, placeLon = coords[0].Latitude, coords[0].Longitude
+		}
+	}
+	p.AddressType = p.Type
+	if p.Category == "boundary" && p.Type == "administrative" {
+		p.AddressType = "county"
+	}
+	p.Lat = strconv.FormatFloat(placeLat, 'f', 7, 64)
+	p.Lon = strconv.FormatFloat(placeLon, 'f', 7, 64)
+	p.BoundingBox = nominalBBox(placeLat, placeLon, p.PlaceRank)
+
+	// Address: from the feature itself outwards, as Nominatim orders it.
+	display := []string{p.Name}
+	if p.PlaceRank > 12 {
+		p.Address[p.AddressType] = p.Name
+	}
+	if names.Admin2 != "" && p.PlaceRank > 8 {
+		p.Address["county"] = names.Admin2
+		display = append(display, names.Admin2)
+	}
+	if names.Admin1 != "" && p.PlaceRank > 4 {
+		p.Address["state"] = names.Admin1
+		display = append(display, names.Admin1)
+	}
+	if postcode != "" && p.PlaceRank > 12 {
+		p.Address["postcode"] = postcode
+		display = append(display, postcode)
+	}
+	if names.Country != "" {
+		p.Address["country"] = names.Country
+		if p.PlaceRank > 4 {
+			display = append(display, names.Country)
+		}
+	}
+	p.Address["country_code"] = strings.ToLower(g.Country)
+	p.DisplayName = textutil.JoinDistinct(display)
+	return p, nil
+}
+
+// importance approximates Nominatim's 0–1 importance from population.
+func importance(population int64) float64 {
+	if population <= 0 {
+		return 0
+	}
+	return math.Round(math.Min(1, math.Log10(float64(population))/8)*1e4) / 1e4
+}
+
+// nominalBBox returns a Nominatim-style [minlat, maxlat, minlon, maxlon]
+// box around a point, sized by place rank.
+func nominalBBox(lat, lon float64, rank int) []string {
+	d := 0.005
+	switch {
+	case rank <= 8:
+		d = 1
+	case rank <= 16:
+		d = 0.1
+	case rank <= 19:
+		d = 0.02
+	}
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 7, 64) }
+	return []string{f(lat - d), f(lat + d), f(lon - d), f(lon + d)}
+}
+
+// nominatimGeoJSON wraps a place in Nominatim's format=geojson layout.
+func nominatimGeoJSON(p *nominatimPlace) map[string]any {
+	lat, _ := strconv.ParseFloat(p.Lat, 64)
+	lon, _ := strconv.ParseFloat(p.Lon, 64)
+	bb := make([]float64, 4)
+	for i, v := range p.BoundingBox {
+		bb[i], _ = strconv.ParseFloat(v, 64)
+	}
+	props := map[string]any{
+		"place_id":     p.PlaceID,
+		"osm_type":     p.OSMType,
+		"osm_id":       p.OSMID,
+		"place_rank":   p.PlaceRank,
+		"category":     p.Category,
+		"type":         p.Type,
+		"importance":   p.Importance,
+		"addresstype":  p.AddressType,
+		"name":         p.Name,
+		"display_name": p.DisplayName,
+	}
+	if p.Address != nil {
+		props["address"] = p.Address
+	}
+	return map[string]any{
+		"type":    "FeatureCollection",
+		"licence": p.Licence,
+		"features": []any{map[string]any{
+			"type":       "Feature",
+			"properties": props,
+			"bbox":       []float64{bb[2], bb[0], bb[3], bb[1]},
+			"geometry": map[string]any{
+				"type":        "Point",
+				"coordinates": []float64{lon, lat},
+			},
+		}},
+	}
+}
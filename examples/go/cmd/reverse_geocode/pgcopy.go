@@ -0,0 +1,89 @@
+package main
+
+/*
+	PostgreSQL bulk loading with COPY.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	On PostgreSQL the loader streams each data file with COPY ... FROM
+	STDIN in the binary format (pgx's CopyFrom) rather than with batched
+	INSERTs: no SQL to parse and plan per batch, no bind-parameter limit,
+	and the server writes the rows in bulk. The rows are the same — read,
+	filtered and derived by rowReader — and each file is still one
+	transaction, so resuming (see loadstate.go) works as with INSERTs.
+
+	The rest of the load is already arranged for bulk loading: the tables
+	are created without keys or indexes, which indexes.sql and
+	foreign_keys.sql add once every file is in, and VACUUM ANALYZE runs
+	last so the planner has statistics for the new tables. COPY needs the
+	pgx driver gorm uses by default; with another driver the loader falls
+	back to INSERTs.
+*/
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+)
+
+// copyFile loads the rows of f with COPY in one transaction, after the
+// reset DELETE when reset is set. ok is false when the connection is not
+// a pgx one and nothing was done.
+func copyFile(db *gorm.DB, f dataFile, rows *rowReader, reset bool) (n int64, ok bool, err error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return 0, true, err
+	}
+	ctx := context.Background()
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return 0, true, err
+	}
+	defer conn.Close()
+
+	names := make([]string, len(rows.cols))
+	for i, c := range rows.cols {
+		names[i] = c.Name
+	}
+	err = conn.Raw(func(driverConn any) error {
+		sc, isPgx := driverConn.(*stdlib.Conn)
+		if !isPgx {
+			return nil
+		}
+		ok = true
+		tx, err := sc.Conn().Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+		if reset {
+			stmt, args := f.resetStatement("postgres")
+			if _, err := tx.Exec(ctx, stmt, args...); err != nil {
+				return err
+			}
+		}
+		if n, err = tx.CopyFrom(ctx, pgx.Identifier{f.Table}, names, rows); err != nil {
+			return fmt.Errorf("%s near line %d: %w", f.Path, rows.lineNo, err)
+		}
+		return tx.Commit(ctx)
+	})
+	return n, ok, err
+}
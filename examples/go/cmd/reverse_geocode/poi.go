@@ -0,0 +1,128 @@
+package main
+
+/*
+	Custom points of interest ("poi" subcommand).
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    go run . poi import --csv stores.csv --category store [--replace]
+	    go run . poi nearest --lat 19.4326 --lon -99.1332 --category store
+	    go run . --lat 19.4326 --lon -99.1332 --poi 3 --poi-category store
+	    curl 'http://localhost:8080/reverse?lat=19.4326&lon=-99.1332&poi=3'
+
+	The library side, and how it works, is in pkg/geocoder/poi.go.
+*/
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+
+	"github.com/rgglez/geonames-loader/examples/go/pkg/geocoder"
+)
+
+func runPOI(args []string) {
+	usage := "usage: poi import|nearest [flags]"
+	if len(args) == 0 || args[0] != "import" && args[0] != "nearest" {
+		log.Fatal(usage)
+	}
+	fs := flag.NewFlagSet("poi "+args[0], flag.ExitOnError)
+	cfgPath := fs.String(
+		"config", "../../../../config/config.yaml",
+		"Path to config YAML file (default: ../../../../config/config.yaml)",
+	)
+	rawURL := fs.String(
+		"url", "",
+		"Connection URL — overrides --config",
+	)
+	csvPath := fs.String("csv", "", "CSV file to import (- for stdin)")
+	replace := fs.Bool("replace", false, "Empty the poi table before importing")
+	category := fs.String("category", "",
+		"Category of the rows without one (import), or to search (nearest)")
+	lat := fs.Float64("lat", math.NaN(), "Latitude in decimal degrees")
+	lon := fs.Float64("lon", math.NaN(), "Longitude in decimal degrees")
+	nRes := fs.Int("results", 3, "Number of nearest POIs to return (default: 3)")
+	strategyName := fs.String("strategy", geocoder.StrategyAuto, "Distance strategy")
+	_ = fs.Parse(args[1:])
+
+	var rows [][]any
+	switch args[0] {
+	case "import":
+		if *csvPath == "" {
+			fmt.Fprintln(os.Stderr, "ERROR: --csv is required.")
+			fs.Usage()
+			os.Exit(1)
+		}
+		in := io.Reader(os.Stdin)
+		if *csvPath != "-" {
+			f, err := os.Open(*csvPath)
+			if err != nil {
+				log.Fatalf("--csv: %v", err)
+			}
+			defer f.Close()
+			in = f
+		}
+		var err error
+		if rows, err = geocoder.ReadPOICSV(in, *category); err != nil {
+			log.Fatalf("%s: %v", *csvPath, err)
+		}
+	case "nearest":
+		if math.IsNaN(*lat) || math.IsNaN(*lon) {
+			fmt.Fprintln(os.Stderr, "ERROR: --lat and --lon are required.")
+			fs.Usage()
+			os.Exit(1)
+		}
+	}
+
+	cfg, err := configFor(*cfgPath, *rawURL)
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	if args[0] == "import" {
+		db, err := openDB(cfg, *rawURL)
+		if err != nil {
+			log.Fatalf("database: %v", err)
+		}
+		if err := geocoder.ImportPOI(db, rows, *replace); err != nil {
+			log.Fatalf("poi import: %v", err)
+		}
+		fmt.Printf("Imported %d points of interest.\n", len(rows))
+		return
+	}
+
+	db, err := openQueryDB(cfg, *rawURL)
+	if err != nil {
+		log.Fatalf("database: %v", err)
+	}
+	if err := geocoder.CheckStrategy(db, *strategyName); err != nil {
+		log.Fatalf("--strategy: %v", err)
+	}
+	res, err := geocoder.New(db, geocoder.WithStrategy(*strategyName)).
+		NearestPOI(*lat, *lon, *category, geocoder.QueryOptions{Limit: *nRes})
+	if err != nil {
+		log.Fatalf("poi nearest: %v", err)
+	}
+	for _, p := range res.Rows {
+		fmt.Printf("  %8.3f km  %-40s  %-12s  %s\n",
+			p.DistanceKm, p.Name, p.Category, p.Metadata)
+	}
+}
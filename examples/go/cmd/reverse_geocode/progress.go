@@ -0,0 +1,252 @@
+package main
+
+/*
+	Progress reporting for the loader.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	A progress board shows one line per running download or import. On a
+	terminal the lines are bars redrawn in place below the messages:
+
+	    Downloading https://download.geonames.org/export/dump/US.zip ...
+	    geoname US.zip      [=========>          ]  47%   12.1 MB/25.6 MB   3.2 MB/s
+	    geoname MX.txt      [===>                ]  18%  20.5 MB/112.0 MB  14.0 MB/s
+
+	Elsewhere (logs, CI) each running task prints a plain line every
+	progressLogInterval. Messages go through the board (Printf), so they
+	never break a bar; a nil *progressTask prints straight to stdout.
+*/
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	progressLabelWidth  = 30
+	progressRedraw      = 200 * time.Millisecond
+	progressLogInterval = 10 * time.Second
+	progressBarWidth    = 20
+)
+
+// progress is a board of running tasks.
+type progress struct {
+	out  io.Writer
+	tty  bool
+	mu   sync.Mutex
+	list []*progressTask
+	// drawn is the number of bar lines on the terminal below the cursor's
+	// line of messages.
+	drawn   int
+	lastLog time.Time
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// progressTask is one download or import on a board.
+type progressTask struct {
+	board *progress
+	label string
+	total int64 // -1 when unknown
+	done  atomic.Int64
+	start time.Time
+}
+
+// newProgress starts a board on standard output.
+func newProgress() *progress {
+	p := &progress{
+		out:     os.Stdout,
+		lastLog: time.Now(),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	if st, err := os.Stdout.Stat(); err == nil && st.Mode()&os.ModeCharDevice != 0 {
+		p.tty = os.Getenv("TERM") != "dumb"
+	}
+	go p.run()
+	return p
+}
+
+func (p *progress) run() {
+	defer close(p.stopped)
+	tick := time.NewTicker(progressRedraw)
+	defer tick.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case now := <-tick.C:
+			p.mu.Lock()
+			if p.tty {
+				p.redraw()
+			} else if now.Sub(p.lastLog) >= progressLogInterval {
+				p.lastLog = now
+				for _, t := range p.list {
+					fmt.Fprintf(p.out, "  %s\n", t.status(false))
+				}
+			}
+			p.mu.Unlock()
+		}
+	}
+}
+
+// close stops the board and clears its bars.
+func (p *progress) close() {
+	close(p.stop)
+	<-p.stopped
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+}
+
+// clear erases the bars; p.mu must be held.
+func (p *progress) clear() {
+	if p.drawn > 0 {
+		fmt.Fprintf(p.out, "\x1b[%dA\x1b[J", p.drawn)
+		p.drawn = 0
+	}
+}
+
+// redraw draws the bars of the running tasks; p.mu must be held.
+func (p *progress) redraw() {
+	p.clear()
+	for _, t := range p.list {
+		fmt.Fprintf(p.out, "  %s\x1b[K\n", t.status(true))
+	}
+	p.drawn = len(p.list)
+}
+
+// Printf prints a message above the bars.
+func (p *progress) Printf(format string, args ...any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.tty {
+		p.clear()
+	}
+	fmt.Fprintf(p.out, format, args...)
+	if p.tty {
+		p.redraw()
+	}
+}
+
+// start adds a task of total bytes (-1 if unknown) to the board. On a
+// nil board it returns a nil task, which reports nothing.
+func (p *progress) start(label string, total int64) *progressTask {
+	if p == nil {
+		return nil
+	}
+	t := &progressTask{board: p, label: label, total: total, start: time.Now()}
+	p.mu.Lock()
+	p.list = append(p.list, t)
+	p.mu.Unlock()
+	return t
+}
+
+// finish removes t from its board.
+func (t *progressTask) finish() {
+	if t == nil {
+		return
+	}
+	p := t.board
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, u := range p.list {
+		if u == t {
+			p.list = append(p.list[:i], p.list[i+1:]...)
+			break
+		}
+	}
+	if p.tty {
+		p.redraw()
+	}
+}
+
+// add counts n more bytes done.
+func (t *progressTask) add(n int64) {
+	if t != nil {
+		t.done.Add(n)
+	}
+}
+
+// Printf prints a message about t above the bars.
+func (t *progressTask) Printf(format string, args ...any) {
+	if t == nil {
+		fmt.Printf(format, args...)
+		return
+	}
+	t.board.Printf(format, args...)
+}
+
+// status renders t as a bar or, for logs, as a line.
+func (t *progressTask) status(bar bool) string {
+	label := t.label
+	if r := []rune(label); len(r) > progressLabelWidth {
+		label = string(r[:progressLabelWidth-1]) + "…"
+	}
+	done := t.done.Load()
+	rate := ""
+	if secs := time.Since(t.start).Seconds(); secs > 0 {
+		rate = formatBytes(int64(float64(done)/secs)) + "/s"
+	}
+	if t.total <= 0 {
+		return fmt.Sprintf("%-*s %10s  %10s", progressLabelWidth, label,
+			formatBytes(done), rate)
+	}
+	frac := min(float64(done)/float64(t.total), 1)
+	size := formatBytes(done) + "/" + formatBytes(t.total)
+	if !bar {
+		return fmt.Sprintf("%s: %s (%.0f%%), %s", label, size, 100*frac, rate)
+	}
+	n := int(frac * progressBarWidth)
+	b := strings.Repeat("=", n)
+	if n < progressBarWidth {
+		b += ">" + strings.Repeat(" ", progressBarWidth-n-1)
+	}
+	return fmt.Sprintf("%-*s [%s] %3.0f%%  %17s  %10s", progressLabelWidth, label,
+		b, 100*frac, size, rate)
+}
+
+// formatBytes formats n bytes with a decimal unit.
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1e9:
+		return fmt.Sprintf("%.1f GB", float64(n)/1e9)
+	case n >= 1e6:
+		return fmt.Sprintf("%.1f MB", float64(n)/1e6)
+	case n >= 1e3:
+		return fmt.Sprintf("%.0f kB", float64(n)/1e3)
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+// progressReader counts the bytes read through it on a task.
+type progressReader struct {
+	r    io.Reader
+	task *progressTask
+}
+
+func (r progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.task.add(int64(n))
+	return n, err
+}
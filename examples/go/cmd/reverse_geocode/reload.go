@@ -0,0 +1,255 @@
+package main
+
+/*
+	Configuration reload for the server.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    kill -HUP $(pidof reverse_geocode)
+	    curl -X POST -H "Authorization: Bearer $ADMIN_KEY" \
+	        http://localhost:8080/admin/reload
+
+	Both re-read the config file (flags given on the command line still
+	override it) and replace the running configuration without dropping
+	connections. POST /admin/reload needs an admin API key: it answers 404
+	when authentication is not enabled and 403 for other keys. With --url
+	there is no config file, and a reload only re-applies the flags.
+
+	Everything except the listen address is reloaded: the database section
+	(a new connection is opened only if it changed), the table names, the
+	cache file, rate limits, API keys (their usage so far is kept), the
+	query queue, the strategy, zones, resilience settings and the default
+	query options (default_results, default_units). A new listen address
+	or jobs section (see jobs.go) needs a restart; the reload logs a
+	warning and keeps the old one.
+
+	The new configuration is built and checked in full — the database
+	pinged and its schema version checked, the strategy checked, the
+	in-memory index loaded — before it replaces the old one; the pool
+	of a connection kept across the reload is resized only then. If any
+	step fails, the old configuration keeps serving and whatever was
+	opened for the new one is closed. Requests
+	already running finish on the configuration they started with; the
+	old connection and cache are closed once they have.
+*/
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+
+	"github.com/rgglez/geonames-loader/examples/go/pkg/geocoder"
+	"gorm.io/gorm"
+)
+
+// liveServer serves the requests with the current server and swaps it on
+// reload.
+type liveServer struct {
+	ctx      context.Context
+	rawURL   string
+	settings func() (*Config, serverConfig, error)
+
+	reloading sync.Mutex // serialises reloads
+
+	mu      sync.RWMutex
+	cur     *server
+	handler http.Handler
+	dbCfg   dbConfig
+}
+
+func newLiveServer(ctx context.Context, s *server, dbCfg dbConfig, rawURL string, settings func() (*Config, serverConfig, error)) *liveServer {
+	l := &liveServer{ctx: ctx, rawURL: rawURL, settings: settings}
+	l.install(s, dbCfg)
+	return l
+}
+
+// install makes s the current server.
+func (l *liveServer) install(s *server, dbCfg dbConfig) {
+	s.reload = l.reload
+	handler := s.routes()
+	l.mu.Lock()
+	l.cur, l.handler, l.dbCfg = s, handler, dbCfg
+	l.mu.Unlock()
+}
+
+// acquire returns the current server for work outside of a request, such
+// as a job, and the function to call when done with it; until then the
+// server counts as in flight, so a reload does not close its connection.
+func (l *liveServer) acquire() (*server, func()) {
+	l.mu.RLock()
+	s := l.cur
+	s.inflight.Add(1)
+	l.mu.RUnlock()
+	return s, s.inflight.Done
+}
+
+// current returns the current server.
+func (l *liveServer) current() *server {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.cur
+}
+
+func (l *liveServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.mu.RLock()
+	s, handler := l.cur, l.handler
+	s.inflight.Add(1)
+	l.mu.RUnlock()
+	defer s.inflight.Done()
+	handler.ServeHTTP(w, r)
+}
+
+// reload builds a server from the config file and swaps it in. On error
+// the current server is kept.
+func (l *liveServer) reload() (*server, error) {
+	l.reloading.Lock()
+	defer l.reloading.Unlock()
+
+	cfg, sc, err := l.settings()
+	if err != nil {
+		return nil, err
+	}
+	old := l.current()
+	l.mu.RLock()
+	oldDBCfg := l.dbCfg
+	l.mu.RUnlock()
+
+	db := old.db
+	if !reflect.DeepEqual(cfg.Database, oldDBCfg) {
+		if db, err = openQueryDB(cfg, l.rawURL); err != nil {
+			return nil, err
+		}
+	}
+	fail := func(err error) (*server, error) {
+		if db != old.db {
+			closeDB(db)
+		}
+		return nil, err
+	}
+	if err := prepareServeDB(db, sc); err != nil {
+		return fail(err)
+	}
+	s, err := newServer(l.ctx, db, sc, old)
+	if err != nil {
+		return fail(err)
+	}
+	// Only now that the configuration is valid is the pool, perhaps the
+	// one serving, resized.
+	if err := sizePool(db, sc); err != nil {
+		if s.cache != nil && s.cache != old.cache {
+			s.cache.Close()
+		}
+		return fail(err)
+	}
+	if sc.Listen != old.cfg.Listen {
+		log.Printf("reload: listen address %s needs a restart; still on %s",
+			sc.Listen, old.cfg.Listen)
+		s.cfg.Listen = old.cfg.Listen
+	}
+	if !reflect.DeepEqual(sc.Jobs, old.cfg.Jobs) {
+		log.Printf("reload: the jobs section needs a restart; keeping the old one")
+		s.cfg.Jobs = old.cfg.Jobs
+	}
+	l.install(s, cfg.Database)
+
+	// Close what the new server does not share once the requests still
+	// running on the old one are done.
+	go func() {
+		old.inflight.Wait()
+		if old.cache != nil && old.cache != s.cache {
+			if err := old.cache.Close(); err != nil {
+				log.Printf("reload: closing cache: %v", err)
+			}
+		}
+		if old.db != s.db {
+			closeDB(old.db)
+		}
+	}()
+	return s, nil
+}
+
+// closeDB closes the connection of db and forgets its per-connection state.
+func closeDB(db *gorm.DB) {
+	geocoder.Release(db)
+	if sqlDB, err := db.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			log.Printf("closing database: %v", err)
+		}
+	}
+}
+
+// reloadOnHangup reloads on every SIGHUP until the server shuts down.
+func (l *liveServer) reloadOnHangup() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-l.ctx.Done():
+			return
+		case <-hup:
+			if s, err := l.reload(); err != nil {
+				log.Printf("reload failed, previous configuration kept: %v", err)
+			} else {
+				log.Printf("reloaded configuration (strategy: %s)", s.strategy)
+			}
+		}
+	}
+}
+
+// handleReload reloads the configuration for admin keys.
+func (s *server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if s.keys == nil {
+		writeError(w, http.StatusNotFound, "authentication is not enabled")
+		return
+	}
+	k := requestAPIKey(r)
+	switch {
+	case k == nil:
+		writeError(w, http.StatusUnauthorized, "API key required")
+		return
+	case !k.admin:
+		writeError(w, http.StatusForbidden, "admin key required")
+		return
+	case s.reload == nil:
+		writeError(w, http.StatusNotFound, "reload is not available")
+		return
+	}
+	ns, err := s.reload()
+	if err != nil {
+		log.Printf("reload failed, previous configuration kept: %v", err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, context.Canceled) {
+			status = http.StatusServiceUnavailable
+		}
+		writeError(w, status, "reload failed, previous configuration kept: "+err.Error())
+		return
+	}
+	log.Printf("reloaded configuration by %s (strategy: %s)", k.name, ns.strategy)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"reloaded": true,
+		"strategy": ns.strategy,
+	})
+}
@@ -0,0 +1,431 @@
+package main
+
+/*
+	Output renderers of the reverse-geocode command.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    go run . --lat 19.4326 --lon -99.1332 --format json
+	    go run . --lat 19.4326 --lon -99.1332 --format geojson > near.geojson
+	    go run . --lat 19.4326 --lon -99.1332 --format csv --units mi
+	    go run . --lat 19.4326 --lon -99.1332 \
+	        --template '{{.Name}}, {{.Country}} ({{printf "%.1f" .DistanceKm}} km)'
+
+	--format picks one of the built-in renderers:
+
+	  text     the human-oriented report (default)
+	  json     one object: the query, the strategy and the postal and
+	           places lists, as the /reverse endpoint returns them
+	  geojson  a FeatureCollection with a Point feature per result; the
+	           properties carry the result fields plus "kind" (postal or
+	           place)
+	  csv      a header and one row per result, postal codes first; the
+	           admin columns hold names for postal codes and codes for
+	           places, and the confidence column is filled in with
+	           --confidence
+
+	--template replaces them with a Go text/template executed once per
+	place, nearest first, each output ending with a newline. The fields
+	are those of Place (.Geonameid, .Name, .Country, .Admin1 code,
+	.Fclass, .Population, .Postalcode, .Latitude, .Longitude, .DistanceKm,
+	.Bearing, .Direction and, with --units, .Distance).
+
+	Only the text report explains an empty list; the other formats leave
+	it empty, so their output always parses.
+*/
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/rgglez/geonames-loader/examples/go/pkg/geocoder"
+)
+
+// reverseOutput is the answer of the reverse-geocode command.
+type reverseOutput struct {
+	Latitude  float64               `json:"latitude"`
+	Longitude float64               `json:"longitude"`
+	Results   int                   `json:"results"`
+	Country   string                `json:"country,omitempty"`
+	Strategy  string                `json:"strategy"`
+	Units     string                `json:"units"`
+	Geodesic  bool                  `json:"geodesic,omitempty"`
+	Postal    []geocoder.PostalCode `json:"postal"`
+	Places    []geocoder.Place      `json:"places"`
+	// POI are the nearest custom points of interest, with --poi (see
+	// poi.go).
+	POI []geocoder.POI `json:"poi,omitempty"`
+	// PostalErr and PlacesErr say why a list is empty (see
+	// pkg/geocoder/result.go).
+	PostalErr error `json:"-"`
+	PlacesErr error `json:"-"`
+	// Offshore is set when the point has no populated place nearby (see
+	// pkg/geocoder/offshore.go); Postal and Places are then empty.
+	Offshore *geocoder.OffshoreError `json:"offshore,omitempty"`
+}
+
+// renderer writes a reverseOutput in one format.
+type renderer interface {
+	Render(w io.Writer, out *reverseOutput) error
+}
+
+// renderFormats lists the --format names.
+var renderFormats = map[string]renderer{
+	"text":    textRenderer{},
+	"json":    jsonRenderer{},
+	"geojson": geojsonRenderer{},
+	"csv":     csvRenderer{},
+}
+
+// newRenderer returns the renderer of format, or a template renderer when
+// tmpl is not empty.
+func newRenderer(format, tmpl string) (renderer, error) {
+	if tmpl != "" {
+		t, err := template.New("place").Parse(tmpl)
+		if err != nil {
+			return nil, err
+		}
+		return templateRenderer{t}, nil
+	}
+	if r, ok := renderFormats[format]; ok {
+		return r, nil
+	}
+	return nil, fmt.Errorf("unknown format %q (want text, json, geojson or csv)", format)
+}
+
+// ---------------------------------------------------------------------------
+// Text
+// ---------------------------------------------------------------------------
+
+type textRenderer struct{}
+
+func (textRenderer) Render(w io.Writer, out *reverseOutput) error {
+	fmt.Fprintln(w, strings.Repeat("=", 60))
+	fmt.Fprintln(w, "GeoNames reverse geocoder — Go / GORM")
+	fmt.Fprintf(w, "  Latitude  : %g\n", out.Latitude)
+	fmt.Fprintf(w, "  Longitude : %g\n", out.Longitude)
+	fmt.Fprintf(w, "  Results   : %d\n", out.Results)
+	if out.Country != "" {
+		fmt.Fprintf(w, "  Country   : %s\n", out.Country)
+	}
+	fmt.Fprintf(w, "  Strategy  : %s\n", out.Strategy)
+	if out.Geodesic {
+		fmt.Fprintln(w, "  Distances : WGS-84 ellipsoid (Vincenty)")
+	}
+	fmt.Fprintln(w, strings.Repeat("=", 60))
+	fmt.Fprintln(w)
+
+	if out.Offshore != nil {
+		printOffshore(w, out.Offshore, out.Units)
+		return nil
+	}
+
+	switch {
+	case errors.Is(out.PostalErr, geocoder.ErrNoData):
+		fmt.Fprintln(w, "No postal-code data loaded.")
+	case noPostalSystem(out.PostalErr):
+		fmt.Fprintf(w, "%s has no postal codes.\n", out.Country)
+	case errors.Is(out.PostalErr, geocoder.ErrCountryNotCovered):
+		fmt.Fprintf(w, "No postal-code data loaded for %s.\n", out.Country)
+	case out.PostalErr != nil:
+		fmt.Fprintln(w, "No postal-code data found for these coordinates.")
+	default:
+		printPostal(w, out.Postal, out.Units)
+	}
+
+	fmt.Fprintln(w, strings.Repeat("-", 60))
+	fmt.Fprintln(w)
+
+	switch {
+	case errors.Is(out.PlacesErr, geocoder.ErrNoData):
+		fmt.Fprintln(w, "No geoname entries loaded.")
+	case errors.Is(out.PlacesErr, geocoder.ErrCountryNotCovered):
+		fmt.Fprintf(w, "No geoname entries loaded for %s.\n", out.Country)
+	case out.PlacesErr != nil:
+		fmt.Fprintln(w, "No geoname entries found.")
+	default:
+		printGeoname(w, out.Places, out.Units)
+	}
+
+	if len(out.POI) > 0 {
+		fmt.Fprintln(w, strings.Repeat("-", 60))
+		fmt.Fprintln(w)
+		printPOI(w, out.POI, out.Units)
+	}
+	return nil
+}
+
+// noPostalSystem reports whether err is a lookup in a country without
+// postal codes.
+func noPostalSystem(err error) bool {
+	var nc *geocoder.CountryNotCoveredError
+	return errors.As(err, &nc) && nc.NoPostalSystem
+}
+
+func printOffshore(w io.Writer, off *geocoder.OffshoreError, units string) {
+	fmt.Fprintln(w, "Offshore: no populated place near these coordinates.")
+	fmt.Fprintln(w)
+	if n := off.Nearest; n != nil {
+		fmt.Fprintf(w, "  Nearest place : %s, %s (%d)\n", n.Name, n.Country, n.Geonameid)
+		fmt.Fprintf(w, "  Coordinates   : %g, %g\n", n.Latitude, n.Longitude)
+		fmt.Fprintf(w, "  Distance      : %.3f %s\n", fromKm(n.DistanceKm, units), units)
+	}
+	if m := off.MarineRegion; m != nil {
+		fmt.Fprintf(w, "  Marine region : %s (%s/%s, %d)\n", m.Name, m.Fclass, m.Fcode, m.Geonameid)
+	}
+}
+
+func printPostal(w io.Writer, rows []geocoder.PostalCode, units string) {
+	fmt.Fprintf(w, "Nearest postal-code entries (%d result(s)):\n\n", len(rows))
+	for _, r := range rows {
+		fmt.Fprintf(w, "  Country     : %s\n", r.Countrycode)
+		fmt.Fprintf(w, "  Postal code : %s\n", r.Postalcode)
+		fmt.Fprintf(w, "  Place       : %s\n", r.Placename)
+		if r.Admin3name != "" {
+			fmt.Fprintf(w, "  Admin 3     : %s\n", r.Admin3name)
+		}
+		if r.Admin2name != "" {
+			fmt.Fprintf(w, "  Admin 2     : %s\n", r.Admin2name)
+		}
+		if r.Admin1name != "" {
+			fmt.Fprintf(w, "  Admin 1     : %s\n", r.Admin1name)
+		}
+		fmt.Fprintf(w, "  Coordinates : %g, %g\n", r.Latitude, r.Longitude)
+		if e := r.Extent; e != nil && e.Rows > 1 {
+			fmt.Fprintf(w, "  Extent      : %d points around %.5f, %.5f, spread %.3f %s\n",
+				e.Rows, e.Latitude, e.Longitude, fromKm(e.SpreadKm, units), units)
+		}
+		if r.Confidence > 0 {
+			fmt.Fprintf(w, "  Confidence  : %.2f\n", r.Confidence)
+		}
+		printRating(w, r.Rating)
+		fmt.Fprintf(w, "  Distance    : %.3f %s%s\n\n", fromKm(r.DistanceKm, units), units,
+			directionSuffix(r.Bearing, r.Direction))
+	}
+}
+
+func printGeoname(w io.Writer, rows []geocoder.Place, units string) {
+	fmt.Fprintf(w, "Nearest geoname entries (%d result(s)):\n\n", len(rows))
+	for _, r := range rows {
+		fmt.Fprintf(w, "  GeoName ID  : %d\n", r.Geonameid)
+		fmt.Fprintf(w, "  Name        : %s\n", r.Name)
+		fmt.Fprintf(w, "  Country     : %s\n", r.Country)
+		fmt.Fprintf(w, "  Feature     : %s/%s\n", r.Fclass, r.Fcode)
+		fmt.Fprintf(w, "  Population  : %d\n", r.Population)
+		if r.Postalcode != "" {
+			fmt.Fprintf(w, "  Postal code : %s\n", r.Postalcode)
+		}
+		if r.Wikipedia != "" {
+			fmt.Fprintf(w, "  Wikipedia   : %s\n", r.Wikipedia)
+		}
+		if r.Wikidata != "" {
+			fmt.Fprintf(w, "  Wikidata    : %s\n", r.Wikidata)
+		}
+		if len(r.Duplicates) > 0 {
+			fmt.Fprintf(w, "  Also        : %s\n", strings.Trim(fmt.Sprint(r.Duplicates), "[]"))
+		}
+		printRating(w, r.Rating)
+		fmt.Fprintf(w, "  Coordinates : %g, %g\n", r.Latitude, r.Longitude)
+		fmt.Fprintf(w, "  Distance    : %.3f %s%s\n\n", fromKm(r.DistanceKm, units), units,
+			directionSuffix(r.Bearing, r.Direction))
+	}
+}
+
+// printRating prints a confidence rating and its factors:
+// "0.78 (distance 0.85, feature 0.75, population 0.62)".
+func printRating(w io.Writer, r *geocoder.Rating) {
+	if r == nil {
+		return
+	}
+	factors := make([]string, len(r.Factors))
+	for i, f := range r.Factors {
+		factors[i] = fmt.Sprintf("%s %.2f", f.Name, f.Value)
+	}
+	fmt.Fprintf(w, "  Rating      : %.2f (%s)\n", r.Confidence, strings.Join(factors, ", "))
+}
+
+// directionSuffix formats a bearing after a distance: " NNW (337°)".
+func directionSuffix(bearing float64, direction string) string {
+	if direction == "" {
+		return ""
+	}
+	return fmt.Sprintf(" %s (%d°)", direction, int(math.Round(bearing))%360)
+}
+
+func printPOI(w io.Writer, rows []geocoder.POI, units string) {
+	fmt.Fprintf(w, "Nearest points of interest (%d result(s)):\n\n", len(rows))
+	for _, r := range rows {
+		fmt.Fprintf(w, "  Name        : %s\n", r.Name)
+		if r.Category != "" {
+			fmt.Fprintf(w, "  Category    : %s\n", r.Category)
+		}
+		if len(r.Metadata) > 0 {
+			fmt.Fprintf(w, "  Metadata    : %s\n", r.Metadata)
+		}
+		fmt.Fprintf(w, "  Coordinates : %g, %g\n", r.Latitude, r.Longitude)
+		fmt.Fprintf(w, "  Distance    : %.3f %s%s\n\n", fromKm(r.DistanceKm, units), units,
+			directionSuffix(r.Bearing, r.Direction))
+	}
+}
+
+// ---------------------------------------------------------------------------
+// JSON and GeoJSON
+// ---------------------------------------------------------------------------
+
+type jsonRenderer struct{}
+
+func (jsonRenderer) Render(w io.Writer, out *reverseOutput) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+type geojsonRenderer struct{}
+
+func (geojsonRenderer) Render(w io.Writer, out *reverseOutput) error {
+	features := []any{}
+	add := func(kind string, row any, lat, lon float64) error {
+		var props map[string]any
+		b, err := json.Marshal(row)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(b, &props); err != nil {
+			return err
+		}
+		delete(props, "latitude")
+		delete(props, "longitude")
+		props["kind"] = kind
+		features = append(features, map[string]any{
+			"type":       "Feature",
+			"properties": props,
+			"geometry": map[string]any{
+				"type":        "Point",
+				"coordinates": []float64{lon, lat},
+			},
+		})
+		return nil
+	}
+	for _, r := range out.Postal {
+		if err := add("postal", r, r.Latitude, r.Longitude); err != nil {
+			return err
+		}
+	}
+	for _, r := range out.Places {
+		if err := add("place", r, r.Latitude, r.Longitude); err != nil {
+			return err
+		}
+	}
+	for _, r := range out.POI {
+		if err := add("poi", r, r.Latitude, r.Longitude); err != nil {
+			return err
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(map[string]any{
+		"type":     "FeatureCollection",
+		"features": features,
+	})
+}
+
+// ---------------------------------------------------------------------------
+// CSV
+// ---------------------------------------------------------------------------
+
+type csvRenderer struct{}
+
+var csvHeader = []string{
+	"kind", "geonameid", "name", "postalcode", "country",
+	"admin1", "admin2", "admin3", "fclass", "fcode", "population",
+	"latitude", "longitude", "distance_km", "distance", "units",
+	"bearing", "direction", "confidence",
+}
+
+func (csvRenderer) Render(w io.Writer, out *reverseOutput) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	num := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+	for _, r := range out.Postal {
+		if err := cw.Write([]string{
+			"postal", "", r.Placename, r.Postalcode, r.Countrycode,
+			r.Admin1name, r.Admin2name, r.Admin3name, "", "", "",
+			num(r.Latitude), num(r.Longitude), num(r.DistanceKm),
+			num(fromKm(r.DistanceKm, out.Units)), out.Units,
+			num(r.Bearing), r.Direction, ratingCell(r.Rating),
+		}); err != nil {
+			return err
+		}
+	}
+	for _, r := range out.Places {
+		if err := cw.Write([]string{
+			"place", strconv.FormatInt(r.Geonameid, 10), r.Name, r.Postalcode, r.Country,
+			r.Admin1, r.Admin2, "", r.Fclass, r.Fcode, strconv.FormatInt(r.Population, 10),
+			num(r.Latitude), num(r.Longitude), num(r.DistanceKm),
+			num(fromKm(r.DistanceKm, out.Units)), out.Units,
+			num(r.Bearing), r.Direction, ratingCell(r.Rating),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ratingCell is the confidence column of a row: empty when not rated.
+func ratingCell(r *geocoder.Rating) string {
+	if r == nil {
+		return ""
+	}
+	return strconv.FormatFloat(r.Confidence, 'f', -1, 64)
+}
+
+// ---------------------------------------------------------------------------
+// Template
+// ---------------------------------------------------------------------------
+
+type templateRenderer struct {
+	t *template.Template
+}
+
+func (r templateRenderer) Render(w io.Writer, out *reverseOutput) error {
+	for _, p := range out.Places {
+		var b strings.Builder
+		if err := r.t.Execute(&b, p); err != nil {
+			return err
+		}
+		s := b.String()
+		if !strings.HasSuffix(s, "\n") {
+			s += "\n"
+		}
+		if _, err := io.WriteString(w, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package main
+
+/*
+	Reverse geocoding along a route.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    go run . route --path '_p~iF~ps|U_ulLnnqC_mqNvxq`@'
+	    go run . route --path '19.4326,-99.1332;19.6,-99.06;20.67,-103.35'
+	    go run . route --path route.geojson --max-km 20 --format json
+	    curl 'http://localhost:8080/reverse/route?path=19.43,-99.13%3B19.6,-99.06'
+	    curl --data-binary @route.geojson http://localhost:8080/reverse/route
+
+	The library side, and how it works, is in pkg/geocoder/route.go.
+*/
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rgglez/geonames-loader/examples/go/pkg/geocoder"
+)
+
+// ---------------------------------------------------------------------------
+// Command line and server
+// ---------------------------------------------------------------------------
+
+func runRoute(args []string) {
+	fs := flag.NewFlagSet("route", flag.ExitOnError)
+	cfgPath := fs.String(
+		"config", "../../../../config/config.yaml",
+		"Path to config YAML file (default: ../../../../config/config.yaml)",
+	)
+	rawURL := fs.String(
+		"url", "",
+		"Connection URL — overrides --config",
+	)
+	path := fs.String("path", "",
+		"Route: encoded polyline, lat,lon;lat,lon... pairs, GeoJSON, or a "+
+			"file holding one of them (- for stdin)")
+	precision := fs.Int("precision", 5,
+		"Decimals of the encoded polyline (5 for Google, 6 for OSRM/Valhalla)")
+	country := fs.String("country", "",
+		"Restrict results to this ISO 3166-1 alpha-2 country code")
+	fclass := fs.String("feature-class", "P", "Feature class of the places")
+	maxKm := fs.Float64("max-km", 0,
+		"Points farther than this from any place have no answer (0: no limit)")
+	strategyName := fs.String("strategy", geocoder.StrategyAuto, "Distance strategy")
+	format := fs.String("format", "text", "Output format: text or json")
+	_ = fs.Parse(args)
+
+	if *path == "" {
+		fmt.Fprintln(os.Stderr, "ERROR: --path is required.")
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "ERROR: --format: unknown format %q (want text or json)\n", *format)
+		os.Exit(1)
+	}
+	text := *path
+	if *path == "-" {
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatalf("--path: %v", err)
+		}
+		text = string(b)
+	} else if b, err := os.ReadFile(*path); err == nil {
+		text = string(b)
+	}
+	points, err := geocoder.ParseRoute(text, *precision)
+	if err != nil {
+		log.Fatalf("--path: %v", err)
+	}
+
+	cfg, err := configFor(*cfgPath, *rawURL)
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	db, err := openQueryDB(cfg, *rawURL)
+	if err != nil {
+		log.Fatalf("database: %v", err)
+	}
+	if err := geocoder.CheckStrategy(db, *strategyName); err != nil {
+		log.Fatalf("--strategy: %v", err)
+	}
+	res, err := geocoder.New(db, geocoder.WithStrategy(*strategyName)).ReverseRoute(points, geocoder.QueryOptions{
+		Country:       strings.ToUpper(*country),
+		FeatureClass:  strings.ToUpper(*fclass),
+		MaxDistanceKm: *maxKm,
+	})
+	if err != nil {
+		log.Fatalf("route: %v", err)
+	}
+	if *format == "json" {
+		out, _ := json.MarshalIndent(res, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+	fmt.Printf("%d points, %d places, %d postal codes (%s, %s)\n\n",
+		res.Points, len(res.Places), len(res.Postal), res.Strategy,
+		res.Duration.Round(time.Millisecond))
+	fmt.Println("Places:")
+	for _, s := range res.Places {
+		fmt.Printf("  %5d–%-5d  %s, %s (%d)\n", s.Entry, s.Exit,
+			s.Row.Name, s.Row.Country, s.Row.Geonameid)
+	}
+	fmt.Println("\nPostal codes:")
+	for _, s := range res.Postal {
+		fmt.Printf("  %5d–%-5d  %s %s, %s\n", s.Entry, s.Exit,
+			s.Row.Countrycode, s.Row.Postalcode, s.Row.Placename)
+	}
+}
+
+// handleRoute answers GET /reverse/route?path=... and POST /reverse/route
+// with the path as the body.
+func (s *server) handleRoute(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	text := q.Get("path")
+	if r.Method == http.MethodPost {
+		b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 4<<20))
+		if err != nil {
+			writeError(w, http.StatusRequestEntityTooLarge, "route too large")
+			return
+		}
+		text = string(b)
+	}
+	precision := 5
+	if v := q.Get("precision"); v != "" {
+		var err error
+		if precision, err = strconv.Atoi(v); err != nil {
+			writeError(w, http.StatusBadRequest, "precision must be an integer")
+			return
+		}
+	}
+	points, err := geocoder.ParseRoute(text, precision)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "path: "+err.Error())
+		return
+	}
+	if len(points) > geocoder.MaxRoutePoints {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf(
+			"path: %d points, at most %d allowed", len(points), geocoder.MaxRoutePoints))
+		return
+	}
+	opts := geocoder.QueryOptions{
+		Country:      strings.ToUpper(q.Get("country")),
+		FeatureClass: strings.ToUpper(q.Get("feature_class")),
+	}
+	if v := q.Get("max_km"); v != "" {
+		if opts.MaxDistanceKm, err = strconv.ParseFloat(v, 64); err != nil || opts.MaxDistanceKm < 0 {
+			writeError(w, http.StatusBadRequest, "max_km must be a non-negative number")
+			return
+		}
+	}
+
+	release, ok := s.acquire(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+
+	res, err := s.geo.WithContext(r.Context()).ReverseRoute(points, opts)
+	if err != nil {
+		log.Printf("route: %v", err)
+		s.writeQueryError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
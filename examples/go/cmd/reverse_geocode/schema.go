@@ -0,0 +1,160 @@
+package main
+
+/*
+	Schema versions ("migrate" subcommand).
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    go run . migrate [--config CONFIG] [--url URL] [--dry-run]
+
+	The library side, and how it works, is in pkg/geocoder/schema.go.
+*/
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rgglez/geonames-loader/examples/go/pkg/geocoder"
+	"gorm.io/gorm"
+)
+
+// schemaUpgrades maps a version to the step that upgrades a database of
+// that version to the next one.
+var schemaUpgrades = map[int]func(tx *gorm.DB) error{}
+
+// openQueryDB opens the database like openDB and checks that it is up
+// and that this binary can query it. Tables without data are logged as
+// warnings; the lookups that need them are skipped (see
+// pkg/geocoder/data.go).
+func openQueryDB(cfg *Config, rawURL string) (*gorm.DB, error) {
+	db, err := openDB(cfg, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	// Ping first: CheckSchema takes failed queries for missing tables.
+	err = pingDB(db)
+	if err == nil {
+		err = geocoder.CheckSchema(db)
+	}
+	if err != nil {
+		closeDB(db)
+		return nil, err
+	}
+	for _, w := range geocoder.ProbeData(db).Warnings() {
+		log.Printf("warning: table=%s problem=%s effect=%q", w.Table, w.Problem, w.Effect)
+	}
+	return db, nil
+}
+
+// insertSchemaVersion records the current schema version, in a table
+// named schema_version.
+const insertSchemaVersion = "INSERT INTO schema_version (version, applied_at) VALUES (?, ?)"
+
+// setSchemaVersion replaces the version recorded in db with v.
+func setSchemaVersion(tx *gorm.DB, v int) error {
+	t := geocoder.TableName(tx, "schema_version")
+	if err := tx.Exec("DELETE FROM " + t).Error; err != nil {
+		return err
+	}
+	return tx.Exec("INSERT INTO "+t+" (version, applied_at) VALUES (?, ?)",
+		v, time.Now().UTC()).Error
+}
+
+// migrate upgrades db to SchemaVersion. With dryRun it only prints the
+// steps.
+func migrate(db *gorm.DB, dryRun bool) error {
+	v, err := geocoder.DatabaseSchemaVersion(db)
+	switch {
+	case err != nil:
+		return err
+	case v == 0:
+		return errors.New("the database has no GeoNames tables — run load")
+	case v > geocoder.SchemaVersion:
+		return fmt.Errorf("database has schema v%d; this binary only knows up to v%d — upgrade the binary",
+			v, geocoder.SchemaVersion)
+	}
+	fmt.Printf("Database schema: v%d; this binary: v%d\n", v, geocoder.SchemaVersion)
+	if dryRun {
+		for from := v; from < geocoder.SchemaVersion; from++ {
+			fmt.Printf("  would upgrade v%d → v%d\n", from, from+1)
+		}
+		return nil
+	}
+	// The table is missing from databases loaded before it existed.
+	if err := db.Exec("CREATE TABLE IF NOT EXISTS " + geocoder.TableName(db, "schema_version") +
+		" (version INTEGER NOT NULL, applied_at TIMESTAMP)").Error; err != nil {
+		return err
+	}
+	if v == geocoder.SchemaVersion {
+		// Record the version of an unversioned database.
+		return setSchemaVersion(db, v)
+	}
+	for from := v; from < geocoder.SchemaVersion; from++ {
+		upgrade, ok := schemaUpgrades[from]
+		if !ok {
+			return fmt.Errorf("no upgrade from schema v%d", from)
+		}
+		fmt.Printf("  upgrading v%d → v%d ... ", from, from+1)
+		// DDL is transactional on PostgreSQL and SQLite; on MySQL a failed
+		// step may be left half done.
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			if err := upgrade(tx); err != nil {
+				return err
+			}
+			return setSchemaVersion(tx, from+1)
+		}); err != nil {
+			fmt.Println("failed")
+			return fmt.Errorf("upgrading to v%d: %w", from+1, err)
+		}
+		fmt.Println("done")
+	}
+	return nil
+}
+
+func runMigrate(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	cfgPath := fs.String(
+		"config", "../../../../config/config.yaml",
+		"Path to config YAML file (default: ../../../../config/config.yaml)",
+	)
+	rawURL := fs.String(
+		"url", "",
+		"Connection URL — overrides the database section of --config",
+	)
+	dryRun := fs.Bool("dry-run", false, "Only report the upgrades to run")
+	_ = fs.Parse(args)
+
+	cfg, err := configFor(*cfgPath, *rawURL)
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	db, err := openDB(cfg, *rawURL)
+	if err != nil {
+		log.Fatalf("database: %v", err)
+	}
+	if err := migrate(db, *dryRun); err != nil {
+		log.Fatalf("migrate: %v", err)
+	}
+	if !*dryRun {
+		fmt.Printf("Database is at schema v%d.\n", geocoder.SchemaVersion)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+/*
+	Forward geocoding: place search by name.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    go run . search --name Guadalajara [--country MX] [--fclass P]
+	    go run . search --name Guadalajra --fuzzy [--min-score 0.5]
+	    go run . search --name Springfield --bias-country US
+	    go run . search --name Springfield --bias-point 39.8,-89.6
+
+	The library side, and how it works, is in pkg/geocoder/search.go.
+*/
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/rgglez/geonames-loader/examples/go/pkg/geocoder"
+)
+
+func printSearch(rows []geocoder.Place, scored bool) {
+	fmt.Printf("Matching places (%d result(s)):\n\n", len(rows))
+	for _, r := range rows {
+		fmt.Printf("  GeoName ID  : %d\n", r.Geonameid)
+		fmt.Printf("  Name        : %s\n", r.Name)
+		fmt.Printf("  Country     : %s\n", r.Country)
+		fmt.Printf("  Feature     : %s/%s\n", r.Fclass, r.Fcode)
+		fmt.Printf("  Population  : %d\n", r.Population)
+		if scored {
+			fmt.Printf("  Score       : %.3f\n", r.Score)
+		}
+		fmt.Printf("  Coordinates : %g, %g\n\n", r.Latitude, r.Longitude)
+	}
+}
+
+func runSearch(args []string) {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	cfgPath := fs.String(
+		"config", "../../../../config/config.yaml",
+		"Path to config YAML file (default: ../../../../config/config.yaml)",
+	)
+	rawURL := fs.String(
+		"url", "",
+		"Connection URL — overrides --config",
+	)
+	name := fs.String("name", "", "Place name to search for (required)")
+	country := fs.String("country", "",
+		"Restrict results to this ISO 3166-1 alpha-2 country code")
+	fclass := fs.String("fclass", "", "Restrict results to this feature class")
+	nRes := fs.Int("results", 5, "Number of results to return (default: 5)")
+	fuzzy := fs.Bool("fuzzy", false,
+		"Tolerate misspellings and rank results by similarity")
+	minScore := fs.Float64("min-score", 0.5,
+		"Lowest similarity (0–1) accepted with --fuzzy")
+	biasCountry := fs.String("bias-country", "",
+		"Rank places in this ISO 3166-1 alpha-2 country higher without excluding others")
+	biasPoint := fs.String("bias-point", "",
+		"Rank places near this lat,lon higher without excluding others")
+	_ = fs.Parse(args)
+
+	if *name == "" {
+		fmt.Fprintln(os.Stderr, "ERROR: --name is required.")
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *minScore < 0 || *minScore > 1 {
+		fmt.Fprintln(os.Stderr, "ERROR: --min-score must be between 0 and 1.")
+		os.Exit(1)
+	}
+
+	cfg, err := configFor(*cfgPath, *rawURL)
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	db, err := openQueryDB(cfg, *rawURL)
+	if err != nil {
+		log.Fatalf("database: %v", err)
+	}
+
+	opts := geocoder.SearchOptions{
+		Name:         *name,
+		Country:      strings.ToUpper(*country),
+		FeatureClass: strings.ToUpper(*fclass),
+		Limit:        *nRes,
+		BiasCountry:  strings.ToUpper(*biasCountry),
+	}
+	if *biasPoint != "" {
+		p, err := geocoder.ParsePoint(*biasPoint)
+		if err != nil {
+			log.Fatalf("--bias-point: %v", err)
+		}
+		opts.BiasPoint = &p
+	}
+	var rows []geocoder.Place
+	if *fuzzy {
+		rows, err = geocoder.FuzzySearchPlaces(db, opts, *name, *minScore)
+	} else {
+		rows, err = geocoder.SearchPlaces(db, opts)
+	}
+	if err != nil {
+		log.Fatalf("search: %v", err)
+	}
+
+	fmt.Println(strings.Repeat("=", 60))
+	fmt.Println("GeoNames place search")
+	fmt.Printf("  Name      : %s\n", *name)
+	if *fuzzy {
+		fmt.Printf("  Matching  : fuzzy (min score %g)\n", *minScore)
+	}
+	if opts.Country != "" {
+		fmt.Printf("  Country   : %s\n", opts.Country)
+	}
+	if opts.BiasCountry != "" {
+		fmt.Printf("  Bias      : country %s\n", opts.BiasCountry)
+	}
+	if p := opts.BiasPoint; p != nil {
+		fmt.Printf("  Bias      : near %g, %g\n", p.Lat, p.Lon)
+	}
+	fmt.Println(strings.Repeat("=", 60))
+	fmt.Println()
+	printSearch(rows, *fuzzy || opts.Biased())
+}
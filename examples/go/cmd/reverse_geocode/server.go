@@ -0,0 +1,889 @@
+package main
+
+/*
+	HTTP server mode ("serve" subcommand).
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    go run . serve [--config CONFIG] [--url URL] [--listen :8080]
+	        [--rate 10] [--burst 20]
+	        [--max-concurrent 8] [--max-queued 64] [--queue-timeout 2s]
+	        [--strategy auto|postgis|earthdistance|haversine|memory|rtree]
+	        [--cache FILE] [--jobs FILE]
+	    kill -HUP <pid>             (reload the config, see reload.go)
+
+	Endpoints:
+	    GET /reverse?lat=19.4326&lon=-99.1332[&results=3][&country=MX]
+	        [&units=km|mi|nmi][&geodesic=1][&fields=country,postalcode]
+	        [&min_population=10000][&bbox=minlon,minlat,maxlon,maxlat]
+	        [&postal_extent=1]      (postal-code extents, pkg/geocoder/postal.go)
+	        [&links=1]              (Wikipedia and Wikidata, pkg/geocoder/links.go)
+	        [&dedupe=1]             (one row per settlement, pkg/geocoder/dedupe.go)
+	        [&confidence=1]         (confidence rating, pkg/geocoder/confidence.go)
+	        [&poi=3][&poi_category=store]
+	                                (nearest custom POIs, see poi.go)
+	    GET /reverse/full?lat=..&lon=..
+	                                (place, postal code, country, admin names
+	                                 and timezone at once, see full.go)
+	    GET /reverse/matrix?lat=..&lon=..[&results=5][&units=mi]
+	                                (nearest places with the distances and
+	                                 bearings between them, see matrix.go)
+	    GET /reverse/nearby?lat=..&lon=..[&units=mi]
+	                                (nearest city, airport, water and
+	                                 mountain, see nearby.go)
+	    GET /reverse/route?path=..[&precision=5][&country=MX][&max_km=20]
+	    POST /reverse/route         (places and postal codes along a route,
+	                                 the path as the body, see route.go)
+	    GET /suggest?q=guad[&limit=10][&country=MX][&bias=MX]
+	                                (autocomplete, see suggest.go)
+	    GET /zones?lat=..&lon=..    (configured zones containing the point,
+	                                 see zones.go)
+	    GET /usage[?name=KEYNAME]   (API-key usage; all keys for admin keys)
+	    GET /coverage               (countries with places and with postal
+	                                 codes, see coverage.go)
+	    GET /nominatim/reverse?lat=..&lon=..[&format=jsonv2][&zoom=18]
+	                                (Nominatim-compatible, see nominatim.go)
+	    GET /findNearbyPlaceNameJSON, /findNearbyPostalCodesJSON, /searchJSON
+	                                (geonames.org-compatible, see geonamesws.go)
+	    POST /jobs, GET /jobs[/{id}[/results]], DELETE /jobs/{id}
+	                                (asynchronous batch jobs with callbacks,
+	                                 see jobs.go)
+	    POST /admin/reload          (reload the config; admin keys only)
+	    GET /metrics                (Prometheus metrics, see metrics.go)
+
+	Admission control, applied in this order:
+	  1. Authentication (optional, see auth.go): requests carrying an unknown
+	     API key are rejected with 401, and with 429 once their IP sent too
+	     many unknown keys; with server.auth.required set, requests without
+	     a key are rejected too. Requests over the key's
+	     quota for the current period get 429 once they pass the rate limit;
+	     a job counts one request per point (see jobs.go).
+	  2. Rate limit: a token bucket per client, keyed by the API key when a
+	     valid one is sent (Authorization: Bearer ... or X-API-Key),
+	     otherwise by remote IP. Exceeding it returns 429 with Retry-After.
+	  3. Query queue: at most max_concurrent_queries requests query the
+	     database at once (the pool is capped to the same size). Up to
+	     max_queued requests wait at most queue_timeout for a slot; beyond
+	     that the server answers 503 instead of piling onto the pool.
+
+	Lookups that still fail with transient database errors are retried and
+	may trip a circuit breaker (server.resilience, see
+	pkg/geocoder/resilience.go).
+*/
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rgglez/geonames-loader/examples/go/pkg/geocoder"
+	"gorm.io/gorm"
+)
+
+// ---------------------------------------------------------------------------
+// Configuration
+// ---------------------------------------------------------------------------
+
+type rateLimitConfig struct {
+	// RequestsPerSecond is the sustained rate allowed per client.
+	// Zero selects the default; a negative value disables rate limiting.
+	RequestsPerSecond float64 `yaml:"requests_per_second"`
+	Burst             int     `yaml:"burst"`
+}
+
+// serverConfig is the optional "server" section of the config YAML.
+type serverConfig struct {
+	Listen               string          `yaml:"listen"`
+	RateLimit            rateLimitConfig `yaml:"rate_limit"`
+	MaxConcurrentQueries int             `yaml:"max_concurrent_queries"`
+	MaxQueued            int             `yaml:"max_queued"`
+	QueueTimeout         time.Duration   `yaml:"queue_timeout"`
+	MaxResults           int             `yaml:"max_results"`
+	// DefaultResults and DefaultUnits apply to /reverse requests that do
+	// not give results or units.
+	DefaultResults int    `yaml:"default_results"`
+	DefaultUnits   string `yaml:"default_units"`
+	// OffshoreKm is the offshore distance (see pkg/geocoder/offshore.go),
+	// 100 km when unset; negative disables the check.
+	OffshoreKm float64 `yaml:"offshore_km"`
+	// TrustForwardedFor keys rate limits on the first X-Forwarded-For
+	// address. Enable only behind a reverse proxy that sets the header.
+	TrustForwardedFor bool       `yaml:"trust_forwarded_for"`
+	Auth              authConfig `yaml:"auth"`
+	// Strategy forces a distance strategy (see pkg/geocoder/strategy.go).
+	Strategy string `yaml:"strategy"`
+	// Resilience configures retries and the circuit breaker (see
+	// pkg/geocoder/resilience.go).
+	Resilience geocoder.ResilienceConfig `yaml:"resilience"`
+	// Zones are the geofences reported with each result (see zones.go).
+	Zones []zoneConfig `yaml:"zones"`
+	// Cache is the persistent lookup cache file (see cache.go); empty
+	// disables it.
+	Cache string `yaml:"cache"`
+	// Nearby are the feature groups of /reverse/nearby (see nearby.go);
+	// empty uses geocoder.DefaultFeatureGroups.
+	Nearby []geocoder.FeatureGroup `yaml:"nearby"`
+	// cacheScope is the cache scope of the database (see cache.go), set
+	// by runServe.
+	cacheScope string
+	// Jobs configures the asynchronous batch jobs (see jobs.go).
+	Jobs jobsConfig `yaml:"jobs"`
+}
+
+// withDefaults fills in unset fields.
+func (c serverConfig) withDefaults() serverConfig {
+	if c.Listen == "" {
+		c.Listen = ":8080"
+	}
+	if c.RateLimit.RequestsPerSecond == 0 {
+		c.RateLimit.RequestsPerSecond = 10
+	}
+	if c.RateLimit.Burst <= 0 {
+		c.RateLimit.Burst = 2 * int(math.Ceil(c.RateLimit.RequestsPerSecond))
+	}
+	if c.MaxConcurrentQueries <= 0 {
+		c.MaxConcurrentQueries = 8
+	}
+	if c.MaxQueued <= 0 {
+		c.MaxQueued = 8 * c.MaxConcurrentQueries
+	}
+	if c.QueueTimeout <= 0 {
+		c.QueueTimeout = 2 * time.Second
+	}
+	if c.MaxResults <= 0 {
+		c.MaxResults = 50
+	}
+	if c.DefaultResults <= 0 {
+		c.DefaultResults = min(3, c.MaxResults)
+	}
+	if c.OffshoreKm == 0 {
+		c.OffshoreKm = geocoder.DefaultOffshoreKm
+	}
+	c.Jobs = c.Jobs.withDefaults()
+	return c
+}
+
+// check validates the settings that are not checked against the
+// database.
+func (c serverConfig) check() error {
+	if c.DefaultResults > c.MaxResults {
+		return fmt.Errorf("default_results (%d) exceeds max_results (%d)",
+			c.DefaultResults, c.MaxResults)
+	}
+	if c.DefaultUnits != "" {
+		if err := checkUnits(c.DefaultUnits); err != nil {
+			return fmt.Errorf("default_units: %w", err)
+		}
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Server
+// ---------------------------------------------------------------------------
+
+type server struct {
+	db       *gorm.DB
+	geo      *geocoder.Geocoder
+	cfg      serverConfig
+	strategy string
+	// strategyName is the resolved strategy, for metric labels.
+	strategyName string
+	limiter      *rateLimiter           // nil when rate limiting is disabled
+	keys         *keyStore              // nil when authentication is disabled
+	cache        *geocoder.ReverseCache // nil when caching is disabled
+	queue        *queryQueue
+	metrics      *metrics
+	jobs         *jobManager // nil when jobs are disabled
+	// authFailures counts the unknown keys of each IP (see auth.go); nil
+	// when authentication is disabled.
+	authFailures *rateLimiter
+	// reload, set by liveServer, replaces the server (see reload.go).
+	reload   func() (*server, error)
+	inflight sync.WaitGroup
+}
+
+// newServer builds the server for db and cfg. Given the server it
+// replaces, prev, it keeps its cache when the file is the same, its rate
+// limiter when the limits are, its API-key usage counters, its metrics
+// and its jobs.
+func newServer(ctx context.Context, db *gorm.DB, cfg serverConfig, prev *server) (_ *server, err error) {
+	var cache *geocoder.ReverseCache
+	switch {
+	case prev != nil && prev.cfg.Cache == cfg.Cache:
+		cache = prev.cache
+	case cfg.Cache != "":
+		if cache, err = geocoder.OpenReverseCache(cfg.Cache); err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err != nil {
+				cache.Close()
+			}
+		}()
+	}
+	if err := geocoder.CheckFeatureGroups(cfg.Nearby); err != nil {
+		return nil, fmt.Errorf("nearby: %w", err)
+	}
+	geo := geocoder.New(db, geocoder.WithStrategy(cfg.Strategy),
+		geocoder.WithResilience(cfg.Resilience), geocoder.WithReverseCache(cache),
+		geocoder.WithCacheScope(cfg.cacheScope),
+		geocoder.WithOffshoreKm(cfg.OffshoreKm), nearbyGroups(cfg.Nearby))
+	if err := registerZones(geo, cfg.Zones); err != nil {
+		return nil, err
+	}
+	s := &server{
+		db:           db,
+		geo:          geo,
+		cfg:          cfg,
+		strategy:     geocoder.DescribeStrategy(db, cfg.Strategy),
+		strategyName: geocoder.ResolveStrategy(db, cfg.Strategy),
+		cache:        cache,
+		queue: newQueryQueue(
+			cfg.MaxConcurrentQueries, cfg.MaxQueued, cfg.QueueTimeout,
+		),
+	}
+	if prev != nil {
+		s.metrics, s.jobs = prev.metrics, prev.jobs
+	} else {
+		s.metrics = newMetrics()
+	}
+	switch {
+	case cfg.RateLimit.RequestsPerSecond <= 0:
+	case prev != nil && prev.limiter != nil && prev.cfg.RateLimit == cfg.RateLimit:
+		s.limiter = prev.limiter
+	default:
+		s.limiter = newRateLimiter(
+			cfg.RateLimit.RequestsPerSecond, cfg.RateLimit.Burst,
+		)
+	}
+	if cfg.Auth.enabled() {
+		keys, err := loadKeyStore(ctx, db, cfg.Auth)
+		if err != nil {
+			return nil, err
+		}
+		if prev != nil && prev.keys != nil {
+			keys.inherit(prev.keys)
+			s.authFailures = prev.authFailures
+		} else {
+			s.authFailures = newRateLimiter(authFailureRate, authFailureBurst)
+		}
+		s.keys = keys
+	}
+	return s, nil
+}
+
+// query wraps a handler that hits the database with the full admission
+// chain: authentication, rate limiting, then quota accounting.
+func (s *server) query(h http.HandlerFunc) http.Handler {
+	return s.limited(s.quota(h))
+}
+
+// limited wraps a handler that is not charged per request with
+// authentication and rate limiting.
+func (s *server) limited(h http.Handler) http.Handler {
+	return s.authenticate(s.rateLimit(h))
+}
+
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	handle := func(pattern string, h http.Handler) {
+		mux.Handle(pattern, s.instrument(pattern, h))
+	}
+	handle("GET /reverse", s.query(s.handleReverse))
+	handle("GET /reverse/full", s.query(s.handleReverseFull))
+	handle("GET /reverse/matrix", s.query(s.handleMatrix))
+	handle("GET /reverse/nearby", s.query(s.handleNearby))
+	handle("GET /reverse/route", s.query(s.handleRoute))
+	handle("POST /reverse/route", s.query(s.handleRoute))
+	handle("GET /suggest", s.query(s.handleSuggest))
+	handle("GET /zones", s.query(s.handleZones))
+	handle("GET /usage", s.limited(http.HandlerFunc(s.handleUsage)))
+	handle("GET /coverage", s.limited(http.HandlerFunc(s.handleCoverage)))
+	handle("POST /admin/reload", s.limited(http.HandlerFunc(s.handleReload)))
+	handle("GET /nominatim/reverse", s.query(s.handleNominatimReverse))
+	handle("GET /findNearbyPlaceNameJSON", s.query(s.handleFindNearbyPlaceName))
+	handle("GET /findNearbyPostalCodesJSON", s.query(s.handleFindNearbyPostalCodes))
+	handle("GET /searchJSON", s.query(s.handleSearch))
+	// A job is charged per point by handleJobSubmit, not per request.
+	handle("POST /jobs", s.limited(http.HandlerFunc(s.handleJobSubmit)))
+	handle("GET /jobs", s.limited(http.HandlerFunc(s.handleJobs)))
+	handle("GET /jobs/{id}", s.limited(http.HandlerFunc(s.handleJob)))
+	handle("GET /jobs/{id}/results", s.limited(http.HandlerFunc(s.handleJobResults)))
+	handle("DELETE /jobs/{id}", s.limited(http.HandlerFunc(s.handleJobDelete)))
+	// Scrapers are neither authenticated nor rate limited (see metrics.go).
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+	return mux
+}
+
+// clientKey identifies the caller for rate limiting: the API key when
+// authenticate validated one, otherwise the remote IP. An unvalidated token
+// is not used, or a client could get a fresh bucket on every request by
+// sending a new one.
+func (s *server) clientKey(r *http.Request) string {
+	if k := requestAPIKey(r); k != nil {
+		return "key:" + k.name
+	}
+	return "ip:" + s.remoteIP(r)
+}
+
+// remoteIP returns the client's address: the first X-Forwarded-For entry
+// with trust_forwarded_for, otherwise the peer's.
+func (s *server) remoteIP(r *http.Request) string {
+	if s.cfg.TrustForwardedFor {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			first, _, _ := strings.Cut(fwd, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return host
+}
+
+// requestToken returns the API token from the Authorization bearer or the
+// X-API-Key header, or "" if neither is set.
+func requestToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if tok, ok := strings.CutPrefix(auth, "Bearer "); ok {
+			return strings.TrimSpace(tok)
+		}
+	}
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
+
+func (s *server) rateLimit(next http.Handler) http.Handler {
+	if s.limiter == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, wait := s.limiter.allow(s.clientKey(r), time.Now())
+		if !ok {
+			secs := int(math.Ceil(wait.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(max(secs, 1)))
+			writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// reverseResponse is the JSON body returned by GET /reverse.
+type reverseResponse struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Strategy  string  `json:"strategy"`
+	Units     string  `json:"units,omitempty"`
+	// Postal and Places are []PostalCode and []Place, or the
+	// projected rows when ?fields= is given.
+	Postal any `json:"postal"`
+	Places any `json:"places"`
+	// Zones lists the configured zones containing the point, if any.
+	Zones []string `json:"zones,omitempty"`
+	// Offshore is set when no populated place is near the point (see
+	// pkg/geocoder/offshore.go); Postal and Places are then empty.
+	Offshore *geocoder.OffshoreError `json:"offshore,omitempty"`
+	// POI are the nearest custom points of interest, with ?poi= (see
+	// poi.go).
+	POI []geocoder.POI `json:"poi,omitempty"`
+}
+
+// projectRows returns rows as JSON objects holding only fields plus the
+// distances.
+func projectRows[T any](rows []T, fields []string) ([]map[string]any, error) {
+	out := make([]map[string]any, len(rows))
+	for i := range rows {
+		b, err := json.Marshal(rows[i])
+		if err != nil {
+			return nil, err
+		}
+		var all map[string]any
+		if err := json.Unmarshal(b, &all); err != nil {
+			return nil, err
+		}
+		out[i] = map[string]any{"distance_km": all["distance_km"]}
+		for _, k := range []string{"distance", "bearing", "direction"} {
+			if v, ok := all[k]; ok {
+				out[i][k] = v
+			}
+		}
+		for _, f := range fields {
+			if v, ok := all[f]; ok {
+				out[i][f] = v
+			}
+		}
+	}
+	return out, nil
+}
+
+// wantsAny reports whether fields is empty or holds one of names.
+func wantsAny(fields []string, names ...string) bool {
+	if len(fields) == 0 {
+		return true
+	}
+	for _, n := range names {
+		if slices.Contains(fields, n) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *server) handleReverse(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	lat, err := parseCoord(q.Get("lat"), 90)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "lat: "+err.Error())
+		return
+	}
+	lon, err := parseCoord(q.Get("lon"), 180)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "lon: "+err.Error())
+		return
+	}
+	limit := s.cfg.DefaultResults
+	if v := q.Get("results"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > s.cfg.MaxResults {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf(
+				"results must be an integer between 1 and %d", s.cfg.MaxResults,
+			))
+			return
+		}
+	}
+	country := strings.ToUpper(q.Get("country"))
+	var minPopulation int64
+	if v := q.Get("min_population"); v != "" {
+		minPopulation, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || minPopulation < 0 {
+			writeError(w, http.StatusBadRequest, "min_population must be a non-negative integer")
+			return
+		}
+	}
+	var bbox []float64
+	if v := q.Get("bbox"); v != "" {
+		if bbox, err = parseBBox(v); err != nil {
+			writeError(w, http.StatusBadRequest, "bbox: "+err.Error())
+			return
+		}
+	}
+	units := q.Get("units")
+	if units == "" {
+		units = s.cfg.DefaultUnits
+	}
+	if units != "" {
+		if err := checkUnits(units); err != nil {
+			writeError(w, http.StatusBadRequest, "units: "+err.Error())
+			return
+		}
+	}
+	geodesic := q.Get("geodesic") == "1" || q.Get("geodesic") == "true"
+	postalExtent := q.Get("postal_extent") == "1" || q.Get("postal_extent") == "true"
+	links := q.Get("links") == "1" || q.Get("links") == "true"
+	dedupe := q.Get("dedupe") == "1" || q.Get("dedupe") == "true"
+	rate := q.Get("confidence") == "1" || q.Get("confidence") == "true"
+	nPOI := 0
+	if v := q.Get("poi"); v != "" {
+		nPOI, err = strconv.Atoi(v)
+		if err != nil || nPOI < 1 || nPOI > s.cfg.MaxResults {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf(
+				"poi must be an integer between 1 and %d", s.cfg.MaxResults,
+			))
+			return
+		}
+	}
+	fields := splitList(strings.ToLower(q.Get("fields")), false)
+	if err := geocoder.CheckFields(fields); err != nil {
+		writeError(w, http.StatusBadRequest, "fields: "+err.Error())
+		return
+	}
+	coords := []string{"latitude", "longitude"}
+	wantPostal := wantsAny(fields, append(coords, geocoder.PostalFields...)...)
+	wantPlaces := wantsAny(fields, append(coords, append(geocoder.PlaceFields, "postalcode")...)...)
+
+	release, ok := s.acquire(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+
+	geo := s.geo.WithContext(r.Context())
+	opts := geocoder.QueryOptions{
+		Limit: limit, Country: country, Fields: fields,
+		MinPopulation: minPopulation, BBox: bbox, Links: links,
+		Dedupe: dedupe, Rate: rate,
+	}
+	var offshore *geocoder.OffshoreError
+	var postalRes geocoder.Result[geocoder.PostalCode]
+	if wantPostal {
+		postalOpts := opts
+		postalOpts.PostalExtent = postalExtent
+		postalRes, err = geo.NearestPostal(lat, lon, postalOpts)
+		if err != nil && !geocoder.IsNoResult(err) {
+			log.Printf("postal query: %v", err)
+			s.writeQueryError(w, err)
+			return
+		}
+		errors.As(err, &offshore)
+	}
+	var placesRes geocoder.Result[geocoder.Place]
+	if wantPlaces && offshore == nil {
+		placesRes, err = geo.NearestPlaces(lat, lon, opts)
+		if err != nil && !geocoder.IsNoResult(err) {
+			log.Printf("geoname query: %v", err)
+			s.writeQueryError(w, err)
+			return
+		}
+		errors.As(err, &offshore)
+	}
+	var poiRes geocoder.Result[geocoder.POI]
+	if nPOI > 0 {
+		poiRes, err = geo.NearestPOI(lat, lon, q.Get("poi_category"), geocoder.QueryOptions{Limit: nPOI})
+		if err != nil && !geocoder.IsNoResult(err) {
+			log.Printf("poi query: %v", err)
+			s.writeQueryError(w, err)
+			return
+		}
+	}
+	zones, err := geo.WithinZones(lat, lon)
+	if err != nil {
+		log.Printf("zones: %v", err)
+		s.writeQueryError(w, err)
+		return
+	}
+	postal, places, poi := postalRes.Rows, placesRes.Rows, poiRes.Rows
+	if postal == nil {
+		postal = []geocoder.PostalCode{}
+	}
+	if places == nil {
+		places = []geocoder.Place{}
+	}
+	if geodesic {
+		geodesicPostal(lat, lon, postal)
+		geodesicGeoname(lat, lon, places)
+		geodesicPOI(lat, lon, poi)
+	}
+	if units != "" {
+		if offshore != nil && offshore.Nearest != nil {
+			offshore.Nearest.Distance = fromKm(offshore.Nearest.DistanceKm, units)
+		}
+		for i := range postal {
+			postal[i].Distance = fromKm(postal[i].DistanceKm, units)
+		}
+		for i := range places {
+			places[i].Distance = fromKm(places[i].DistanceKm, units)
+		}
+		for i := range poi {
+			poi[i].Distance = fromKm(poi[i].DistanceKm, units)
+		}
+	}
+
+	resp := reverseResponse{
+		Latitude:  lat,
+		Longitude: lon,
+		Strategy:  s.strategy,
+		Units:     units,
+		Postal:    postal,
+		Places:    places,
+		Zones:     zones,
+		Offshore:  offshore,
+		POI:       poi,
+	}
+	if len(fields) > 0 {
+		if resp.Postal, err = projectRows(postal, fields); err == nil {
+			placeFields := fields
+			if links {
+				placeFields = append(slices.Clip(placeFields), "wikipedia", "wikidata")
+			}
+			if dedupe {
+				placeFields = append(slices.Clip(placeFields), "duplicates")
+			}
+			resp.Places, err = projectRows(places, placeFields)
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// acquire waits for a query slot. When none becomes available it writes the
+// error response and reports false.
+func (s *server) acquire(w http.ResponseWriter, r *http.Request) (func(), bool) {
+	release, err := s.queue.acquire(r.Context())
+	if err != nil {
+		reason := ""
+		switch {
+		case errors.Is(err, errQueueFull):
+			reason = "queue_full"
+		case errors.Is(err, errQueueTimeout):
+			reason = "queue_timeout"
+		}
+		if reason != "" {
+			s.metrics.queryError(reason)
+			w.Header().Set("Retry-After", "1")
+			writeError(w, http.StatusServiceUnavailable, err.Error())
+		}
+		return nil, false
+	}
+	return release, true
+}
+
+// parseCoord parses a required decimal-degree value within ±limit.
+func parseCoord(v string, limit float64) (float64, error) {
+	if v == "" {
+		return 0, errors.New("required")
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, errors.New("not a number")
+	}
+	if f < -limit || f > limit {
+		return 0, fmt.Errorf("must be between %g and %g", -limit, limit)
+	}
+	return f, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("writing response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// writeQueryError answers a failed lookup: 503 while the circuit breaker
+// is open, 500 otherwise.
+func (s *server) writeQueryError(w http.ResponseWriter, err error) {
+	if errors.Is(err, geocoder.ErrBackendUnavailable) {
+		s.metrics.queryError("unavailable")
+		w.Header().Set("Retry-After", strconv.Itoa(
+			int(math.Ceil(s.cfg.Resilience.WithDefaults().Cooldown.Seconds()))))
+		writeError(w, http.StatusServiceUnavailable, "database unavailable")
+		return
+	}
+	s.metrics.queryError("database")
+	writeError(w, http.StatusInternalServerError, "query failed")
+}
+
+// ---------------------------------------------------------------------------
+// Entry point
+// ---------------------------------------------------------------------------
+
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	cfgPath := fs.String(
+		"config", "../../../../config/config.yaml",
+		"Path to config YAML file (default: ../../../../config/config.yaml)",
+	)
+	rawURL := fs.String(
+		"url", "",
+		"Connection URL — overrides the database section of --config",
+	)
+	listen := fs.String("listen", "", "Listen address (default: :8080)")
+	rate := fs.Float64(
+		"rate", 0,
+		"Requests per second allowed per client IP or API key "+
+			"(default: 10; negative disables rate limiting)",
+	)
+	burst := fs.Int("burst", 0, "Rate-limit burst size (default: 2 × rate)")
+	maxConcurrent := fs.Int(
+		"max-concurrent", 0,
+		"Maximum queries running against the database at once (default: 8)",
+	)
+	maxQueued := fs.Int(
+		"max-queued", 0,
+		"Maximum requests waiting for a query slot (default: 8 × max-concurrent)",
+	)
+	queueTimeout := fs.Duration(
+		"queue-timeout", 0,
+		"Maximum time a request waits for a query slot (default: 2s)",
+	)
+	strategy := fs.String(
+		"strategy", "",
+		"Distance strategy: auto, postgis, earthdistance, haversine, memory or rtree "+
+			"(default: auto)",
+	)
+	cachePath := fs.String("cache", "",
+		"Persistent lookup cache file, created if missing (see cache.go)")
+	jobsPath := fs.String("jobs", "",
+		"Job store file, created if missing; enables the /jobs endpoints (see jobs.go)")
+	_ = fs.Parse(args)
+
+	// settings reads the config file, at start-up and on every reload.
+	settings := func() (*Config, serverConfig, error) {
+		cfg, err := configFor(*cfgPath, *rawURL)
+		if err != nil {
+			return nil, serverConfig{}, fmt.Errorf("config: %w", err)
+		}
+		// Flags given explicitly on the command line override the config
+		// file.
+		sc := cfg.Server
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "listen":
+				sc.Listen = *listen
+			case "rate":
+				sc.RateLimit.RequestsPerSecond = *rate
+			case "burst":
+				sc.RateLimit.Burst = *burst
+			case "max-concurrent":
+				sc.MaxConcurrentQueries = *maxConcurrent
+			case "max-queued":
+				sc.MaxQueued = *maxQueued
+			case "queue-timeout":
+				sc.QueueTimeout = *queueTimeout
+			case "strategy":
+				sc.Strategy = *strategy
+			case "cache":
+				sc.Cache = *cachePath
+			case "jobs":
+				sc.Jobs.Store = *jobsPath
+			}
+		})
+		sc = sc.withDefaults()
+		sc.cacheScope = cacheScope(cfg.Database, *rawURL)
+		if err := sc.check(); err != nil {
+			return nil, sc, fmt.Errorf("config: %w", err)
+		}
+		return cfg, sc, nil
+	}
+	cfg, sc, err := settings()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := openQueryDB(cfg, *rawURL)
+	if err != nil {
+		log.Fatalf("database: %v", err)
+	}
+	if err := sizePool(db, sc); err != nil {
+		log.Fatalf("server: %v", err)
+	}
+	if err := prepareServeDB(db, sc); err != nil {
+		log.Fatalf("server: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(
+		context.Background(), os.Interrupt, syscall.SIGTERM,
+	)
+	defer stop()
+
+	srv, err := newServer(ctx, db, sc, nil)
+	if err != nil {
+		log.Fatalf("server: %v", err)
+	}
+	if sc.Jobs.Store != "" {
+		if srv.jobs, err = openJobs(sc.Jobs); err != nil {
+			log.Fatalf("server: %v", err)
+		}
+	}
+	live := newLiveServer(ctx, srv, cfg.Database, *rawURL, settings)
+	go live.reloadOnHangup()
+	if srv.jobs != nil {
+		srv.jobs.start(ctx, live.acquire)
+	}
+	httpServer := &http.Server{
+		Addr:              sc.Listen,
+		Handler:           live,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(
+			context.Background(), 10*time.Second,
+		)
+		defer cancel()
+		_ = httpServer.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("listening on %s (strategy: %s)", sc.Listen, srv.strategy)
+	err = httpServer.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		// Let in-flight requests finish before closing the cache.
+		<-drained
+	}
+	if srv.jobs != nil {
+		// The workers stop once ctx is done.
+		stop()
+		if err := srv.jobs.Close(); err != nil {
+			log.Printf("jobs: %v", err)
+		}
+	}
+	if cache := live.current().cache; cache != nil {
+		if err := cache.Close(); err != nil {
+			log.Printf("cache: %v", err)
+		}
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("server: %v", err)
+	}
+}
+
+// sizePool caps the connection pool of db to the query slots of sc.
+func sizePool(db *gorm.DB, sc serverConfig) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	sqlDB.SetMaxOpenConns(sc.MaxConcurrentQueries)
+	sqlDB.SetMaxIdleConns(sc.MaxConcurrentQueries)
+	return nil
+}
+
+// prepareServeDB checks the strategy of sc on db and loads the in-memory
+// index when that strategy is forced. It leaves the pool alone, so that a
+// reload can check a configuration without touching the one serving.
+func prepareServeDB(db *gorm.DB, sc serverConfig) error {
+	if err := geocoder.CheckStrategy(db, sc.Strategy); err != nil {
+		return err
+	}
+	if sc.Strategy == geocoder.StrategyMemory {
+		// Load the index before accepting requests rather than on the
+		// first one.
+		log.Printf("loading in-memory index")
+		if err := geocoder.LoadMemoryIndex(db); err != nil {
+			return fmt.Errorf("in-memory index: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,459 @@
+package main
+
+/*
+	Read-only SQLite snapshots ("snapshot" subcommand) and the R*Tree
+	strategy that queries them.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    go run . snapshot --out geonames-mx.db --country MX,GT
+	    go run . snapshot --url postgres://... --out places.db --fclass P,A \
+	        --with-alternates
+	    go run . snapshot --out mx-links.db --country MX --with-links
+
+	The library side, and how it works, is in pkg/geocoder/snapshot.go.
+*/
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rgglez/geonames-loader/examples/go/internal/sqlbuild"
+	"github.com/rgglez/geonames-loader/examples/go/pkg/geocoder"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// ---------------------------------------------------------------------------
+// Snapshot
+// ---------------------------------------------------------------------------
+
+// snapshotOptions selects what a snapshot contains.
+type snapshotOptions struct {
+	Countries      []string
+	FeatureClasses []string
+	WithAlternates bool
+	// WithLinks copies the link and wkdt rows of the alternatename table
+	// (see pkg/geocoder/links.go), when WithAlternates does not copy all.
+	WithLinks bool
+}
+
+// snapshotTable is a table copied into a snapshot.
+type snapshotTable struct {
+	Name    string
+	Columns []string
+	// CountryCol is the column --country filters on; "" copies every row.
+	CountryCol string
+}
+
+// snapshotTables returns the tables to copy, in copy order.
+func (o snapshotOptions) snapshotTables() []snapshotTable {
+	places := []string{
+		"geonameid", "name", "asciiname", "latitude", "longitude", "fclass",
+		"fcode", "country", "admin1", "admin2", "population", "timezone",
+		"moddate",
+	}
+	if o.WithAlternates {
+		places = append(places, "alternatenames")
+	}
+	tables := []snapshotTable{
+		{"geoname", places, "country"},
+		{"postalcodes", []string{
+			"countrycode", "postalcode", "placename", "admin1name",
+			"admin1code", "admin2name", "admin2code", "admin3name",
+			"admin3code", "latitude", "longitude", "accuracy",
+		}, "countrycode"},
+		{"admin1codesascii", []string{
+			"code", "name", "nameascii", "geonameid", "countrycode",
+		}, "countrycode"},
+		{"admin2codesascii", []string{
+			"code", "name", "nameascii", "geonameid", "countrycode",
+		}, "countrycode"},
+		{"timezones", []string{
+			"countrycode", "timezoneid", "gmt_offset", "dst_offset", "raw_offset",
+		}, "countrycode"},
+		{"countryinfo", columnNames(loadTables["countryinfo"]), ""},
+		{"featurecodes", columnNames(loadTables["featurecodes"]), ""},
+		{"continentcodes", []string{"code", "name", "geonameid"}, ""},
+	}
+	if o.WithAlternates || o.WithLinks {
+		tables = append(tables, snapshotTable{
+			"alternatename", columnNames(loadTables["alternatename"]), "",
+		})
+	}
+	return tables
+}
+
+// snapshotIndexes are the indexes of a snapshot: the lookups by code and
+// id, and the R*Tree tables of the rtree strategy.
+var snapshotIndexes = []string{
+	"CREATE INDEX geoname_geonameid_idx ON geoname (geonameid)",
+	"CREATE INDEX admin1codesascii_code_idx ON admin1codesascii (code)",
+	"CREATE INDEX admin2codesascii_code_idx ON admin2codesascii (code)",
+	"CREATE INDEX countryinfo_iso_alpha2_idx ON countryinfo (iso_alpha2)",
+	"CREATE INDEX timezones_timezoneid_idx ON timezones (timezoneid)",
+	"CREATE INDEX postalcodes_countrycode_idx ON postalcodes (countrycode)",
+	"CREATE VIRTUAL TABLE geoname_rtree" +
+		" USING rtree(id, minlat, maxlat, minlon, maxlon)",
+	"INSERT INTO geoname_rtree SELECT geonameid, latitude, latitude," +
+		" longitude, longitude FROM geoname" +
+		" WHERE latitude IS NOT NULL AND longitude IS NOT NULL",
+	"CREATE VIRTUAL TABLE postalcodes_rtree" +
+		" USING rtree(id, minlat, maxlat, minlon, maxlon)",
+	"INSERT INTO postalcodes_rtree SELECT rowid, latitude, latitude," +
+		" longitude, longitude FROM postalcodes" +
+		" WHERE latitude IS NOT NULL AND longitude IS NOT NULL",
+}
+
+// snapshotAlternateIndexes are added when alternate names are copied.
+var snapshotAlternateIndexes = []string{
+	"CREATE INDEX alternatename_geonameid_idx ON alternatename (geonameid)",
+}
+
+func columnNames(cols []loadColumn) []string {
+	names := make([]string, len(cols))
+	for i, c := range cols {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// snapshotColumns returns the loader columns of table named in names.
+func snapshotColumns(table string, names []string) []loadColumn {
+	kinds := map[string]loadKind{}
+	for _, c := range loadTables[table] {
+		kinds[c.Name] = c.Kind
+	}
+	cols := make([]loadColumn, len(names))
+	for i, n := range names {
+		cols[i] = loadColumn{n, kinds[n]}
+	}
+	return cols
+}
+
+// snapshotValue normalises a value read from the source database to what
+// the loader stores in SQLite.
+func snapshotValue(v any, k loadKind) any {
+	switch x := v.(type) {
+	case []byte: // MySQL returns most types as text
+		if k == loadString || k == loadDate {
+			return string(x)
+		}
+		return convertValue(string(x), k, "sqlite")
+	case time.Time:
+		if k == loadDate {
+			return x.Format("2006-01-02")
+		}
+		return x.UTC().Format("2006-01-02 15:04:05")
+	}
+	return v
+}
+
+// snapshotWhere returns the row filter of table t of src and its
+// arguments.
+func (o snapshotOptions) snapshotWhere(src *gorm.DB, t snapshotTable) (string, []any) {
+	var conds []string
+	var args []any
+	in := func(col string, vals []string) {
+		conds = append(conds, col+" IN (?"+strings.Repeat(", ?", len(vals)-1)+")")
+		for _, v := range vals {
+			args = append(args, v)
+		}
+	}
+	switch {
+	case t.Name == "alternatename":
+		// The alternate names of the places copied.
+		sub := "SELECT geonameid FROM " + geocoder.TableName(src, "geoname")
+		var subConds []string
+		if len(o.Countries) > 0 {
+			subConds = append(subConds, "country IN (?"+
+				strings.Repeat(", ?", len(o.Countries)-1)+")")
+			for _, c := range o.Countries {
+				args = append(args, c)
+			}
+		}
+		if len(o.FeatureClasses) > 0 {
+			subConds = append(subConds, "fclass IN (?"+
+				strings.Repeat(", ?", len(o.FeatureClasses)-1)+")")
+			for _, c := range o.FeatureClasses {
+				args = append(args, c)
+			}
+		}
+		if len(subConds) > 0 {
+			conds = append(conds, "geonameid IN ("+sub+" WHERE "+
+				strings.Join(subConds, " AND ")+")")
+		}
+		if !o.WithAlternates {
+			in("isolanguage", []string{"link", "wkdt"})
+		}
+	case t.CountryCol != "" && len(o.Countries) > 0:
+		in(t.CountryCol, o.Countries)
+	}
+	if t.Name == "geoname" && len(o.FeatureClasses) > 0 {
+		in("fclass", o.FeatureClasses)
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// copyTable streams the selected rows of t from src into tx.
+func copyTable(src *gorm.DB, tx *sql.Tx, t snapshotTable, o snapshotOptions) (int64, error) {
+	cols := snapshotColumns(t.Name, t.Columns)
+	where, args := o.snapshotWhere(src, t)
+	rows, err := src.Raw(fmt.Sprintf("SELECT %s FROM %s%s",
+		strings.Join(t.Columns, ", "), geocoder.TableName(src, t.Name), where), args...).Rows()
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	batch := sqlbuild.MaxParams / len(cols)
+	full, err := tx.Prepare(insertSQL("sqlite", t.Name, cols, batch))
+	if err != nil {
+		return 0, err
+	}
+	defer full.Close()
+
+	var n int64
+	vals := make([]any, len(cols))
+	ptrs := make([]any, len(cols))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	buf := make([]any, 0, batch*len(cols))
+	flush := func() error {
+		if len(buf) == 0 {
+			return nil
+		}
+		var err error
+		if r := len(buf) / len(cols); r == batch {
+			_, err = full.Exec(buf...)
+		} else {
+			_, err = tx.Exec(insertSQL("sqlite", t.Name, cols, r), buf...)
+		}
+		n += int64(len(buf) / len(cols))
+		buf = buf[:0]
+		return err
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return n, err
+		}
+		for i, c := range cols {
+			buf = append(buf, snapshotValue(vals[i], c.Kind))
+		}
+		if len(buf) == cap(buf) {
+			if err := flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return n, err
+	}
+	return n, flush()
+}
+
+// copyMeta copies the meta rows, which the optional meta table may lack.
+func copyMeta(src *gorm.DB, tx *sql.Tx) error {
+	var meta []struct {
+		Version     string `gorm:"column:version"`
+		DataURI     string `gorm:"column:data_uri"`
+		DataVersion string `gorm:"column:data_version"`
+		Date        string `gorm:"column:date_accessed"`
+	}
+	if src.Raw(`
+		SELECT version, data_uri, data_version,
+		       CAST(date_accessed AS CHAR(19)) AS date_accessed
+		FROM `+geocoder.TableName(src, "meta")).Scan(&meta).Error != nil {
+		return nil
+	}
+	for _, m := range meta {
+		if _, err := tx.Exec(
+			"INSERT INTO meta (version, data_uri, data_version, date_accessed)"+
+				" VALUES (?, ?, ?, ?)",
+			m.Version, m.DataURI, m.DataVersion, m.Date,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildSnapshot writes the snapshot of src to path, which must not exist.
+func buildSnapshot(src *gorm.DB, path string, o snapshotOptions) error {
+	if err := geocoder.SupportedDialect(src); err != nil {
+		return err
+	}
+	dst, err := gorm.Open(sqlite.Open(path), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return err
+	}
+	sqlDB, err := dst.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+	// A single connection, so the PRAGMAs apply to every statement.
+	sqlDB.SetMaxOpenConns(1)
+
+	// The file is only renamed into place once complete: no journal.
+	for _, stmt := range []string{
+		"PRAGMA journal_mode = OFF",
+		"PRAGMA synchronous = OFF",
+	} {
+		if err := dst.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	if err := runMigration(dst, "schema.sql"); err != nil {
+		return err
+	}
+
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, t := range o.snapshotTables() {
+		start := time.Now()
+		n, err := copyTable(src, tx, t, o)
+		if err != nil {
+			return fmt.Errorf("copying %s: %w", t.Name, err)
+		}
+		fmt.Printf("  %-18s %12d rows  %s\n", t.Name, n,
+			time.Since(start).Round(time.Millisecond))
+	}
+	if err := copyMeta(src, tx); err != nil {
+		return fmt.Errorf("copying meta: %w", err)
+	}
+	if _, err := tx.Exec(insertSchemaVersion, geocoder.SchemaVersion, time.Now().UTC()); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	fmt.Print("  Building indexes ... ")
+	stmts := snapshotIndexes
+	if o.WithAlternates || o.WithLinks {
+		stmts = append(stmts, snapshotAlternateIndexes...)
+	}
+	for _, stmt := range stmts {
+		if err := dst.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	fmt.Println("done")
+
+	fmt.Print("  VACUUM ... ")
+	for _, stmt := range []string{
+		"ANALYZE",
+		"PRAGMA journal_mode = DELETE",
+		"VACUUM",
+	} {
+		if err := dst.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	fmt.Println("done")
+	return nil
+}
+
+func runSnapshot(args []string) {
+	fs := flag.NewFlagSet("snapshot", flag.ExitOnError)
+	cfgPath := fs.String(
+		"config", "../../../../config/config.yaml",
+		"Path to config YAML file (default: ../../../../config/config.yaml)",
+	)
+	rawURL := fs.String(
+		"url", "",
+		"Source connection URL — overrides --config",
+	)
+	outPath := fs.String("out", "", "SQLite file to create (required)")
+	countries := fs.String("country", "",
+		"Comma-separated ISO 3166-1 alpha-2 codes to include (default: all)")
+	fclass := fs.String("fclass", "",
+		"Comma-separated feature classes of the places to include (default: all)")
+	withAlternates := fs.Bool("with-alternates", false,
+		"Include alternate names (geoname.alternatenames and the alternatename table)")
+	withLinks := fs.Bool("with-links", false,
+		"Include the Wikipedia links and Wikidata IDs of the alternatename table")
+	force := fs.Bool("force", false, "Replace --out if it exists")
+	_ = fs.Parse(args)
+
+	if *outPath == "" {
+		fmt.Fprintln(os.Stderr, "ERROR: --out is required.")
+		fs.Usage()
+		os.Exit(1)
+	}
+	if _, err := os.Stat(*outPath); err == nil && !*force {
+		log.Fatalf("snapshot: %s exists (use --force to replace it)", *outPath)
+	}
+	o := snapshotOptions{WithAlternates: *withAlternates, WithLinks: *withLinks}
+	var err error
+	if o.Countries, err = parseCodeList(*countries, 2, ""); err != nil {
+		log.Fatalf("snapshot: --country: %v", err)
+	}
+	if o.FeatureClasses, err = parseCodeList(*fclass, 1, featureClasses); err != nil {
+		log.Fatalf("snapshot: --fclass: %v", err)
+	}
+
+	cfg, err := configFor(*cfgPath, *rawURL)
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	db, err := openQueryDB(cfg, *rawURL)
+	if err != nil {
+		log.Fatalf("database: %v", err)
+	}
+
+	fmt.Println(strings.Repeat("=", 60))
+	fmt.Println("GeoNames SQLite snapshot")
+	fmt.Printf("  Source   : %s\n", db.Dialector.Name())
+	fmt.Printf("  Output   : %s\n", *outPath)
+	fmt.Printf("  Countries: %s\n", orAll(strings.Join(o.Countries, ", ")))
+	if len(o.FeatureClasses) > 0 {
+		fmt.Printf("  Classes  : %s\n", strings.Join(o.FeatureClasses, ", "))
+	}
+	fmt.Println(strings.Repeat("=", 60))
+
+	tmp := *outPath + ".tmp"
+	os.Remove(tmp)
+	if err := buildSnapshot(db, tmp, o); err != nil {
+		os.Remove(tmp)
+		log.Fatalf("snapshot: %v", err)
+	}
+	if err := os.Rename(tmp, *outPath); err != nil {
+		log.Fatalf("snapshot: %v", err)
+	}
+	if st, err := os.Stat(*outPath); err == nil {
+		fmt.Printf("\nWrote %s (%.1f MB).\n", *outPath, float64(st.Size())/(1<<20))
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+/*
+	Autocomplete: ranked place suggestions for a typed prefix.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    go run . suggest --prefix guad [--bias-country MX] [--results 10]
+	    go run . suggest --create-indexes
+
+	The library side, and how it works, is in pkg/geocoder/suggest.go.
+*/
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rgglez/geonames-loader/examples/go/pkg/geocoder"
+)
+
+func (s *server) handleSuggest(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	prefix := q.Get("q")
+	if strings.TrimSpace(prefix) == "" {
+		writeError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	opts := geocoder.DefaultSuggestOptions()
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		opts.Limit = min(n, s.cfg.MaxResults)
+	}
+	opts.Country = strings.ToUpper(q.Get("country"))
+	opts.BiasCountry = strings.ToUpper(q.Get("bias"))
+
+	release, ok := s.acquire(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+
+	rows, err := s.geo.WithContext(r.Context()).Suggest(prefix, opts)
+	if err != nil {
+		log.Printf("suggest: %v", err)
+		s.writeQueryError(w, err)
+		return
+	}
+	if rows == nil {
+		rows = []geocoder.Suggestion{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"suggestions": rows})
+}
+
+func runSuggest(args []string) {
+	fs := flag.NewFlagSet("suggest", flag.ExitOnError)
+	cfgPath := fs.String(
+		"config", "../../../../config/config.yaml",
+		"Path to config YAML file (default: ../../../../config/config.yaml)",
+	)
+	rawURL := fs.String(
+		"url", "",
+		"Connection URL — overrides --config",
+	)
+	prefix := fs.String("prefix", "", "Text typed so far")
+	nRes := fs.Int("results", 10, "Number of suggestions (default: 10)")
+	country := fs.String("country", "",
+		"Restrict suggestions to this ISO 3166-1 alpha-2 country code")
+	bias := fs.String("bias-country", "",
+		"Rank places in this ISO 3166-1 alpha-2 country higher")
+	createIdx := fs.Bool("create-indexes", false,
+		"Create the prefix indexes used by suggestions and exit")
+	_ = fs.Parse(args)
+
+	if *prefix == "" && !*createIdx {
+		fmt.Fprintln(os.Stderr, "ERROR: --prefix or --create-indexes is required.")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	cfg, err := configFor(*cfgPath, *rawURL)
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	db, err := openQueryDB(cfg, *rawURL)
+	if err != nil {
+		log.Fatalf("database: %v", err)
+	}
+
+	if *createIdx {
+		stmts := geocoder.SuggestIndexDDL(db)
+		if len(stmts) == 0 {
+			fmt.Println("No extra indexes needed for this dialect.")
+			return
+		}
+		if err := geocoder.CreateSuggestIndexes(db); err != nil {
+			log.Fatalf("suggest: %v", err)
+		}
+		for _, s := range stmts {
+			fmt.Println(s + ";")
+		}
+		return
+	}
+
+	opts := geocoder.DefaultSuggestOptions()
+	opts.Limit = *nRes
+	opts.Country = strings.ToUpper(*country)
+	opts.BiasCountry = strings.ToUpper(*bias)
+	rows, err := geocoder.New(db).Suggest(*prefix, opts)
+	if err != nil {
+		log.Fatalf("suggest: %v", err)
+	}
+	for _, s := range rows {
+		fmt.Printf("  %.4f  %-40s  %s/%s  pop %d\n",
+			s.Score, s.Label, s.Fclass, s.Fcode, s.Population)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+/*
+	Geofences: named polygons and point-in-zone checks.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	The library side, and how it works, is in pkg/geocoder/zones.go.
+*/
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/rgglez/geonames-loader/examples/go/pkg/geocoder"
+)
+
+// ---------------------------------------------------------------------------
+// Server
+// ---------------------------------------------------------------------------
+
+// zoneConfig is one entry of the server's "zones" list. The geometry is
+// given inline or read from File, in WKT or GeoJSON.
+type zoneConfig struct {
+	Name     string `yaml:"name"`
+	Geometry string `yaml:"geometry"`
+	File     string `yaml:"file"`
+}
+
+// registerZones adds the configured zones to g.
+func registerZones(g *geocoder.Geocoder, zones []zoneConfig) error {
+	for _, zc := range zones {
+		geometry := zc.Geometry
+		if zc.File != "" {
+			data, err := os.ReadFile(zc.File)
+			if err != nil {
+				return fmt.Errorf("zone %s: %w", zc.Name, err)
+			}
+			geometry = string(data)
+		}
+		if err := g.RegisterZone(zc.Name, geometry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// zonesResponse is the JSON body returned by GET /zones.
+type zonesResponse struct {
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	Zones     []string `json:"zones"`
+}
+
+func (s *server) handleZones(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	lat, err := parseCoord(q.Get("lat"), 90)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "lat: "+err.Error())
+		return
+	}
+	lon, err := parseCoord(q.Get("lon"), 180)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "lon: "+err.Error())
+		return
+	}
+
+	release, ok := s.acquire(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+
+	zones, err := s.geo.WithContext(r.Context()).WithinZones(lat, lon)
+	if err != nil {
+		s.writeQueryError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, zonesResponse{
+		Latitude: lat, Longitude: lon, Zones: zones,
+	})
+}
@@ -0,0 +1,130 @@
+// Package kdtree is the nearest-neighbour index on the sphere shared by the
+// in-memory strategies of the geocoder library and the offline geocoder.
+package kdtree
+
+/*
+	3-D k-d tree over points on the unit sphere.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Positions are unit vectors on the sphere. Straight-line (chord)
+	distance between unit vectors grows monotonically with great-circle
+	distance, so an ordinary 3-D nearest-neighbour search gives exact
+	Haversine results. The package has no dependencies beyond the
+	standard library, so it builds for WebAssembly.
+*/
+
+import (
+	"math"
+	"sort"
+)
+
+// Tree is a static 3-D k-d tree stored implicitly: the median of each
+// index range is the node, the halves its children.
+type Tree struct {
+	pts [][3]float64
+	idx []int32
+}
+
+// UnitVector returns the position of (lat, lon) on the unit sphere.
+func UnitVector(lat, lon float64) [3]float64 {
+	const rad = math.Pi / 180
+	sinLat, cosLat := math.Sincos(lat * rad)
+	sinLon, cosLon := math.Sincos(lon * rad)
+	return [3]float64{cosLat * cosLon, cosLat * sinLon, sinLat}
+}
+
+// ChordAngle converts a squared chord length between unit vectors to the
+// great-circle angle between them, in radians.
+func ChordAngle(chordSq float64) float64 {
+	return 2 * math.Asin(min(1, math.Sqrt(chordSq)/2))
+}
+
+// New returns the tree of pts; the indexes of Nearest are into pts.
+func New(pts [][3]float64) *Tree {
+	t := &Tree{pts: pts, idx: make([]int32, len(pts))}
+	for i := range t.idx {
+		t.idx[i] = int32(i)
+	}
+	t.build(0, len(pts), 0)
+	return t
+}
+
+func (t *Tree) build(lo, hi, axis int) {
+	if hi-lo < 2 {
+		return
+	}
+	sub := t.idx[lo:hi]
+	sort.Slice(sub, func(i, j int) bool {
+		return t.pts[sub[i]][axis] < t.pts[sub[j]][axis]
+	})
+	mid := (lo + hi) / 2
+	next := (axis + 1) % 3
+	t.build(lo, mid, next)
+	t.build(mid+1, hi, next)
+}
+
+// Hit is a search result: point index and squared chord distance.
+type Hit struct {
+	I    int32
+	Dist float64
+}
+
+// Nearest returns up to k points closest to q for which accept returns
+// true, nearest first.
+func (t *Tree) Nearest(q [3]float64, k int, accept func(i int32) bool) []Hit {
+	hits := make([]Hit, 0, k)
+	var search func(lo, hi, axis int)
+	search = func(lo, hi, axis int) {
+		if lo >= hi {
+			return
+		}
+		mid := (lo + hi) / 2
+		p := t.idx[mid]
+		pt := t.pts[p]
+		if accept(p) {
+			dx, dy, dz := pt[0]-q[0], pt[1]-q[1], pt[2]-q[2]
+			d := dx*dx + dy*dy + dz*dz
+			if len(hits) < k || d < hits[len(hits)-1].Dist {
+				pos := sort.Search(len(hits), func(i int) bool { return hits[i].Dist > d })
+				if len(hits) < k {
+					hits = append(hits, Hit{})
+				}
+				copy(hits[pos+1:], hits[pos:])
+				hits[pos] = Hit{p, d}
+			}
+		}
+		diff := q[axis] - pt[axis]
+		next := (axis + 1) % 3
+		if diff < 0 {
+			search(lo, mid, next)
+			if len(hits) < k || diff*diff < hits[len(hits)-1].Dist {
+				search(mid+1, hi, next)
+			}
+		} else {
+			search(mid+1, hi, next)
+			if len(hits) < k || diff*diff < hits[len(hits)-1].Dist {
+				search(lo, mid, next)
+			}
+		}
+	}
+	if k > 0 {
+		search(0, len(t.idx), 0)
+	}
+	return hits
+}
@@ -0,0 +1,314 @@
+package sqlbuild
+
+/*
+	SELECT builder and the distance expressions of each strategy.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Every clause of a Select is added together with the values of its
+	placeholders, and Build lays the values out in the order the clauses
+	appear in the SQL text. Optional clauses — a country, a feature class,
+	a population floor, a bounding box — can then be added in any order
+	and number without shifting the arguments of the others. Strings always
+	go through placeholders; the only values formatted into the SQL are
+	float64s, the point of a Haversine expression. Placeholders are always
+	"?", which gorm binds in the syntax of each driver ($1, $2, ... on
+	PostgreSQL).
+
+	Build formats one clause per line:
+
+	    SELECT g.name, g.country,
+	           <distance> AS distance_km
+	    FROM geoname g
+	    WHERE g.latitude IS NOT NULL
+	      AND g.country = ?
+	    ORDER BY distance_km
+	    LIMIT ?
+
+	Table names and aliases are interpolated as given: callers pass names
+	checked against Identifier, never user input.
+*/
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Expr is a SQL fragment and the values of its placeholders.
+type Expr struct {
+	SQL  string
+	Args []any
+}
+
+// E returns the Expr of sql and args.
+func E(sql string, args ...any) Expr {
+	return Expr{SQL: sql, Args: args}
+}
+
+// As returns e followed by " AS name".
+func (e Expr) As(name string) Expr {
+	return Expr{SQL: e.SQL + " AS " + name, Args: e.Args}
+}
+
+// Col returns column qualified with alias, or column alone when alias is
+// "".
+func Col(alias, column string) string {
+	if alias == "" {
+		return column
+	}
+	return alias + "." + column
+}
+
+// Select is a SELECT statement under construction. The zero value is
+// ready to use.
+type Select struct {
+	cols   []Expr
+	from   string
+	joins  []Expr
+	where  []Expr
+	order  Expr
+	limit  []any
+	offset []any
+	indent string
+}
+
+// Columns adds the columns cols, qualified with alias.
+func (s *Select) Columns(alias string, cols ...string) *Select {
+	if len(cols) == 0 {
+		return s
+	}
+	names := make([]string, len(cols))
+	for i, c := range cols {
+		names[i] = Col(alias, c)
+	}
+	s.cols = append(s.cols, E(strings.Join(names, ", ")))
+	return s
+}
+
+// Column adds a result column computed by e.
+func (s *Select) Column(e Expr) *Select {
+	s.cols = append(s.cols, e)
+	return s
+}
+
+// From sets the table queried, with an optional alias.
+func (s *Select) From(table, alias string) *Select {
+	s.from = table
+	if alias != "" {
+		s.from += " " + alias
+	}
+	return s
+}
+
+// Join adds a join clause, e.g. "JOIN postalcodes p ON p.rowid = r.id".
+func (s *Select) Join(e Expr) *Select {
+	s.joins = append(s.joins, e)
+	return s
+}
+
+// Where adds a condition, ANDed with the others.
+func (s *Select) Where(cond string, args ...any) *Select {
+	return s.WhereExpr(E(cond, args...))
+}
+
+// WhereExpr is Where for a condition built elsewhere.
+func (s *Select) WhereExpr(e Expr) *Select {
+	s.where = append(s.where, e)
+	return s
+}
+
+// WhereIf adds cond only when ok, for the optional filters of a query.
+func (s *Select) WhereIf(ok bool, cond string, args ...any) *Select {
+	if ok {
+		s.Where(cond, args...)
+	}
+	return s
+}
+
+// WhereBBox restricts the columns latitude and longitude of alias to
+// bbox (min lon, min lat, max lon, max lat), or does nothing when bbox is
+// nil. A box whose min lon exceeds its max lon crosses the antimeridian.
+func (s *Select) WhereBBox(alias string, bbox []float64) *Select {
+	if bbox == nil {
+		return s
+	}
+	lat, lon := Col(alias, "latitude"), Col(alias, "longitude")
+	s.Where(lat+" BETWEEN ? AND ?", bbox[1], bbox[3])
+	if bbox[0] <= bbox[2] {
+		return s.Where(lon+" BETWEEN ? AND ?", bbox[0], bbox[2])
+	}
+	return s.Where("("+lon+" >= ? OR "+lon+" <= ?)", bbox[0], bbox[2])
+}
+
+// OrderBy sets the ORDER BY expression.
+func (s *Select) OrderBy(e Expr) *Select {
+	s.order = e
+	return s
+}
+
+// Limit sets the LIMIT, bound as a placeholder.
+func (s *Select) Limit(n int) *Select {
+	s.limit = []any{n}
+	return s
+}
+
+// Offset sets the OFFSET, bound as a placeholder; it needs a Limit.
+func (s *Select) Offset(n int) *Select {
+	s.offset = []any{n}
+	return s
+}
+
+// Indent prefixes every line but the first with prefix, including the
+// lines of multi-line clauses, for a Select nested in another statement.
+func (s *Select) Indent(prefix string) *Select {
+	s.indent = prefix
+	return s
+}
+
+// Build returns the statement and its arguments.
+func (s *Select) Build() (string, []any) {
+	var b strings.Builder
+	var args []any
+	nl := "\n" + s.indent
+	b.WriteString("SELECT ")
+	for i, c := range s.cols {
+		if i > 0 {
+			b.WriteString("," + nl + "       ")
+		}
+		b.WriteString(strings.ReplaceAll(c.SQL, "\n", nl))
+		args = append(args, c.Args...)
+	}
+	b.WriteString(nl + "FROM " + s.from)
+	for _, j := range s.joins {
+		b.WriteString(nl + strings.ReplaceAll(j.SQL, "\n", nl))
+		args = append(args, j.Args...)
+	}
+	for i, w := range s.where {
+		if i == 0 {
+			b.WriteString(nl + "WHERE ")
+		} else {
+			b.WriteString(nl + "  AND ")
+		}
+		b.WriteString(w.SQL)
+		args = append(args, w.Args...)
+	}
+	if s.order.SQL != "" {
+		b.WriteString(nl + "ORDER BY " + s.order.SQL)
+		args = append(args, s.order.Args...)
+	}
+	if s.limit != nil {
+		b.WriteString(nl + "LIMIT ?")
+		args = append(args, s.limit...)
+		if s.offset != nil {
+			b.WriteString(" OFFSET ?")
+			args = append(args, s.offset...)
+		}
+	}
+	return b.String(), args
+}
+
+// ---------------------------------------------------------------------------
+// Distance expressions
+// ---------------------------------------------------------------------------
+
+// PostGISPoint returns the geography of the columns of alias.
+func PostGISPoint(alias string) string {
+	return fmt.Sprintf("ST_MakePoint(%s, %s)::geography",
+		Col(alias, "longitude"), Col(alias, "latitude"))
+}
+
+// PostGISDistance returns the PostGIS distance in km from the columns of
+// alias to (lat, lon).
+func PostGISDistance(alias string, lat, lon float64) Expr {
+	return E("ST_Distance("+PostGISPoint(alias)+", ST_MakePoint(?, ?)::geography) / 1000.0",
+		lon, lat)
+}
+
+// PostGISWithin returns the ST_DWithin condition, which the GIST index on
+// the geography of the columns answers, for radiusM metres around
+// (lat, lon).
+func PostGISWithin(alias string, lat, lon, radiusM float64) Expr {
+	return E("ST_DWithin("+PostGISPoint(alias)+", ST_MakePoint(?, ?)::geography, ?)",
+		lon, lat, radiusM)
+}
+
+// EarthPoint returns the earthdistance point of the columns of alias.
+func EarthPoint(alias string) string {
+	return fmt.Sprintf("ll_to_earth(%s, %s)", Col(alias, "latitude"), Col(alias, "longitude"))
+}
+
+// EarthDistance returns the earthdistance distance in km from the
+// columns of alias to (lat, lon).
+func EarthDistance(alias string, lat, lon float64) Expr {
+	return E("earth_distance("+EarthPoint(alias)+", ll_to_earth(?, ?)) / 1000.0", lat, lon)
+}
+
+// EarthBox returns the earth_box condition, which the GIST index on
+// ll_to_earth answers, for radiusM metres around (lat, lon).
+func EarthBox(alias string, lat, lon, radiusM float64) Expr {
+	return E("earth_box(ll_to_earth(?, ?), ?) @> "+EarthPoint(alias), lat, lon, radiusM)
+}
+
+// ---------------------------------------------------------------------------
+// Nearest postal code of each place
+// ---------------------------------------------------------------------------
+
+// nearbyPostal is the condition on the postal codes (alias p, or none)
+// of the place aliased g: same country, within deg degrees. Conditions
+// after the first start a line with indent.
+func nearbyPostal(p string, deg float64, indent string) string {
+	lat, lon := Col(p, "latitude"), Col(p, "longitude")
+	return strings.Join([]string{
+		Col(p, "countrycode") + " = g.country",
+		lat + " IS NOT NULL AND " + lon + " IS NOT NULL",
+		fmt.Sprintf("%s BETWEEN g.latitude - %.4f AND g.latitude + %.4f", lat, deg, deg),
+		fmt.Sprintf("%s BETWEEN g.longitude - %.4f AND g.longitude + %.4f", lon, deg, deg),
+	}, "\n"+indent+"  AND ")
+}
+
+// NearestPostalLateral returns the PostgreSQL LEFT JOIN LATERAL that
+// finds, as pc.postalcode, the postal code of table nearest to each place
+// (alias g) within deg degrees, ordered by the KNN expression order.
+func NearestPostalLateral(table, order string, deg float64) Expr {
+	return E(fmt.Sprintf(`LEFT JOIN LATERAL (
+    SELECT postalcode FROM %s
+    WHERE %s
+    ORDER BY %s
+    LIMIT 1
+) pc ON true`, table, nearbyPostal("", deg, "    "), order))
+}
+
+// NearestPostalSubquery returns the correlated subquery of dialect
+// ("mysql" or "sqlite") that finds the postal code of table nearest to
+// each place (alias g) within deg degrees. SQLite cannot resolve outer
+// columns in a subquery's ORDER BY, so there the distance is computed in
+// a nested SELECT and sorted outside it.
+func NearestPostalSubquery(dialect, table string, deg float64) string {
+	if dialect == "sqlite" {
+		return fmt.Sprintf(`(SELECT postalcode FROM (
+            SELECT p.postalcode, %s AS d
+            FROM %s p
+            WHERE %s)
+        ORDER BY d
+        LIMIT 1)`, HaversineCols(), table, nearbyPostal("p", deg, "            "))
+	}
+	return fmt.Sprintf(`(SELECT p.postalcode FROM %s p
+        WHERE %s
+        ORDER BY %s
+        LIMIT 1)`, table, nearbyPostal("p", deg, "        "), HaversineCols())
+}
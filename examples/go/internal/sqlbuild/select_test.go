@@ -0,0 +1,99 @@
+package sqlbuild
+
+/*
+	Tests of the SELECT builder.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSelectBuild(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    *Select
+		wantSQL  string
+		wantArgs []any
+	}{
+		{
+			name:    "columns only",
+			query:   new(Select).Columns("", "a", "b").From("t", ""),
+			wantSQL: "SELECT a, b\nFROM t",
+		},
+		{
+			name: "arguments follow clause order",
+			query: new(Select).
+				Limit(5).
+				OrderBy(E("d")).
+				Where("c = ?", "x").
+				Column(E("f(?) AS d", 1.5)).
+				Columns("g", "name").
+				From("geoname", "g").
+				Join(E("JOIN other o ON o.id = g.id AND o.k = ?", "k")),
+			wantSQL: "SELECT f(?) AS d,\n       g.name\nFROM geoname g\n" +
+				"JOIN other o ON o.id = g.id AND o.k = ?\n" +
+				"WHERE c = ?\nORDER BY d\nLIMIT ?",
+			wantArgs: []any{1.5, "k", "x", 5},
+		},
+		{
+			name: "optional conditions",
+			query: new(Select).Columns("", "a").From("t", "").
+				WhereIf(false, "b = ?", "skipped").
+				WhereIf(true, "c = ?", "kept").
+				Where("d IS NOT NULL"),
+			wantSQL:  "SELECT a\nFROM t\nWHERE c = ?\n  AND d IS NOT NULL",
+			wantArgs: []any{"kept"},
+		},
+		{
+			name:     "bounding box",
+			query:    new(Select).Columns("", "a").From("t", "").WhereBBox("t", []float64{-99.4, 19.1, -98.9, 19.7}),
+			wantSQL:  "SELECT a\nFROM t\nWHERE t.latitude BETWEEN ? AND ?\n  AND t.longitude BETWEEN ? AND ?",
+			wantArgs: []any{19.1, 19.7, -99.4, -98.9},
+		},
+		{
+			name:     "bounding box across the antimeridian",
+			query:    new(Select).Columns("", "a").From("t", "").WhereBBox("", []float64{170, -20, -170, -10}),
+			wantSQL:  "SELECT a\nFROM t\nWHERE latitude BETWEEN ? AND ?\n  AND (longitude >= ? OR longitude <= ?)",
+			wantArgs: []any{-20.0, -10.0, 170.0, -170.0},
+		},
+		{
+			name:    "no bounding box",
+			query:   new(Select).Columns("", "a").From("t", "").WhereBBox("t", nil),
+			wantSQL: "SELECT a\nFROM t",
+		},
+		{
+			name: "indented",
+			query: new(Select).Columns("", "a").From("t", "").
+				Join(E("JOIN (\n    SELECT 1\n) x ON true")).
+				Indent("  "),
+			wantSQL: "SELECT a\n  FROM t\n  JOIN (\n      SELECT 1\n  ) x ON true",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, args := tt.query.Build()
+			if sql != tt.wantSQL {
+				t.Errorf("SQL:\n%s\nwant:\n%s", sql, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+// Package sqlbuild assembles the SQL fragments shared by the geocoder
+// library and the reverse_geocode command.
+package sqlbuild
+
+/*
+	SQL building blocks: Haversine distance expressions, multi-row INSERTs
+	and identifier checks.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	The package is internal: its functions take plain values rather than
+	the geocoder's types, and may change with them.
+*/
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// EarthRadiusKm is the mean Earth radius the distance expressions use.
+const EarthRadiusKm = 6371.0
+
+// MaxParams bounds the bind parameters of one INSERT, below the limits of
+// SQLite (32766), PostgreSQL and MySQL (65535).
+const MaxParams = 30_000
+
+// Identifier matches table names that are safe to interpolate into SQL,
+// optionally schema-qualified.
+var Identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// Haversine returns a SQL distance expression (in km) for the fixed point
+// (lat, lon) vs. the columns "latitude" and "longitude", prefixed with
+// alias (e.g. "g" → "g.latitude") unless it is "". Uses repeated
+// multiplication instead of POWER() for SQLite compatibility.
+func Haversine(lat, lon float64, alias string) string {
+	rad := math.Pi / 180.0
+	cosLat := math.Cos(lat * rad)
+	latCol, lonCol := "latitude", "longitude"
+	if alias != "" {
+		latCol = alias + ".latitude"
+		lonCol = alias + ".longitude"
+	}
+	return fmt.Sprintf(
+		`2.0 * %.10f * ASIN(SQRT(`+
+			`SIN((%s - %.10f) * %.10f / 2.0)`+
+			` * SIN((%s - %.10f) * %.10f / 2.0)`+
+			` + %.10f * COS(%s * %.10f)`+
+			` * SIN((%s - %.10f) * %.10f / 2.0)`+
+			` * SIN((%s - %.10f) * %.10f / 2.0)`+
+			`))`,
+		EarthRadiusKm,
+		latCol, lat, rad, latCol, lat, rad,
+		cosLat, latCol, rad,
+		lonCol, lon, rad, lonCol, lon, rad,
+	)
+}
+
+// HaversineCols returns a SQL expression for the Haversine distance (km)
+// between two column-referenced points using table aliases "g" (geoname)
+// and "p" (postalcodes). Used in correlated subqueries for nearest postal
+// code.
+func HaversineCols() string {
+	rad := math.Pi / 180.0
+	return fmt.Sprintf(
+		`2.0 * %.10f * ASIN(SQRT(`+
+			`SIN((p.latitude  - g.latitude)  * %.10f / 2.0)`+
+			` * SIN((p.latitude  - g.latitude)  * %.10f / 2.0)`+
+			` + COS(g.latitude * %.10f) * COS(p.latitude * %.10f)`+
+			` * SIN((p.longitude - g.longitude) * %.10f / 2.0)`+
+			` * SIN((p.longitude - g.longitude) * %.10f / 2.0)`+
+			`))`,
+		EarthRadiusKm,
+		rad, rad,
+		rad, rad,
+		rad, rad,
+	)
+}
+
+// Insert returns a multi-row INSERT of n rows of cols into table, with
+// the placeholders of dialect (a gorm dialector name).
+func Insert(dialect, table string, cols []string, n int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "INSERT INTO %s (%s) VALUES ", table, strings.Join(cols, ", "))
+	p := 0
+	for r := 0; r < n; r++ {
+		if r > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteByte('(')
+		for c := range cols {
+			if c > 0 {
+				b.WriteString(", ")
+			}
+			p++
+			if dialect == "postgres" {
+				fmt.Fprintf(&b, "$%d", p)
+			} else {
+				b.WriteByte('?')
+			}
+		}
+		b.WriteByte(')')
+	}
+	return b.String()
+}
@@ -0,0 +1,64 @@
+package sqlbuild
+
+/*
+	Tests of the INSERT builder and identifier check.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Insert
+// ---------------------------------------------------------------------------
+
+func TestInsert(t *testing.T) {
+	tests := []struct {
+		dialect string
+		want    string
+	}{
+		{"postgres", "INSERT INTO poi (name, latitude) VALUES ($1, $2), ($3, $4)"},
+		{"sqlite", "INSERT INTO poi (name, latitude) VALUES (?, ?), (?, ?)"},
+	}
+	for _, tt := range tests {
+		if got := Insert(tt.dialect, "poi", []string{"name", "latitude"}, 2); got != tt.want {
+			t.Errorf("Insert(%s) = %q, want %q", tt.dialect, got, tt.want)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Identifier
+// ---------------------------------------------------------------------------
+
+func TestIdentifier(t *testing.T) {
+	for name, want := range map[string]bool{
+		"geoname":           true,
+		"tenant_a.places":   true,
+		"a.b.c":             false,
+		"geoname; DROP x":   false,
+		"1table":            false,
+		`"quoted"`:          false,
+		"postalcodes_rtree": true,
+		"schema.":           false,
+	} {
+		if got := Identifier.MatchString(name); got != want {
+			t.Errorf("Identifier(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
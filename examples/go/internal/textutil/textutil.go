@@ -0,0 +1,36 @@
+// Package textutil holds the text helpers shared by the geocoder library
+// and the reverse_geocode command.
+package textutil
+
+/*
+	Text helpers.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import "strings"
+
+// JoinDistinct joins non-empty parts with ", ", dropping consecutive
+// duplicates such as a city and county of the same name.
+func JoinDistinct(parts []string) string {
+	var out []string
+	for _, p := range parts {
+		if p != "" && (len(out) == 0 || out[len(out)-1] != p) {
+			out = append(out, p)
+		}
+	}
+	return strings.Join(out, ", ")
+}
@@ -0,0 +1,305 @@
+package geocoder
+
+/*
+	Multi-point proximity queries.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	NearestPostalBatch and NearestPlacesBatch answer many points at once.
+	On PostgreSQL (PostGIS and earthdistance strategies) the points are
+	sent as a VALUES list and each one is joined LATERAL to a KNN subquery
+	ordered by the <-> operator, so the GIST index answers every point and
+	the whole batch costs one round trip:
+
+	    SELECT q.i AS point_index, n.*
+	    FROM (VALUES (0, 19.43, -99.13), (1, ...)) AS q(i, lat, lon)
+	    CROSS JOIN LATERAL (
+	        SELECT ... FROM geoname g
+	        WHERE <within 500 km of q>
+	        ORDER BY <g <-> q>
+	        LIMIT k
+	    ) n
+
+	Batches larger than batchQueryPoints are split into several queries.
+	The other strategies have no such join and run one query per point.
+	Either way the rows of points[i] come back in the i-th slice, nearest
+	first; a point with nothing nearby gets an empty slice rather than an
+	error. Batch lookups bypass the reverse cache.
+*/
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rgglez/geonames-loader/examples/go/internal/sqlbuild"
+	"gorm.io/gorm"
+)
+
+// batchQueryPoints caps the points of one batch query, keeping it well
+// below PostgreSQL's 65535 bind parameters.
+const batchQueryPoints = 1000
+
+// Point is a WGS-84 coordinate pair in decimal degrees.
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// batchRow tags a result row with the index of its point.
+type batchRow[T any] struct {
+	PointIndex int `gorm:"column:point_index"`
+	Row        T   `gorm:"embedded"`
+}
+
+// NearestPostalBatch is NearestPostal for many points: the i-th slice of
+// the result holds the postal codes nearest to points[i].
+func (g *Geocoder) NearestPostalBatch(points []Point, opts QueryOptions) (Result[[]PostalCode], error) {
+	if err := requireData(g.db, "postalcodes"); err != nil {
+		return Result[[]PostalCode]{}, err
+	}
+	res, err := nearestBatch(g, "postalcodes", points, opts, queryPostalBatch, queryPostal,
+		func(r *PostalCode) float64 { return r.DistanceKm })
+	if opts.Rate {
+		for _, rows := range res.Rows {
+			ratePostal(rows, opts)
+		}
+	}
+	return res, err
+}
+
+// NearestPlacesBatch is NearestPostalBatch for geoname entries.
+func (g *Geocoder) NearestPlacesBatch(points []Point, opts QueryOptions) (Result[[]Place], error) {
+	if err := requireData(g.db, "geoname"); err != nil {
+		return Result[[]Place]{}, err
+	}
+	if g.noPostal || !dataStatusFor(g.db).Has("postalcodes") ||
+		uncovered(g.db, "postalcodes", opts.Country) != nil {
+		opts = opts.withoutPostalCode()
+	}
+	res, err := nearestBatch(g, "geoname", points, opts, queryGeonameBatch, queryGeoname,
+		func(r *Place) float64 { return r.DistanceKm })
+	if opts.Rate {
+		for _, rows := range res.Rows {
+			ratePlaces(rows, opts)
+		}
+	}
+	return res, err
+}
+
+// nearestBatch runs batch, one query per batchQueryPoints points, on
+// PostgreSQL and single on every point elsewhere. No query runs when
+// table has no row of opts.Country (see coverage.go).
+func nearestBatch[T any](
+	g *Geocoder, table string, points []Point, opts QueryOptions,
+	batch func(*gorm.DB, []Point, QueryOptions) ([][]T, error),
+	single func(*gorm.DB, float64, float64, QueryOptions) ([]T, error),
+	dist func(*T) float64,
+) (Result[[]T], error) {
+	if opts.Strategy == "" {
+		opts.Strategy = g.strategy
+	}
+	strategy := ResolveStrategy(g.db, opts.Strategy)
+	res := Result[[]T]{Strategy: strategy}
+	if err := CheckStrategy(g.db, opts.Strategy); err != nil {
+		return res, err
+	}
+	if uncovered(g.db, table, opts.Country) != nil {
+		res.Rows = make([][]T, len(points))
+		return res, nil
+	}
+	start := time.Now()
+	out := make([][]T, 0, len(points))
+	for len(points) > 0 {
+		chunk := points[:min(len(points), batchQueryPoints)]
+		points = points[len(chunk):]
+		key := fmt.Sprintf("batch|%s|%x|%+v", table, hashPoints(chunk), opts)
+		rows, err := guarded(g, key, func() ([][]T, error) {
+			if strategy == StrategyPostGIS || strategy == StrategyEarthdistance {
+				return batch(g.db, chunk, opts)
+			}
+			rows := make([][]T, len(chunk))
+			for i, p := range chunk {
+				var err error
+				if rows[i], err = single(g.db, p.Lat, p.Lon, opts); err != nil {
+					return nil, err
+				}
+			}
+			return rows, nil
+		})
+		if err != nil {
+			res.Duration = time.Since(start)
+			return res, err
+		}
+		for i, r := range rows {
+			setDirections(chunk[i].Lat, chunk[i].Lon, r)
+			out = append(out, withinKm(r, opts.MaxDistanceKm, dist))
+		}
+	}
+	res.Rows, res.Duration = out, time.Since(start)
+	return res, nil
+}
+
+// hashPoints returns the SHA-256 of the coordinates of points, which keys
+// a batch in the stale cache at a fixed size however many points it has.
+func hashPoints(points []Point) [sha256.Size]byte {
+	b := make([]byte, 0, 16*len(points))
+	for _, p := range points {
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(p.Lat))
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(p.Lon))
+	}
+	return sha256.Sum256(b)
+}
+
+// ---------------------------------------------------------------------------
+// PostgreSQL LATERAL KNN queries
+// ---------------------------------------------------------------------------
+
+// batchValues returns the VALUES list q(i, lat, lon) of points and its
+// arguments.
+func batchValues(points []Point) (string, []interface{}) {
+	rows := make([]string, len(points))
+	args := make([]interface{}, 0, 3*len(points))
+	for i, p := range points {
+		rows[i] = "(?::int, ?::float8, ?::float8)"
+		args = append(args, i, p.Lat, p.Lon)
+	}
+	return "(VALUES " + strings.Join(rows, ", ") + ") AS q(i, lat, lon)", args
+}
+
+// splitBatch groups rows by point, in point order.
+func splitBatch[T any](rows []batchRow[T], n int) [][]T {
+	out := make([][]T, n)
+	for _, r := range rows {
+		out[r.PointIndex] = append(out[r.PointIndex], r.Row)
+	}
+	return out
+}
+
+// postgresBatchExprs returns the distance (km), pre-filter and KNN order
+// expressions of the PostgreSQL strategy for rows of alias (which may be
+// "") against the point q, pre-filtered to radiusM metres.
+func postgresBatchExprs(strategy, alias string, radiusM float64) (dist, within, order sqlbuild.Expr) {
+	if strategy == StrategyPostGIS {
+		row, point := sqlbuild.PostGISPoint(alias), "ST_MakePoint(q.lon, q.lat)::geography"
+		return sqlbuild.E("ST_Distance(" + row + ", " + point + ") / 1000.0"),
+			sqlbuild.E("ST_DWithin("+row+", "+point+", ?)", radiusM),
+			sqlbuild.E(row + " <-> " + point)
+	}
+	row := sqlbuild.EarthPoint(alias)
+	point := "ll_to_earth(q.lat, q.lon)"
+	return sqlbuild.E("earth_distance(" + row + ", " + point + ") / 1000.0"),
+		sqlbuild.E("earth_box("+point+", ?) @> "+row, radiusM),
+		sqlbuild.E(row + " <-> " + point)
+}
+
+// batchSQL wraps the nearest-rows query inner, which reads the point
+// from q.lat and q.lon, in a LATERAL join over the VALUES list of points.
+func batchSQL(points []Point, inner *sqlbuild.Select) (string, []any) {
+	values, args := batchValues(points)
+	innerSQL, innerArgs := inner.Indent("    ").Build()
+	return fmt.Sprintf(`SELECT q.i AS point_index, n.*
+FROM %s
+CROSS JOIN LATERAL (
+    %s
+) n`, values, innerSQL), append(args, innerArgs...)
+}
+
+// nearestPostalBatchSQL returns the batch query for the postal codes of
+// table nearest to each of points under a PostgreSQL strategy.
+func nearestPostalBatchSQL(strategy, table string, points []Point, opts QueryOptions) (string, []any) {
+	dist, within, order := postgresBatchExprs(strategy, "", opts.radiusM())
+	inner := new(sqlbuild.Select).
+		Columns("", opts.columns(PostalFields)...).
+		Column(dist.As("distance_km")).
+		From(table, "").
+		Where("latitude IS NOT NULL").
+		Where("longitude IS NOT NULL").
+		WhereExpr(within)
+	opts.postalFilter(inner, "").
+		OrderBy(order).
+		Limit(opts.Limit)
+	return batchSQL(points, inner)
+}
+
+func queryPostalBatch(
+	db *gorm.DB, points []Point, opts QueryOptions,
+) ([][]PostalCode, error) {
+	var rows []batchRow[PostalCode]
+	rawSQL, args := nearestPostalBatchSQL(ResolveStrategy(db, opts.Strategy),
+		TableName(db, "postalcodes"), points, opts)
+	if err := db.Raw(rawSQL, args...).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := splitBatch(rows, len(points))
+	for _, r := range out {
+		sortByDistance(r, func(p *PostalCode) float64 { return p.DistanceKm })
+	}
+	return out, nil
+}
+
+// nearestPlaceBatchSQL is the geoname counterpart of
+// nearestPostalBatchSQL, with the nearest postal code of each place in
+// the postal table when opts selects it.
+func nearestPlaceBatchSQL(
+	strategy, geoname, postal string, points []Point, opts QueryOptions,
+) (string, []any) {
+	dist, within, order := postgresBatchExprs(strategy, "g", opts.radiusM())
+	inner := new(sqlbuild.Select).
+		Columns("g", opts.columns(PlaceFields)...).
+		Column(dist.As("distance_km")).
+		From(geoname, "g")
+	if opts.selects("postalcode") {
+		inner.Columns("pc", "postalcode").
+			Join(sqlbuild.NearestPostalLateral(postal, knnOrder(strategy), degRadius))
+	}
+	inner.Where("g.latitude IS NOT NULL").
+		Where("g.longitude IS NOT NULL").
+		WhereExpr(within)
+	opts.geonameFilter(inner, "g").
+		OrderBy(order).
+		Limit(opts.Limit)
+	return batchSQL(points, inner)
+}
+
+func queryGeonameBatch(
+	db *gorm.DB, points []Point, opts QueryOptions,
+) ([][]Place, error) {
+	var rows []batchRow[Place]
+	rawSQL, args := nearestPlaceBatchSQL(ResolveStrategy(db, opts.Strategy),
+		TableName(db, "geoname"), TableName(db, "postalcodes"), points, opts)
+	if err := db.Raw(rawSQL, args...).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := splitBatch(rows, len(points))
+	for _, r := range out {
+		sortByDistance(r, func(g *Place) float64 { return g.DistanceKm })
+	}
+	return out, nil
+}
+
+// sortByDistance orders rows nearest first. The KNN operators order by
+// an approximation (the sphere, or the chord), which may swap rows whose
+// exact distances are within metres of each other.
+func sortByDistance[T any](rows []T, dist func(*T) float64) {
+	sort.SliceStable(rows, func(i, j int) bool { return dist(&rows[i]) < dist(&rows[j]) })
+}
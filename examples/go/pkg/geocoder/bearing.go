@@ -0,0 +1,104 @@
+package geocoder
+
+/*
+	Bearing and compass direction from the query point to each result.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Every row NearestPostal, NearestPlaces, NearestPOI and their batch
+	forms return carries, besides its distance, the direction in which it
+	lies seen from the query point:
+
+	  Bearing    the initial great-circle bearing, in degrees clockwise
+	             from true north (0 ≤ b < 360)
+	  Direction  the nearest of the 16 compass points: N, NNE, NE, ENE,
+	             E, ... NNW (each covers 22.5°, N is 348.75°–11.25°)
+
+	They are computed in Go once the query returns, whatever the strategy,
+	and cost a few trigonometric calls per row. A row at the query point
+	itself has no direction: bearing 0 and Direction "".
+*/
+
+import (
+	"math"
+)
+
+// compassPoints are the 16 points of the compass, clockwise from north.
+var compassPoints = [16]string{
+	"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE",
+	"S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW",
+}
+
+// InitialBearing returns the initial great-circle bearing in degrees
+// (0 ≤ b < 360, clockwise from north) from the first point to the second,
+// or 0 when they coincide.
+func InitialBearing(lat1, lon1, lat2, lon2 float64) float64 {
+	const rad = math.Pi / 180
+	sinLat1, cosLat1 := math.Sincos(lat1 * rad)
+	sinLat2, cosLat2 := math.Sincos(lat2 * rad)
+	sinDLon, cosDLon := math.Sincos((lon2 - lon1) * rad)
+	y := sinDLon * cosLat2
+	x := cosLat1*sinLat2 - sinLat1*cosLat2*cosDLon
+	if y == 0 && x == 0 {
+		return 0
+	}
+	return math.Mod(math.Atan2(y, x)/rad+360, 360)
+}
+
+// CompassPoint returns the compass point of 16 (e.g. "NNW") nearest to
+// bearing, in degrees.
+func CompassPoint(bearing float64) string {
+	i := int(math.Round(math.Mod(bearing, 360)/22.5+16)) % 16
+	return compassPoints[i]
+}
+
+// direction returns the bearing and compass point of (lat2, lon2) seen
+// from (lat1, lon1), with no compass point when they coincide.
+func direction(lat1, lon1, lat2, lon2 float64) (float64, string) {
+	if lat1 == lat2 && lon1 == lon2 {
+		return 0, ""
+	}
+	b := InitialBearing(lat1, lon1, lat2, lon2)
+	return b, CompassPoint(b)
+}
+
+// bearer is a result row that takes its direction from the query point.
+type bearer interface {
+	setDirection(lat, lon float64)
+}
+
+func (r *PostalCode) setDirection(lat, lon float64) {
+	r.Bearing, r.Direction = direction(lat, lon, r.Latitude, r.Longitude)
+}
+
+func (r *Place) setDirection(lat, lon float64) {
+	r.Bearing, r.Direction = direction(lat, lon, r.Latitude, r.Longitude)
+}
+
+func (r *POI) setDirection(lat, lon float64) {
+	r.Bearing, r.Direction = direction(lat, lon, r.Latitude, r.Longitude)
+}
+
+// setDirections sets the direction of rows from (lat, lon).
+func setDirections[T any](lat, lon float64, rows []T) {
+	for i := range rows {
+		if b, ok := any(&rows[i]).(bearer); ok {
+			b.setDirection(lat, lon)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package geocoder
+
+/*
+	Tests of the bearings and compass points.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"math"
+	"testing"
+)
+
+func TestInitialBearing(t *testing.T) {
+	for _, c := range []struct {
+		name                   string
+		lat1, lon1, lat2, lon2 float64
+		want                   float64
+	}{
+		{"north", 0, 0, 1, 0, 0},
+		{"east", 0, 0, 0, 1, 90},
+		{"south", 0, 0, -1, 0, 180},
+		{"west", 0, 0, 0, -1, 270},
+		{"coincident", 19.4326, -99.1332, 19.4326, -99.1332, 0},
+		{"across the antimeridian, east", 0, 179, 0, -179, 90},
+		{"across the antimeridian, west", 0, -179, 0, 179, 270},
+		{"from the north pole", 90, 0, 0, 0, 180},
+		{"to the north pole", 19.4326, -99.1332, 90, 0, 0},
+		{"just west of north", 0, 0, 1, -1e-9, 360 - 1e-9},
+		// Mexico City to Guadalajara: west-northwest.
+		{"Guadalajara", 19.4326, -99.1332, 20.6597, -103.3496, 287.9},
+	} {
+		got := InitialBearing(c.lat1, c.lon1, c.lat2, c.lon2)
+		if got < 0 || got >= 360 {
+			t.Errorf("%s: InitialBearing = %v, out of [0, 360)", c.name, got)
+		}
+		if d := math.Abs(got - c.want); d > 0.05 {
+			t.Errorf("%s: InitialBearing = %.4f, want %.4f", c.name, got, c.want)
+		}
+	}
+}
+
+func TestCompassPoint(t *testing.T) {
+	for _, c := range []struct {
+		bearing float64
+		want    string
+	}{
+		{0, "N"},
+		{11.24, "N"},
+		{11.26, "NNE"},
+		{22.5, "NNE"},
+		{45, "NE"},
+		{90, "E"},
+		{180, "S"},
+		{270, "W"},
+		{326.24, "NW"},
+		{337.5, "NNW"},
+		{348.74, "NNW"},
+		{348.76, "N"},
+		{359.99, "N"},
+		{360, "N"},
+		{720 + 90, "E"},
+		{-0.01, "N"},
+		{-22.5, "NNW"},
+		{-90, "W"},
+	} {
+		if got := CompassPoint(c.bearing); got != c.want {
+			t.Errorf("CompassPoint(%v) = %q, want %q", c.bearing, got, c.want)
+		}
+	}
+}
+
+func TestDirection(t *testing.T) {
+	if b, d := direction(19.4326, -99.1332, 19.4326, -99.1332); b != 0 || d != "" {
+		t.Errorf("direction of the query point = %v, %q; want 0, \"\"", b, d)
+	}
+	if b, d := direction(0, 0, 0, -1); b != 270 || d != "W" {
+		t.Errorf("direction due west = %v, %q; want 270, \"W\"", b, d)
+	}
+}
@@ -0,0 +1,326 @@
+package geocoder
+
+/*
+	Persistent reverse-lookup cache ("cache" subcommand).
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	With --cache (or server.cache in the config), nearest-postal and
+	nearest-place lookups are cached in a bbolt file that survives
+	restarts. Entries are keyed by the geohash of the point at precision 7
+	— cells of about 153 × 153 m — and by the query options (limit,
+	country, feature class, fields), so batch jobs whose points cluster in
+	the same blocks mostly skip the database.
+
+	An entry holds the rows found for the first point queried in its cell.
+	A later point in the same cell gets those rows with the distances
+	recomputed (Haversine) from its own coordinates and re-sorted, which
+	may differ from a fresh query only for places near the cell edge.
+	Empty answers are cached too; errors are not.
+
+	The keys hold nothing of the database: a Geocoder whose lookups may
+	share the file with those of another database, or of other table
+	names, is given a scope with WithCacheScope that prefixes its keys.
+	The commands scope by the connection settings and table names, so a
+	server reloaded onto another database does not answer from the old
+	one's entries.
+
+	Hit and miss counters are kept in the file and updated when the
+	process closes the cache. bbolt locks the file, so one process at a
+	time can use it; "cache stats" waits up to a second for the lock.
+	The data never expires: clear the cache after reloading the database.
+
+	The command-line side is in cmd/reverse_geocode/cache.go.
+*/
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// cachePrecision is the geohash length of cache keys.
+const cachePrecision = 7
+
+// Buckets of the cache file.
+var (
+	cacheMetaBucket = []byte("meta")
+	cacheBuckets    = []string{"postalcodes", "geoname"}
+)
+
+// ---------------------------------------------------------------------------
+// Geohash
+// ---------------------------------------------------------------------------
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// geohash returns the geohash of (lat, lon) with precision characters.
+func geohash(lat, lon float64, precision int) string {
+	latR, lonR := [2]float64{-90, 90}, [2]float64{-180, 180}
+	b := make([]byte, 0, precision)
+	bits, ch, even := 0, 0, true
+	for len(b) < precision {
+		r, v := &latR, lat
+		if even {
+			r, v = &lonR, lon
+		}
+		mid := (r[0] + r[1]) / 2
+		ch <<= 1
+		if v >= mid {
+			ch |= 1
+			r[0] = mid
+		} else {
+			r[1] = mid
+		}
+		even = !even
+		if bits++; bits == 5 {
+			b = append(b, geohashAlphabet[ch])
+			bits, ch = 0, 0
+		}
+	}
+	return string(b)
+}
+
+// ---------------------------------------------------------------------------
+// Cache
+// ---------------------------------------------------------------------------
+
+// ReverseCache is a persistent cache of proximity query results. It is
+// safe for concurrent use.
+type ReverseCache struct {
+	db           *bolt.DB
+	hits, misses atomic.Int64
+}
+
+// OpenReverseCache opens or creates the cache file at path.
+func OpenReverseCache(path string) (*ReverseCache, error) {
+	db, err := bolt.Open(path, 0o644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening cache %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range append([]string{string(cacheMetaBucket)}, cacheBuckets...) {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return err
+			}
+		}
+		meta := tx.Bucket(cacheMetaBucket)
+		if meta.Get([]byte("created")) == nil {
+			return meta.Put([]byte("created"), []byte(time.Now().UTC().Format(time.RFC3339)))
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("opening cache %s: %w", path, err)
+	}
+	return &ReverseCache{db: db}, nil
+}
+
+// Close saves the hit and miss counters and closes the file.
+func (c *ReverseCache) Close() error {
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(cacheMetaBucket)
+		for name, n := range map[string]int64{
+			"hits": c.hits.Swap(0), "misses": c.misses.Swap(0),
+		} {
+			if err := meta.Put([]byte(name), counterBytes(counter(meta, name)+n)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return errors.Join(err, c.db.Close())
+}
+
+func counter(b *bolt.Bucket, name string) int64 {
+	if v := b.Get([]byte(name)); len(v) == 8 {
+		return int64(binary.BigEndian.Uint64(v))
+	}
+	return 0
+}
+
+func counterBytes(n int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(n))
+}
+
+// cacheKey identifies the query for (lat, lon) with opts in scope.
+func cacheKey(scope string, lat, lon float64, opts QueryOptions) []byte {
+	key := fmt.Sprintf("%s|%d|%s|%s|%s",
+		geohash(lat, lon, cachePrecision), opts.Limit, opts.Country, opts.FeatureClass,
+		strings.Join(opts.Fields, ","))
+	if opts.MinPopulation > 0 || opts.BBox != nil {
+		// Appended only when set, so existing cache files stay valid.
+		key += fmt.Sprintf("|%d|%v", opts.MinPopulation, opts.BBox)
+	}
+	if opts.MaxDistanceKm > 0 {
+		// It narrows the pre-filter radius of the PostgreSQL strategies.
+		key += fmt.Sprintf("|r%g", opts.MaxDistanceKm)
+	}
+	if len(opts.FeatureCodes) > 0 {
+		key += "|c" + strings.Join(opts.FeatureCodes, ",")
+	}
+	if scope != "" {
+		key = scope + "|" + key
+	}
+	return []byte(key)
+}
+
+// cachedQuery answers query from c when the geohash cell of (lat, lon)
+// has been queried before in scope with the same options, and stores its
+// result otherwise. A nil c runs query.
+func cachedQuery[T any](
+	c *ReverseCache, scope, table string, lat, lon float64, opts QueryOptions,
+	query func() ([]T, error),
+) ([]T, error) {
+	if c == nil {
+		return query()
+	}
+	key := cacheKey(scope, lat, lon, opts)
+	var rows []T
+	var found bool
+	err := c.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket([]byte(table)).Get(key)
+		if v == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(v, &rows)
+	})
+	if err == nil && found {
+		c.hits.Add(1)
+		relocate(rows, lat, lon)
+		return rows, nil
+	}
+
+	c.misses.Add(1)
+	if rows, err = query(); err != nil {
+		return nil, err
+	}
+	if v, err := json.Marshal(rows); err == nil {
+		// Batch concurrent writers into one transaction.
+		if err := c.db.Batch(func(tx *bolt.Tx) error {
+			return tx.Bucket([]byte(table)).Put(key, v)
+		}); err != nil {
+			log.Printf("cache: %v", err)
+		}
+	}
+	return rows, nil
+}
+
+// relocate recomputes the distances of cached rows from (lat, lon) and
+// sorts them nearest first.
+func relocate[T any](rows []T, lat, lon float64) {
+	switch rs := any(rows).(type) {
+	case []PostalCode:
+		for i := range rs {
+			rs[i].DistanceKm = haversineKm(lat, lon, rs[i].Latitude, rs[i].Longitude)
+		}
+		sort.SliceStable(rs, func(i, j int) bool { return rs[i].DistanceKm < rs[j].DistanceKm })
+	case []Place:
+		for i := range rs {
+			rs[i].DistanceKm = haversineKm(lat, lon, rs[i].Latitude, rs[i].Longitude)
+		}
+		sort.SliceStable(rs, func(i, j int) bool { return rs[i].DistanceKm < rs[j].DistanceKm })
+	}
+}
+
+// WithReverseCache caches the Geocoder's proximity lookups in c.
+func WithReverseCache(c *ReverseCache) Option {
+	return func(g *Geocoder) { g.cache = c }
+}
+
+// WithCacheScope keeps the Geocoder's cache entries apart from those of
+// Geocoders with another scope, such as ones on another database sharing
+// the file. The empty scope is that of unscoped Geocoders.
+func WithCacheScope(scope string) Option {
+	return func(g *Geocoder) { g.cacheScope = scope }
+}
+
+// ---------------------------------------------------------------------------
+// Statistics
+// ---------------------------------------------------------------------------
+
+// CacheStats describes a cache file.
+type CacheStats struct {
+	Path      string           `json:"path"`
+	SizeBytes int64            `json:"size_bytes"`
+	Created   string           `json:"created,omitempty"`
+	Entries   map[string]int64 `json:"entries"`
+	Hits      int64            `json:"hits"`
+	Misses    int64            `json:"misses"`
+	// HitRatio is Hits / (Hits + Misses), or 0 before any lookup.
+	HitRatio float64 `json:"hit_ratio"`
+}
+
+// Stats returns the statistics of the cache, including the counters of
+// this process not yet saved.
+func (c *ReverseCache) Stats() (*CacheStats, error) {
+	st := &CacheStats{Path: c.db.Path(), Entries: map[string]int64{}}
+	err := c.db.View(func(tx *bolt.Tx) error {
+		st.SizeBytes = tx.Size()
+		meta := tx.Bucket(cacheMetaBucket)
+		st.Created = string(meta.Get([]byte("created")))
+		st.Hits = counter(meta, "hits") + c.hits.Load()
+		st.Misses = counter(meta, "misses") + c.misses.Load()
+		for _, name := range cacheBuckets {
+			st.Entries[name] = int64(tx.Bucket([]byte(name)).Stats().KeyN)
+		}
+		return nil
+	})
+	if n := st.Hits + st.Misses; n > 0 {
+		st.HitRatio = float64(st.Hits) / float64(n)
+	}
+	return st, err
+}
+
+// Counters returns the hits and misses of this process not yet saved.
+func (c *ReverseCache) Counters() (hits, misses int64) {
+	return c.hits.Load(), c.misses.Load()
+}
+
+// Clear removes every entry and resets the counters.
+func (c *ReverseCache) Clear() error {
+	c.hits.Store(0)
+	c.misses.Store(0)
+	return c.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range cacheBuckets {
+			if err := tx.DeleteBucket([]byte(name)); err != nil {
+				return err
+			}
+			if _, err := tx.CreateBucket([]byte(name)); err != nil {
+				return err
+			}
+		}
+		meta := tx.Bucket(cacheMetaBucket)
+		for _, name := range []string{"hits", "misses"} {
+			if err := meta.Delete([]byte(name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,160 @@
+package geocoder
+
+/*
+	Database capability probing.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	The strategy dispatchers need to know which PostgreSQL extensions and
+	types are installed, and whether a SQLite file is a snapshot with
+	R*Tree tables. Instead of querying the catalogs on every call,
+	the first caller probes them once and the answer is cached for the
+	connection (every gorm session derived from the same gorm.Open).
+
+	A probe that fails — a catalog the role may not read, a dropped
+	connection — is recorded in Capabilities.Errors and its features
+	reported as absent, which falls back to the portable strategies. Such
+	an incomplete result is re-probed after capabilityRetry instead of
+	being kept for the life of the process. WithCapabilities replaces the
+	probe altogether.
+*/
+
+import (
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// capabilityRetry is how long an incomplete probe result is trusted.
+const capabilityRetry = time.Minute
+
+// Capabilities describes what a database supports.
+type Capabilities struct {
+	Dialect string `json:"dialect"`
+	// PostgreSQL extensions.
+	PostGIS       bool `json:"postgis"`
+	Ganos         bool `json:"ganos"`
+	Earthdistance bool `json:"earthdistance"`
+	Trgm          bool `json:"pg_trgm"`
+	// GeographyType is true when the geography type is registered, which
+	// is what the PostGIS strategy actually needs (see hasGeographyType).
+	GeographyType bool `json:"geography_type"`
+	// RTree is true on SQLite snapshots, which carry the R*Tree tables of
+	// the rtree strategy (see snapshot.go).
+	RTree bool `json:"sqlite_rtree"`
+	// Errors maps the name of each failed probe to its error message.
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// Complete reports whether every probe succeeded.
+func (c Capabilities) Complete() bool {
+	return len(c.Errors) == 0
+}
+
+// capEntry is the cached probe result of one connection.
+type capEntry struct {
+	mu       sync.Mutex
+	caps     *Capabilities
+	probedAt time.Time
+	override bool
+}
+
+var capCache sync.Map // *gorm.Config → *capEntry
+
+func capEntryFor(db *gorm.DB) *capEntry {
+	v, _ := capCache.LoadOrStore(db.Config, &capEntry{})
+	return v.(*capEntry)
+}
+
+// capabilitiesFor returns the capabilities of db, probing on first use.
+func capabilitiesFor(db *gorm.DB) Capabilities {
+	e := capEntryFor(db)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.caps == nil ||
+		!e.override && !e.caps.Complete() && time.Since(e.probedAt) > capabilityRetry {
+		c := probeCapabilities(db)
+		e.caps, e.probedAt = &c, time.Now()
+	}
+	return *e.caps
+}
+
+// setCapabilities replaces the cached capabilities of db; they are never
+// probed again.
+func setCapabilities(db *gorm.DB, c Capabilities) {
+	e := capEntryFor(db)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c.Dialect = db.Dialector.Name()
+	e.caps, e.override = &c, true
+}
+
+// probeCapabilities queries the catalogs of db.
+func probeCapabilities(db *gorm.DB) Capabilities {
+	c := Capabilities{Dialect: db.Dialector.Name()}
+	fail := func(probe string, err error) {
+		if c.Errors == nil {
+			c.Errors = map[string]string{}
+		}
+		c.Errors[probe] = err.Error()
+	}
+	if c.Dialect == "sqlite" {
+		var n int64
+		err := db.Raw(`
+			SELECT count(*) FROM sqlite_master
+			WHERE name IN ('geoname_rtree', 'postalcodes_rtree')`).Scan(&n).Error
+		if err != nil {
+			fail("rtree", err)
+		}
+		c.RTree = n == 2
+		return c
+	}
+	if c.Dialect != "postgres" {
+		return c
+	}
+
+	var exts []string
+	err := db.Raw(`
+		SELECT extname FROM pg_extension
+		WHERE extname IN ('postgis', 'ganos_spatialref', 'earthdistance', 'pg_trgm')`,
+	).Scan(&exts).Error
+	if err != nil {
+		fail("extensions", err)
+	}
+	for _, e := range exts {
+		switch e {
+		case "postgis":
+			c.PostGIS = true
+		case "ganos_spatialref":
+			c.Ganos = true
+		case "earthdistance":
+			c.Earthdistance = true
+		case "pg_trgm":
+			c.Trgm = true
+		}
+	}
+
+	var n int64
+	err = db.Raw("SELECT count(*) FROM pg_type WHERE typname = 'geography'").Scan(&n).Error
+	if err != nil {
+		fail("geography_type", err)
+	}
+	c.GeographyType = n > 0
+	return c
+}
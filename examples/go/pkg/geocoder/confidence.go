@@ -0,0 +1,206 @@
+package geocoder
+
+/*
+	Confidence rating of reverse-geocoding results.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    go run . --lat 19.4326 --lon -99.1332 --confidence --format json
+	    curl 'http://localhost:8080/reverse?lat=19.4326&lon=-99.1332&confidence=1'
+
+	    res, _ := geo.NearestPlaces(lat, lon, geocoder.QueryOptions{Limit: 1, Rate: true})
+	    if res.Rows[0].Rating.Confidence < 0.6 { queueForReview(...) }
+
+	A nearest place is not always a good answer: the point may be 40 km
+	from a hamlet of 200 people, or on top of a capital. With
+	QueryOptions.Rate each row of NearestPlaces and NearestPostal (and of
+	their batch forms) gets a Rating: a Confidence between 0 and 1 and
+	the factors it averages, each with its value, weight and input, so a
+	pipeline can accept the geocodes above a threshold and send the rest
+	to manual review — and tell why a row scored low.
+
+	  factor           weight  value
+	  distance         0.4     1 / (1 + km / half), half 10 km for places
+	                           and 5 km for postal codes
+	  feature          0.2     rank of the feature code: 1 for a capital
+	                           (PPLC), 0.95 to 0.8 for the seats of
+	                           divisions, 0.75 for other populated places,
+	                           down to 0.2 for abandoned or destroyed ones;
+	                           other classes by class (see featureRanks)
+	  population       0.2     log10(population + 1) / 7, at most 1 (a city
+	                           of ten million)
+	  postal_accuracy  0.2     the postal code's Confidence (see postal.go)
+
+	Places get the first three, postal codes the distance and the postal
+	accuracy; a factor whose input was left out of QueryOptions.Fields is
+	left out, and the weights of the others are scaled to sum to 1. The
+	composite result (ReverseGeocodeFull) is always rated, with all four:
+	the nearest place and the accuracy of the nearest postal code.
+
+	The weights and ranks are a heuristic, not a calibrated probability:
+	compare scores with each other and pick thresholds from a sample of
+	your own data.
+*/
+
+import (
+	"fmt"
+	"math"
+)
+
+// Weights of the rating factors.
+const (
+	distanceWeight   = 0.4
+	featureWeight    = 0.2
+	populationWeight = 0.2
+	postalWeight     = 0.2
+)
+
+// Distances that halve the distance factor of a place and of a postal
+// code.
+const (
+	placeHalfKm  = 10
+	postalHalfKm = 5
+)
+
+// featureRanks rates the feature codes of populated places.
+var featureRanks = map[string]float64{
+	"PPLC": 1, "PPLG": 0.95, "PPLA": 0.95, "PPLA2": 0.9, "PPLA3": 0.85,
+	"PPLA4": 0.8, "PPLA5": 0.8, "PPL": 0.75, "PPLS": 0.7, "PPLX": 0.65,
+	"PPLF": 0.6, "PPLL": 0.6, "PPLR": 0.6, "STLMT": 0.6,
+	"PPLCH": 0.3, "PPLH": 0.2, "PPLQ": 0.2, "PPLW": 0.2,
+}
+
+// classRanks rates the feature codes featureRanks does not list, by
+// their class.
+var classRanks = map[string]float64{
+	"P": 0.6, "A": 0.5, "S": 0.4, "L": 0.4,
+	"H": 0.3, "R": 0.3, "T": 0.3, "V": 0.3, "U": 0.1,
+}
+
+// RatingFactor is one of the factors of a Rating.
+type RatingFactor struct {
+	// Name is distance, feature, population or postal_accuracy.
+	Name string `json:"name"`
+	// Value is the factor's score, between 0 and 1.
+	Value float64 `json:"value"`
+	// Weight is the share of Value in the confidence.
+	Weight float64 `json:"weight"`
+	// Input is what the value was computed from, e.g. "2.314 km" or
+	// "P/PPLA2".
+	Input string `json:"input"`
+}
+
+// Rating is the confidence in a result, between 0 and 1, with the
+// factors it averages; see confidence.go.
+type Rating struct {
+	Confidence float64        `json:"confidence"`
+	Factors    []RatingFactor `json:"factors"`
+}
+
+// newRating averages factors by their weights, which it scales to sum
+// to 1.
+func newRating(factors ...RatingFactor) *Rating {
+	var sum, weights float64
+	for _, f := range factors {
+		weights += f.Weight
+	}
+	for i := range factors {
+		f := &factors[i]
+		f.Weight = math.Round(f.Weight/weights*1000) / 1000
+		f.Value = math.Round(f.Value*100) / 100
+		sum += f.Value * f.Weight
+	}
+	return &Rating{Confidence: math.Round(sum*100) / 100, Factors: factors}
+}
+
+func distanceFactor(km, halfKm float64) RatingFactor {
+	return RatingFactor{
+		Name: "distance", Value: 1 / (1 + km/halfKm), Weight: distanceWeight,
+		Input: fmt.Sprintf("%.3f km", km),
+	}
+}
+
+func featureFactor(fclass, fcode string) RatingFactor {
+	v, ok := featureRanks[fcode]
+	if !ok {
+		v = classRanks[fclass]
+	}
+	return RatingFactor{Name: "feature", Value: v, Weight: featureWeight, Input: fclass + "/" + fcode}
+}
+
+func populationFactor(population int64) RatingFactor {
+	return RatingFactor{
+		Name: "population", Value: min(1, math.Log10(float64(max(population, 0))+1)/7),
+		Weight: populationWeight, Input: fmt.Sprint(population),
+	}
+}
+
+func postalFactor(r *PostalCode) RatingFactor {
+	c := r.Confidence
+	if c == 0 {
+		c = postalConfidence(r.Accuracy, r.Extent)
+	}
+	return RatingFactor{
+		Name: "postal_accuracy", Value: c, Weight: postalWeight,
+		Input: fmt.Sprintf("accuracy %d", r.Accuracy),
+	}
+}
+
+// placeFactors returns the factors of a place whose fields opts reads.
+func placeFactors(r *Place, opts QueryOptions) []RatingFactor {
+	fs := []RatingFactor{distanceFactor(r.DistanceKm, placeHalfKm)}
+	if opts.selects("fclass") || opts.selects("fcode") {
+		fs = append(fs, featureFactor(r.Fclass, r.Fcode))
+	}
+	if opts.selects("population") {
+		fs = append(fs, populationFactor(r.Population))
+	}
+	return fs
+}
+
+// ratePlaces sets the Rating of rows.
+func ratePlaces(rows []Place, opts QueryOptions) {
+	for i := range rows {
+		rows[i].Rating = newRating(placeFactors(&rows[i], opts)...)
+	}
+}
+
+// ratePostal sets the Rating of rows.
+func ratePostal(rows []PostalCode, opts QueryOptions) {
+	for i := range rows {
+		r := &rows[i]
+		fs := []RatingFactor{distanceFactor(r.DistanceKm, postalHalfKm)}
+		if opts.selects("accuracy") {
+			fs = append(fs, postalFactor(r))
+		}
+		r.Rating = newRating(fs...)
+	}
+}
+
+// rateFull returns the Rating of a composite result, nil without a place.
+func rateFull(res *FullResult) *Rating {
+	if res.Place == nil {
+		return nil
+	}
+	fs := placeFactors(res.Place, QueryOptions{})
+	if res.Postal != nil {
+		fs = append(fs, postalFactor(res.Postal))
+	}
+	return newRating(fs...)
+}
@@ -0,0 +1,122 @@
+package geocoder
+
+/*
+	Tests of the confidence ratings.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNewRating(t *testing.T) {
+	f := func(name string, value, weight float64) RatingFactor {
+		return RatingFactor{Name: name, Value: value, Weight: weight}
+	}
+	for _, c := range []struct {
+		name    string
+		factors []RatingFactor
+		// weights are the scaled weights, in order.
+		weights    []float64
+		confidence float64
+	}{
+		{"all four", []RatingFactor{
+			f("distance", 0.5, distanceWeight), f("feature", 1, featureWeight),
+			f("population", 0.8, populationWeight), f("postal_accuracy", 0.9, postalWeight),
+		}, []float64{0.4, 0.2, 0.2, 0.2}, 0.74},
+		{"place", []RatingFactor{
+			f("distance", 0.5, distanceWeight), f("feature", 1, featureWeight),
+			f("population", 0.8, populationWeight),
+		}, []float64{0.5, 0.25, 0.25}, 0.7},
+		{"postal code", []RatingFactor{
+			f("distance", 0.5, distanceWeight), f("postal_accuracy", 0.9, postalWeight),
+		}, []float64{0.667, 0.333}, 0.63},
+		{"distance only", []RatingFactor{f("distance", 0.123, distanceWeight)}, []float64{1}, 0.12},
+		{"perfect", []RatingFactor{
+			f("distance", 1, distanceWeight), f("feature", 1, featureWeight),
+		}, []float64{0.667, 0.333}, 1},
+		{"worthless", []RatingFactor{
+			f("distance", 0, distanceWeight), f("population", 0, populationWeight),
+		}, []float64{0.667, 0.333}, 0},
+	} {
+		r := newRating(c.factors...)
+		var sum float64
+		for i, w := range c.weights {
+			if got := r.Factors[i].Weight; got != w {
+				t.Errorf("%s: weight of %s = %v, want %v", c.name, r.Factors[i].Name, got, w)
+			}
+			sum += r.Factors[i].Weight
+		}
+		if math.Abs(sum-1) > 0.001 {
+			t.Errorf("%s: weights sum to %v", c.name, sum)
+		}
+		if r.Confidence != c.confidence {
+			t.Errorf("%s: confidence = %v, want %v", c.name, r.Confidence, c.confidence)
+		}
+	}
+}
+
+func TestRatingFactors(t *testing.T) {
+	for _, c := range []struct {
+		f    RatingFactor
+		want float64
+	}{
+		{distanceFactor(0, placeHalfKm), 1},
+		{distanceFactor(10, placeHalfKm), 0.5},
+		{distanceFactor(5, postalHalfKm), 0.5},
+		{featureFactor("P", "PPLC"), 1},
+		{featureFactor("P", "PPLX"), 0.65},
+		{featureFactor("P", "PPLZ"), 0.6}, // unlisted code: by class
+		{featureFactor("H", "LK"), 0.3},
+		{featureFactor("", ""), 0},
+		{populationFactor(0), 0},
+		{populationFactor(-5), 0},
+		{populationFactor(9_999_999), 1},
+		{populationFactor(1e9), 1},
+	} {
+		if math.Abs(c.f.Value-c.want) > 1e-6 {
+			t.Errorf("%s(%s) = %v, want %v", c.f.Name, c.f.Input, c.f.Value, c.want)
+		}
+	}
+}
+
+// TestRatedFields checks that the factors follow the fields selected.
+func TestRatedFields(t *testing.T) {
+	p := Place{DistanceKm: 10, Fclass: "P", Fcode: "PPLC", Population: 9_999_999}
+	for _, c := range []struct {
+		fields []string
+		want   int
+	}{
+		{nil, 3},
+		{[]string{"name"}, 1},
+		{[]string{"fcode"}, 2},
+		{[]string{"population", "fclass"}, 3},
+	} {
+		if got := len(placeFactors(&p, QueryOptions{Fields: c.fields})); got != c.want {
+			t.Errorf("fields %v: %d factors, want %d", c.fields, got, c.want)
+		}
+	}
+
+	if r := rateFull(&FullResult{}); r != nil {
+		t.Errorf("rating without a place = %+v, want nil", r)
+	}
+	r := rateFull(&FullResult{Place: &p, Postal: &PostalCode{Confidence: 0.9}})
+	if len(r.Factors) != 4 || r.Confidence != 0.78 {
+		t.Errorf("composite rating = %+v, want 4 factors and confidence 0.78", r)
+	}
+}
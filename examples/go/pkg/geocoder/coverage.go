@@ -0,0 +1,273 @@
+package geocoder
+
+/*
+	Countries loaded in geoname and postalcodes.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    cov, err := geo.Coverage()
+	    if !cov.HasPostal("AO") { ... }   // Angola has no postal codes
+	    fmt.Println(cov.NoPostal)
+
+	A lookup restricted to a country (QueryOptions.Country) the table has
+	no row of used to run in full — on MySQL and SQLite a scan of the
+	table within the radius — and only then learn from explainEmpty that
+	the country is not covered. Many countries have no postal codes in
+	GeoNames at all, so a NearestPostal for one of them always paid it.
+
+	The Geocoder now keeps, per connection, which countries have rows in
+	geoname and in postalcodes: one bit per ISO 3166-1 alpha-2 code
+	(countrySet), filled by a single query that probes the country
+	indexes of both tables for each country of countryinfo. A lookup for
+	a country without rows fails at once with a *CountryNotCoveredError,
+	and NearestPlaces skips the nearest-postal subquery of its places
+	when their country has no postal codes. Codes missing from
+	countryinfo are not probed and their lookups run as before. Without
+	countryinfo the countries are read from the tables themselves, a
+	full scan of each.
+
+	The coverage is probed again once it is older than coverageTTL, so
+	countries loaded while the Geocoder runs are found within ten
+	minutes; until then their lookups fail as not covered. A failed
+	probe only disables the shortcut, and is tried again once it is older
+	than coverageRetry, so lookups do not queue behind a probe that keeps
+	failing. Coverage returns the lists, with the countries countryinfo
+	gives no postal-code format (no postal system), which is what the
+	server's GET /coverage serves.
+*/
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// coverageTTL is how long a probed coverage is trusted, and coverageRetry
+// how long a failed probe is.
+const (
+	coverageTTL   = 10 * time.Minute
+	coverageRetry = time.Minute
+)
+
+// countrySet is a set of ISO 3166-1 alpha-2 codes, one bit per code.
+type countrySet [(26*26 + 63) / 64]uint64
+
+// countryBit returns the bit of code, which must be two letters A-Z.
+func countryBit(code string) (int, bool) {
+	if len(code) != 2 {
+		return 0, false
+	}
+	a, b := code[0]-'A', code[1]-'A'
+	if a >= 26 || b >= 26 {
+		return 0, false
+	}
+	return int(a)*26 + int(b), true
+}
+
+func (s *countrySet) add(code string) {
+	if i, ok := countryBit(code); ok {
+		s[i/64] |= 1 << (i % 64)
+	}
+}
+
+func (s *countrySet) has(code string) bool {
+	i, ok := countryBit(code)
+	return ok && s[i/64]&(1<<(i%64)) != 0
+}
+
+// list returns the codes of the set in alphabetical order.
+func (s *countrySet) list() []string {
+	codes := []string{}
+	for i := range 26 * 26 {
+		if s[i/64]&(1<<(i%64)) != 0 {
+			codes = append(codes, string([]byte{byte('A' + i/26), byte('A' + i%26)}))
+		}
+	}
+	return codes
+}
+
+// Coverage lists the countries the database holds data of.
+type Coverage struct {
+	// Places and Postal list the countries with rows in geoname and in
+	// postalcodes; only those of countryinfo when it is loaded.
+	Places []string `json:"places"`
+	Postal []string `json:"postal_codes"`
+	// NoPostal lists the countries with places but no postal codes.
+	NoPostal []string `json:"no_postal"`
+	// NoPostalSystem lists the countries countryinfo gives no postal-code
+	// format, which have no postal codes to load.
+	NoPostalSystem []string  `json:"no_postal_system"`
+	ProbedAt       time.Time `json:"probed_at"`
+
+	places, postal, noSystem countrySet
+	// probed holds the countries whose rows were looked for; all is set
+	// when the tables themselves were read, which probes every country.
+	probed countrySet
+	all    bool
+}
+
+// HasPlaces reports whether geoname has rows of country.
+func (c *Coverage) HasPlaces(country string) bool { return c.places.has(country) }
+
+// HasPostal reports whether postalcodes has rows of country.
+func (c *Coverage) HasPostal(country string) bool { return c.postal.has(country) }
+
+// excludes reports whether table provably has no row of country. Codes
+// that are not two capital letters are never excluded — MySQL compares
+// them case-insensitively — nor are those missing from countryinfo.
+func (c *Coverage) excludes(table, country string) bool {
+	if _, ok := countryBit(country); !ok || !c.all && !c.probed.has(country) {
+		return false
+	}
+	switch table {
+	case "geoname":
+		return !c.places.has(country)
+	case "postalcodes":
+		return !c.postal.has(country)
+	}
+	return false
+}
+
+// ---------------------------------------------------------------------------
+// Probe
+// ---------------------------------------------------------------------------
+
+// coverageEntry is the cached coverage of one connection.
+type coverageEntry struct {
+	mu  sync.Mutex
+	cov *Coverage
+	// err is the error of the last probe, made at failedAt, if it failed.
+	err      error
+	failedAt time.Time
+}
+
+var coverageCache sync.Map // *gorm.Config → *coverageEntry
+
+// coverageFor returns the coverage of db, probing it when the last probe
+// is older than coverageTTL. Errors are not cached: the probe may have
+// run under the context of a cancelled request.
+func coverageFor(db *gorm.DB) (*Coverage, error) {
+	v, _ := coverageCache.LoadOrStore(db.Config, &coverageEntry{})
+	e := v.(*coverageEntry)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err != nil && time.Since(e.failedAt) <= coverageRetry {
+		return nil, e.err
+	}
+	if e.cov == nil || time.Since(e.cov.ProbedAt) > coverageTTL {
+		cov, err := ProbeCoverage(db)
+		if err != nil {
+			e.cov, e.err, e.failedAt = nil, err, time.Now()
+			return nil, err
+		}
+		e.cov, e.err = cov, nil
+	}
+	return e.cov, nil
+}
+
+// uncovered returns a *CountryNotCoveredError when table is known to
+// have no row of country, and nil otherwise.
+func uncovered(db *gorm.DB, table, country string) error {
+	if country == "" {
+		return nil
+	}
+	cov, err := coverageFor(db)
+	if err != nil || !cov.excludes(table, country) {
+		return nil
+	}
+	return &CountryNotCoveredError{
+		Country: country, Table: table,
+		NoPostalSystem: table == "postalcodes" && cov.noSystem.has(country),
+	}
+}
+
+// ProbeCoverage reads the coverage of db now, bypassing the
+// per-connection cache Geocoder.Coverage uses.
+func ProbeCoverage(db *gorm.DB) (*Coverage, error) {
+	st := dataStatusFor(db)
+	cov := &Coverage{ProbedAt: time.Now()}
+	if st.Has("countryinfo") {
+		exists := func(table, col string) string {
+			if !st.Has(table) {
+				return "(1 = 0)"
+			}
+			return fmt.Sprintf("EXISTS (SELECT 1 FROM %s t WHERE t.%s = c.iso_alpha2)",
+				TableName(db, table), col)
+		}
+		var rows []struct {
+			Code   string `gorm:"column:code"`
+			Places bool   `gorm:"column:places"`
+			Postal bool   `gorm:"column:postal"`
+			Format string `gorm:"column:format"`
+		}
+		err := db.Raw(fmt.Sprintf(`
+			SELECT c.iso_alpha2 AS code, %s AS places, %s AS postal,
+			       COALESCE(c.postal, '') AS format
+			FROM %s c`,
+			exists("geoname", "country"), exists("postalcodes", "countrycode"),
+			TableName(db, "countryinfo"))).Scan(&rows).Error
+		if err != nil {
+			return nil, fmt.Errorf("probing the coverage: %w", err)
+		}
+		for _, r := range rows {
+			cov.probed.add(r.Code)
+			if r.Places {
+				cov.places.add(r.Code)
+			}
+			if r.Postal {
+				cov.postal.add(r.Code)
+			}
+			if r.Format == "" {
+				cov.noSystem.add(r.Code)
+			}
+		}
+	} else {
+		cov.all = true
+		for _, t := range []struct {
+			table, col string
+			set        *countrySet
+		}{
+			{"geoname", "country", &cov.places},
+			{"postalcodes", "countrycode", &cov.postal},
+		} {
+			if !st.Has(t.table) {
+				continue
+			}
+			var codes []string
+			err := db.Raw(fmt.Sprintf("SELECT DISTINCT %s FROM %s WHERE %[1]s IS NOT NULL",
+				t.col, TableName(db, t.table))).Scan(&codes).Error
+			if err != nil {
+				return nil, fmt.Errorf("probing the coverage: %w", err)
+			}
+			for _, c := range codes {
+				t.set.add(c)
+			}
+		}
+	}
+
+	var noPostal countrySet
+	for i := range noPostal {
+		noPostal[i] = cov.places[i] &^ cov.postal[i]
+	}
+	cov.Places, cov.Postal = cov.places.list(), cov.postal.list()
+	cov.NoPostal, cov.NoPostalSystem = noPostal.list(), cov.noSystem.list()
+	return cov, nil
+}
@@ -0,0 +1,72 @@
+package geocoder
+
+/*
+	Tests of the country coverage cache.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// TestCoverageRetry checks that a failed probe is cached for
+// coverageRetry instead of being repeated by every lookup.
+func TestCoverageRetry(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "geonames.db")),
+		&gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Release(db)
+	// countryinfo without its postal column: the probe fails.
+	for _, stmt := range []string{
+		"CREATE TABLE countryinfo (iso_alpha2 TEXT)",
+		"INSERT INTO countryinfo VALUES ('MX')",
+		"CREATE TABLE geoname (country TEXT)",
+		"INSERT INTO geoname VALUES ('MX')",
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := coverageFor(db); err == nil {
+		t.Fatal("probe without countryinfo.postal: no error")
+	}
+
+	if err := db.Exec("ALTER TABLE countryinfo ADD COLUMN postal TEXT").Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := coverageFor(db); err == nil {
+		t.Error("within coverageRetry: probed again")
+	}
+
+	v, _ := coverageCache.Load(db.Config)
+	v.(*coverageEntry).failedAt = time.Now().Add(-coverageRetry - time.Second)
+	cov, err := coverageFor(db)
+	if err != nil {
+		t.Fatalf("after coverageRetry: %v", err)
+	}
+	if !cov.HasPlaces("MX") {
+		t.Errorf("coverage = %+v, want places in MX", cov)
+	}
+}
@@ -0,0 +1,302 @@
+package geocoder
+
+/*
+	Detection of missing and empty tables.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    st := geo.DataStatus()
+	    if !st.Has("postalcodes") { ... }
+	    for _, w := range st.Warnings() { log.Print(w) }
+
+	A database may hold only part of GeoNames: loaded without postal codes,
+	a load still running or interrupted, a table dropped by hand. Instead
+	of failing half way through a lookup with "no such table", the
+	Geocoder checks, once per connection like the capability probe (see
+	capabilities.go), which of the tables in dataTables exist and whether
+	they hold any row, and skips the queries that need a table without
+	data:
+
+	  geoname           NearestPlaces fails with a *MissingDataError
+	  postalcodes       NearestPostal fails with a *MissingDataError;
+	                    NearestPlaces leaves Postalcode empty and the
+	                    full result has no postal code
+	  alternatename     places get no Wikipedia/Wikidata links
+	  countryinfo,      the names of the country, first- and second-level
+	  admin1codesascii, divisions are left empty
+	  admin2codesascii
+	  timezones         the full result has no time zone
+
+	A *MissingDataError matches ErrNoData and is a no-result error for
+	IsNoResult, so callers that already cope with an empty answer carry
+	on; DataStatus tells them beforehand what the database covers, and
+	Warnings lists every table without data with what it disables.
+
+	Whether a table is empty is exact (one LIMIT 1 query); Rows is the
+	estimate of the catalogs — pg_class.reltuples, the TABLE_ROWS of
+	information_schema, SQLite's sqlite_stat1, written by ANALYZE — and -1
+	when they have none. A status with tables missing or empty is probed
+	again after dataRetry, so a database being loaded is picked up
+	without a restart.
+*/
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// dataRetry is how long a status with tables missing or empty is trusted.
+const dataRetry = time.Minute
+
+// dataTables are the tables whose data DataStatus reports, with what
+// their absence disables.
+var dataTables = []struct{ table, effect string }{
+	{"geoname", "place lookups fail"},
+	{"postalcodes", "postal-code lookups are skipped"},
+	{"alternatename", "places have no Wikipedia or Wikidata links"},
+	{"countryinfo", "country names are left empty"},
+	{"admin1codesascii", "first-level division names are left empty"},
+	{"admin2codesascii", "second-level division names are left empty"},
+	{"timezones", "full results have no time zone"},
+}
+
+// TableStatus describes the data of one table.
+type TableStatus struct {
+	// Name is the table's name in the database (see TableName).
+	Name   string `json:"name"`
+	Exists bool   `json:"exists"`
+	Empty  bool   `json:"empty"`
+	// Rows is the catalogs' estimate of the number of rows, or -1.
+	Rows int64 `json:"rows"`
+}
+
+// DataStatus describes which GeoNames data a database holds.
+type DataStatus struct {
+	// Tables maps the default name of each table in dataTables to its
+	// status.
+	Tables map[string]TableStatus `json:"tables"`
+	// Errors maps the tables that could not be probed to the error
+	// message; they are reported as holding data.
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// Has reports whether table exists and holds rows. Tables that were not
+// probed are assumed to.
+func (s DataStatus) Has(table string) bool {
+	t, ok := s.Tables[table]
+	return !ok || t.Exists && !t.Empty
+}
+
+// Complete reports whether every probed table holds rows.
+func (s DataStatus) Complete() bool {
+	for table := range s.Tables {
+		if !s.Has(table) {
+			return false
+		}
+	}
+	return len(s.Errors) == 0
+}
+
+// DataWarning is the warning about a table without data.
+type DataWarning struct {
+	Table string `json:"table"`
+	// Problem is "missing" or "empty".
+	Problem string `json:"problem"`
+	// Effect says which lookups are skipped or degraded.
+	Effect string `json:"effect"`
+}
+
+func (w DataWarning) String() string {
+	return fmt.Sprintf("table %s is %s: %s", w.Table, w.Problem, w.Effect)
+}
+
+// Warnings lists the tables without data, in the order of dataTables.
+func (s DataStatus) Warnings() []DataWarning {
+	var ws []DataWarning
+	for _, d := range dataTables {
+		t, ok := s.Tables[d.table]
+		switch {
+		case !ok:
+		case !t.Exists:
+			ws = append(ws, DataWarning{Table: d.table, Problem: "missing", Effect: d.effect})
+		case t.Empty:
+			ws = append(ws, DataWarning{Table: d.table, Problem: "empty", Effect: d.effect})
+		}
+	}
+	return ws
+}
+
+// MissingDataError reports a lookup skipped because its table has no data.
+// It matches ErrNoData.
+type MissingDataError struct {
+	Table string
+	// Missing is true when the table does not exist, false when it is
+	// empty.
+	Missing bool
+}
+
+func (e *MissingDataError) Error() string {
+	if e.Missing {
+		return fmt.Sprintf("geocoder: table %s is missing", e.Table)
+	}
+	return fmt.Sprintf("geocoder: table %s is empty", e.Table)
+}
+
+func (e *MissingDataError) Is(target error) bool {
+	return target == ErrNoData
+}
+
+// requireData returns a *MissingDataError when table has no data.
+func requireData(db *gorm.DB, table string) error {
+	st := dataStatusFor(db)
+	if st.Has(table) {
+		return nil
+	}
+	return &MissingDataError{Table: table, Missing: !st.Tables[table].Exists}
+}
+
+// ---------------------------------------------------------------------------
+// Probe
+// ---------------------------------------------------------------------------
+
+// dataEntry is the cached status of one connection.
+type dataEntry struct {
+	mu       sync.Mutex
+	status   *DataStatus
+	probedAt time.Time
+}
+
+var dataCache sync.Map // *gorm.Config → *dataEntry
+
+// dataStatusFor returns the data status of db, probing on first use.
+func dataStatusFor(db *gorm.DB) DataStatus {
+	v, _ := dataCache.LoadOrStore(db.Config, &dataEntry{})
+	e := v.(*dataEntry)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.status == nil || !e.status.Complete() && time.Since(e.probedAt) > dataRetry {
+		st := ProbeData(db)
+		e.status, e.probedAt = &st, time.Now()
+	}
+	return *e.status
+}
+
+// ProbeData checks the tables of db now, bypassing the per-connection
+// cache Geocoder.DataStatus uses.
+func ProbeData(db *gorm.DB) DataStatus {
+	st := DataStatus{Tables: map[string]TableStatus{}}
+	for _, d := range dataTables {
+		t, err := probeTable(db, TableName(db, d.table))
+		if err != nil {
+			if st.Errors == nil {
+				st.Errors = map[string]string{}
+			}
+			st.Errors[d.table] = err.Error()
+			continue
+		}
+		st.Tables[d.table] = t
+	}
+	return st
+}
+
+// probeTable returns the status of the table named name.
+func probeTable(db *gorm.DB, name string) (TableStatus, error) {
+	t := TableStatus{Name: name, Rows: -1}
+	var err error
+	switch db.Dialector.Name() {
+	case "postgres":
+		var rows []int64
+		err = db.Raw("SELECT reltuples::bigint FROM pg_class WHERE oid = to_regclass(?)",
+			name).Scan(&rows).Error
+		if len(rows) > 0 {
+			t.Exists, t.Rows = true, rows[0]
+		}
+	case "mysql":
+		schema, table, ok := strings.Cut(name, ".")
+		if !ok {
+			schema, table = "", name
+		}
+		var rows []*int64
+		err = db.Raw(`
+			SELECT TABLE_ROWS FROM information_schema.TABLES
+			WHERE TABLE_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE()) AND TABLE_NAME = ?`,
+			schema, table).Scan(&rows).Error
+		if len(rows) > 0 {
+			t.Exists = true
+			if rows[0] != nil {
+				t.Rows = *rows[0]
+			}
+		}
+	case "sqlite":
+		prefix, table := "", name
+		if s, tb, ok := strings.Cut(name, "."); ok {
+			prefix, table = s+".", tb
+		}
+		var n int64
+		err = db.Raw("SELECT count(*) FROM "+prefix+"sqlite_master WHERE type IN ('table', 'view') AND name = ?",
+			table).Scan(&n).Error
+		t.Exists = n > 0
+		if err == nil && t.Exists {
+			t.Rows = sqliteStatRows(db, prefix, table)
+		}
+	default:
+		return t, fmt.Errorf("%w: %s", ErrUnsupportedDialect, db.Dialector.Name())
+	}
+	if err != nil || !t.Exists {
+		return t, err
+	}
+
+	var n int64
+	err = db.Raw("SELECT count(*) FROM (SELECT 1 FROM " + name + " LIMIT 1) t").Scan(&n).Error
+	t.Empty = n == 0
+	switch {
+	case t.Empty:
+		t.Rows = 0
+	case t.Rows == 0:
+		// Statistics older than the data.
+		t.Rows = -1
+	}
+	return t, err
+}
+
+// sqliteStatRows returns the row count ANALYZE recorded for table, or -1.
+func sqliteStatRows(db *gorm.DB, prefix, table string) int64 {
+	var n int64
+	if db.Raw("SELECT count(*) FROM "+prefix+"sqlite_master WHERE name = 'sqlite_stat1'").
+		Scan(&n).Error != nil || n == 0 {
+		return -1
+	}
+	var stats []string
+	if db.Raw("SELECT stat FROM "+prefix+"sqlite_stat1 WHERE tbl = ? LIMIT 1", table).
+		Scan(&stats).Error != nil || len(stats) == 0 {
+		return -1
+	}
+	// The first number of stat is the row count of the table.
+	first, _, _ := strings.Cut(stats[0], " ")
+	rows, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return rows
+}
@@ -0,0 +1,186 @@
+package geocoder
+
+/*
+	Deduplication of nearest places that are the same settlement.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    go run . --lat 20.6767 --lon -103.3475 --results 5 --dedupe
+	    curl 'http://localhost:8080/reverse?lat=20.6767&lon=-103.3475&dedupe=1'
+
+	GeoNames often holds one town several times: the populated place
+	(PPL, PPLA, ...), its sections (PPLX) and the administrative division
+	it is the seat of (ADM2, ADM3: "Municipio de Guadalajara"), all within
+	a few kilometres. With QueryOptions.Dedupe, NearestPlaces fetches
+	dedupeCandidates rows per result wanted and groups them into
+	settlements. Only populated places (class P) and administrative
+	divisions (class A) are grouped — an airport or a lake named after a
+	town stays on its own. Two of them are the same settlement when they
+	are in the same country and first-level division, at most dedupeKm
+	apart, and
+
+	  - their names are alike: one holds the other as whole words
+	    ("Guadalajara" in "Municipio de Guadalajara"), or their
+	    similarity (the measure of fuzzy.go) is at least dedupeSimilarity;
+	    or
+	  - one is a section of a populated place (PPLX) and the other the
+	    populated place itself, whatever their names.
+
+	Each settlement is represented by its best row — a populated place
+	(class P, not a section) before anything else, then the most
+	populous, then the nearest — which lists the geonameids of the rows
+	it absorbed in Duplicates. The representatives come back nearest
+	first, at most opts.Limit of them.
+
+	Rows are compared with the representative of each settlement, best
+	first, so the grouping does not chain a string of neighbouring
+	sections into one town. With QueryOptions.Fields, the dedupeFields
+	the grouping needs are queried too, and cleared again afterwards
+	unless they were asked for.
+*/
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const (
+	dedupeCandidates = 4    // rows fetched per result wanted
+	dedupeKm         = 10   // farthest apart two rows of a settlement can be
+	dedupeSimilarity = 0.85 // least nameSimilarity of alike names
+)
+
+// dedupeFields are the fields the grouping reads.
+var dedupeFields = []string{
+	"geonameid", "name", "fclass", "fcode", "country", "admin1", "population",
+}
+
+// dedupePlaces groups rows into settlements and returns the representative
+// of each, nearest first, at most limit of them.
+func dedupePlaces(rows []Place, limit int) []Place {
+	order := slices.Clone(rows)
+	slices.SortStableFunc(order, func(a, b Place) int {
+		return cmp.Or(
+			cmp.Compare(settlementRank(a), settlementRank(b)),
+			cmp.Compare(b.Population, a.Population),
+			cmp.Compare(a.DistanceKm, b.DistanceKm),
+		)
+	})
+	var reps []Place
+	for _, r := range order {
+		i := slices.IndexFunc(reps, func(rep Place) bool { return sameSettlement(rep, r) })
+		if i < 0 {
+			reps = append(reps, r)
+			continue
+		}
+		reps[i].Duplicates = append(reps[i].Duplicates, r.Geonameid)
+	}
+	slices.SortStableFunc(reps, func(a, b Place) int {
+		return cmp.Compare(a.DistanceKm, b.DistanceKm)
+	})
+	if len(reps) > limit {
+		reps = reps[:limit]
+	}
+	return reps
+}
+
+// clearUnselected clears the dedupeFields of rows that fields leaves out;
+// empty fields selects them all.
+func clearUnselected(rows []Place, fields []string) {
+	if len(fields) == 0 {
+		return
+	}
+	for _, f := range dedupeFields {
+		if slices.Contains(fields, f) {
+			continue
+		}
+		for i := range rows {
+			r := &rows[i]
+			switch f {
+			case "geonameid":
+				r.Geonameid = 0
+			case "name":
+				r.Name = ""
+			case "fclass":
+				r.Fclass = ""
+			case "fcode":
+				r.Fcode = ""
+			case "country":
+				r.Country = ""
+			case "admin1":
+				r.Admin1 = ""
+			case "population":
+				r.Population = 0
+			}
+		}
+	}
+}
+
+// settlementRank orders the rows a settlement is represented by: populated
+// places, their sections, then everything else.
+func settlementRank(p Place) int {
+	switch {
+	case p.Fclass == "P" && p.Fcode != "PPLX":
+		return 0
+	case p.Fclass == "P":
+		return 1
+	case p.Fclass == "A":
+		return 2
+	}
+	return 3
+}
+
+// sameSettlement reports whether the representative rep and r are the same
+// settlement.
+func sameSettlement(rep, r Place) bool {
+	if settlementRank(rep) > 2 || settlementRank(r) > 2 || rep.Country != r.Country ||
+		rep.Admin1 != "" && r.Admin1 != "" && rep.Admin1 != r.Admin1 {
+		return false
+	}
+	if haversineKm(rep.Latitude, rep.Longitude, r.Latitude, r.Longitude) > dedupeKm {
+		return false
+	}
+	if settlementRank(rep) == 0 && r.Fcode == "PPLX" {
+		return true
+	}
+	return alikeNames(rep.Name, r.Name)
+}
+
+// alikeNames reports whether a and b name the same thing, ignoring case.
+func alikeNames(a, b string) bool {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	if a == "" || b == "" {
+		return false
+	}
+	if containsWords(a, b) || containsWords(b, a) {
+		return true
+	}
+	return nameSimilarity(a, b) >= dedupeSimilarity
+}
+
+// containsWords reports whether the words of sub appear together in s.
+func containsWords(s, sub string) bool {
+	words := func(s string) string {
+		notWord := func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }
+		return " " + strings.Join(strings.FieldsFunc(s, notWord), " ") + " "
+	}
+	return strings.Contains(words(s), words(sub))
+}
@@ -0,0 +1,137 @@
+package geocoder
+
+/*
+	Tests of the settlement grouping.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Places around Guadalajara, nearest first from 20.6767, -103.3475.
+var (
+	gdlSection = Place{Geonameid: 1, Name: "Colonia Americana", Fclass: "P", Fcode: "PPLX",
+		Country: "MX", Admin1: "14", Latitude: 20.672, Longitude: -103.365, DistanceKm: 1.9}
+	gdl = Place{Geonameid: 2, Name: "Guadalajara", Fclass: "P", Fcode: "PPLA",
+		Country: "MX", Admin1: "14", Population: 1495182, Latitude: 20.667, Longitude: -103.392, DistanceKm: 4.7}
+	gdlSeat = Place{Geonameid: 3, Name: "Municipio de Guadalajara", Fclass: "A", Fcode: "ADM2",
+		Country: "MX", Admin1: "14", Latitude: 20.68, Longitude: -103.33, DistanceKm: 1.9}
+	gdlAirport = Place{Geonameid: 4, Name: "Aeropuerto de Guadalajara", Fclass: "S", Fcode: "AIRP",
+		Country: "MX", Admin1: "14", Latitude: 20.676, Longitude: -103.35, DistanceKm: 0.3}
+	zapopan = Place{Geonameid: 5, Name: "Zapopan", Fclass: "P", Fcode: "PPLA2",
+		Country: "MX", Admin1: "14", Population: 1476491, Latitude: 20.72, Longitude: -103.39, DistanceKm: 6.5}
+	// A namesake across a state border, within dedupeKm.
+	gdlOther = Place{Geonameid: 6, Name: "Guadalajara", Fclass: "P", Fcode: "PPL",
+		Country: "MX", Admin1: "11", Latitude: 20.70, Longitude: -103.40, DistanceKm: 6}
+)
+
+func TestDedupePlaces(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		rows  []Place
+		limit int
+		// want lists "geonameid duplicates" of the representatives.
+		want string
+	}{
+		{"section folded", []Place{gdlSection, gdl}, 5, "[2 [1]]"},
+		{"ADM2 seat folded", []Place{gdlSeat, gdl}, 5, "[2 [3]]"},
+		{"airport kept", []Place{gdlAirport, gdl}, 5, "[4 [] 2 []]"},
+		{"other name kept", []Place{gdl, zapopan}, 5, "[2 [] 5 []]"},
+		{"other admin1 kept", []Place{gdl, gdlOther}, 5, "[2 [] 6 []]"},
+		{
+			"limit after grouping", []Place{gdlAirport, gdlSection, gdlSeat, gdl, zapopan}, 2,
+			"[4 [] 2 [1 3]]",
+		},
+		{"section alone", []Place{gdlSection}, 5, "[1 []]"},
+	} {
+		var got []string
+		for _, p := range dedupePlaces(c.rows, c.limit) {
+			got = append(got, fmt.Sprintf("%d %v", p.Geonameid, p.Duplicates))
+		}
+		if fmt.Sprint(got) != c.want {
+			t.Errorf("%s: got %v, want %s", c.name, got, c.want)
+		}
+	}
+}
+
+func TestSameSettlement(t *testing.T) {
+	far := gdlSection
+	far.Latitude = 20.9 // 25 km north
+	otherCountry := gdlSeat
+	otherCountry.Country = "US"
+	noAdmin1 := gdlSeat
+	noAdmin1.Admin1 = ""
+	for _, c := range []struct {
+		name   string
+		rep, r Place
+		want   bool
+	}{
+		{"PPLX of a populated place", gdl, gdlSection, true},
+		{"seat with the place's name", gdl, gdlSeat, true},
+		{"seat without admin1", gdl, noAdmin1, true},
+		{"PPLX too far", gdl, far, false},
+		{"other country", gdl, otherCountry, false},
+		{"other admin1", gdl, gdlOther, false},
+		{"spot feature", gdl, gdlAirport, false},
+		{"other name", gdl, zapopan, false},
+		// A section only joins a populated place, not another section.
+		{"PPLX of a PPLX", gdlSection, far, false},
+	} {
+		if got := sameSettlement(c.rep, c.r); got != c.want {
+			t.Errorf("%s: sameSettlement = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestAlikeNames(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		want bool
+	}{
+		{"Guadalajara", "Municipio de Guadalajara", true},
+		{"Municipio de Guadalajara", "guadalajara", true},
+		{"San José", "San José de Gracia", true},
+		{"Frankfurt am Main", "Frankfurt (Oder)", false},
+		{"Mexico City", "Ciudad de México", false},
+		{"Tlalnepantla", "Tlanepantla", true},
+		{"Köln", "Koln", false}, // too short for one edit
+		{"Guadalajara", "Guadalupe", false},
+		{"Ana", "Santa Ana", true},
+		{"Ana", "Banana", false},
+		{"", "Guadalajara", false},
+	} {
+		if got := alikeNames(c.a, c.b); got != c.want {
+			t.Errorf("alikeNames(%q, %q) = %v, want %v", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestClearUnselected(t *testing.T) {
+	rows := []Place{gdl}
+	clearUnselected(rows, []string{"name", "country"})
+	p := rows[0]
+	if p.Name != gdl.Name || p.Country != gdl.Country || p.Geonameid != 0 || p.Fclass != "" ||
+		p.Fcode != "" || p.Admin1 != "" || p.Population != 0 || p.Latitude != gdl.Latitude {
+		t.Errorf("fields name,country: %+v", p)
+	}
+	rows = []Place{gdl}
+	if clearUnselected(rows, nil); rows[0].Population != gdl.Population {
+		t.Errorf("no fields: %+v, want every field", rows[0])
+	}
+}
@@ -0,0 +1,107 @@
+package geocoder
+
+/*
+	Distance units and ellipsoidal (geodesic) distance recomputation.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	The Haversine and earthdistance strategies compute distances on a
+	sphere of radius 6371 km, which can be off by up to ~0.5 %. PostGIS and
+	Ganos already measure geography distances on the WGS-84 spheroid. With
+	--geodesic the distances of the returned rows are recomputed in Go with
+	Vincenty's inverse formula on the WGS-84 ellipsoid (sub-millimetre
+	accuracy) and the rows re-sorted, whatever the strategy. Vincenty's
+	iteration does not converge for nearly antipodal points; those rows
+	keep the database distance.
+
+	Distances are always stored in kilometres (DistanceKm); --units only
+	changes how they are reported.
+
+	The command-line side is in cmd/reverse_geocode/distance.go.
+*/
+
+import (
+	"math"
+)
+
+// haversineKm returns the great-circle distance in kilometres between two
+// points on the sphere the SQL strategies use.
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const rad = math.Pi / 180
+	sinDLat := math.Sin((lat2 - lat1) * rad / 2)
+	sinDLon := math.Sin((lon2 - lon1) * rad / 2)
+	a := sinDLat*sinDLat + math.Cos(lat1*rad)*math.Cos(lat2*rad)*sinDLon*sinDLon
+	return 2 * earthRadiusKm * math.Asin(min(1, math.Sqrt(a)))
+}
+
+// WGS-84 ellipsoid.
+const (
+	wgs84A = 6378137.0
+	wgs84F = 1 / 298.257223563
+	wgs84B = wgs84A * (1 - wgs84F)
+)
+
+// VincentyKm returns the ellipsoidal distance in kilometres between two
+// points using Vincenty's inverse formula. ok is false when the iteration
+// fails to converge (nearly antipodal points).
+func VincentyKm(lat1, lon1, lat2, lon2 float64) (km float64, ok bool) {
+	const rad = math.Pi / 180
+	L := (lon2 - lon1) * rad
+	U1 := math.Atan((1 - wgs84F) * math.Tan(lat1*rad))
+	U2 := math.Atan((1 - wgs84F) * math.Tan(lat2*rad))
+	sinU1, cosU1 := math.Sincos(U1)
+	sinU2, cosU2 := math.Sincos(U2)
+
+	lambda := L
+	var sinSigma, cosSigma, sigma, cosSqAlpha, cos2SigmaM float64
+	for i := 0; i < 200; i++ {
+		sinLambda, cosLambda := math.Sincos(lambda)
+		sinSigma = math.Sqrt(math.Pow(cosU2*sinLambda, 2) +
+			math.Pow(cosU1*sinU2-sinU1*cosU2*cosLambda, 2))
+		if sinSigma == 0 {
+			return 0, true // coincident points
+		}
+		cosSigma = sinU1*sinU2 + cosU1*cosU2*cosLambda
+		sigma = math.Atan2(sinSigma, cosSigma)
+		sinAlpha := cosU1 * cosU2 * sinLambda / sinSigma
+		cosSqAlpha = 1 - sinAlpha*sinAlpha
+		cos2SigmaM = 0
+		if cosSqAlpha != 0 { // not an equatorial line
+			cos2SigmaM = cosSigma - 2*sinU1*sinU2/cosSqAlpha
+		}
+		C := wgs84F / 16 * cosSqAlpha * (4 + wgs84F*(4-3*cosSqAlpha))
+		prev := lambda
+		lambda = L + (1-C)*wgs84F*sinAlpha*(sigma+C*sinSigma*
+			(cos2SigmaM+C*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+		if math.Abs(lambda-prev) < 1e-12 {
+			ok = true
+			break
+		}
+	}
+	if !ok {
+		return 0, false
+	}
+
+	uSq := cosSqAlpha * (wgs84A*wgs84A - wgs84B*wgs84B) / (wgs84B * wgs84B)
+	A := 1 + uSq/16384*(4096+uSq*(-768+uSq*(320-175*uSq)))
+	B := uSq / 1024 * (256 + uSq*(-128+uSq*(74-47*uSq)))
+	deltaSigma := B * sinSigma * (cos2SigmaM + B/4*(cosSigma*
+		(-1+2*cos2SigmaM*cos2SigmaM)-B/6*cos2SigmaM*
+		(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+	return wgs84B * A * (sigma - deltaSigma) / 1000, true
+}
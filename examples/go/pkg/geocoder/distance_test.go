@@ -0,0 +1,68 @@
+package geocoder
+
+/*
+	Tests of the geodesic distances.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"math"
+	"testing"
+)
+
+func TestVincentyKm(t *testing.T) {
+	for _, c := range []struct {
+		name                   string
+		lat1, lon1, lat2, lon2 float64
+		// km is the distance, within a millimetre, when ok.
+		km float64
+		ok bool
+	}{
+		// Vincenty's own example: Flinders Peak to Buninyong.
+		{"Flinders Peak", -37.95103341666667, 144.42486788888889, -37.65282113888889, 143.92649552777777, 54.972271, true},
+		{"equator, 1°", 0, 0, 0, 1, 111.319491, true},
+		{"quarter meridian", 0, 0, 90, 0, 10001.965729, true},
+		{"pole to pole", 90, 0, -90, 0, 20003.931459, true},
+		{"coincident", 19.4326, -99.1332, 19.4326, -99.1332, 0, true},
+		{"far, converging", 0, 0, 0.5, 179.5, 19936.288579, true},
+		// Nearly antipodal points: the iteration does not converge.
+		{"antipodal, equator", 0, 0, 0, 180, 0, false},
+		{"antipodal, off the equator", 10, 0, -10, 180, 0, false},
+		{"nearly antipodal", 0, 0, 0.5, 179.7, 0, false},
+	} {
+		km, ok := VincentyKm(c.lat1, c.lon1, c.lat2, c.lon2)
+		if ok != c.ok || (ok && math.Abs(km-c.km) > 1e-6) {
+			t.Errorf("%s: VincentyKm = %.6f, %v; want %.6f, %v", c.name, km, ok, c.km, c.ok)
+		}
+	}
+}
+
+// TestHaversineKm checks the sphere of the SQL strategies against
+// Vincenty: within 0.5 %.
+func TestHaversineKm(t *testing.T) {
+	for _, c := range [][4]float64{
+		{19.4326, -99.1332, 20.6597, -103.3496},
+		{0, 0, 0, 1},
+		{0, 0, 90, 0},
+		{-37.95, 144.42, 51.5, -0.12},
+	} {
+		want, _ := VincentyKm(c[0], c[1], c[2], c[3])
+		if got := haversineKm(c[0], c[1], c[2], c[3]); math.Abs(got-want) > want*0.005 {
+			t.Errorf("haversineKm%v = %.3f, Vincenty %.3f", c, got, want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// Package geocoder finds the postal codes and places nearest to a point,
+// and searches places by name, in a database loaded with the GeoNames
+// dumps by load_geonames.py or the reverse_geocode load command.
+//
+// New wraps a *gorm.DB (PostgreSQL, MySQL/MariaDB or SQLite) in a
+// Geocoder, whose lookups pick the fastest distance strategy the database
+// supports:
+//
+//	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+//	if err := geocoder.CheckSchema(db); err != nil {
+//		return err // ErrSchemaVersion: load or migrate the database
+//	}
+//	geo := geocoder.New(db, geocoder.WithReverseCache(cache))
+//	res, err := geo.NearestPlaces(19.4326, -99.1332, geocoder.QueryOptions{
+//		Limit:        3,
+//		FeatureClass: "P",
+//	})
+//
+// # Stability
+//
+// The exported API of this package — Geocoder, its options, QueryOptions,
+// Place, PostalCode and the other result and error types — follows
+// semantic versioning, with releases tagged examples/go/vX.Y.Z. Minor
+// releases only add to it; a breaking change would move the module to a
+// /v2 path. The packages under internal/ and the reverse_geocode command
+// are not covered. Every release builds with CGO_ENABLED=0, which
+// TestBuildWithoutCgo checks.
+//
+// # Drivers and cgo
+//
+// The package itself needs no cgo, and neither do the PostgreSQL
+// (gorm.io/driver/postgres, pgx) and MySQL/MariaDB
+// (gorm.io/driver/mysql) drivers, so binaries for them may be static and
+// cross-compiled. SQLite (gorm.io/driver/sqlite, mattn/go-sqlite3) needs
+// cgo, and the sqlite_math_functions build tag for the SQRT and
+// trigonometric functions of the haversine strategy. Built without cgo,
+// the package does not recognise SQLite's "database is locked" as a
+// transient error, since the SQLite driver cannot open a database then.
+package geocoder
+
+/*
+	Package documentation.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
@@ -0,0 +1,196 @@
+package geocoder
+
+/*
+	Composite reverse geocoding: everything known about a point in one call.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	ReverseGeocodeFull runs in two parallel rounds on the Geocoder's pool:
+	  1. nearest populated place (feature class P), nearest postal code
+	     and the registered zones containing the point (see zones.go);
+	  2. given the place: country details, admin1/admin2 names and the
+	     place's timezone with its UTC offsets.
+	GeoNames has no boundaries, so the "containing" country and divisions
+	are those of the nearest populated place. When that place is farther
+	than the Geocoder's offshore distance, the second round is skipped and
+	the result holds the zones and an Offshore report (see offshore.go).
+
+	The command-line side is in cmd/reverse_geocode/full.go.
+*/
+
+import (
+	"fmt"
+	"sync"
+)
+
+// CountryInfo is the countryinfo row of a country.
+type CountryInfo struct {
+	ISOAlpha2    string `gorm:"column:iso_alpha2"    json:"iso_alpha2"`
+	ISOAlpha3    string `gorm:"column:iso_alpha3"    json:"iso_alpha3"`
+	Name         string `gorm:"column:country"       json:"name"`
+	Capital      string `gorm:"column:capital"       json:"capital,omitempty"`
+	Continent    string `gorm:"column:continent"     json:"continent,omitempty"`
+	CurrencyCode string `gorm:"column:currency_code" json:"currency_code,omitempty"`
+	Languages    string `gorm:"column:languages"     json:"languages,omitempty"`
+	Geonameid    int64  `gorm:"column:geonameid"     json:"geonameid,omitempty"`
+}
+
+// TimezoneInfo is a timezones row.
+type TimezoneInfo struct {
+	ID        string  `gorm:"column:timezoneid" json:"id"`
+	GMTOffset float64 `gorm:"column:gmt_offset" json:"gmt_offset"`
+	DSTOffset float64 `gorm:"column:dst_offset" json:"dst_offset"`
+	RawOffset float64 `gorm:"column:raw_offset" json:"raw_offset"`
+}
+
+// FullResult is the composite answer of ReverseGeocodeFull. Members are nil
+// (or empty) when the database has nothing for them.
+type FullResult struct {
+	Latitude  float64       `json:"latitude"`
+	Longitude float64       `json:"longitude"`
+	Place     *Place        `json:"place"`
+	Postal    *PostalCode   `json:"postal"`
+	Country   *CountryInfo  `json:"country"`
+	Admin     AdminNames    `json:"admin"`
+	Timezone  *TimezoneInfo `json:"timezone"`
+	// Zones lists the registered zones containing the point, if any.
+	Zones []string `json:"zones,omitempty"`
+	// Offshore is set instead of Place and the rest when no populated
+	// place is within the offshore distance (see offshore.go).
+	Offshore *OffshoreError `json:"offshore,omitempty"`
+	// Rating rates Place and the accuracy of Postal (see confidence.go).
+	Rating *Rating `json:"rating,omitempty"`
+}
+
+// parallel runs fns concurrently and returns the first error.
+func parallel(fns ...func() error) error {
+	errs := make([]error, len(fns))
+	var wg sync.WaitGroup
+	for i, fn := range fns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = fn()
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReverseGeocodeFull returns the nearest populated place, nearest postal
+// code, country, administrative hierarchy and timezone of (lat, lon).
+func (g *Geocoder) ReverseGeocodeFull(lat, lon float64) (*FullResult, error) {
+	key := fmt.Sprintf("full|%g|%g|%s", lat, lon, g.strategy)
+	return guarded(g, key, func() (*FullResult, error) {
+		return g.reverseGeocodeFull(lat, lon)
+	})
+}
+
+func (g *Geocoder) reverseGeocodeFull(lat, lon float64) (*FullResult, error) {
+	res := &FullResult{Latitude: lat, Longitude: lon}
+	if err := requireData(g.db, "geoname"); err != nil {
+		return res, err
+	}
+	data := dataStatusFor(g.db)
+
+	err := parallel(
+		func() error {
+			opts := QueryOptions{Limit: 1, FeatureClass: "P", Strategy: g.strategy}
+			if !data.Has("postalcodes") {
+				opts = opts.withoutPostalCode()
+			}
+			rows, err := queryGeoname(g.db, lat, lon, opts)
+			if len(rows) > 0 {
+				res.Place = &rows[0]
+			}
+			return err
+		},
+		func() error {
+			if !data.Has("postalcodes") {
+				return nil
+			}
+			rows, err := queryPostal(g.db, lat, lon, QueryOptions{Limit: 1, Strategy: g.strategy})
+			if len(rows) > 0 {
+				res.Postal = &rows[0]
+			}
+			return err
+		},
+		func() (err error) {
+			res.Zones, err = g.WithinZones(lat, lon)
+			return err
+		},
+	)
+	if err != nil || res.Place == nil {
+		return res, err
+	}
+	if g.offshoreKm > 0 && res.Place.DistanceKm > g.offshoreKm {
+		if res.Offshore, err = g.offshore(lat, lon, g.strategy); res.Offshore != nil {
+			res.Place, res.Postal = nil, nil
+		}
+		if err != nil || res.Offshore != nil {
+			return res, err
+		}
+	}
+
+	p := res.Place
+	res.Rating = rateFull(res)
+	err = parallel(
+		func() error {
+			if !data.Has("countryinfo") {
+				return nil
+			}
+			var rows []CountryInfo
+			err := g.db.Raw(`
+				SELECT iso_alpha2, iso_alpha3, country, capital, continent,
+				       currency_code, languages, geonameid
+				FROM `+TableName(g.db, "countryinfo")+`
+				WHERE iso_alpha2 = ?
+				LIMIT 1`, p.Country).Scan(&rows).Error
+			if len(rows) > 0 {
+				res.Country = &rows[0]
+			}
+			return err
+		},
+		func() (err error) {
+			res.Admin, err = g.AdminNames(p.Country, p.Admin1, p.Admin2)
+			return err
+		},
+		func() error {
+			if !data.Has("timezones") {
+				return nil
+			}
+			var rows []TimezoneInfo
+			err := g.db.Raw(`
+				SELECT t.timezoneid, t.gmt_offset, t.dst_offset, t.raw_offset
+				FROM `+TableName(g.db, "geoname")+` g
+				JOIN `+TableName(g.db, "timezones")+` t ON t.timezoneid = g.timezone
+				WHERE g.geonameid = ?
+				LIMIT 1`, p.Geonameid).Scan(&rows).Error
+			if len(rows) > 0 {
+				res.Timezone = &rows[0]
+			}
+			return err
+		},
+	)
+	return res, err
+}
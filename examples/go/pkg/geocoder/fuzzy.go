@@ -0,0 +1,273 @@
+package geocoder
+
+/*
+	Fuzzy place-name matching with similarity scores.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Matching strategy (chosen automatically):
+	  - PostgreSQL + pg_trgm: trigram similarity() on name and asciiname,
+	    filtered with the % operator so that a GIN trigram index is used:
+	        CREATE EXTENSION IF NOT EXISTS pg_trgm;
+	        CREATE INDEX geoname_name_trgm_idx
+	            ON geoname USING gin (lower(name) gin_trgm_ops);
+	        CREATE INDEX geoname_asciiname_trgm_idx
+	            ON geoname USING gin (lower(asciiname) gin_trgm_ops);
+	  - Everything else: candidates sharing the first letter of the query
+	    are streamed from the database and scored in Go with the mean of
+	    Jaro-Winkler similarity and normalised Levenshtein similarity.
+	    A misspelt first letter is therefore never forgiven: "Sudad"
+	    does not find "Ciudad", although the two score 0.74. Scanning
+	    every name instead would read the whole geoname table per search.
+
+	Both scores range from 0 (nothing in common) to 1 (identical, ignoring
+	case). Trigram scores run lower than the Go ones for the same pair, so
+	a --min-score tuned on one backend may need adjusting on the other.
+*/
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rgglez/geonames-loader/examples/go/internal/sqlbuild"
+	"gorm.io/gorm"
+)
+
+// hasTrgm returns true if the pg_trgm extension is installed.
+func hasTrgm(db *gorm.DB) bool {
+	return capabilitiesFor(db).Trgm
+}
+
+// FuzzySearchPlaces returns places whose name or asciiname resembles name
+// with a score of at least minScore, best match first (ties broken by
+// population). The name filters of opts are ignored; Country,
+// FeatureClass, FeatureCode, Limit, Offset and the biases apply as in
+// SearchPlaces, except that Score keeps the similarity.
+func FuzzySearchPlaces(
+	db *gorm.DB, opts SearchOptions, name string, minScore float64,
+) ([]Place, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return nil, nil
+	}
+	base := SearchOptions{
+		Country:      opts.Country,
+		FeatureClass: opts.FeatureClass,
+		FeatureCode:  opts.FeatureCode,
+	}
+	fetch := opts
+	if opts.Biased() {
+		fetch = opts.candidates()
+	}
+	search := fuzzySearchGo
+	if IsPostgres(db) && hasTrgm(db) {
+		search = fuzzySearchTrgm
+	}
+	rows, err := search(db, base, name, minScore, fetch.Limit, fetch.Offset)
+	if err != nil || !opts.Biased() {
+		return rows, err
+	}
+	return opts.rerank(rows, func(r *Place) float64 { return r.Score }), nil
+}
+
+// fuzzySearchTrgm scores with pg_trgm. The % operator compares against
+// pg_trgm.similarity_threshold, which is set for this transaction only.
+func fuzzySearchTrgm(
+	db *gorm.DB, base SearchOptions, name string, minScore float64,
+	limit, offset int,
+) ([]Place, error) {
+	var rows []Place
+	q := new(sqlbuild.Select).
+		Columns("g", "geonameid", "name", "fclass", "fcode", "country",
+			"admin1", "admin2", "population", "latitude", "longitude").
+		Column(sqlbuild.E("GREATEST(similarity(lower(g.name), ?), "+
+			"similarity(lower(g.asciiname), ?)) AS score", name, name)).
+		From(TableName(db, "geoname"), "g")
+	rawSQL, args := base.filter(q).
+		Where("(lower(g.name) % ? OR lower(g.asciiname) % ?)", name, name).
+		OrderBy(sqlbuild.E("score DESC, COALESCE(g.population, 0) DESC, g.geonameid")).
+		Limit(limit).
+		Offset(offset).
+		Build()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// SET does not take bind parameters; minScore is a float in [0, 1].
+		err := tx.Exec(fmt.Sprintf(
+			"SET LOCAL pg_trgm.similarity_threshold = %g", minScore,
+		)).Error
+		if err != nil {
+			return err
+		}
+		return tx.Raw(rawSQL, args...).Scan(&rows).Error
+	})
+	return rows, err
+}
+
+// fuzzySearchGo scores candidates in Go. Only names starting with the same
+// letter as the query are considered, which keeps the scan bounded but
+// misses names whose first letter the query gets wrong.
+func fuzzySearchGo(
+	db *gorm.DB, base SearchOptions, name string, minScore float64,
+	limit, offset int,
+) ([]Place, error) {
+	first, _ := utf8.DecodeRuneInString(name)
+	base.NameStartsWith = string(first)
+	rawSQL, args := base.filter(new(sqlbuild.Select).
+		Columns("g", "geonameid", "name", "asciiname", "fclass", "fcode",
+			"country", "admin1", "admin2", "population", "latitude", "longitude").
+		From(TableName(db, "geoname"), "g")).
+		Build()
+
+	rows, err := db.Raw(rawSQL, args...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []Place
+	for rows.Next() {
+		var r struct {
+			Place
+			Asciiname string `gorm:"column:asciiname"`
+		}
+		if err := db.ScanRows(rows, &r); err != nil {
+			return nil, err
+		}
+		score := max(
+			nameSimilarity(name, strings.ToLower(r.Name)),
+			nameSimilarity(name, strings.ToLower(r.Asciiname)),
+		)
+		if score >= minScore {
+			r.Score = score
+			matches = append(matches, r.Place)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Population != b.Population {
+			return a.Population > b.Population
+		}
+		return a.Geonameid < b.Geonameid
+	})
+	if offset >= len(matches) {
+		return nil, nil
+	}
+	matches = matches[offset:]
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// ---------------------------------------------------------------------------
+// String similarity
+// ---------------------------------------------------------------------------
+
+// nameSimilarity is the mean of Jaro-Winkler and normalised Levenshtein
+// similarity: Jaro-Winkler rewards a shared prefix, Levenshtein penalises
+// every edit evenly.
+func nameSimilarity(a, b string) float64 {
+	if b == "" {
+		return 0
+	}
+	ra, rb := []rune(a), []rune(b)
+	lev := 1 - float64(levenshtein(ra, rb))/float64(max(len(ra), len(rb)))
+	return (jaroWinkler(ra, rb) + lev) / 2
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// jaroWinkler returns the Jaro-Winkler similarity of a and b, with the
+// usual prefix scale of 0.1 over at most four characters.
+func jaroWinkler(a, b []rune) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	window := max(len(a), len(b))/2 - 1
+	if window < 0 {
+		window = 0
+	}
+	matchedA := make([]bool, len(a))
+	matchedB := make([]bool, len(b))
+	matches := 0
+	for i := range a {
+		lo, hi := max(0, i-window), min(len(b), i+window+1)
+		for j := lo; j < hi; j++ {
+			if !matchedB[j] && a[i] == b[j] {
+				matchedA[i], matchedB[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+	transpositions, j := 0, 0
+	for i := range a {
+		if !matchedA[i] {
+			continue
+		}
+		for !matchedB[j] {
+			j++
+		}
+		if a[i] != b[j] {
+			transpositions++
+		}
+		j++
+	}
+	m := float64(matches)
+	jaro := (m/float64(len(a)) + m/float64(len(b)) +
+		(m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < min(4, len(a), len(b)) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
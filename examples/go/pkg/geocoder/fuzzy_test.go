@@ -0,0 +1,94 @@
+package geocoder
+
+/*
+	Tests of the string similarity of fuzzy searches.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLevenshtein(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"ciudad", "ciudad", 0},
+		{"kitten", "sitting", 3},
+		{"marhta", "martha", 2}, // a transposition is two edits
+		{"sudad", "ciudad", 2},
+		{"méxico", "mexico", 1}, // runes, not bytes
+		{"köln", "koln", 1},
+		{"東京", "東京都", 1},
+	} {
+		if got := levenshtein([]rune(c.a), []rune(c.b)); got != c.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", c.a, c.b, got, c.want)
+		}
+		if got := levenshtein([]rune(c.b), []rune(c.a)); got != c.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", c.b, c.a, got, c.want)
+		}
+	}
+}
+
+func TestJaroWinkler(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		want float64
+	}{
+		{"", "", 1},
+		{"abc", "", 0},
+		{"", "abc", 0},
+		{"ciudad", "ciudad", 1},
+		{"abc", "xyz", 0},
+		// The textbook pairs.
+		{"martha", "marhta", 0.9611},
+		{"dwayne", "duane", 0.84},
+		{"dixon", "dicksonx", 0.8133},
+		// With a window of 0, two runes match only in place.
+		{"ab", "ba", 0},
+		{"sudad", "ciudad", 0.8222},
+		{"méxico", "mexico", 0.9},
+		{"東京", "東京都", 0.9111},
+	} {
+		if got := jaroWinkler([]rune(c.a), []rune(c.b)); math.Abs(got-c.want) > 1e-4 {
+			t.Errorf("jaroWinkler(%q, %q) = %.4f, want %.4f", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestNameSimilarity(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		want float64
+	}{
+		{"guadalajara", "guadalajara", 1},
+		{"guadalajra", "guadalajara", 0.9355},
+		{"sudad", "ciudad", 0.7444},
+		{"köln", "koln", 0.8},
+		{"ciudad", "", 0},
+		{"", "", 0},
+	} {
+		if got := nameSimilarity(c.a, c.b); math.Abs(got-c.want) > 1e-4 {
+			t.Errorf("nameSimilarity(%q, %q) = %.4f, want %.4f", c.a, c.b, got, c.want)
+		}
+	}
+}
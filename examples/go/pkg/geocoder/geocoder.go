@@ -0,0 +1,306 @@
+package geocoder
+
+/*
+	Geocoder: a reusable handle on a GeoNames database.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	The query functions in query.go take a *gorm.DB and keep no state of
+	their own. A Geocoder bundles the connection with state worth keeping
+	between calls — a cache of administrative names, the strategy and
+	capability overrides, the registered zones — and is the entry point
+	for the higher-level lookups built on those functions.
+*/
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Geocoder answers lookups against one GeoNames database. It is safe for
+// concurrent use.
+type Geocoder struct {
+	db       *gorm.DB
+	admin    *adminCache
+	strategy string
+	caps     *Capabilities // override given with WithCapabilities
+	res      *resilience   // nil unless WithResilience was given
+	zones    *zoneSet      // see zones.go
+	cache    *ReverseCache // nil unless WithReverseCache was given
+	noPostal bool          // set by WithPostalCode(false)
+	// cacheScope prefixes the cache keys (see WithCacheScope).
+	cacheScope string
+	// offshoreKm is set by WithOffshoreKm (see offshore.go).
+	offshoreKm float64
+	// groups is set by WithFeatureGroups (see nearby.go).
+	groups []FeatureGroup
+}
+
+// Option configures a Geocoder.
+type Option func(*Geocoder)
+
+// WithStrategy forces the distance strategy (one of the Strategy*
+// constants) instead of detecting it from the database.
+func WithStrategy(s string) Option {
+	return func(g *Geocoder) { g.strategy = s }
+}
+
+// WithCapabilities skips the capability probe and uses c instead, for
+// databases whose catalogs the connecting role cannot read. It applies to
+// every Geocoder and query sharing the connection.
+func WithCapabilities(c Capabilities) Option {
+	return func(g *Geocoder) { g.caps = &c }
+}
+
+// WithPostalCode(false) leaves the Postalcode of NearestPlaces results
+// empty, sparing every query the nearest-postal subquery — the bulk of
+// its cost on MySQL and SQLite. NearestPostalForPlace resolves the postal
+// code of a single place on demand.
+func WithPostalCode(enabled bool) Option {
+	return func(g *Geocoder) { g.noPostal = !enabled }
+}
+
+// adminCache memoises LookupAdminNames by country.admin1.admin2 code.
+type adminCache struct {
+	mu    sync.Mutex
+	names map[string]AdminNames
+}
+
+// New returns a Geocoder using db. It does not check the schema of the
+// data: call CheckSchema once after connecting, or a database loaded for
+// another SchemaVersion fails with a missing column in the middle of a
+// query instead of with ErrSchemaVersion.
+func New(db *gorm.DB, opts ...Option) *Geocoder {
+	g := &Geocoder{
+		db:    db,
+		admin: &adminCache{names: map[string]AdminNames{}},
+		zones: &zoneSet{},
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	if g.caps != nil {
+		setCapabilities(db, *g.caps)
+	}
+	return g
+}
+
+// WithContext returns a Geocoder whose queries are bound to ctx. It shares
+// the caches of g.
+func (g *Geocoder) WithContext(ctx context.Context) *Geocoder {
+	c := *g
+	c.db = g.db.WithContext(ctx)
+	return &c
+}
+
+// Capabilities returns what the database supports. The catalogs are
+// probed once per connection; see capabilities.go.
+func (g *Geocoder) Capabilities() Capabilities {
+	return capabilitiesFor(g.db)
+}
+
+// DataStatus returns which tables of the database exist and hold rows.
+// The tables are checked once per connection; see data.go.
+func (g *Geocoder) DataStatus() DataStatus {
+	return dataStatusFor(g.db)
+}
+
+// Coverage returns the countries with places and with postal codes in the
+// database, probed at most every ten minutes; see coverage.go.
+func (g *Geocoder) Coverage() (*Coverage, error) {
+	return coverageFor(g.db)
+}
+
+// Strategy returns the distance strategy the Geocoder uses.
+func (g *Geocoder) Strategy() string {
+	return ResolveStrategy(g.db, g.strategy)
+}
+
+// DB returns the underlying connection.
+func (g *Geocoder) DB() *gorm.DB {
+	return g.db
+}
+
+// NearestPostal returns the postal codes nearest to (lat, lon), nearest
+// first, with their confidence (see postal.go). opts.Strategy defaults to
+// the Geocoder's strategy. When nothing is found the error explains why
+// (see result.go); without postal codes in the database it is a
+// *MissingDataError (see data.go).
+func (g *Geocoder) NearestPostal(lat, lon float64, opts QueryOptions) (Result[PostalCode], error) {
+	if err := requireData(g.db, "postalcodes"); err != nil {
+		return Result[PostalCode]{}, err
+	}
+	res, err := nearest(g, lat, lon, opts, queryPostal, "postalcodes", "countrycode",
+		func(r *PostalCode) float64 { return r.DistanceKm })
+	if err != nil {
+		return res, err
+	}
+	if opts.PostalExtent {
+		if res.Rows, err = g.postalExtents(res.Rows); err != nil {
+			return res, err
+		}
+	}
+	for i := range res.Rows {
+		r := &res.Rows[i]
+		r.Confidence = postalConfidence(r.Accuracy, r.Extent)
+	}
+	if opts.Rate {
+		ratePostal(res.Rows, opts)
+	}
+	return res, nil
+}
+
+// NearestPlaces is NearestPostal for geoname entries.
+func (g *Geocoder) NearestPlaces(lat, lon float64, opts QueryOptions) (Result[Place], error) {
+	if err := requireData(g.db, "geoname"); err != nil {
+		return Result[Place]{}, err
+	}
+	if g.noPostal || !dataStatusFor(g.db).Has("postalcodes") ||
+		uncovered(g.db, "postalcodes", opts.Country) != nil {
+		opts = opts.withoutPostalCode()
+	}
+	if opts.Links && len(opts.Fields) > 0 && !opts.selects("geonameid") {
+		// The links are looked up by geonameid.
+		opts.Fields = append(slices.Clip(opts.Fields), "geonameid")
+	}
+	limit, fields := opts.Limit, opts.Fields
+	if opts.Dedupe {
+		opts.Limit *= dedupeCandidates
+		for _, f := range dedupeFields {
+			if !opts.selects(f) {
+				opts.Fields = append(slices.Clip(opts.Fields), f)
+			}
+		}
+	}
+	res, err := nearest(g, lat, lon, opts, queryGeoname, "geoname", "country",
+		func(r *Place) float64 { return r.DistanceKm })
+	if err == nil && opts.Dedupe {
+		res.Rows = dedupePlaces(res.Rows, limit)
+		clearUnselected(res.Rows, fields)
+		opts.Fields = fields
+	}
+	if err == nil && opts.Links {
+		err = g.placeLinks(res.Rows)
+	}
+	if err == nil && opts.Rate {
+		ratePlaces(res.Rows, opts)
+	}
+	return res, err
+}
+
+// NearestPostalForPlace returns the postal code nearest to the geoname
+// entry geonameid within its own country: the Postalcode NearestPlaces
+// fills in unless WithPostalCode(false) was given. Places with no postal
+// code within the 500 km pre-filter radius yield ErrNoResultWithinRadius.
+func (g *Geocoder) NearestPostalForPlace(geonameid int64) (Result[PostalCode], error) {
+	var places []Place
+	err := g.db.Raw(`
+		SELECT geonameid, country, latitude, longitude
+		FROM `+TableName(g.db, "geoname")+`
+		WHERE geonameid = ?
+		  AND latitude IS NOT NULL AND longitude IS NOT NULL`, geonameid,
+	).Scan(&places).Error
+	if err != nil {
+		return Result[PostalCode]{}, err
+	}
+	if len(places) == 0 {
+		return Result[PostalCode]{}, fmt.Errorf("%w: geonameid %d", ErrPlaceNotFound, geonameid)
+	}
+	p := places[0]
+	if p.Country == "" {
+		return Result[PostalCode]{}, ErrNoResultWithinRadius
+	}
+	return g.NearestPostal(p.Latitude, p.Longitude, QueryOptions{
+		Limit: 1, Country: p.Country, MaxDistanceKm: geoRadiusM / 1000,
+	})
+}
+
+// nearest runs query under the Geocoder's strategy and resilience policy.
+// table and countryCol name the queried table for explainEmpty.
+func nearest[T any](
+	g *Geocoder, lat, lon float64, opts QueryOptions,
+	query func(*gorm.DB, float64, float64, QueryOptions) ([]T, error),
+	table, countryCol string, dist func(*T) float64,
+) (Result[T], error) {
+	if opts.Strategy == "" {
+		opts.Strategy = g.strategy
+	}
+	res := Result[T]{Strategy: ResolveStrategy(g.db, opts.Strategy)}
+	if err := CheckStrategy(g.db, opts.Strategy); err != nil {
+		return res, err
+	}
+	if err := uncovered(g.db, table, opts.Country); err != nil {
+		return res, err
+	}
+	start := time.Now()
+	key := fmt.Sprintf("%s|%g|%g|%+v", table, lat, lon, opts)
+	rows, err := guarded(g, key, func() ([]T, error) {
+		rows, err := cachedQuery(g.cache, g.cacheScope, table, lat, lon, opts, func() ([]T, error) {
+			return query(g.db, lat, lon, opts)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if rows = withinKm(rows, opts.MaxDistanceKm, dist); len(rows) == 0 {
+			return nil, explainEmpty(g.db, table, countryCol, opts.Country)
+		}
+		if err := g.checkOffshore(lat, lon, dist(&rows[0]), opts.Strategy); err != nil {
+			return nil, err
+		}
+		return rows, nil
+	})
+	setDirections(lat, lon, rows)
+	res.Rows, res.Duration = rows, time.Since(start)
+	return res, err
+}
+
+// Release drops the state kept for the connection of db — capabilities,
+// data status, coverage, table names and in-memory indexes — before it is
+// closed. Geocoders using it must not be used afterwards.
+func Release(db *gorm.DB) {
+	capCache.Delete(db.Config)
+	dataCache.Delete(db.Config)
+	coverageCache.Delete(db.Config)
+	memIndexes.Delete(db.Config)
+	tableCache.Delete(db.Config)
+	poiIndexes.Delete(db.Config)
+}
+
+// AdminNames is a cached LookupAdminNames.
+func (g *Geocoder) AdminNames(country, admin1, admin2 string) (AdminNames, error) {
+	key := country + "." + admin1 + "." + admin2
+	g.admin.mu.Lock()
+	n, ok := g.admin.names[key]
+	g.admin.mu.Unlock()
+	if ok {
+		return n, nil
+	}
+	n, err := LookupAdminNames(g.db, country, admin1, admin2)
+	if err != nil {
+		return n, err
+	}
+	g.admin.mu.Lock()
+	g.admin.names[key] = n
+	g.admin.mu.Unlock()
+	return n, nil
+}
@@ -0,0 +1,325 @@
+package geocoder
+
+/*
+	Geometry input: points in WKT, WKB or GeoJSON, and GeoJSON features.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	--point replaces --lat and --lon. ParsePoint tells the encodings apart
+	by their first characters: "{" is GeoJSON (a Point geometry or a Feature
+	holding one), a hexadecimal string is WKB (ISO or PostGIS EWKB, either
+	byte order), "lat,lon" is a plain pair and anything else WKT. WKT, WKB
+	and GeoJSON put the longitude first; Z and M values are ignored, and an
+	SRID, where the encoding has one, must be 4326.
+
+	--features reads a GeoJSON FeatureCollection (or a single Feature) from
+	a file, or standard input for "-", and writes it to standard output
+	with the nearest populated place of each Point feature added to its
+	properties, named like the columns of the enrich command:
+
+	    geo_place, geo_country, geo_admin1, geo_postalcode, geo_distance_km
+
+	Every other member of the features is kept. Features of other geometry
+	types pass through unchanged. All points are looked up with the batch
+	queries (see batch.go), so on PostgreSQL a file costs a couple of round
+	trips per thousand features; --country and --strategy apply. On a
+	database without postal codes, geo_postalcode is left null.
+
+	The command-line side is in cmd/reverse_geocode/geometry.go.
+*/
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParsePoint parses a point given as WKT, WKB (hex), GeoJSON or "lat,lon".
+func ParsePoint(s string) (Point, error) {
+	s = strings.TrimSpace(s)
+	var p Point
+	var err error
+	switch {
+	case s == "":
+		return p, errors.New("empty point")
+	case strings.HasPrefix(s, "{"):
+		var g geoJSON
+		if err := json.Unmarshal([]byte(s), &g); err != nil {
+			return p, fmt.Errorf("GeoJSON: %w", err)
+		}
+		p, err = g.point()
+	case isHex(s):
+		p, err = parseWKBPoint(s)
+	case strings.Contains(s, ",") && !strings.Contains(s, "("):
+		var pp *Point
+		if pp, err = parseLatLon(s); err == nil {
+			p = *pp
+		}
+	default:
+		p, err = parseWKTPoint(s)
+	}
+	if err != nil {
+		return p, err
+	}
+	if p.Lat < -90 || p.Lat > 90 || p.Lon < -180 || p.Lon > 180 ||
+		math.IsNaN(p.Lat) || math.IsNaN(p.Lon) {
+		return p, fmt.Errorf("coordinates out of range: %g, %g", p.Lat, p.Lon)
+	}
+	return p, nil
+}
+
+// isHex reports whether s could be hex WKB: a WKB point takes 21 bytes.
+func isHex(s string) bool {
+	if len(s) < 42 || len(s)%2 != 0 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// ---------------------------------------------------------------------------
+// WKT and WKB
+// ---------------------------------------------------------------------------
+
+// stripSRID removes an EWKT "SRID=n;" prefix, which must name 4326.
+func stripSRID(s string) (string, error) {
+	if i := strings.IndexByte(s, ';'); i >= 0 &&
+		strings.HasPrefix(strings.ToUpper(s), "SRID=") {
+		if srid := strings.TrimSpace(s[5:i]); srid != "4326" {
+			return "", fmt.Errorf("unsupported SRID %s (want 4326)", srid)
+		}
+		s = s[i+1:]
+	}
+	return s, nil
+}
+
+// parseWKTPoint parses a WKT POINT.
+func parseWKTPoint(s string) (Point, error) {
+	s, err := stripSRID(s)
+	if err != nil {
+		return Point{}, err
+	}
+	kind, body, ok := strings.Cut(strings.TrimSpace(s), "(")
+	kind = strings.ToUpper(strings.TrimSpace(kind))
+	kind = strings.TrimSpace(strings.TrimRight(kind, "ZM "))
+	if kind != "POINT" {
+		return Point{}, fmt.Errorf("unsupported WKT type %q (want POINT)", kind)
+	}
+	body, rest, closed := strings.Cut(body, ")")
+	if !ok || !closed || strings.TrimSpace(rest) != "" {
+		return Point{}, fmt.Errorf("WKT: bad point %q", s)
+	}
+	f := strings.Fields(body)
+	if len(f) < 2 || len(f) > 4 {
+		return Point{}, fmt.Errorf("WKT: bad point %q", s)
+	}
+	lon, err1 := strconv.ParseFloat(f[0], 64)
+	lat, err2 := strconv.ParseFloat(f[1], 64)
+	if err1 != nil || err2 != nil {
+		return Point{}, fmt.Errorf("WKT: bad point %q", s)
+	}
+	return Point{Lat: lat, Lon: lon}, nil
+}
+
+// WKB geometry type flags of PostGIS EWKB.
+const (
+	ewkbZ    = 0x80000000
+	ewkbM    = 0x40000000
+	ewkbSRID = 0x20000000
+)
+
+// parseWKBPoint parses a hex-encoded WKB or EWKB point.
+func parseWKBPoint(s string) (Point, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) < 5 {
+		return Point{}, fmt.Errorf("WKB: too short")
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	switch b[0] {
+	case 0:
+		order = binary.BigEndian
+	case 1:
+	default:
+		return Point{}, fmt.Errorf("WKB: bad byte order %d", b[0])
+	}
+	typ := order.Uint32(b[1:5])
+	b = b[5:]
+	if typ&ewkbSRID != 0 {
+		if len(b) < 4 {
+			return Point{}, fmt.Errorf("WKB: too short")
+		}
+		if srid := order.Uint32(b); srid != 4326 {
+			return Point{}, fmt.Errorf("unsupported SRID %d (want 4326)", srid)
+		}
+		b = b[4:]
+	}
+	// ISO WKB adds 1000, 2000 or 3000 for Z, M and ZM.
+	if base := (typ &^ (ewkbZ | ewkbM | ewkbSRID)) % 1000; base != 1 {
+		return Point{}, fmt.Errorf("unsupported WKB type %d (want Point)", base)
+	}
+	if len(b) < 16 {
+		return Point{}, fmt.Errorf("WKB: too short")
+	}
+	return Point{
+		Lon: math.Float64frombits(order.Uint64(b[0:8])),
+		Lat: math.Float64frombits(order.Uint64(b[8:16])),
+	}, nil
+}
+
+// ---------------------------------------------------------------------------
+// GeoJSON
+// ---------------------------------------------------------------------------
+
+// point reads a GeoJSON Point, or a Feature holding one.
+func (g *geoJSON) point() (Point, error) {
+	switch g.Type {
+	case "Point":
+		var c []float64
+		if err := json.Unmarshal(g.Coordinates, &c); err != nil {
+			return Point{}, fmt.Errorf("GeoJSON Point: %w", err)
+		}
+		if len(c) < 2 {
+			return Point{}, fmt.Errorf("GeoJSON: position with %d values", len(c))
+		}
+		return Point{Lat: c[1], Lon: c[0]}, nil
+	case "Feature":
+		if g.Geometry == nil {
+			return Point{}, fmt.Errorf("GeoJSON Feature without geometry")
+		}
+		return g.Geometry.point()
+	}
+	return Point{}, fmt.Errorf("unsupported GeoJSON type %q (want Point or Feature)", g.Type)
+}
+
+// ReverseFeatures reads the GeoJSON features of r, adds the nearest place
+// of each Point feature to its properties and writes them to w. It
+// returns the number of features annotated and skipped.
+func (g *Geocoder) ReverseFeatures(r io.Reader, w io.Writer, opts QueryOptions) (done, skipped int, err error) {
+	var doc map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return 0, 0, fmt.Errorf("GeoJSON: %w", err)
+	}
+	var typ string
+	_ = json.Unmarshal(doc["type"], &typ)
+	var features []map[string]json.RawMessage
+	switch typ {
+	case "FeatureCollection":
+		if err := json.Unmarshal(doc["features"], &features); err != nil {
+			return 0, 0, fmt.Errorf("GeoJSON features: %w", err)
+		}
+	case "Feature":
+		features = []map[string]json.RawMessage{doc}
+	default:
+		return 0, 0, fmt.Errorf("unsupported GeoJSON type %q (want Feature or FeatureCollection)", typ)
+	}
+
+	// Collect the Point features.
+	var points []Point
+	var index []int
+	for i, f := range features {
+		var geom geoJSON
+		if json.Unmarshal(f["geometry"], &geom) != nil || geom.Type != "Point" {
+			skipped++
+			continue
+		}
+		p, err := geom.point()
+		if err != nil {
+			return 0, 0, fmt.Errorf("feature %d: %w", i+1, err)
+		}
+		points, index = append(points, p), append(index, i)
+	}
+	if len(points) == 0 {
+		return 0, skipped, writeFeatures(w, doc, typ, features)
+	}
+
+	placeOpts := opts
+	placeOpts.Limit, placeOpts.FeatureClass = 1, "P"
+	places, err := g.NearestPlacesBatch(points, placeOpts)
+	if err != nil {
+		return 0, 0, err
+	}
+	// Without postal codes loaded the geo_postalcode members stay null.
+	var postal Result[[]PostalCode]
+	if dataStatusFor(g.db).Has("postalcodes") {
+		postalOpts := opts
+		postalOpts.Limit = 1
+		postal, err = g.NearestPostalBatch(points, postalOpts)
+		if err != nil && !IsNoResult(err) {
+			return 0, 0, err
+		}
+	}
+
+	for n, i := range index {
+		props := map[string]any{}
+		if raw := features[i]["properties"]; len(raw) > 0 && string(raw) != "null" {
+			if err := json.Unmarshal(raw, &props); err != nil {
+				return 0, 0, fmt.Errorf("feature %d properties: %w", i+1, err)
+			}
+		}
+		props["geo_place"], props["geo_country"], props["geo_admin1"] = nil, nil, nil
+		props["geo_postalcode"], props["geo_distance_km"] = nil, nil
+		if rows := places.Rows[n]; len(rows) > 0 {
+			p := rows[0]
+			names, err := g.AdminNames(p.Country, p.Admin1, "")
+			if err != nil {
+				return 0, 0, err
+			}
+			props["geo_place"], props["geo_country"] = p.Name, p.Country
+			props["geo_admin1"], props["geo_distance_km"] = names.Admin1, p.DistanceKm
+		}
+		if n < len(postal.Rows) && len(postal.Rows[n]) > 0 {
+			props["geo_postalcode"] = postal.Rows[n][0].Postalcode
+		}
+		raw, err := json.Marshal(props)
+		if err != nil {
+			return 0, 0, err
+		}
+		features[i]["properties"] = raw
+		done++
+	}
+
+	return done, skipped, writeFeatures(w, doc, typ, features)
+}
+
+// writeFeatures writes features to w as the document doc of type typ
+// they were read from. A collection without features is written with an
+// empty array.
+func writeFeatures(w io.Writer, doc map[string]json.RawMessage, typ string, features []map[string]json.RawMessage) error {
+	if typ != "FeatureCollection" {
+		return json.NewEncoder(w).Encode(features[0])
+	}
+	if features == nil {
+		features = []map[string]json.RawMessage{}
+	}
+	raw, err := json.Marshal(features)
+	if err != nil {
+		return err
+	}
+	doc["features"] = raw
+	return json.NewEncoder(w).Encode(doc)
+}
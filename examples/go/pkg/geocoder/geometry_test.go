@@ -0,0 +1,126 @@
+package geocoder
+
+/*
+	Tests of the geometry input.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParsePoint(t *testing.T) {
+	const lat, lon = 19.4326, -99.1332
+	for _, c := range []struct {
+		in string
+		// err is a substring of the error, or "" for lat, lon.
+		err string
+	}{
+		{"19.4326,-99.1332", ""},
+		{" 19.4326 , -99.1332 ", ""},
+		{"POINT(-99.1332 19.4326)", ""},
+		{"point ( -99.1332 19.4326 )", ""},
+		{"POINT Z (-99.1332 19.4326 2240)", ""},
+		{"SRID=4326;POINT(-99.1332 19.4326)", ""},
+		{`{"type":"Point","coordinates":[-99.1332,19.4326]}`, ""},
+		{`{"type":"Feature","geometry":{"type":"Point","coordinates":[-99.1332,19.4326,2240]}}`, ""},
+		// WKB little and big endian, EWKB with SRID, ISO WKB Point Z.
+		{"0101000000f1f44a5986c858c0e63fa4dfbe6e3340", ""},
+		{"0000000001c058c886594af4f140336ebedfa43fe6", ""},
+		{"0101000020e6100000f1f44a5986c858c0e63fa4dfbe6e3340", ""},
+		{"01e9030000f1f44a5986c858c0e63fa4dfbe6e3340000000000080a140", ""},
+
+		{"", "empty point"},
+		{"91,0", "out of range"},
+		{"0,181", "out of range"},
+		{"LINESTRING(0 0, 1 1)", "unsupported WKT type"},
+		{"POINT(-99.1332)", "bad point"},
+		{"POINT(-99.1332 19.4326", "bad point"},
+		{"POINT(-99.1332 19.4326) x", "bad point"},
+		{"POINT(west north)", "bad point"},
+		{"SRID=3857;POINT(0 0)", "unsupported SRID 3857"},
+		{`{"type":"Point","coordinates":[-99.1332]}`, "position with 1 values"},
+		{`{"type":"Feature"}`, "without geometry"},
+		{`{"type":"Polygon","coordinates":[]}`, "unsupported GeoJSON type"},
+		{`{"type":`, "GeoJSON"},
+		{"0102000000f1f44a5986c858c0e63fa4dfbe6e3340", "unsupported WKB type 2"},
+		{"0201000000f1f44a5986c858c0e63fa4dfbe6e3340", "bad byte order 2"},
+		{"0101000020110f0000f1f44a5986c858c0e63fa4dfbe6e3340", "unsupported SRID 3857"},
+		{"0101000020e6100000f1f44a5986c858c000000000", "too short"},
+	} {
+		p, err := ParsePoint(c.in)
+		switch {
+		case c.err == "" && err != nil:
+			t.Errorf("%q: %v", c.in, err)
+		case c.err == "" && (math.Abs(p.Lat-lat) > 1e-9 || math.Abs(p.Lon-lon) > 1e-9):
+			t.Errorf("%q = %+v, want %g, %g", c.in, p, lat, lon)
+		case c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)):
+			t.Errorf("%q: error %v, want %q", c.in, err, c.err)
+		}
+	}
+}
+
+// TestParseWKBPointShort checks the lengths isHex lets through to
+// parseWKBPoint.
+func TestParseWKBPointShort(t *testing.T) {
+	for _, in := range []string{"", "01", "0101000000", "0101000000f1f44a5986c858c0", "zz"} {
+		if _, err := parseWKBPoint(in); err == nil || !strings.Contains(err.Error(), "too short") {
+			t.Errorf("%q: error %v, want too short", in, err)
+		}
+	}
+}
+
+// TestReverseFeaturesEmpty checks that documents without Point features
+// are written back unchanged, without querying the database.
+func TestReverseFeaturesEmpty(t *testing.T) {
+	for _, c := range []struct{ in, want string }{
+		{`{"type":"FeatureCollection","features":[]}`, `{"features":[],"type":"FeatureCollection"}`},
+		{`{"type":"FeatureCollection","features":null}`, `{"features":[],"type":"FeatureCollection"}`},
+		{
+			`{"type":"Feature","geometry":{"type":"LineString","coordinates":[[0,0],[1,1]]}}`,
+			`{"geometry":{"type":"LineString","coordinates":[[0,0],[1,1]]},"type":"Feature"}`,
+		},
+	} {
+		var out bytes.Buffer
+		done, _, err := new(Geocoder).ReverseFeatures(strings.NewReader(c.in), &out, QueryOptions{})
+		if err != nil {
+			t.Errorf("%s: %v", c.in, err)
+			continue
+		}
+		if got := strings.TrimSpace(out.String()); done != 0 || got != c.want {
+			t.Errorf("%s: wrote %s (%d done), want %s", c.in, got, done, c.want)
+		}
+	}
+}
+
+func TestReverseFeaturesErrors(t *testing.T) {
+	for _, c := range []struct{ in, err string }{
+		{`{"type":"Point","coordinates":[0,0]}`, "unsupported GeoJSON type"},
+		{`{"type":"FeatureCollection","features":{}}`, "GeoJSON features"},
+		{`{"type":"FeatureCollection","features":[{"type":"Feature","geometry":{"type":"Point","coordinates":[0]}}]}`,
+			"feature 1"},
+		{`[`, "GeoJSON"},
+	} {
+		_, _, err := new(Geocoder).ReverseFeatures(strings.NewReader(c.in), new(bytes.Buffer), QueryOptions{})
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: error %v, want %q", c.in, err, c.err)
+		}
+	}
+}
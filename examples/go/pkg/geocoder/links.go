@@ -0,0 +1,125 @@
+package geocoder
+
+/*
+	Wikipedia and Wikidata cross-references of places.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    go run . --lat 19.4326 --lon -99.1332 --with-links
+	    curl 'http://localhost:8080/reverse?lat=19.4326&lon=-99.1332&links=1'
+
+	Besides names, the alternatename table holds pseudo-languages: rows
+	whose isolanguage is "link" carry a URL, mostly of a Wikipedia article,
+	and rows whose isolanguage is "wkdt" the Wikidata item (Q-number) of
+	the place. With QueryOptions.Links, NearestPlaces reads them for the
+	places found, in one query, and sets
+
+	  Wikipedia  the English Wikipedia article, or the first Wikipedia
+	             article in another language when there is none
+	  Wikidata   the Wikidata QID, e.g. Q1489
+
+	Places without such rows keep the fields empty. The rows are loaded
+	with the alternate names (or alone with "load --links-only"); a
+	database without the alternatename table fails the lookup.
+*/
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rgglez/geonames-loader/examples/go/internal/sqlbuild"
+)
+
+// Pseudo-languages of the alternatename rows read for Links.
+const (
+	linkLanguage     = "link"
+	wikidataLanguage = "wkdt"
+)
+
+// linkRow is an alternatename row read by placeLinks.
+type linkRow struct {
+	Geonameid     int64  `gorm:"column:geonameid"`
+	Isolanguage   string `gorm:"column:isolanguage"`
+	Alternatename string `gorm:"column:alternatename"`
+}
+
+// linksSQL returns the query for the link and wkdt rows of ids in the
+// alternatename table (named table).
+func linksSQL(table string, ids []any) (string, []any) {
+	return new(sqlbuild.Select).
+		Columns("", "geonameid", "isolanguage", "alternatename").
+		From(table, "").
+		Where("geonameid IN (?"+strings.Repeat(", ?", len(ids)-1)+")", ids...).
+		Where("isolanguage IN (?, ?)", linkLanguage, wikidataLanguage).
+		OrderBy(sqlbuild.E("geonameid, alternatenameid")).
+		Build()
+}
+
+// placeLinks sets the Wikipedia and Wikidata fields of rows. Without
+// alternate names they are left empty.
+func (g *Geocoder) placeLinks(rows []Place) error {
+	if !dataStatusFor(g.db).Has("alternatename") {
+		return nil
+	}
+	var ids []any
+	for _, r := range rows {
+		if r.Geonameid != 0 {
+			ids = append(ids, r.Geonameid)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	var links []linkRow
+	rawSQL, args := linksSQL(TableName(g.db, "alternatename"), ids)
+	if err := g.db.Raw(rawSQL, args...).Scan(&links).Error; err != nil {
+		return fmt.Errorf("reading links: %w", err)
+	}
+
+	wikipedia := map[int64]string{}
+	wikidata := map[int64]string{}
+	for _, l := range links {
+		switch {
+		case l.Isolanguage == wikidataLanguage:
+			if wikidata[l.Geonameid] == "" {
+				wikidata[l.Geonameid] = l.Alternatename
+			}
+		case isWikipedia(l.Alternatename):
+			cur := wikipedia[l.Geonameid]
+			if cur == "" || !isEnglishWikipedia(cur) && isEnglishWikipedia(l.Alternatename) {
+				wikipedia[l.Geonameid] = l.Alternatename
+			}
+		}
+	}
+	for i := range rows {
+		rows[i].Wikipedia = wikipedia[rows[i].Geonameid]
+		rows[i].Wikidata = wikidata[rows[i].Geonameid]
+	}
+	return nil
+}
+
+// isWikipedia reports whether url is a Wikipedia article.
+func isWikipedia(url string) bool {
+	return strings.Contains(url, ".wikipedia.org/")
+}
+
+// isEnglishWikipedia reports whether url is an English Wikipedia article.
+func isEnglishWikipedia(url string) bool {
+	return strings.Contains(url, "//en.wikipedia.org/")
+}
@@ -0,0 +1,124 @@
+package geocoder
+
+/*
+	Distance and bearing matrix between the nearest places.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    curl 'http://localhost:8080/reverse/matrix?lat=19.4326&lon=-99.1332&results=5'
+
+	Geocoder.PlaceMatrix runs NearestPlaces and then, in Go, measures the
+	leg from the query point to every place and between every pair of
+	places, so a map can draw direction indicators without doing geodesy
+	itself:
+
+	  From[i]            distance and bearing from the query point to place i
+	  DistancesKm[i][j]  distance from place i to place j
+	  Bearings[i][j]     initial bearing from place i to place j
+
+	Distances are ellipsoidal (VincentyKm, see distance.go), falling back
+	to the sphere for nearly antipodal points, so From[i].DistanceKm can
+	differ slightly from the DistanceKm the strategy returned. Bearings
+	are InitialBearing (see bearing.go); the course back from j to i is
+	not b + 180 in general. The diagonal holds 0 distances and bearings.
+
+	The matrix has n² cells, so the server caps n with max_results.
+*/
+
+import (
+	"time"
+)
+
+// Leg is the distance and initial bearing from one point to another.
+type Leg struct {
+	DistanceKm float64 `json:"distance_km"`
+	Bearing    float64 `json:"bearing"`
+	// Distance is DistanceKm in the requested unit, when one was asked for.
+	Distance float64 `json:"distance,omitempty"`
+}
+
+// MatrixResult is the answer of PlaceMatrix.
+type MatrixResult struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Places    []Place `json:"places"`
+	// From holds the leg from the query point to each place.
+	From []Leg `json:"from"`
+	// DistancesKm and Bearings hold the legs from place i to place j.
+	DistancesKm [][]float64 `json:"distances_km"`
+	Bearings    [][]float64 `json:"bearings"`
+	// Units and Distances are DistancesKm in the requested unit, when one
+	// was asked for.
+	Units     string        `json:"units,omitempty"`
+	Distances [][]float64   `json:"distances,omitempty"`
+	Strategy  string        `json:"strategy"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// PlaceMatrix returns the places nearest to (lat, lon), as NearestPlaces
+// does, with the distances and bearings from the point to each and
+// between each pair.
+func (g *Geocoder) PlaceMatrix(lat, lon float64, opts QueryOptions) (MatrixResult, error) {
+	res, err := g.NearestPlaces(lat, lon, opts)
+	if err != nil {
+		return MatrixResult{}, err
+	}
+	m := MatrixResult{
+		Latitude: lat, Longitude: lon, Places: res.Rows,
+		Strategy: res.Strategy, Duration: res.Duration,
+	}
+	points := make([]Point, len(res.Rows))
+	m.From = make([]Leg, len(res.Rows))
+	for i, r := range res.Rows {
+		points[i] = Point{Lat: r.Latitude, Lon: r.Longitude}
+		m.From[i] = LegTo(Point{Lat: lat, Lon: lon}, points[i])
+	}
+	m.DistancesKm, m.Bearings = DistanceMatrix(points)
+	return m, nil
+}
+
+// DistanceMatrix returns the distances in kilometres and the initial
+// bearings from each of points to each other.
+func DistanceMatrix(points []Point) (km, bearings [][]float64) {
+	n := len(points)
+	km = make([][]float64, n)
+	bearings = make([][]float64, n)
+	for i := range points {
+		km[i] = make([]float64, n)
+		bearings[i] = make([]float64, n)
+	}
+	for i := range points {
+		for j := i + 1; j < n; j++ {
+			leg := LegTo(points[i], points[j])
+			km[i][j], km[j][i] = leg.DistanceKm, leg.DistanceKm
+			bearings[i][j] = leg.Bearing
+			bearings[j][i] = InitialBearing(points[j].Lat, points[j].Lon, points[i].Lat, points[i].Lon)
+		}
+	}
+	return km, bearings
+}
+
+// LegTo returns the leg from a to b.
+func LegTo(a, b Point) Leg {
+	km, ok := VincentyKm(a.Lat, a.Lon, b.Lat, b.Lon)
+	if !ok {
+		km = haversineKm(a.Lat, a.Lon, b.Lat, b.Lon)
+	}
+	return Leg{DistanceKm: km, Bearing: InitialBearing(a.Lat, a.Lon, b.Lat, b.Lon)}
+}
@@ -0,0 +1,193 @@
+package geocoder
+
+/*
+	In-memory nearest-neighbour index (the "memory" distance strategy).
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	The first query using the memory strategy loads every postalcodes and
+	geoname row with coordinates into RAM and builds a k-d tree over their
+	positions as unit vectors on the sphere (internal/kdtree). Straight-line
+	(chord) distance between unit vectors grows monotonically with
+	great-circle distance, so an ordinary 3-D nearest-neighbour search
+	gives exact Haversine results without any database round trip.
+
+	Like the PostgreSQL strategies, a query returns no row farther than
+	geoRadiusM (500 km, or MaxDistanceKm when smaller), and like every SQL
+	strategy, the postal code of a place is the nearest of its country
+	within degRadius degrees of latitude and longitude.
+
+	The load takes a while and the full planet needs several GB of RAM;
+	the strategy is meant for benchmarking and for databases loaded with a
+	few countries. Indexes are cached per connection for the life of the
+	process.
+*/
+
+import (
+	"math"
+	"slices"
+	"sync"
+
+	"github.com/rgglez/geonames-loader/examples/go/internal/kdtree"
+	"gorm.io/gorm"
+)
+
+// unitVector returns the position of (lat, lon) on the unit sphere.
+func unitVector(lat, lon float64) [3]float64 {
+	return kdtree.UnitVector(lat, lon)
+}
+
+// chordToKm converts a squared chord length between unit vectors to a
+// great-circle distance in kilometres.
+func chordToKm(chordSq float64) float64 {
+	return earthRadiusKm * kdtree.ChordAngle(chordSq)
+}
+
+// ---------------------------------------------------------------------------
+// Index
+// ---------------------------------------------------------------------------
+
+// memIndex holds the postalcodes and geoname tables in memory.
+type memIndex struct {
+	postal     []PostalCode
+	places     []Place
+	postalTree *kdtree.Tree
+	placeTree  *kdtree.Tree
+}
+
+// memIndexes caches one memIndex per connection. gorm sessions derived
+// from one gorm.Open (WithContext, Session) share the *gorm.Config, which
+// makes it a convenient key.
+var memIndexes sync.Map // *gorm.Config → *memIndexEntry
+
+type memIndexEntry struct {
+	once sync.Once
+	idx  *memIndex
+	err  error
+}
+
+// LoadMemoryIndex loads the in-memory index of db now rather than on the
+// first query using the memory strategy.
+func LoadMemoryIndex(db *gorm.DB) error {
+	_, err := memoryIndexFor(db)
+	return err
+}
+
+// memoryIndexFor returns the in-memory index of db, loading it on first use.
+func memoryIndexFor(db *gorm.DB) (*memIndex, error) {
+	v, _ := memIndexes.LoadOrStore(db.Config, &memIndexEntry{})
+	e := v.(*memIndexEntry)
+	e.once.Do(func() { e.idx, e.err = loadMemIndex(db) })
+	return e.idx, e.err
+}
+
+func loadMemIndex(db *gorm.DB) (*memIndex, error) {
+	m := &memIndex{}
+	err := db.Raw(`
+		SELECT countrycode, postalcode, placename,
+		       admin1name, admin2name, admin3name, accuracy,
+		       latitude, longitude
+		FROM ` + TableName(db, "postalcodes") + `
+		WHERE latitude IS NOT NULL AND longitude IS NOT NULL`).Scan(&m.postal).Error
+	if err != nil {
+		return nil, err
+	}
+	err = db.Raw(`
+		SELECT geonameid, name, fclass, fcode, country,
+		       admin1, admin2, population, latitude, longitude
+		FROM ` + TableName(db, "geoname") + `
+		WHERE latitude IS NOT NULL AND longitude IS NOT NULL`).Scan(&m.places).Error
+	if err != nil {
+		return nil, err
+	}
+
+	pts := make([][3]float64, len(m.postal))
+	for i, p := range m.postal {
+		pts[i] = unitVector(p.Latitude, p.Longitude)
+	}
+	m.postalTree = kdtree.New(pts)
+	pts = make([][3]float64, len(m.places))
+	for i, g := range m.places {
+		pts[i] = unitVector(g.Latitude, g.Longitude)
+	}
+	m.placeTree = kdtree.New(pts)
+	return m, nil
+}
+
+// hitsWithin drops the hits farther than opts.radiusM().
+func hitsWithin(hits []kdtree.Hit, opts QueryOptions) []kdtree.Hit {
+	maxKm := opts.radiusM() / 1000
+	for i, h := range hits {
+		if chordToKm(h.Dist) > maxKm {
+			return hits[:i]
+		}
+	}
+	return hits
+}
+
+func (m *memIndex) nearestPostal(lat, lon float64, opts QueryOptions) []PostalCode {
+	hits := m.postalTree.Nearest(unitVector(lat, lon), opts.Limit, func(i int32) bool {
+		return opts.Country == "" || m.postal[i].Countrycode == opts.Country
+	})
+	hits = hitsWithin(hits, opts)
+	out := make([]PostalCode, len(hits))
+	for n, h := range hits {
+		out[n] = m.postal[h.I]
+		out[n].DistanceKm = chordToKm(h.Dist)
+	}
+	return out
+}
+
+// postalForPlace returns the postal code of the country of g nearest to
+// it within degRadius degrees, as the SQL strategies' subquery does.
+func (m *memIndex) postalForPlace(g *Place) string {
+	hits := m.postalTree.Nearest(unitVector(g.Latitude, g.Longitude), 1, func(i int32) bool {
+		p := &m.postal[i]
+		return p.Countrycode == g.Country &&
+			math.Abs(p.Latitude-g.Latitude) <= degRadius &&
+			math.Abs(p.Longitude-g.Longitude) <= degRadius
+	})
+	if len(hits) == 0 {
+		return ""
+	}
+	return m.postal[hits[0].I].Postalcode
+}
+
+// nearestGeoname also fills Postalcode with the nearest postal code of the
+// place's own country, like the SQL strategies, when opts selects it.
+func (m *memIndex) nearestGeoname(lat, lon float64, opts QueryOptions) []Place {
+	hits := m.placeTree.Nearest(unitVector(lat, lon), opts.Limit, func(i int32) bool {
+		g := &m.places[i]
+		return (opts.Country == "" || g.Country == opts.Country) &&
+			(opts.FeatureClass == "" || g.Fclass == opts.FeatureClass) &&
+			(len(opts.FeatureCodes) == 0 || slices.Contains(opts.FeatureCodes, g.Fcode)) &&
+			g.Population >= opts.MinPopulation &&
+			opts.inBBox(g.Latitude, g.Longitude)
+	})
+	hits = hitsWithin(hits, opts)
+	out := make([]Place, len(hits))
+	for n, h := range hits {
+		g := m.places[h.I]
+		g.DistanceKm = chordToKm(h.Dist)
+		if opts.selects("postalcode") {
+			g.Postalcode = m.postalForPlace(&g)
+		}
+		out[n] = g
+	}
+	return out
+}
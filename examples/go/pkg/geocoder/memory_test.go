@@ -0,0 +1,83 @@
+package geocoder
+
+/*
+	Tests of the memory strategy.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/rgglez/geonames-loader/examples/go/internal/kdtree"
+)
+
+// testMemIndex returns the index of places and postal, built as
+// loadMemIndex does.
+func testMemIndex(places []Place, postal []PostalCode) *memIndex {
+	m := &memIndex{places: places, postal: postal}
+	pts := make([][3]float64, len(postal))
+	for i, p := range postal {
+		pts[i] = unitVector(p.Latitude, p.Longitude)
+	}
+	m.postalTree = kdtree.New(pts)
+	pts = make([][3]float64, len(places))
+	for i, g := range places {
+		pts[i] = unitVector(g.Latitude, g.Longitude)
+	}
+	m.placeTree = kdtree.New(pts)
+	return m
+}
+
+// TestMemoryRadius checks that the memory strategy applies the radii of
+// the SQL strategies: geoRadiusM to the rows and degRadius to the postal
+// code of each place.
+func TestMemoryRadius(t *testing.T) {
+	m := testMemIndex([]Place{
+		{Geonameid: 1, Country: "MX", Latitude: 19.43, Longitude: -99.13},  // Mexico City
+		{Geonameid: 2, Country: "MX", Latitude: 21.17, Longitude: -86.85},  // Cancún
+		{Geonameid: 3, Country: "MX", Latitude: 20.67, Longitude: -103.39}, // Guadalajara
+	}, []PostalCode{
+		{Countrycode: "MX", Postalcode: "06000", Latitude: 19.43, Longitude: -99.14},
+	})
+	places := func(lat, lon float64, opts QueryOptions) string {
+		var out []string
+		for _, g := range m.nearestGeoname(lat, lon, opts) {
+			out = append(out, fmt.Sprintf("%d %s", g.Geonameid, g.Postalcode))
+		}
+		return fmt.Sprint(out)
+	}
+	for _, c := range []struct {
+		name     string
+		lat, lon float64
+		opts     QueryOptions
+		want     string
+	}{
+		{"postal code within reach", 19.43, -99.13, QueryOptions{Limit: 1}, "[1 06000]"},
+		{"postal code out of reach", 21.17, -86.85, QueryOptions{Limit: 1}, "[2 ]"},
+		{"beyond 500 km", 19.43, -99.13, QueryOptions{Limit: 3}, "[1 06000 3 06000]"},
+		{"beyond MaxDistanceKm", 19.43, -99.13, QueryOptions{Limit: 3, MaxDistanceKm: 10}, "[1 06000]"},
+		{"nothing within 500 km", 25, -92, QueryOptions{Limit: 1}, "[]"},
+	} {
+		if got := places(c.lat, c.lon, c.opts); got != c.want {
+			t.Errorf("%s: places %s, want %s", c.name, got, c.want)
+		}
+	}
+	if got := m.nearestPostal(21.17, -86.85, QueryOptions{Limit: 1}); len(got) != 0 {
+		t.Errorf("postal codes beyond 500 km: %+v, want none", got)
+	}
+}
@@ -0,0 +1,172 @@
+package geocoder
+
+/*
+	Nearest feature of each kind: "what's around this point".
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    go run . --lat 20.6767 --lon -103.3475 --nearby
+	    curl 'http://localhost:8080/reverse/nearby?lat=20.6767&lon=-103.3475'
+
+	Config (server section; the command line reads it too):
+	    nearby:
+	      - {name: city, class: P, min_population: 15000}
+	      - {name: airport, class: S, codes: [AIRP]}
+	      - {name: hospital, class: S, codes: [HSP, HSPC]}
+
+	Nearby answers a dashboard-style question in one call: the nearest
+	city, airport, body of water and mountain of a point. Each
+	FeatureGroup is a feature class, optionally narrowed to some feature
+	codes and a least population; DefaultFeatureGroups are used unless
+	WithFeatureGroups gives others.
+
+	The groups are looked up concurrently, one NearestPlaces query with
+	Limit 1 each, so every strategy, the lookup cache and the resilience
+	policy apply to them as to any other lookup; on a connection pool the
+	call takes about as long as its slowest group. The nearest postal
+	code of each place is not looked up, and the offshore check does not
+	apply: a point at sea still gets its nearest airport.
+
+	A group with nothing in range — no mountains loaded, or none within
+	the 500 km pre-filter radius of the PostgreSQL strategies — comes back
+	with no Place; any other error fails the whole call.
+*/
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// FeatureGroup is a kind of feature Nearby looks for.
+type FeatureGroup struct {
+	Name string `yaml:"name" json:"name"`
+	// Class is the GeoNames feature class (A, H, L, P, R, S, T, U or V).
+	Class string `yaml:"class" json:"class"`
+	// Codes narrows the class to these feature codes; empty takes them all.
+	Codes []string `yaml:"codes" json:"codes,omitempty"`
+	// MinPopulation drops places with fewer inhabitants.
+	MinPopulation int64 `yaml:"min_population" json:"min_population,omitempty"`
+}
+
+// DefaultFeatureGroups are the groups of Nearby unless WithFeatureGroups
+// gives others.
+var DefaultFeatureGroups = []FeatureGroup{
+	{Name: "city", Class: "P", MinPopulation: 15000},
+	{Name: "airport", Class: "S", Codes: []string{"AIRP"}},
+	{Name: "water", Class: "H", Codes: []string{
+		"LK", "LKS", "LKI", "LKN", "RSV", "LGN", "STM", "BAY", "GULF", "SEA", "OCN",
+	}},
+	{Name: "mountain", Class: "T", Codes: []string{"MT", "MTS", "PK", "PKS", "VLC"}},
+}
+
+var (
+	featureClassRE = regexp.MustCompile(`^[AHLPRSTUV]$`)
+	featureCodeRE  = regexp.MustCompile(`^[A-Z0-9]{1,10}$`)
+)
+
+// CheckFeatureGroups reports the first group without a name, with the
+// name of an earlier one, or with an unknown class or malformed code.
+func CheckFeatureGroups(groups []FeatureGroup) error {
+	for i, fg := range groups {
+		switch {
+		case fg.Name == "":
+			return fmt.Errorf("feature group %d: no name", i+1)
+		case slices.ContainsFunc(groups[:i], func(o FeatureGroup) bool { return o.Name == fg.Name }):
+			return fmt.Errorf("feature group %q: duplicate name", fg.Name)
+		case !featureClassRE.MatchString(fg.Class):
+			return fmt.Errorf("feature group %q: invalid class %q (want one of A, H, L, P, R, S, T, U, V)",
+				fg.Name, fg.Class)
+		case fg.MinPopulation < 0:
+			return fmt.Errorf("feature group %q: negative min_population", fg.Name)
+		}
+		for _, c := range fg.Codes {
+			if !featureCodeRE.MatchString(c) {
+				return fmt.Errorf("feature group %q: invalid feature code %q", fg.Name, c)
+			}
+		}
+	}
+	return nil
+}
+
+// WithFeatureGroups sets the groups of Nearby; they must pass
+// CheckFeatureGroups.
+func WithFeatureGroups(groups []FeatureGroup) Option {
+	return func(g *Geocoder) { g.groups = slices.Clone(groups) }
+}
+
+// NearbyFeature is the nearest place of one group, nil when there is none.
+type NearbyFeature struct {
+	Group string `json:"group"`
+	Place *Place `json:"place"`
+}
+
+// NearbyResult is the answer of Nearby.
+type NearbyResult struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	// Features holds one entry per group, in the order of the groups.
+	Features []NearbyFeature `json:"features"`
+	Strategy string          `json:"strategy"`
+	Duration time.Duration   `json:"duration"`
+}
+
+// FeatureGroups returns the groups of Nearby.
+func (g *Geocoder) FeatureGroups() []FeatureGroup {
+	if g.groups == nil {
+		return DefaultFeatureGroups
+	}
+	return g.groups
+}
+
+// Nearby returns the nearest place of each feature group to (lat, lon).
+func (g *Geocoder) Nearby(lat, lon float64) (*NearbyResult, error) {
+	groups := g.FeatureGroups()
+	res := &NearbyResult{
+		Latitude: lat, Longitude: lon,
+		Features: make([]NearbyFeature, len(groups)),
+		Strategy: ResolveStrategy(g.db, g.strategy),
+	}
+	start := time.Now()
+	// The offshore check is about populated places, not these.
+	c := *g
+	c.offshoreKm = 0
+	fns := make([]func() error, len(groups))
+	for i, fg := range groups {
+		res.Features[i].Group = fg.Name
+		fns[i] = func() error {
+			places, err := c.NearestPlaces(lat, lon, QueryOptions{
+				Limit: 1, FeatureClass: fg.Class, FeatureCodes: fg.Codes,
+				MinPopulation: fg.MinPopulation,
+			}.withoutPostalCode())
+			switch {
+			case IsNoResult(err):
+				return nil
+			case err != nil:
+				return fmt.Errorf("%s: %w", fg.Name, err)
+			}
+			res.Features[i].Place = &places.Rows[0]
+			return nil
+		}
+	}
+	err := parallel(fns...)
+	res.Duration = time.Since(start)
+	return res, err
+}
@@ -0,0 +1,48 @@
+package geocoder
+
+/*
+	Checks that the module builds without cgo.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Only the SQLite driver needs cgo; PostgreSQL and MySQL users, and
+	cross-compiled or static binaries, build with CGO_ENABLED=0. The
+	test runs the go command, so -short skips it.
+*/
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func TestBuildWithoutCgo(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go build")
+	}
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("no go command in PATH")
+	}
+	cmd := exec.Command(gobin, "build", "./...")
+	cmd.Dir = "../.."
+	cmd.Env = append(os.Environ(), "CGO_ENABLED=0")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("CGO_ENABLED=0 go build ./...: %v\n%s", err, out)
+	}
+}
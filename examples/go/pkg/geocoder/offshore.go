@@ -0,0 +1,148 @@
+package geocoder
+
+/*
+	Offshore points: no place nearby.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    go run . --lat 24.5 --lon -90.0                    # Gulf of Mexico
+	    go run . --lat 24.5 --lon -90.0 --offshore-km 0    # old behaviour
+
+	A proximity query always finds something: a point in the middle of
+	the Gulf of Mexico gets a town on the coast 480 km away, as if it were
+	there. With WithOffshoreKm (--offshore-km on the command line,
+	offshore_km in the server section; 100 km by default for both), a
+	lookup whose nearest row is farther than that checks for a populated
+	place (feature class P) within the distance, and when there is none
+	returns an *OffshoreError instead of the rows. It carries
+
+	  Nearest       the nearest populated place — the coast, for a point
+	                at sea — with its distance
+	  MarineRegion  the nearest ocean, sea, gulf, bay, strait... (feature
+	                class H, see marineCodes), when one is loaded
+
+	GeoNames has no coastlines, so "offshore" means no populated place
+	within the distance: the open sea mostly, but also an ice sheet, a
+	desert or a country that was not loaded. The error matches both
+	ErrOffshore and ErrNoResultWithinRadius. On PostgreSQL the 500 km
+	pre-filter radius applies to both probes, so Nearest and MarineRegion
+	are nil for points farther than that from any.
+
+	Points near a populated place pay nothing: the probe only runs when
+	the first row of a lookup is already farther than the distance.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrOffshore matches the *OffshoreError of a point with no populated
+// place nearby.
+var ErrOffshore = errors.New("geocoder: point offshore")
+
+// DefaultOffshoreKm is the offshore distance of the command line and the
+// server.
+const DefaultOffshoreKm = 100
+
+// marineCodes are the feature codes of class H that name an area of sea.
+var marineCodes = []string{
+	"OCN", "SEA", "GULF", "BAY", "BAYS", "BGHT", "SD", "STRT", "STRTS",
+	"CHNM", "FJD", "FJDS", "LGN", "COVE", "INLT",
+}
+
+// marineCandidates is how many of the nearest class-H features are
+// searched for a marine one; rivers and lakes on the coast come first.
+const marineCandidates = 50
+
+// OffshoreError reports a point with no populated place within the
+// Geocoder's offshore distance. It matches ErrOffshore and
+// ErrNoResultWithinRadius.
+type OffshoreError struct {
+	// Nearest is the nearest populated place, if any is within reach of
+	// the query (see above).
+	Nearest *Place `json:"nearest_place"`
+	// MarineRegion is the nearest ocean, sea, gulf... if one is loaded.
+	MarineRegion *Place `json:"marine_region,omitempty"`
+}
+
+func (e *OffshoreError) Error() string {
+	msg := "geocoder: point offshore"
+	if e.Nearest != nil {
+		msg += fmt.Sprintf(", %.0f km from %s (%s)",
+			e.Nearest.DistanceKm, e.Nearest.Name, e.Nearest.Country)
+	}
+	if e.MarineRegion != nil {
+		msg += ", in " + e.MarineRegion.Name
+	}
+	return msg
+}
+
+func (e *OffshoreError) Is(target error) bool {
+	return target == ErrOffshore || target == ErrNoResultWithinRadius
+}
+
+// WithOffshoreKm makes lookups return an *OffshoreError for points with no
+// populated place within km; zero, the default, turns the check off.
+func WithOffshoreKm(km float64) Option {
+	return func(g *Geocoder) { g.offshoreKm = max(km, 0) }
+}
+
+// offshore returns the *OffshoreError of (lat, lon), or nil when a
+// populated place is within the offshore distance.
+func (g *Geocoder) offshore(lat, lon float64, strategy string) (*OffshoreError, error) {
+	opts := QueryOptions{Limit: 1, FeatureClass: "P", Strategy: strategy}.withoutPostalCode()
+	places, err := queryGeoname(g.db, lat, lon, opts)
+	if err != nil {
+		return nil, err
+	}
+	off := &OffshoreError{}
+	if len(places) > 0 {
+		if places[0].DistanceKm <= g.offshoreKm {
+			return nil, nil
+		}
+		off.Nearest = &places[0]
+	}
+	opts.Limit, opts.FeatureClass = marineCandidates, "H"
+	water, err := queryGeoname(g.db, lat, lon, opts)
+	if err != nil {
+		return nil, err
+	}
+	if i := slices.IndexFunc(water, func(r Place) bool {
+		return slices.Contains(marineCodes, r.Fcode)
+	}); i >= 0 {
+		off.MarineRegion = &water[i]
+	}
+	return off, nil
+}
+
+// checkOffshore returns the *OffshoreError of (lat, lon) when nearestKm,
+// the distance of a lookup's first row, is past the offshore distance
+// and no populated place is closer.
+func (g *Geocoder) checkOffshore(lat, lon, nearestKm float64, strategy string) error {
+	if g.offshoreKm <= 0 || nearestKm <= g.offshoreKm {
+		return nil
+	}
+	off, err := g.offshore(lat, lon, strategy)
+	if err != nil || off == nil {
+		return err
+	}
+	return off
+}
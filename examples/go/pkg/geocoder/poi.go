@@ -0,0 +1,383 @@
+package geocoder
+
+/*
+	Custom points of interest ("poi" subcommand).
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Your own places — stores, depots, sensors — can be kept next to the
+	GeoNames data in a poi table (id, name, category, latitude, longitude,
+	metadata) and looked up like them. "poi import" creates the table
+	when it is missing and loads a CSV file with a header row:
+
+	  name                        required
+	  lat or latitude             required
+	  lon, lng or longitude       required
+	  category                    optional; --category fills it in
+	  metadata                    optional, a JSON object
+	  any other column            added to metadata as a string
+
+	so "name,lat,lon,store_id,phone" stores {"phone": ..., "store_id":
+	...} as each row's metadata. The file is loaded in one transaction;
+	--replace empties the table first. The load subcommand and
+	load_geonames.py leave the table alone.
+
+	Geocoder.NearestPOI(lat, lon, category, opts) returns the nearest
+	rows of one category ("" for all) with the strategy of the other
+	lookups: PostGIS and earthdistance (GIST indexes on the table are
+	created by the import where the extensions are available), the
+	Haversine scan, and the memory strategy, which keeps the table in its
+	own k-d tree, read on first use and again after an import by the same
+	process. The rtree strategy of snapshots has no R*Tree for the table
+	and scans it. POI lookups bypass the lookup cache, as the table
+	changes with each import, and are not checked for offshore points
+	(see offshore.go). In reverse results the --poi nearest POIs
+	(?poi= on /reverse) are listed next to the places.
+
+	The command-line side is in cmd/reverse_geocode/poi.go.
+*/
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rgglez/geonames-loader/examples/go/internal/kdtree"
+	"github.com/rgglez/geonames-loader/examples/go/internal/sqlbuild"
+	"gorm.io/gorm"
+)
+
+// POI holds one row from the poi proximity query.
+type POI struct {
+	ID        int64   `gorm:"column:id"        json:"id"`
+	Name      string  `gorm:"column:name"      json:"name"`
+	Category  string  `gorm:"column:category"  json:"category,omitempty"`
+	Latitude  float64 `gorm:"column:latitude"  json:"latitude"`
+	Longitude float64 `gorm:"column:longitude" json:"longitude"`
+	// Metadata is the JSON object imported with the row.
+	Metadata   poiMetadata `gorm:"column:metadata"    json:"metadata,omitempty"`
+	DistanceKm float64     `gorm:"column:distance_km" json:"distance_km"`
+	// Distance is DistanceKm in the requested unit, when one was asked for.
+	Distance float64 `gorm:"-" json:"distance,omitempty"`
+	// Bearing and Direction locate the row from the query point (see
+	// bearing.go).
+	Bearing   float64 `gorm:"-" json:"bearing"`
+	Direction string  `gorm:"-" json:"direction,omitempty"`
+}
+
+// poiMetadata is the metadata column, stored as JSON text and written to
+// JSON output as the object itself.
+type poiMetadata []byte
+
+// Scan implements sql.Scanner; drivers return TEXT as string or []byte.
+func (m *poiMetadata) Scan(v any) error {
+	switch v := v.(type) {
+	case nil:
+		*m = nil
+	case string:
+		*m = poiMetadata(v)
+	case []byte:
+		*m = append(poiMetadata(nil), v...)
+	default:
+		return fmt.Errorf("metadata: unexpected %T", v)
+	}
+	return nil
+}
+
+func (m poiMetadata) MarshalJSON() ([]byte, error) {
+	if len(m) == 0 {
+		return []byte("null"), nil
+	}
+	return m, nil
+}
+
+// poiColumns are the columns read by the POI queries.
+const poiColumns = "id, name, category, latitude, longitude, metadata"
+
+// NearestPOI returns the points of interest of category ("" for any)
+// nearest to (lat, lon), nearest first. opts.Country, FeatureClass and
+// Fields do not apply.
+func (g *Geocoder) NearestPOI(lat, lon float64, category string, opts QueryOptions) (Result[POI], error) {
+	opts.Category = category
+	opts.Country, opts.FeatureClass, opts.Fields = "", "", nil
+	// Neither cached nor checked for offshore points (see above).
+	plain := *g
+	plain.cache, plain.offshoreKm = nil, 0
+	res, err := nearest(&plain, lat, lon, opts, queryPOI, "poi", "",
+		func(r *POI) float64 { return r.DistanceKm })
+	if err != nil && !IsNoResult(err) && !g.db.Migrator().HasTable(TableName(g.db, "poi")) {
+		return res, errors.New("the database has no poi table — run poi import")
+	}
+	return res, err
+}
+
+// nearestPOISQL returns the query for the rows of the poi table (named
+// table) nearest to (lat, lon) under strategy; rtree has no R*Tree for
+// the table and runs the Haversine scan.
+func nearestPOISQL(strategy, table string, lat, lon float64, opts QueryOptions) (string, []any) {
+	dist, within := proximityExprs(strategy, "", lat, lon, opts.radiusM())
+	q := new(sqlbuild.Select).
+		Column(sqlbuild.E(poiColumns)).
+		Column(dist.As("distance_km")).
+		From(table, "")
+	if within.SQL != "" {
+		q.WhereExpr(within)
+	}
+	return q.WhereIf(opts.Category != "", "category = ?", opts.Category).
+		OrderBy(sqlbuild.E("distance_km")).
+		Limit(opts.Limit).
+		Build()
+}
+
+func queryPOI(db *gorm.DB, lat, lon float64, opts QueryOptions) ([]POI, error) {
+	strategy := ResolveStrategy(db, opts.Strategy)
+	if strategy == StrategyMemory {
+		idx, err := poiIndexFor(db)
+		if err != nil {
+			return nil, err
+		}
+		return idx.nearest(lat, lon, opts), nil
+	}
+	var rows []POI
+	rawSQL, args := nearestPOISQL(strategy, TableName(db, "poi"), lat, lon, opts)
+	err := db.Raw(rawSQL, args...).Scan(&rows).Error
+	return rows, err
+}
+
+// ---------------------------------------------------------------------------
+// In-memory index
+// ---------------------------------------------------------------------------
+
+// poiIndex is the k-d tree of the poi table for the memory strategy.
+type poiIndex struct {
+	rows []POI
+	tree *kdtree.Tree
+}
+
+// poiIndexes caches one poiIndex per connection, like memIndexes.
+var poiIndexes sync.Map // *gorm.Config → *poiIndexEntry
+
+type poiIndexEntry struct {
+	once sync.Once
+	idx  *poiIndex
+	err  error
+}
+
+// poiIndexFor returns the POI index of db, loading it on first use.
+func poiIndexFor(db *gorm.DB) (*poiIndex, error) {
+	v, _ := poiIndexes.LoadOrStore(db.Config, &poiIndexEntry{})
+	e := v.(*poiIndexEntry)
+	e.once.Do(func() {
+		idx := &poiIndex{}
+		e.err = db.Raw("SELECT " + poiColumns + " FROM " + TableName(db, "poi")).
+			Scan(&idx.rows).Error
+		pts := make([][3]float64, len(idx.rows))
+		for i, r := range idx.rows {
+			pts[i] = unitVector(r.Latitude, r.Longitude)
+		}
+		idx.tree = kdtree.New(pts)
+		e.idx = idx
+	})
+	if e.err != nil {
+		// Let the next lookup try again, e.g. once the table is imported.
+		poiIndexes.CompareAndDelete(db.Config, e)
+	}
+	return e.idx, e.err
+}
+
+func (p *poiIndex) nearest(lat, lon float64, opts QueryOptions) []POI {
+	hits := p.tree.Nearest(unitVector(lat, lon), opts.Limit, func(i int32) bool {
+		return opts.Category == "" || p.rows[i].Category == opts.Category
+	})
+	out := make([]POI, len(hits))
+	for n, h := range hits {
+		out[n] = p.rows[h.I]
+		out[n].DistanceKm = chordToKm(h.Dist)
+	}
+	return out
+}
+
+// ---------------------------------------------------------------------------
+// Import
+// ---------------------------------------------------------------------------
+
+// poiLoadColumns are the columns the import writes.
+var poiLoadColumns = []string{"name", "category", "latitude", "longitude", "metadata"}
+
+// poiTableDDL returns the statements creating the poi table and its
+// indexes.
+func poiTableDDL(db *gorm.DB) []string {
+	t := TableName(db, "poi")
+	id, float := "id INTEGER PRIMARY KEY", "REAL"
+	switch db.Dialector.Name() {
+	case "postgres":
+		id, float = "id BIGSERIAL PRIMARY KEY", "DOUBLE PRECISION"
+	case "mysql":
+		id, float = "id BIGINT AUTO_INCREMENT PRIMARY KEY", "DOUBLE"
+	}
+	return []string{
+		fmt.Sprintf(`CREATE TABLE %s (
+			%s,
+			name      VARCHAR(200) NOT NULL,
+			category  VARCHAR(100),
+			latitude  %s NOT NULL,
+			longitude %s NOT NULL,
+			metadata  TEXT
+		)`, t, id, float, float),
+		fmt.Sprintf("CREATE INDEX poi_category_idx ON %s (category)", t),
+		fmt.Sprintf("CREATE INDEX poi_latitude_longitude_idx ON %s (latitude, longitude)", t),
+	}
+}
+
+// createPOITable creates the poi table, with the GIST indexes of the
+// PostgreSQL strategies where the extensions allow.
+func createPOITable(db *gorm.DB) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range poiTableDDL(tx) {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil || !IsPostgres(db) {
+		return err
+	}
+	t := TableName(db, "poi")
+	idx := []string{"CREATE INDEX poi_geo_idx ON " + t +
+		" USING GIST (ll_to_earth(latitude, longitude))"}
+	if HasPostGIS(db) {
+		idx = append(idx, "CREATE INDEX poi_postgis_idx ON "+t+
+			" USING GIST ((ST_MakePoint(longitude, latitude)::geography))")
+	}
+	for _, stmt := range idx {
+		if err := db.Exec(stmt).Error; err != nil {
+			fmt.Printf("  [poi: GIST index skipped: %v]\n", err)
+		}
+	}
+	return nil
+}
+
+// ReadPOICSV reads the rows of a POI CSV file as the values of
+// poiLoadColumns.
+func ReadPOICSV(r io.Reader, category string) ([][]any, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+	col := func(names ...string) int {
+		return slices.IndexFunc(header, func(h string) bool {
+			return slices.Contains(names, strings.ToLower(strings.TrimSpace(h)))
+		})
+	}
+	nameCol, latCol, lonCol := col("name"), col("lat", "latitude"), col("lon", "lng", "longitude")
+	catCol, metaCol := col("category"), col("metadata")
+	if nameCol < 0 || latCol < 0 || lonCol < 0 {
+		return nil, errors.New("header: name, lat and lon columns are required")
+	}
+	var rows [][]any
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		lat, err1 := strconv.ParseFloat(rec[latCol], 64)
+		lon, err2 := strconv.ParseFloat(rec[lonCol], 64)
+		if err := errors.Join(err1, err2); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if math.Abs(lat) > 90 || math.Abs(lon) > 180 {
+			return nil, fmt.Errorf("line %d: coordinates %g, %g out of range", line, lat, lon)
+		}
+		if rec[nameCol] == "" {
+			return nil, fmt.Errorf("line %d: empty name", line)
+		}
+		meta := map[string]any{}
+		if metaCol >= 0 && rec[metaCol] != "" {
+			if err := json.Unmarshal([]byte(rec[metaCol]), &meta); err != nil {
+				return nil, fmt.Errorf("line %d: metadata: %w", line, err)
+			}
+		}
+		for i, h := range header {
+			if i != nameCol && i != latCol && i != lonCol && i != catCol && i != metaCol && rec[i] != "" {
+				meta[h] = rec[i]
+			}
+		}
+		var metaJSON any
+		if len(meta) > 0 {
+			b, err := json.Marshal(meta)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: metadata: %w", line, err)
+			}
+			metaJSON = string(b)
+		}
+		var cat any
+		switch {
+		case catCol >= 0 && rec[catCol] != "":
+			cat = rec[catCol]
+		case category != "":
+			cat = category
+		}
+		rows = append(rows, []any{rec[nameCol], cat, lat, lon, metaJSON})
+	}
+}
+
+// ImportPOI loads rows into the poi table, creating it when missing and
+// emptying it first with replace.
+func ImportPOI(db *gorm.DB, rows [][]any, replace bool) error {
+	if !db.Migrator().HasTable(TableName(db, "poi")) {
+		if err := createPOITable(db); err != nil {
+			return err
+		}
+	}
+	defer poiIndexes.Delete(db.Config)
+	dialect := db.Dialector.Name()
+	batch := sqlbuild.MaxParams / len(poiLoadColumns)
+	return db.Transaction(func(tx *gorm.DB) error {
+		if replace {
+			if err := tx.Exec("DELETE FROM " + TableName(tx, "poi")).Error; err != nil {
+				return err
+			}
+		}
+		for len(rows) > 0 {
+			chunk := rows[:min(len(rows), batch)]
+			rows = rows[len(chunk):]
+			args := make([]any, 0, len(chunk)*len(poiLoadColumns))
+			for _, r := range chunk {
+				args = append(args, r...)
+			}
+			stmt := sqlbuild.Insert(dialect, TableName(tx, "poi"), poiLoadColumns, len(chunk))
+			if err := tx.Exec(stmt, args...).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
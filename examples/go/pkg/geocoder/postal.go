@@ -0,0 +1,159 @@
+package geocoder
+
+/*
+	Postal-code confidence and extents.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    go run . --lat 19.4326 --lon -99.1332 --postal-extent
+	    curl 'http://localhost:8080/reverse?lat=19.4326&lon=-99.1332&postal_extent=1'
+
+	A postal code is an area, but GeoNames gives it as points: one per
+	code, or one per place sharing the code (Mexican codes cover several
+	colonias, each its own row), with an accuracy column saying how each
+	point was placed:
+
+	  6  centroid of the addresses or of the shape
+	  4  coordinates of a GeoNames place (geonameid)
+	  1  estimated
+
+	NearestPostal turns it into Confidence, between 0 and 1: 0.9, 0.7 and
+	0.4 for the values above and 0.5 when the column is empty. With
+	QueryOptions.PostalExtent it also reads every row of each code found,
+	and
+
+	  - reports them as the code's Extent: the number of rows, their
+	    centroid, bounding box and spread (half the box's diagonal);
+	  - lowers the confidence as the spread grows — halved at 25 km —
+	    since the point returned is then only one corner of the area;
+	  - keeps one row per code, the nearest, so a code that comes as five
+	    colonias is not listed five times (fewer rows than asked for may
+	    come back).
+
+	The extent is a box around points, not the code's boundary, which
+	GeoNames does not have. The centroid is a plain average of the
+	coordinates, good for codes that do not cross the antimeridian.
+*/
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// PostalExtent describes all the rows of one postal code.
+type PostalExtent struct {
+	Rows int `json:"rows"`
+	// Latitude and Longitude are the centroid of the rows.
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	// BBox is the bounding box of the rows: min lon, min lat, max lon,
+	// max lat.
+	BBox [4]float64 `json:"bbox"`
+	// SpreadKm is half the diagonal of BBox.
+	SpreadKm float64 `json:"spread_km"`
+}
+
+// postalExtentRow is one row of the extent query.
+type postalExtentRow struct {
+	Countrycode string  `gorm:"column:countrycode"`
+	Postalcode  string  `gorm:"column:postalcode"`
+	N           int     `gorm:"column:n"`
+	Lat         float64 `gorm:"column:lat"`
+	Lon         float64 `gorm:"column:lon"`
+	MinLat      float64 `gorm:"column:min_lat"`
+	MinLon      float64 `gorm:"column:min_lon"`
+	MaxLat      float64 `gorm:"column:max_lat"`
+	MaxLon      float64 `gorm:"column:max_lon"`
+}
+
+// accuracyConfidence maps the GeoNames accuracy column to a confidence.
+var accuracyConfidence = map[int]float64{6: 0.9, 4: 0.7, 1: 0.4}
+
+// postalSpreadHalfKm is the spread that halves a code's confidence.
+const postalSpreadHalfKm = 25
+
+// postalConfidence returns the confidence of a row of accuracy acc in a
+// code of extent ext (nil when unknown).
+func postalConfidence(acc int, ext *PostalExtent) float64 {
+	c, ok := accuracyConfidence[acc]
+	if !ok {
+		c = 0.5
+	}
+	if ext != nil {
+		c /= 1 + ext.SpreadKm/postalSpreadHalfKm
+	}
+	return math.Round(c*100) / 100
+}
+
+// postalExtents fills in the Extent of rows, keeping the first (nearest)
+// row of each code.
+func (g *Geocoder) postalExtents(rows []PostalCode) ([]PostalCode, error) {
+	var conds []string
+	var args []any
+	seen := map[[2]string]bool{}
+	out := rows[:0:0]
+	for _, r := range rows {
+		k := [2]string{r.Countrycode, r.Postalcode}
+		if r.Postalcode == "" {
+			// Not selected: nothing to group by.
+			out = append(out, r)
+			continue
+		}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		out = append(out, r)
+		conds = append(conds, "(countrycode = ? AND postalcode = ?)")
+		args = append(args, r.Countrycode, r.Postalcode)
+	}
+	if len(conds) == 0 {
+		return out, nil
+	}
+	var ext []postalExtentRow
+	err := g.db.Raw(fmt.Sprintf(`
+		SELECT countrycode, postalcode, COUNT(*) AS n,
+		       AVG(latitude) AS lat, AVG(longitude) AS lon,
+		       MIN(latitude) AS min_lat, MIN(longitude) AS min_lon,
+		       MAX(latitude) AS max_lat, MAX(longitude) AS max_lon
+		FROM %s
+		WHERE latitude IS NOT NULL AND longitude IS NOT NULL
+		  AND (%s)
+		GROUP BY countrycode, postalcode`,
+		TableName(g.db, "postalcodes"), strings.Join(conds, " OR ")), args...,
+	).Scan(&ext).Error
+	if err != nil {
+		return rows, err
+	}
+	byCode := make(map[[2]string]*PostalExtent, len(ext))
+	for _, e := range ext {
+		byCode[[2]string{e.Countrycode, e.Postalcode}] = &PostalExtent{
+			Rows:      e.N,
+			Latitude:  e.Lat,
+			Longitude: e.Lon,
+			BBox:      [4]float64{e.MinLon, e.MinLat, e.MaxLon, e.MaxLat},
+			SpreadKm:  haversineKm(e.MinLat, e.MinLon, e.MaxLat, e.MaxLon) / 2,
+		}
+	}
+	for i := range out {
+		out[i].Extent = byCode[[2]string{out[i].Countrycode, out[i].Postalcode}]
+	}
+	return out, nil
+}
@@ -0,0 +1,543 @@
+package geocoder
+
+/*
+	Proximity queries: the result types, QueryOptions and the SQL behind
+	each distance strategy.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Distance strategy (chosen automatically by dialect, or forced with
+	WithStrategy; see strategy.go):
+	  - PostgreSQL + Ganos (ganos_spatialref): uses ST_DWithin / ST_Distance
+	    with a GIST index on ST_MakePoint(longitude, latitude)::geography
+	    (preferred on Aliyun Apsara RDS for PostgreSQL).
+	  - PostgreSQL + PostGIS: uses ST_DWithin / ST_Distance with a GIST
+	    index on ST_MakePoint(longitude, latitude)::geography (preferred
+	    when the PostGIS extension is installed).
+	  - PostgreSQL (no Ganos/PostGIS): uses earthdistance + GIST index
+	    (fast KNN via earth_box).
+	  All PostgreSQL strategies require load_geonames.py to have been run
+	  without --skip-indexes.
+	  - MySQL/MariaDB, SQLite: Haversine formula executed entirely in SQL.
+	    Falls back to a full table scan (no GIST equivalent).
+	    SQLite requires CGO and math functions (SQLite >= 3.35).
+	  - StrategyMemory: nearest-neighbour search on an in-process k-d
+	    tree loaded from the database on first use (see memory.go).
+	  - SQLite snapshots: expanding bounding box search on R*Tree tables
+	    (see snapshot.go).
+
+	Callers normally go through Geocoder (geocoder.go) rather than the
+	query functions here.
+*/
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/rgglez/geonames-loader/examples/go/internal/sqlbuild"
+	"gorm.io/gorm"
+)
+
+// ---------------------------------------------------------------------------
+// Constants
+// ---------------------------------------------------------------------------
+
+const (
+	earthRadiusKm = sqlbuild.EarthRadiusKm
+	// geoRadiusM is the earth_box() / ST_DWithin() pre-filter radius.
+	// Increase if the nearest result could be farther than this distance.
+	geoRadiusM = 500_000 // 500 km
+	// degRadius is the approximate degree equivalent of geoRadiusM
+	// (1° ≈ 111 320 m at the equator). Used as a bounding-box pre-filter on
+	// lat/lon columns to let the DB use the composite B-tree index
+	// (countrycode, latitude, longitude) before computing haversine ordering.
+	degRadius = geoRadiusM / 111_320.0 // ≈ 4.5°
+)
+
+// IsPostgres reports whether db is a PostgreSQL connection.
+func IsPostgres(db *gorm.DB) bool {
+	return db.Dialector.Name() == "postgres"
+}
+
+// The has* helpers below read the cached capability probe (see
+// capabilities.go); only the first call per connection hits the catalogs.
+
+// HasPostGIS returns true if the postgis extension is installed.
+func HasPostGIS(db *gorm.DB) bool {
+	return capabilitiesFor(db).PostGIS
+}
+
+// HasGanos returns true if the ganos_spatialref extension is installed.
+func HasGanos(db *gorm.DB) bool {
+	return capabilitiesFor(db).Ganos
+}
+
+// hasGeographyType returns true if the 'geography' PostgreSQL type is actually
+// registered in pg_type.
+//
+// Checking for the extension alone (ganos_spatialref or postgis) is not
+// sufficient: on some Aliyun Apsara RDS configurations ganos_spatialref is
+// present but the geography type is absent because ganos_geometry was not
+// installed with CASCADE.  The ::geography cast — used in all ST_DWithin /
+// ST_Distance queries and indexes — raises a SyntaxError if the type is
+// missing.  This function is the real gate for the geography-based strategy.
+func hasGeographyType(db *gorm.DB) bool {
+	return capabilitiesFor(db).GeographyType
+}
+
+// ---------------------------------------------------------------------------
+// Result types
+// ---------------------------------------------------------------------------
+
+// PostalCode holds one row from the postalcodes proximity query.
+type PostalCode struct {
+	Countrycode string  `gorm:"column:countrycode" json:"countrycode"`
+	Postalcode  string  `gorm:"column:postalcode"  json:"postalcode"`
+	Placename   string  `gorm:"column:placename"   json:"placename"`
+	Admin1name  string  `gorm:"column:admin1name"  json:"admin1name,omitempty"`
+	Admin2name  string  `gorm:"column:admin2name"  json:"admin2name,omitempty"`
+	Admin3name  string  `gorm:"column:admin3name"  json:"admin3name,omitempty"`
+	Latitude    float64 `gorm:"column:latitude"    json:"latitude"`
+	Longitude   float64 `gorm:"column:longitude"   json:"longitude"`
+	Accuracy    int     `gorm:"column:accuracy"    json:"accuracy,omitempty"`
+	DistanceKm  float64 `gorm:"column:distance_km" json:"distance_km"`
+	// Distance is DistanceKm in the requested unit, when one was asked for.
+	Distance float64 `gorm:"-" json:"distance,omitempty"`
+	// Bearing and Direction locate the row from the query point (see
+	// bearing.go).
+	Bearing   float64 `gorm:"-" json:"bearing"`
+	Direction string  `gorm:"-" json:"direction,omitempty"`
+	// Confidence and Extent are filled in by Geocoder.NearestPostal (see
+	// postal.go).
+	Confidence float64       `gorm:"-" json:"confidence,omitempty"`
+	Extent     *PostalExtent `gorm:"-" json:"extent,omitempty"`
+	// Rating is set with QueryOptions.Rate (see confidence.go).
+	Rating *Rating `gorm:"-" json:"rating,omitempty"`
+}
+
+// Place holds one row from the geoname proximity query.
+type Place struct {
+	Geonameid  int64   `gorm:"column:geonameid"   json:"geonameid"`
+	Name       string  `gorm:"column:name"        json:"name"`
+	Fclass     string  `gorm:"column:fclass"      json:"fclass"`
+	Fcode      string  `gorm:"column:fcode"       json:"fcode"`
+	Country    string  `gorm:"column:country"     json:"country"`
+	Admin1     string  `gorm:"column:admin1"      json:"admin1,omitempty"`
+	Admin2     string  `gorm:"column:admin2"      json:"admin2,omitempty"`
+	Population int64   `gorm:"column:population"  json:"population"`
+	Latitude   float64 `gorm:"column:latitude"    json:"latitude"`
+	Longitude  float64 `gorm:"column:longitude"   json:"longitude"`
+	DistanceKm float64 `gorm:"column:distance_km" json:"distance_km"`
+	Postalcode string  `gorm:"column:postalcode"  json:"postalcode,omitempty"`
+	Score      float64 `gorm:"column:score"       json:"score,omitempty"`
+	// Distance is DistanceKm in the requested unit, when one was asked for.
+	Distance float64 `gorm:"-" json:"distance,omitempty"`
+	// Bearing and Direction locate the row from the query point (see
+	// bearing.go).
+	Bearing   float64 `gorm:"-" json:"bearing"`
+	Direction string  `gorm:"-" json:"direction,omitempty"`
+	// Wikipedia and Wikidata are filled in with QueryOptions.Links (see
+	// links.go).
+	Wikipedia string `gorm:"-" json:"wikipedia,omitempty"`
+	Wikidata  string `gorm:"-" json:"wikidata,omitempty"`
+	// Duplicates lists the geonameids of the rows of the same settlement
+	// this one stands for, with QueryOptions.Dedupe (see dedupe.go).
+	Duplicates []int64 `gorm:"-" json:"duplicates,omitempty"`
+	// Rating is set with QueryOptions.Rate (see confidence.go).
+	Rating *Rating `gorm:"-" json:"rating,omitempty"`
+}
+
+// ---------------------------------------------------------------------------
+// Query options
+// ---------------------------------------------------------------------------
+
+// QueryOptions narrows a proximity query. Empty filter fields do not
+// restrict the results.
+type QueryOptions struct {
+	Limit   int
+	Country string // ISO 3166-1 alpha-2 code
+	// FeatureClass restricts geoname results to one GeoNames feature class
+	// (A, H, L, P, R, S, T, U or V). Ignored by postal-code queries.
+	FeatureClass string
+	// FeatureCodes restricts geoname results to these feature codes (AIRP,
+	// LK, ...). Ignored by postal-code queries.
+	FeatureCodes []string
+	// MinPopulation drops geoname results with fewer inhabitants; zero
+	// keeps them all. Ignored by postal-code queries.
+	MinPopulation int64
+	// BBox restricts geoname results to a box (minlon, minlat, maxlon,
+	// maxlat, see parseBBox; minlon > maxlon crosses the antimeridian).
+	// Ignored by postal-code queries.
+	BBox []float64
+	// Category restricts POI results to one category (see poi.go).
+	Category string
+	// Strategy forces a distance strategy (see strategy.go); empty or
+	// "auto" detects one from the database.
+	Strategy string
+	// MaxDistanceKm drops results farther than this from the point. Zero
+	// keeps every result. Applied by the Geocoder methods only.
+	MaxDistanceKm float64
+	// PostalExtent reads all the rows of each postal code found, for its
+	// extent and confidence, and keeps one row per code (see postal.go).
+	// Applied by Geocoder.NearestPostal only.
+	PostalExtent bool
+	// Links reads the Wikipedia article and Wikidata QID of each place
+	// from the alternatename table (see links.go). Applied by
+	// Geocoder.NearestPlaces only.
+	Links bool
+	// Dedupe returns one row per settlement, folding the entries of a
+	// town, its sections and the division it is the seat of into one
+	// (see dedupe.go). Applied by Geocoder.NearestPlaces only.
+	Dedupe bool
+	// Rate adds a Rating to each row: a confidence between 0 and 1 and
+	// its factors (see confidence.go). Applied by the Geocoder methods
+	// only.
+	Rate bool
+	// Fields restricts the columns read to these result fields (JSON
+	// names, see PostalFields and PlaceFields); empty reads them all.
+	// Leaving out "postalcode" spares geoname queries the nearest-postal
+	// subquery.
+	Fields []string
+}
+
+// Selectable fields of the result types, in SELECT order. The coordinates
+// and the distance are always read; geoname's postalcode comes from the
+// nearest-postal subquery rather than a column.
+var (
+	PostalFields = []string{
+		"countrycode", "postalcode", "placename",
+		"admin1name", "admin2name", "admin3name", "accuracy",
+	}
+	PlaceFields = []string{
+		"geonameid", "name", "fclass", "fcode", "country",
+		"admin1", "admin2", "population",
+	}
+)
+
+// CheckFields reports the first of fields that no result type has.
+func CheckFields(fields []string) error {
+	for _, f := range fields {
+		if f != "latitude" && f != "longitude" && f != "postalcode" &&
+			!slices.Contains(PostalFields, f) && !slices.Contains(PlaceFields, f) {
+			return fmt.Errorf("unknown field %q", f)
+		}
+	}
+	return nil
+}
+
+// selects reports whether field is wanted.
+func (o QueryOptions) selects(field string) bool {
+	return len(o.Fields) == 0 || slices.Contains(o.Fields, field)
+}
+
+// columns returns the wanted columns of cols, then the coordinates.
+func (o QueryOptions) columns(cols []string) []string {
+	var out []string
+	for _, c := range cols {
+		if o.selects(c) {
+			out = append(out, c)
+		}
+	}
+	return append(out, "latitude", "longitude")
+}
+
+// withoutPostalCode returns o for a geoname query that skips the
+// nearest-postal subquery.
+func (o QueryOptions) withoutPostalCode() QueryOptions {
+	if len(o.Fields) == 0 {
+		o.Fields = PlaceFields
+		return o
+	}
+	o.Fields = slices.DeleteFunc(slices.Clone(o.Fields), func(f string) bool {
+		return f == "postalcode"
+	})
+	if len(o.Fields) == 0 {
+		// Empty would select everything again; the coordinates are read
+		// anyway.
+		o.Fields = []string{"latitude"}
+	}
+	return o
+}
+
+// postalFilter adds the conditions of o on a postalcodes table (alias
+// alias, or none) to q.
+func (o QueryOptions) postalFilter(q *sqlbuild.Select, alias string) *sqlbuild.Select {
+	return q.WhereIf(o.Country != "", sqlbuild.Col(alias, "countrycode")+" = ?", o.Country)
+}
+
+// geonameFilter is the geoname-table counterpart of postalFilter.
+func (o QueryOptions) geonameFilter(q *sqlbuild.Select, alias string) *sqlbuild.Select {
+	codes := make([]any, len(o.FeatureCodes))
+	for i, c := range o.FeatureCodes {
+		codes[i] = c
+	}
+	return q.
+		WhereIf(o.Country != "", sqlbuild.Col(alias, "country")+" = ?", o.Country).
+		WhereIf(o.FeatureClass != "", sqlbuild.Col(alias, "fclass")+" = ?", o.FeatureClass).
+		WhereIf(len(codes) > 0, sqlbuild.Col(alias, "fcode")+" IN (?"+
+			strings.Repeat(", ?", max(len(codes)-1, 0))+")", codes...).
+		WhereIf(o.MinPopulation > 0, sqlbuild.Col(alias, "population")+" >= ?", o.MinPopulation).
+		WhereBBox(alias, o.BBox)
+}
+
+// radiusM returns the pre-filter radius of the PostgreSQL strategies:
+// geoRadiusM, or MaxDistanceKm when that is smaller.
+func (o QueryOptions) radiusM() float64 {
+	if o.MaxDistanceKm > 0 && o.MaxDistanceKm*1000 < geoRadiusM {
+		return o.MaxDistanceKm * 1000
+	}
+	return geoRadiusM
+}
+
+// inBBox reports whether (lat, lon) is inside o.BBox, or o has none. It
+// is the in-memory counterpart of the geonameFilter condition.
+func (o QueryOptions) inBBox(lat, lon float64) bool {
+	b := o.BBox
+	if b == nil {
+		return true
+	}
+	if lat < b[1] || lat > b[3] {
+		return false
+	}
+	if b[0] <= b[2] {
+		return lon >= b[0] && lon <= b[2]
+	}
+	return lon >= b[0] || lon <= b[2]
+}
+
+// ---------------------------------------------------------------------------
+// Proximity queries
+// ---------------------------------------------------------------------------
+
+// proximityExprs returns the distance (km) from the columns of alias to
+// (lat, lon) under strategy, and the pre-filter condition that lets the
+// PostgreSQL strategies use their GIST index ("" for Haversine, whose
+// pre-filter is opts.haversineBox, see stages.go).
+func proximityExprs(strategy, alias string, lat, lon, radiusM float64) (dist, within sqlbuild.Expr) {
+	switch strategy {
+	case StrategyPostGIS:
+		return sqlbuild.PostGISDistance(alias, lat, lon), sqlbuild.PostGISWithin(alias, lat, lon, radiusM)
+	case StrategyEarthdistance:
+		return sqlbuild.EarthDistance(alias, lat, lon), sqlbuild.EarthBox(alias, lat, lon, radiusM)
+	}
+	return sqlbuild.E(sqlbuild.Haversine(lat, lon, alias)), sqlbuild.Expr{}
+}
+
+// knnOrder returns the KNN expression of a PostgreSQL strategy ordering
+// the rows of the unaliased table by distance to the place aliased g.
+func knnOrder(strategy string) string {
+	if strategy == StrategyPostGIS {
+		return sqlbuild.PostGISPoint("") + " <-> " + sqlbuild.PostGISPoint("g")
+	}
+	return sqlbuild.EarthPoint("") + " <-> " + sqlbuild.EarthPoint("g")
+}
+
+// nearestPostalSQL returns the query for the postal codes of table
+// nearest to (lat, lon) under strategy (postgis, earthdistance or
+// haversine).
+func nearestPostalSQL(strategy, table string, lat, lon float64, opts QueryOptions) (string, []any) {
+	dist, within := proximityExprs(strategy, "", lat, lon, opts.radiusM())
+	q := new(sqlbuild.Select).
+		Columns("", opts.columns(PostalFields)...).
+		Column(dist.As("distance_km")).
+		From(table, "").
+		Where("latitude IS NOT NULL").
+		Where("longitude IS NOT NULL")
+	if within.SQL != "" {
+		q.WhereExpr(within)
+	}
+	if strategy == StrategyHaversine {
+		q.WhereBBox("", opts.haversineBox(lat, lon))
+	}
+	return opts.postalFilter(q, "").
+		OrderBy(sqlbuild.E("distance_km")).
+		Limit(opts.Limit).
+		Build()
+}
+
+// nearestPlaceSQL returns the query for the places of the geoname table
+// nearest to (lat, lon) under strategy, on a connection of dialect. When
+// opts selects it, each place carries the nearest postal code of its own
+// country in the postal table: a LATERAL join on PostgreSQL, a correlated
+// subquery elsewhere.
+func nearestPlaceSQL(
+	strategy, dialect, geoname, postal string, lat, lon float64, opts QueryOptions,
+) (string, []any) {
+	dist, within := proximityExprs(strategy, "g", lat, lon, opts.radiusM())
+	q := new(sqlbuild.Select).
+		Columns("g", opts.columns(PlaceFields)...).
+		Column(dist.As("distance_km")).
+		From(geoname, "g")
+	if opts.selects("postalcode") {
+		if strategy == StrategyPostGIS || strategy == StrategyEarthdistance {
+			q.Columns("pc", "postalcode").
+				Join(sqlbuild.NearestPostalLateral(postal, knnOrder(strategy), degRadius))
+		} else {
+			q.Column(sqlbuild.E(sqlbuild.NearestPostalSubquery(dialect, postal, degRadius)).
+				As("postalcode"))
+		}
+	}
+	q.Where("g.latitude IS NOT NULL").Where("g.longitude IS NOT NULL")
+	if within.SQL != "" {
+		q.WhereExpr(within)
+	}
+	if strategy == StrategyHaversine {
+		q.WhereBBox("g", opts.haversineBox(lat, lon))
+	}
+	return opts.geonameFilter(q, "g").
+		OrderBy(sqlbuild.E("distance_km")).
+		Limit(opts.Limit).
+		Build()
+}
+
+func queryPostalSQL(
+	db *gorm.DB, lat, lon float64, opts QueryOptions,
+) ([]PostalCode, error) {
+	strategy := ResolveStrategy(db, opts.Strategy)
+	table := TableName(db, "postalcodes")
+	return stagedQuery(strategy, opts, func(o QueryOptions) ([]PostalCode, error) {
+		var rows []PostalCode
+		rawSQL, args := nearestPostalSQL(strategy, table, lat, lon, o)
+		res := db.Raw(rawSQL, args...).Scan(&rows)
+		return rows, res.Error
+	}, func(r *PostalCode) float64 { return r.DistanceKm })
+}
+
+func queryGeonameSQL(
+	db *gorm.DB, lat, lon float64, opts QueryOptions,
+) ([]Place, error) {
+	strategy := ResolveStrategy(db, opts.Strategy)
+	geoname, postal := TableName(db, "geoname"), TableName(db, "postalcodes")
+	return stagedQuery(strategy, opts, func(o QueryOptions) ([]Place, error) {
+		var rows []Place
+		rawSQL, args := nearestPlaceSQL(strategy, db.Dialector.Name(), geoname, postal, lat, lon, o)
+		res := db.Raw(rawSQL, args...).Scan(&rows)
+		return rows, res.Error
+	}, func(r *Place) float64 { return r.DistanceKm })
+}
+
+// ---------------------------------------------------------------------------
+// Administrative names
+// ---------------------------------------------------------------------------
+
+// AdminNames holds the display names, and GeoNames IDs, of a place's
+// country and first two administrative divisions.
+type AdminNames struct {
+	Country   string `json:"country,omitempty"`
+	CountryID int64  `json:"country_geonameid,omitempty"`
+	Admin1    string `json:"admin1,omitempty"`
+	Admin1ID  int64  `json:"admin1_geonameid,omitempty"`
+	Admin2    string `json:"admin2,omitempty"`
+	Admin2ID  int64  `json:"admin2_geonameid,omitempty"`
+}
+
+type namedRow struct {
+	Name      string `gorm:"column:name"`
+	Geonameid *int64 `gorm:"column:geonameid"`
+}
+
+// LookupAdminNames resolves the country and admin codes of a geoname row
+// through countryinfo, admin1codesascii and admin2codesascii. Missing
+// entries, and those of tables without data (see data.go), are left empty.
+func LookupAdminNames(
+	db *gorm.DB, country, admin1, admin2 string,
+) (AdminNames, error) {
+	var names AdminNames
+	if country == "" {
+		return names, nil
+	}
+	data := dataStatusFor(db)
+	lookup := func(table, sql, arg string, name *string, id *int64) error {
+		if !data.Has(table) {
+			return nil
+		}
+		sql = fmt.Sprintf(sql, TableName(db, table))
+		var rows []namedRow
+		if err := db.Raw(sql, arg).Scan(&rows).Error; err != nil {
+			return err
+		}
+		if len(rows) > 0 {
+			*name = strings.TrimSpace(rows[0].Name)
+			if rows[0].Geonameid != nil {
+				*id = *rows[0].Geonameid
+			}
+		}
+		return nil
+	}
+
+	if err := lookup("countryinfo",
+		"SELECT country AS name, geonameid FROM %s WHERE iso_alpha2 = ? LIMIT 1",
+		country, &names.Country, &names.CountryID,
+	); err != nil {
+		return names, err
+	}
+	if admin1 == "" {
+		return names, nil
+	}
+	if err := lookup("admin1codesascii",
+		"SELECT name, geonameid FROM %s WHERE code = ? LIMIT 1",
+		country+"."+admin1, &names.Admin1, &names.Admin1ID,
+	); err != nil {
+		return names, err
+	}
+	if admin2 == "" {
+		return names, nil
+	}
+	err := lookup("admin2codesascii",
+		"SELECT name, geonameid FROM %s WHERE code = ? LIMIT 1",
+		country+"."+admin1+"."+admin2, &names.Admin2, &names.Admin2ID,
+	)
+	return names, err
+}
+
+// ---------------------------------------------------------------------------
+// Query dispatchers
+// ---------------------------------------------------------------------------
+
+func queryPostal(
+	db *gorm.DB, lat, lon float64, opts QueryOptions,
+) ([]PostalCode, error) {
+	switch ResolveStrategy(db, opts.Strategy) {
+	case StrategyMemory:
+		idx, err := memoryIndexFor(db)
+		if err != nil {
+			return nil, err
+		}
+		return idx.nearestPostal(lat, lon, opts), nil
+	case StrategyRTree:
+		return queryPostalRTree(db, lat, lon, opts)
+	}
+	return queryPostalSQL(db, lat, lon, opts)
+}
+
+func queryGeoname(
+	db *gorm.DB, lat, lon float64, opts QueryOptions,
+) ([]Place, error) {
+	switch ResolveStrategy(db, opts.Strategy) {
+	case StrategyMemory:
+		idx, err := memoryIndexFor(db)
+		if err != nil {
+			return nil, err
+		}
+		return idx.nearestGeoname(lat, lon, opts), nil
+	case StrategyRTree:
+		return queryGeonameRTree(db, lat, lon, opts)
+	}
+	return queryGeonameSQL(db, lat, lon, opts)
+}
@@ -0,0 +1,210 @@
+package geocoder
+
+/*
+	Golden-SQL tests of the proximity queries.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Each case writes its statement and arguments to testdata/sql/NAME.sql
+	and compares them with the file. After a deliberate change to the SQL,
+	regenerate the files and review the diff:
+
+	    go test ./pkg/geocoder -run TestQuerySQL -update
+*/
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/sql")
+
+// checkGolden compares the statement rawSQL and its arguments with the
+// golden file of name.
+func checkGolden(t *testing.T, name, rawSQL string, args []any) {
+	t.Helper()
+	got := fmt.Sprintf("%s\n\n-- args: %v\n", rawSQL, args)
+	path := filepath.Join("testdata", "sql", name+".sql")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("SQL of %s differs from %s:\n%s", name, path, got)
+	}
+}
+
+// Mexico City, and the options shared by the cases.
+const testLat, testLon = 19.4326, -99.1332
+
+var (
+	filtered = QueryOptions{
+		Limit: 5, Country: "MX", FeatureClass: "P", MinPopulation: 10000,
+		BBox: []float64{-99.4, 19.1, -98.9, 19.7},
+	}
+	antimeridian = QueryOptions{Limit: 3, BBox: []float64{170, -20, -170, -10}}
+	fieldsOnly   = QueryOptions{Limit: 1, Fields: []string{"name", "country"}}
+	withinRadius = QueryOptions{Limit: 1, Country: "DE", MaxDistanceKm: 25}
+)
+
+func TestQuerySQL(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func() (string, []any)
+	}{
+		{"postal_postgis", func() (string, []any) {
+			return nearestPostalSQL(StrategyPostGIS, "postalcodes", testLat, testLon, QueryOptions{Limit: 3})
+		}},
+		{"postal_postgis_radius", func() (string, []any) {
+			return nearestPostalSQL(StrategyPostGIS, "postalcodes", testLat, testLon, withinRadius)
+		}},
+		{"postal_earthdistance_country", func() (string, []any) {
+			return nearestPostalSQL(StrategyEarthdistance, "geo.postal", testLat, testLon,
+				QueryOptions{Limit: 3, Country: "MX"})
+		}},
+		{"postal_haversine_radius", func() (string, []any) {
+			return nearestPostalSQL(StrategyHaversine, "postalcodes", testLat, testLon, withinRadius)
+		}},
+		{"postal_haversine", func() (string, []any) {
+			return nearestPostalSQL(StrategyHaversine, "postalcodes", testLat, testLon, QueryOptions{Limit: 3})
+		}},
+		{"place_postgis", func() (string, []any) {
+			return nearestPlaceSQL(StrategyPostGIS, "postgres", "geoname", "postalcodes",
+				testLat, testLon, QueryOptions{Limit: 3})
+		}},
+		{"place_earthdistance_filtered", func() (string, []any) {
+			return nearestPlaceSQL(StrategyEarthdistance, "postgres", "geoname", "postalcodes",
+				testLat, testLon, filtered)
+		}},
+		{"place_haversine_mysql", func() (string, []any) {
+			return nearestPlaceSQL(StrategyHaversine, "mysql", "geoname", "postalcodes",
+				testLat, testLon, filtered)
+		}},
+		{"place_haversine_sqlite", func() (string, []any) {
+			return nearestPlaceSQL(StrategyHaversine, "sqlite", "geoname", "postalcodes",
+				testLat, testLon, QueryOptions{Limit: 3})
+		}},
+		{"place_haversine_antimeridian", func() (string, []any) {
+			return nearestPlaceSQL(StrategyHaversine, "sqlite", "geoname", "postalcodes",
+				-16.5, 179.9, antimeridian)
+		}},
+		{"place_postgis_fields", func() (string, []any) {
+			return nearestPlaceSQL(StrategyPostGIS, "postgres", "geoname", "postalcodes",
+				testLat, testLon, fieldsOnly)
+		}},
+		{"place_haversine_feature_codes", func() (string, []any) {
+			return nearestPlaceSQL(StrategyHaversine, "mysql", "geoname", "postalcodes",
+				testLat, testLon, QueryOptions{
+					Limit: 1, FeatureClass: "T", FeatureCodes: []string{"MT", "PK", "VLC"},
+				}.withoutPostalCode())
+		}},
+		{"place_postgis_no_postal", func() (string, []any) {
+			return nearestPlaceSQL(StrategyPostGIS, "postgres", "geoname", "postalcodes",
+				testLat, testLon, QueryOptions{Limit: 3}.withoutPostalCode())
+		}},
+		{"postal_batch_postgis", func() (string, []any) {
+			return nearestPostalBatchSQL(StrategyPostGIS, "postalcodes",
+				[]Point{{Lat: testLat, Lon: testLon}, {Lat: 20.67, Lon: -103.35}}, withinRadius)
+		}},
+		{"place_batch_earthdistance", func() (string, []any) {
+			return nearestPlaceBatchSQL(StrategyEarthdistance, "geoname", "postalcodes",
+				[]Point{{Lat: testLat, Lon: testLon}, {Lat: 20.67, Lon: -103.35}}, filtered)
+		}},
+		{"poi_postgis_category", func() (string, []any) {
+			return nearestPOISQL(StrategyPostGIS, "poi", testLat, testLon,
+				QueryOptions{Limit: 2, Category: "store"})
+		}},
+		{"poi_haversine", func() (string, []any) {
+			return nearestPOISQL(StrategyHaversine, "poi", testLat, testLon, QueryOptions{Limit: 2})
+		}},
+		{"links", func() (string, []any) {
+			return linksSQL("alternatename", []any{int64(3530597), int64(4005539)})
+		}},
+		{"search_name_fcode", func() (string, []any) {
+			return searchSQL("geoname", SearchOptions{
+				Name: "san_jose", Country: "CR", FeatureCode: "PPLA", Limit: 10, Offset: 20,
+			})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rawSQL, args := tt.build()
+			checkGolden(t, tt.name, rawSQL, args)
+		})
+	}
+}
+
+// TestStagedQueryBudget checks that a query past stageBudget skips to
+// its final statement instead of returning the incomplete rows of a
+// stage, and still finishes an over-full stage with its exact radius.
+func TestStagedQueryBudget(t *testing.T) {
+	defer func(b time.Duration) { stageBudget = b }(stageBudget)
+	stageBudget = 0
+
+	var radii []float64
+	dist := func(d *float64) float64 { return *d }
+	run := func(query func(QueryOptions) []float64) []float64 {
+		t.Helper()
+		radii = nil
+		rows, err := stagedQuery(StrategyHaversine, QueryOptions{Limit: 3},
+			func(o QueryOptions) ([]float64, error) {
+				radii = append(radii, o.MaxDistanceKm)
+				return query(o), nil
+			}, dist)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rows
+	}
+
+	// Too few rows within 5 km: the final query answers.
+	rows := run(func(o QueryOptions) []float64 {
+		if o.MaxDistanceKm == 0 {
+			return []float64{1, 2, 3}
+		}
+		return []float64{1}
+	})
+	if len(rows) != 3 || fmt.Sprint(radii) != "[5 0]" {
+		t.Errorf("got rows %v after radii %v, want 3 rows after [5 0]", rows, radii)
+	}
+
+	// Enough rows within the 5 km box, the third beyond 5 km: the exact
+	// radius answers.
+	rows = run(func(o QueryOptions) []float64 {
+		if o.MaxDistanceKm == 5 {
+			return []float64{1, 2, 6}
+		}
+		return []float64{1, 2, 5.5}
+	})
+	if len(rows) != 3 || rows[2] != 5.5 || fmt.Sprint(radii) != "[5 6.001]" {
+		t.Errorf("got rows %v after radii %v, want [1 2 5.5] after [5 6.001]", rows, radii)
+	}
+}
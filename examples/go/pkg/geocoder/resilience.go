@@ -0,0 +1,344 @@
+package geocoder
+
+/*
+	Retries, circuit breaker and stale-result cache for Geocoder lookups.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Every Geocoder lookup (NearestPostal, NearestPlaces, ReverseGeocodeFull,
+	Suggest) runs through guarded, which adds, when configured:
+
+	  Retry      Transient errors — deadlocks, serialization failures,
+	             dropped or refused connections, a primary turned read-only
+	             during replica promotion, SQLite "database is locked" —
+	             are retried up to MaxAttempts times with exponential
+	             backoff (InitialBackoff doubling up to MaxBackoff, ±25 %
+	             jitter). Other errors are returned at once.
+	  Breaker    After FailureThreshold consecutive lookups that failed
+	             with a transient error (retries included), the breaker
+	             opens for Cooldown: lookups fail fast with
+	             ErrBackendUnavailable instead of queueing on a dead
+	             database. After the cooldown one trial lookup is let
+	             through; its outcome closes or re-opens the breaker.
+	  Stale      The last StaleEntries successful answers are kept. While
+	             the breaker is open, or when a lookup gives up on a
+	             transient error, a kept answer for the same arguments is
+	             returned instead of the error. Answers are kept encoded,
+	             so every caller gets a copy of its own to change.
+
+	A Geocoder created without WithResilience gets none of this, as before.
+	The server reads the "resilience" section of the server config:
+
+	  server:
+	    resilience:
+	      max_attempts: 3          # 1 disables retries
+	      initial_backoff: 50ms
+	      max_backoff: 1s
+	      failure_threshold: 5     # negative disables the breaker
+	      cooldown: 30s
+	      stale_entries: 10000     # negative disables the stale cache
+*/
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// ErrBackendUnavailable is returned while the circuit breaker is open and
+// no cached answer is available.
+var ErrBackendUnavailable = errors.New("geocoder: backend unavailable")
+
+// ResilienceConfig configures retries, the circuit breaker and the stale
+// cache. Zero fields take the defaults noted in the file header; a
+// negative FailureThreshold or StaleEntries disables the breaker or the
+// stale cache.
+type ResilienceConfig struct {
+	MaxAttempts      int           `yaml:"max_attempts"`
+	InitialBackoff   time.Duration `yaml:"initial_backoff"`
+	MaxBackoff       time.Duration `yaml:"max_backoff"`
+	FailureThreshold int           `yaml:"failure_threshold"`
+	Cooldown         time.Duration `yaml:"cooldown"`
+	StaleEntries     int           `yaml:"stale_entries"`
+}
+
+// WithDefaults fills in unset fields.
+func (c ResilienceConfig) WithDefaults() ResilienceConfig {
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 3
+	}
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = 50 * time.Millisecond
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = time.Second
+	}
+	if c.FailureThreshold == 0 {
+		c.FailureThreshold = 5
+	}
+	if c.Cooldown <= 0 {
+		c.Cooldown = 30 * time.Second
+	}
+	if c.StaleEntries == 0 {
+		c.StaleEntries = 10000
+	}
+	return c
+}
+
+// WithResilience enables retries, the circuit breaker and the stale cache.
+func WithResilience(c ResilienceConfig) Option {
+	return func(g *Geocoder) {
+		c = c.WithDefaults()
+		g.res = &resilience{cfg: c, stale: newStaleCache(c.StaleEntries)}
+	}
+}
+
+// resilience is the state shared by a Geocoder and its WithContext copies.
+type resilience struct {
+	cfg   ResilienceConfig
+	stale *staleCache // nil when disabled
+
+	mu        sync.Mutex
+	failures  int       // consecutive transient failures
+	openUntil time.Time // breaker open while now < openUntil
+	trial     bool      // a half-open trial lookup is running
+}
+
+// allow reports whether a lookup may reach the database.
+func (r *resilience) allow() bool {
+	if r.cfg.FailureThreshold <= 0 {
+		return true
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failures < r.cfg.FailureThreshold {
+		return true
+	}
+	if time.Now().Before(r.openUntil) || r.trial {
+		return false
+	}
+	r.trial = true
+	return true
+}
+
+// record updates the breaker with the outcome of a lookup.
+func (r *resilience) record(err error) {
+	if r.cfg.FailureThreshold <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trial = false
+	if err == nil || !isTransient(err) {
+		r.failures = 0
+		return
+	}
+	r.failures++
+	if r.failures >= r.cfg.FailureThreshold {
+		r.openUntil = time.Now().Add(r.cfg.Cooldown)
+	}
+}
+
+// abandon ends a lookup without judging the database.
+func (r *resilience) abandon() {
+	r.mu.Lock()
+	r.trial = false
+	r.mu.Unlock()
+}
+
+// backoff returns the delay before retry number attempt (1-based).
+func (r *resilience) backoff(attempt int) time.Duration {
+	d := r.cfg.InitialBackoff << (attempt - 1)
+	if d <= 0 || d > r.cfg.MaxBackoff {
+		d = r.cfg.MaxBackoff
+	}
+	return time.Duration(float64(d) * (0.75 + rand.Float64()/2))
+}
+
+// guarded runs fn under g's resilience policy. key identifies the lookup
+// and its arguments for the stale cache.
+func guarded[T any](g *Geocoder, key string, fn func() (T, error)) (T, error) {
+	r := g.res
+	if r == nil {
+		return fn()
+	}
+	stale := func(err error) (T, error) {
+		var v T
+		if r.stale != nil {
+			if raw, ok := r.stale.get(key); ok && json.Unmarshal(raw, &v) == nil {
+				return v, nil
+			}
+		}
+		var zero T
+		return zero, err
+	}
+	if !r.allow() {
+		return stale(ErrBackendUnavailable)
+	}
+
+	ctx := g.db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var v T
+	var err error
+	for attempt := 1; ; attempt++ {
+		v, err = fn()
+		if err == nil || !isTransient(err) || attempt >= r.cfg.MaxAttempts {
+			break
+		}
+		t := time.NewTimer(r.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			r.abandon() // the caller gave up, not the database
+			var zero T
+			return zero, ctx.Err()
+		case <-t.C:
+		}
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		r.abandon() // the lookup timed out or its caller went away
+		return v, err
+	}
+	r.record(err)
+	if err != nil {
+		if isTransient(err) {
+			return stale(err)
+		}
+		return v, err
+	}
+	if r.stale != nil {
+		// Callers change the rows they get in place (confidence, units,
+		// directions), so the cache keeps a copy of its own.
+		if raw, err := json.Marshal(v); err == nil {
+			r.stale.put(key, raw)
+		}
+	}
+	return v, nil
+}
+
+// isTransient reports whether err is worth retrying.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	// PostgreSQL (pgconn.PgError).
+	var pgErr interface{ SQLState() string }
+	if errors.As(err, &pgErr) {
+		code := pgErr.SQLState()
+		switch code {
+		case "40001", // serialization_failure
+			"40P01", // deadlock_detected
+			"25006", // read_only_sql_transaction
+			"55P03": // lock_not_available
+			return true
+		}
+		// Class 08 connection exceptions; 57P01-57P03 shutdown and
+		// "cannot connect now" during failover.
+		return strings.HasPrefix(code, "08") || strings.HasPrefix(code, "57P")
+	}
+
+	// MySQL / MariaDB.
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		switch myErr.Number {
+		case 1205, // lock wait timeout
+			1213, // deadlock
+			1053, // server shutdown in progress
+			1290, // --read-only
+			1836, // read-only mode
+			1792, // read-only transaction
+			2006, // server has gone away
+			2013: // lost connection
+			return true
+		}
+		return false
+	}
+	if errors.Is(err, mysql.ErrInvalidConn) {
+		return true
+	}
+
+	// SQLite (see resilience_sqlite.go).
+	return isTransientSQLite(err)
+}
+
+// ---------------------------------------------------------------------------
+// Stale cache
+// ---------------------------------------------------------------------------
+
+// staleCache keeps the last n answers, JSON-encoded, evicting the oldest
+// insertion.
+type staleCache struct {
+	mu    sync.Mutex
+	n     int
+	items map[string][]byte
+	order []string // ring of keys in insertion order
+	next  int
+}
+
+func newStaleCache(n int) *staleCache {
+	if n <= 0 {
+		return nil
+	}
+	return &staleCache{n: n, items: make(map[string][]byte, n)}
+}
+
+func (c *staleCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.items[key]
+	return v, ok
+}
+
+func (c *staleCache) put(key string, v []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.items[key]; ok {
+		c.items[key] = v
+		return
+	}
+	if len(c.order) < c.n {
+		c.order = append(c.order, key)
+	} else {
+		delete(c.items, c.order[c.next])
+		c.order[c.next] = key
+		c.next = (c.next + 1) % c.n
+	}
+	c.items[key] = v
+}
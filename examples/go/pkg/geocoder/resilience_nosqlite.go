@@ -0,0 +1,32 @@
+//go:build !cgo
+
+package geocoder
+
+/*
+	Transient SQLite errors, without cgo.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Without cgo go-sqlite3 cannot open a database, so there are no SQLite
+	errors to classify (see resilience_sqlite.go).
+*/
+
+// isTransientSQLite reports false: SQLite needs cgo.
+func isTransientSQLite(err error) bool {
+	return false
+}
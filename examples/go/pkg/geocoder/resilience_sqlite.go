@@ -0,0 +1,44 @@
+//go:build cgo
+
+package geocoder
+
+/*
+	Transient SQLite errors.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	go-sqlite3 defines its error codes only when built with cgo; without
+	it the driver is a stub that fails to open any database, and
+	resilience_nosqlite.go takes the place of this file.
+*/
+
+import (
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// isTransientSQLite reports whether err is SQLite's "database is locked"
+// or "database table is locked".
+func isTransientSQLite(err error) bool {
+	var liteErr sqlite3.Error
+	if errors.As(err, &liteErr) {
+		return liteErr.Code == sqlite3.ErrBusy || liteErr.Code == sqlite3.ErrLocked
+	}
+	return false
+}
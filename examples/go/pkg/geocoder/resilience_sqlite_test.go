@@ -0,0 +1,46 @@
+//go:build cgo
+
+package geocoder
+
+/*
+	Tests of the transient SQLite errors.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+func TestIsTransientSQLite(t *testing.T) {
+	for _, c := range []struct {
+		err  error
+		want bool
+	}{
+		{sqlite3.Error{Code: sqlite3.ErrBusy}, true},
+		{sqlite3.Error{Code: sqlite3.ErrLocked}, true},
+		{fmt.Errorf("query: %w", sqlite3.Error{Code: sqlite3.ErrBusy}), true},
+		{sqlite3.Error{Code: sqlite3.ErrConstraint}, false},
+		{sqlite3.Error{Code: sqlite3.ErrError}, false},
+	} {
+		if got := isTransient(c.err); got != c.want {
+			t.Errorf("isTransient(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package geocoder
+
+/*
+	Tests of the retry policy, circuit breaker and stale cache.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// resilientGeocoder returns a Geocoder with no connection, for running
+// guarded on functions standing in for queries.
+func resilientGeocoder(c ResilienceConfig) *Geocoder {
+	c.InitialBackoff, c.MaxBackoff = time.Millisecond, time.Millisecond
+	return New(&gorm.DB{Statement: &gorm.Statement{}}, WithResilience(c))
+}
+
+// TestStaleCopies checks that the stale cache answers with copies that
+// callers may change.
+func TestStaleCopies(t *testing.T) {
+	g := resilientGeocoder(ResilienceConfig{MaxAttempts: 1, StaleEntries: 10})
+	rows, err := guarded(g, "k", func() ([]Place, error) {
+		return []Place{{Name: "Berlin", DistanceKm: 1}}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows[0].DistanceKm *= 1000
+
+	fail := func() ([]Place, error) { return nil, driver.ErrBadConn }
+	for range 2 {
+		rows, err = guarded(g, "k", fail)
+		if err != nil || len(rows) != 1 || rows[0].DistanceKm != 1 {
+			t.Fatalf("stale answer = %+v, %v, want Berlin at 1 km", rows, err)
+		}
+		rows[0].DistanceKm *= 1000
+	}
+}
+
+// TestBreakerCanceledTrial checks that a half-open trial cancelled by its
+// caller leaves the breaker open.
+func TestBreakerCanceledTrial(t *testing.T) {
+	g := resilientGeocoder(ResilienceConfig{MaxAttempts: 1, FailureThreshold: 1, Cooldown: time.Nanosecond})
+	if _, err := guarded(g, "k", func() (int, error) { return 0, driver.ErrBadConn }); err == nil {
+		t.Fatal("failing lookup: no error")
+	}
+	time.Sleep(time.Millisecond)
+	_, err := guarded(g, "k", func() (int, error) { return 0, context.Canceled })
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled trial: error %v, want context.Canceled", err)
+	}
+	if r := g.res; r.failures != 1 || r.trial {
+		t.Errorf("after a cancelled trial: %d failures, trial %v; want 1, false", r.failures, r.trial)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	for _, c := range []struct {
+		err  error
+		want bool
+	}{
+		{driver.ErrBadConn, true},
+		{fmt.Errorf("query: %w", io.ErrUnexpectedEOF), true},
+		{&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, true},
+		{syscall.ECONNRESET, true},
+		{&pgconn.PgError{Code: "40P01"}, true}, // deadlock
+		{&pgconn.PgError{Code: "40001"}, true}, // serialization failure
+		{&pgconn.PgError{Code: "25006"}, true}, // read-only after failover
+		{&pgconn.PgError{Code: "57P01"}, true}, // admin shutdown
+		{&pgconn.PgError{Code: "57P03"}, true}, // cannot connect now
+		{&pgconn.PgError{Code: "08006"}, true}, // connection failure
+		{fmt.Errorf("wrapped: %w", &pgconn.PgError{Code: "40P01"}), true},
+		{&pgconn.PgError{Code: "42P01"}, false},  // undefined table
+		{&pgconn.PgError{Code: "23505"}, false},  // unique violation
+		{&mysql.MySQLError{Number: 1213}, true},  // deadlock
+		{&mysql.MySQLError{Number: 1205}, true},  // lock wait timeout
+		{&mysql.MySQLError{Number: 1290}, true},  // --read-only
+		{&mysql.MySQLError{Number: 1836}, true},  // read-only mode
+		{&mysql.MySQLError{Number: 2013}, true},  // lost connection
+		{&mysql.MySQLError{Number: 1146}, false}, // no such table
+		{mysql.ErrInvalidConn, true},
+		{context.Canceled, false},
+		{fmt.Errorf("query: %w", context.DeadlineExceeded), false},
+		{ErrNoResultWithinRadius, false},
+		{errors.New("syntax error"), false},
+	} {
+		if got := isTransient(c.err); got != c.want {
+			t.Errorf("isTransient(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	r := &resilience{cfg: ResilienceConfig{
+		InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second,
+	}}
+	for _, c := range []struct {
+		attempt int
+		base    time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{80, time.Second}, // the shift overflows
+	} {
+		for range 20 {
+			d := r.backoff(c.attempt)
+			if d < c.base*3/4 || d > c.base*5/4 {
+				t.Errorf("backoff(%d) = %v, want %v ±25%%", c.attempt, d, c.base)
+			}
+		}
+	}
+}
+
+// TestRetry checks that transient errors are retried up to MaxAttempts
+// and others are not.
+func TestRetry(t *testing.T) {
+	g := resilientGeocoder(ResilienceConfig{MaxAttempts: 3, FailureThreshold: -1})
+	for _, c := range []struct {
+		err   error
+		calls int
+	}{
+		{&pgconn.PgError{Code: "40P01"}, 3},
+		{&pgconn.PgError{Code: "42P01"}, 1},
+	} {
+		calls := 0
+		_, err := guarded(g, "k", func() (int, error) { calls++; return 0, c.err })
+		if !errors.Is(err, c.err) || calls != c.calls {
+			t.Errorf("%v: %d calls, error %v; want %d calls", c.err, calls, err, c.calls)
+		}
+	}
+	calls := 0
+	v, err := guarded(g, "k", func() (int, error) {
+		if calls++; calls < 3 {
+			return 0, driver.ErrBadConn
+		}
+		return 42, nil
+	})
+	if v != 42 || err != nil || calls != 3 {
+		t.Errorf("recovering lookup = %d, %v after %d calls, want 42 after 3", v, err, calls)
+	}
+}
+
+// TestBreaker walks the breaker through closed, open, half-open and back.
+func TestBreaker(t *testing.T) {
+	g := resilientGeocoder(ResilienceConfig{
+		MaxAttempts: 1, FailureThreshold: 2, Cooldown: 20 * time.Millisecond, StaleEntries: -1,
+	})
+	calls := 0
+	fail := func() (int, error) { calls++; return 0, driver.ErrBadConn }
+	ok := func() (int, error) { calls++; return 1, nil }
+	lookup := func(fn func() (int, error), wantErr error, wantCalls int) {
+		t.Helper()
+		calls = 0
+		_, err := guarded(g, "k", fn)
+		if !errors.Is(err, wantErr) || calls != wantCalls {
+			t.Fatalf("error %v after %d calls, want %v after %d", err, calls, wantErr, wantCalls)
+		}
+	}
+
+	lookup(fail, driver.ErrBadConn, 1)
+	lookup(ok, nil, 1) // a success resets the count
+	lookup(fail, driver.ErrBadConn, 1)
+	lookup(fail, driver.ErrBadConn, 1) // opens
+	lookup(ok, ErrBackendUnavailable, 0)
+
+	time.Sleep(30 * time.Millisecond)
+	lookup(fail, driver.ErrBadConn, 1) // the failed trial re-opens it
+	lookup(ok, ErrBackendUnavailable, 0)
+
+	time.Sleep(30 * time.Millisecond)
+	lookup(ok, nil, 1) // the trial closes it
+	lookup(fail, driver.ErrBadConn, 1)
+	lookup(ok, nil, 1)
+}
+
+func TestResilienceDefaults(t *testing.T) {
+	c := ResilienceConfig{}.WithDefaults()
+	if c.FailureThreshold != 5 || c.StaleEntries != 10000 || c.MaxAttempts != 3 {
+		t.Errorf("defaults = %+v, want a breaker at 5 and 10000 stale entries", c)
+	}
+	g := resilientGeocoder(ResilienceConfig{FailureThreshold: -1, StaleEntries: -1})
+	if g.res.stale != nil {
+		t.Error("StaleEntries -1: stale cache enabled")
+	}
+	for range 10 {
+		g.res.record(driver.ErrBadConn)
+	}
+	if !g.res.allow() {
+		t.Error("FailureThreshold -1: breaker opened")
+	}
+}
+
+func TestHashPoints(t *testing.T) {
+	a := []Point{{Lat: 19.4326, Lon: -99.1332}, {Lat: 52.52, Lon: 13.405}}
+	b := []Point{{Lat: 52.52, Lon: 13.405}, {Lat: 19.4326, Lon: -99.1332}}
+	c := []Point{{Lat: -99.1332, Lon: 19.4326}, {Lat: 52.52, Lon: 13.405}}
+	if hashPoints(a) != hashPoints(slices.Clone(a)) {
+		t.Error("equal batches hash differently")
+	}
+	if hashPoints(a) == hashPoints(b) || hashPoints(a) == hashPoints(c) {
+		t.Error("reordered or swapped coordinates hash the same")
+	}
+}
@@ -0,0 +1,144 @@
+package geocoder
+
+/*
+	Lookup results and the errors that explain an empty one.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	The free query functions return an empty slice both when nothing is
+	near the point and when the table has no data at all for the requested
+	country. The Geocoder methods tell these apart: a lookup that finds no
+	row returns an error matching one of
+
+	  ErrCountryNotCovered     opts.Country has no rows in the table
+	                           (a *CountryNotCoveredError names both;
+	                           known countries fail before any query,
+	                           see coverage.go)
+	  ErrNoResultWithinRadius  no row within opts.MaxDistanceKm or, on
+	                           PostgreSQL, the 500 km pre-filter radius
+	  ErrOffshore              no populated place within the offshore
+	                           distance (an *OffshoreError, which also
+	                           matches ErrNoResultWithinRadius; see
+	                           offshore.go)
+	  ErrUnsupportedDialect    the connection is not PostgreSQL, MySQL or
+	                           SQLite, or cannot run the forced strategy
+	  ErrPlaceNotFound         a lookup by geonameid named no geoname
+	                           entry with coordinates
+	  ErrNoData                the table the lookup needs is missing or
+	                           empty (a *MissingDataError names it; see
+	                           data.go)
+
+	so callers can match them with errors.Is. Successful lookups come
+	wrapped in a Result recording the strategy that answered and how long
+	the lookup took, retries included.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Errors returned by Geocoder lookups.
+var (
+	ErrNoResultWithinRadius = errors.New("geocoder: no result within radius")
+	ErrCountryNotCovered    = errors.New("geocoder: country not covered")
+	ErrUnsupportedDialect   = errors.New("geocoder: unsupported dialect")
+	ErrPlaceNotFound        = errors.New("geocoder: place not found")
+	ErrNoData               = errors.New("geocoder: no data loaded")
+)
+
+// CountryNotCoveredError reports a country with no rows in a table. It
+// matches ErrCountryNotCovered.
+type CountryNotCoveredError struct {
+	Country string
+	Table   string
+	// NoPostalSystem is set for postalcodes when countryinfo gives the
+	// country no postal-code format (see coverage.go).
+	NoPostalSystem bool
+}
+
+func (e *CountryNotCoveredError) Error() string {
+	if e.NoPostalSystem {
+		return fmt.Sprintf("geocoder: country %s not covered by %s (no postal code system)", e.Country, e.Table)
+	}
+	return fmt.Sprintf("geocoder: country %s not covered by %s", e.Country, e.Table)
+}
+
+func (e *CountryNotCoveredError) Is(target error) bool {
+	return target == ErrCountryNotCovered
+}
+
+// Result is the answer of a Geocoder lookup.
+type Result[T any] struct {
+	Rows []T `json:"rows"`
+	// Strategy is the distance strategy that ran the query (one of the
+	// Strategy* constants).
+	Strategy string        `json:"strategy"`
+	Duration time.Duration `json:"duration"`
+}
+
+// IsNoResult reports whether err only says that a lookup found nothing.
+func IsNoResult(err error) bool {
+	return errors.Is(err, ErrNoResultWithinRadius) || errors.Is(err, ErrCountryNotCovered) ||
+		errors.Is(err, ErrNoData)
+}
+
+// SupportedDialect reports whether the query functions know db's dialect.
+func SupportedDialect(db *gorm.DB) error {
+	switch name := db.Dialector.Name(); name {
+	case "postgres", "mysql", "sqlite":
+		return nil
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedDialect, name)
+	}
+}
+
+// explainEmpty returns the error describing why a lookup in table (whose
+// country column is countryCol) found nothing.
+func explainEmpty(db *gorm.DB, table, countryCol, country string) error {
+	if country != "" {
+		var n int64
+		err := db.Raw(fmt.Sprintf(
+			"SELECT count(*) FROM (SELECT 1 FROM %s WHERE %s = ? LIMIT 1) t",
+			TableName(db, table), countryCol), country).Scan(&n).Error
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return &CountryNotCoveredError{Country: country, Table: table}
+		}
+	}
+	return ErrNoResultWithinRadius
+}
+
+// withinKm returns the leading rows whose distance is at most maxKm.
+// Rows must be sorted by distance; maxKm <= 0 keeps them all.
+func withinKm[T any](rows []T, maxKm float64, dist func(*T) float64) []T {
+	if maxKm <= 0 {
+		return rows
+	}
+	for i := range rows {
+		if dist(&rows[i]) > maxKm {
+			return rows[:i]
+		}
+	}
+	return rows
+}
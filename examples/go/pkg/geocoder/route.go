@@ -0,0 +1,272 @@
+package geocoder
+
+/*
+	Reverse geocoding along a route.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	ParseRoute reads a route in one of three forms, told apart by their
+	characters:
+
+	  - an encoded polyline (Google's algorithm, 5 decimals by default,
+	    6 for OSRM/Valhalla with --precision 6), which never holds digits,
+	    commas or spaces;
+	  - "lat,lon;lat,lon;..." pairs (in a query string the semicolons
+	    must be escaped as %3B; newlines separate points too);
+	  - GeoJSON, "{...}": a LineString, MultiLineString or a Feature
+	    holding one. As elsewhere in GeoJSON, longitude comes first.
+
+	Geocoder.ReverseRoute looks up the nearest populated place (feature
+	class P unless opts.FeatureClass says otherwise) and postal code of
+	every point with the batch queries (see batch.go) and collapses runs
+	of points with the same answer into one segment, with the index of the
+	first point (Entry) and the last (Exit). The result is the ordered list
+	of places and postal codes the route passes — a place met again later
+	gets a second segment. With opts.MaxDistanceKm a point farther than
+	that from any place has no answer and ends the segment it was in.
+
+	Only the points given are looked up: a motorway polyline with 50 km
+	between vertices says nothing about the towns in between, so sample
+	long routes densely enough for the answer wanted (routing engines can
+	return every step of the geometry).
+
+	The command-line side is in cmd/reverse_geocode/route.go.
+*/
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxRoutePoints caps the points of one /reverse/route request.
+const MaxRoutePoints = 10000
+
+// RouteSegment is a run of route points with the same nearest row.
+type RouteSegment[T any] struct {
+	// Entry and Exit are the indices of the first and last point of the
+	// run.
+	Entry int `json:"entry"`
+	Exit  int `json:"exit"`
+	// Row is the answer of the Entry point; the other points of the run
+	// have the same place or code at their own distances.
+	Row T `json:"row"`
+}
+
+// RouteResult is the answer of ReverseRoute.
+type RouteResult struct {
+	Points   int                        `json:"points"`
+	Places   []RouteSegment[Place]      `json:"places"`
+	Postal   []RouteSegment[PostalCode] `json:"postal"`
+	Strategy string                     `json:"strategy"`
+	Duration time.Duration              `json:"duration"`
+}
+
+// ReverseRoute returns the places and postal codes along points, in order,
+// with consecutive points of the same answer merged. opts.Limit is
+// ignored; opts.FeatureClass defaults to P.
+func (g *Geocoder) ReverseRoute(points []Point, opts QueryOptions) (*RouteResult, error) {
+	start := time.Now()
+	opts.Limit = 1
+	placeOpts := opts.withoutPostalCode()
+	if placeOpts.FeatureClass == "" {
+		placeOpts.FeatureClass = "P"
+	}
+	places, err := g.NearestPlacesBatch(points, placeOpts)
+	if err != nil {
+		return nil, err
+	}
+	postal, err := g.NearestPostalBatch(points, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &RouteResult{
+		Points: len(points),
+		Places: segments(places.Rows, func(r *Place) string {
+			return strconv.FormatInt(r.Geonameid, 10)
+		}),
+		Postal: segments(postal.Rows, func(r *PostalCode) string {
+			return r.Countrycode + " " + r.Postalcode
+		}),
+		Strategy: places.Strategy,
+		Duration: time.Since(start),
+	}, nil
+}
+
+// segments merges the consecutive points of rows (the answers of each
+// point, nearest first) whose first row has the same key.
+func segments[T any](rows [][]T, key func(*T) string) []RouteSegment[T] {
+	out := []RouteSegment[T]{}
+	last := ""
+	for i, r := range rows {
+		if len(r) == 0 {
+			last = ""
+			continue
+		}
+		k := key(&r[0])
+		if k == last {
+			out[len(out)-1].Exit = i
+			continue
+		}
+		out = append(out, RouteSegment[T]{Entry: i, Exit: i, Row: r[0]})
+		last = k
+	}
+	return out
+}
+
+// ---------------------------------------------------------------------------
+// Route input
+// ---------------------------------------------------------------------------
+
+// ParseRoute parses a route given as an encoded polyline of precision
+// decimals, "lat,lon;lat,lon..." pairs or GeoJSON.
+func ParseRoute(s string, precision int) ([]Point, error) {
+	s = strings.TrimSpace(s)
+	var pts []Point
+	var err error
+	switch {
+	case s == "":
+		return nil, errors.New("empty route")
+	case s[0] == '{':
+		var g geoJSON
+		if err := json.Unmarshal([]byte(s), &g); err != nil {
+			return nil, fmt.Errorf("GeoJSON: %w", err)
+		}
+		pts, err = g.line()
+	case strings.Trim(s, "0123456789.,;+-eE \t\r\n") == "":
+		pts, err = parsePairs(s)
+	default:
+		pts, err = DecodePolyline(s, precision)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range pts {
+		if p.Lat < -90 || p.Lat > 90 || p.Lon < -180 || p.Lon > 180 {
+			return nil, fmt.Errorf("point %d (%g, %g) out of range", i, p.Lat, p.Lon)
+		}
+	}
+	return pts, nil
+}
+
+// parsePairs parses "lat,lon;lat,lon...". Newlines separate points too.
+func parsePairs(s string) ([]Point, error) {
+	var pts []Point
+	for _, pair := range strings.FieldsFunc(s, func(r rune) bool {
+		return r == ';' || r == '\n' || r == '\r'
+	}) {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		latS, lonS, ok := strings.Cut(pair, ",")
+		if !ok {
+			return nil, fmt.Errorf("point %d: %q is not lat,lon", len(pts), pair)
+		}
+		lat, err1 := strconv.ParseFloat(strings.TrimSpace(latS), 64)
+		lon, err2 := strconv.ParseFloat(strings.TrimSpace(lonS), 64)
+		if err := errors.Join(err1, err2); err != nil {
+			return nil, fmt.Errorf("point %d: %w", len(pts), err)
+		}
+		pts = append(pts, Point{Lat: lat, Lon: lon})
+	}
+	return pts, nil
+}
+
+// DecodePolyline decodes an encoded polyline whose coordinates were
+// rounded to precision decimals (5 for Google, 6 for OSRM and Valhalla).
+func DecodePolyline(s string, precision int) ([]Point, error) {
+	if precision < 1 || precision > 10 {
+		return nil, fmt.Errorf("polyline precision %d out of range", precision)
+	}
+	factor := 1.0
+	for range precision {
+		factor *= 10
+	}
+	var pts []Point
+	var lat, lon int64
+	for i := 0; i < len(s); {
+		var delta [2]int64
+		for j := range delta {
+			var v int64
+			shift := uint(0)
+			for {
+				if i >= len(s) {
+					return nil, errors.New("polyline: truncated")
+				}
+				b := int64(s[i]) - 63
+				i++
+				if b < 0 || b > 63 || shift > 60 {
+					return nil, fmt.Errorf("polyline: invalid character at %d", i-1)
+				}
+				v |= (b & 0x1f) << shift
+				shift += 5
+				if b < 0x20 {
+					break
+				}
+			}
+			if v&1 != 0 {
+				v = ^(v >> 1)
+			} else {
+				v >>= 1
+			}
+			delta[j] = v
+		}
+		lat += delta[0]
+		lon += delta[1]
+		pts = append(pts, Point{Lat: float64(lat) / factor, Lon: float64(lon) / factor})
+	}
+	return pts, nil
+}
+
+// line reads a GeoJSON LineString or MultiLineString, or a Feature holding
+// one; the parts of a MultiLineString are joined.
+func (g *geoJSON) line() ([]Point, error) {
+	var lines [][][]float64
+	switch g.Type {
+	case "LineString":
+		var c [][]float64
+		if err := json.Unmarshal(g.Coordinates, &c); err != nil {
+			return nil, fmt.Errorf("GeoJSON LineString: %w", err)
+		}
+		lines = [][][]float64{c}
+	case "MultiLineString":
+		if err := json.Unmarshal(g.Coordinates, &lines); err != nil {
+			return nil, fmt.Errorf("GeoJSON MultiLineString: %w", err)
+		}
+	case "Feature":
+		if g.Geometry == nil {
+			return nil, errors.New("GeoJSON Feature without geometry")
+		}
+		return g.Geometry.line()
+	default:
+		return nil, fmt.Errorf("unsupported GeoJSON type %q (want LineString, MultiLineString or Feature)", g.Type)
+	}
+	var pts []Point
+	for _, l := range lines {
+		for _, c := range l {
+			if len(c) < 2 {
+				return nil, fmt.Errorf("GeoJSON: position with %d values", len(c))
+			}
+			pts = append(pts, Point{Lat: c[1], Lon: c[0]})
+		}
+	}
+	return pts, nil
+}
@@ -0,0 +1,170 @@
+package geocoder
+
+/*
+	Tests of the route input and its segments.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// encodePolyline is the inverse of DecodePolyline.
+func encodePolyline(pts []Point, precision int) string {
+	factor := math.Pow10(precision)
+	var sb strings.Builder
+	var lat, lon int64
+	for _, p := range pts {
+		nlat, nlon := int64(math.Round(p.Lat*factor)), int64(math.Round(p.Lon*factor))
+		for _, d := range []int64{nlat - lat, nlon - lon} {
+			v := d << 1
+			if d < 0 {
+				v = ^v
+			}
+			for v >= 0x20 {
+				sb.WriteByte(byte(0x20|v&0x1f) + 63)
+				v >>= 5
+			}
+			sb.WriteByte(byte(v) + 63)
+		}
+		lat, lon = nlat, nlon
+	}
+	return sb.String()
+}
+
+func samePoints(a, b []Point) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i].Lat-b[i].Lat) > 1e-9 || math.Abs(a[i].Lon-b[i].Lon) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDecodePolyline(t *testing.T) {
+	// Google's example.
+	pts, err := DecodePolyline("_p~iF~ps|U_ulLnnqC_mqNvxq`@", 5)
+	want := []Point{{38.5, -120.2}, {40.7, -120.95}, {43.252, -126.453}}
+	if err != nil || !samePoints(pts, want) {
+		t.Errorf("Google's example = %v, %v; want %v", pts, err, want)
+	}
+
+	for _, c := range []struct {
+		precision int
+		pts       []Point
+	}{
+		{5, []Point{{19.4326, -99.1332}}},
+		{5, []Point{{19.4326, -99.1332}, {19.4326, -99.1332}, {20.6597, -103.3496}}},
+		{5, []Point{{90, 180}, {-90, -180}, {0, 0}}},
+		{6, []Point{{19.432608, -99.133209}, {21.161908, -86.851528}}},
+		{6, []Point{{-33.868820, 151.209296}, {51.507351, -0.127758}}},
+	} {
+		s := encodePolyline(c.pts, c.precision)
+		got, err := DecodePolyline(s, c.precision)
+		if err != nil || !samePoints(got, c.pts) {
+			t.Errorf("DecodePolyline(%q, %d) = %v, %v; want %v", s, c.precision, got, err, c.pts)
+		}
+	}
+
+	if pts, err := DecodePolyline("", 5); err != nil || len(pts) != 0 {
+		t.Errorf("empty polyline = %v, %v; want no points", pts, err)
+	}
+
+	for _, c := range []struct {
+		in        string
+		precision int
+		err       string
+	}{
+		{"_p~iF~ps|U_ulLnnqC_mqNvxq`", 5, "truncated"}, // last value cut
+		{"_p~iF~ps|U_ulL", 5, "truncated"},             // no longitude
+		{"_p~iF", 5, "truncated"},
+		{"_p~iF ps|U", 5, "invalid character at 5"},
+		{"_p~iF\x7fps|U", 5, "invalid character at 5"},
+		{"~~~~~~~~~~~~~~?", 5, "invalid character at 13"}, // overflows int64
+		{"_p~iF~ps|U", 0, "precision 0 out of range"},
+		{"_p~iF~ps|U", 11, "precision 11 out of range"},
+	} {
+		_, err := DecodePolyline(c.in, c.precision)
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("DecodePolyline(%q, %d): error %v, want %q", c.in, c.precision, err, c.err)
+		}
+	}
+}
+
+func TestParseRoute(t *testing.T) {
+	two := []Point{{19.4326, -99.1332}, {20.6597, -103.3496}}
+	for _, c := range []struct {
+		in string
+		// err is a substring of the error, or "" for two.
+		err string
+	}{
+		{encodePolyline(two, 5), ""},
+		{"  " + encodePolyline(two, 5) + "\n", ""},
+		{"19.4326,-99.1332;20.6597,-103.3496", ""},
+		{"19.4326, -99.1332\n20.6597, -103.3496\n", ""},
+		{"19.4326,-99.1332;;20.6597,-103.3496;", ""},
+		{`{"type":"LineString","coordinates":[[-99.1332,19.4326],[-103.3496,20.6597]]}`, ""},
+		{`{"type":"MultiLineString","coordinates":[[[-99.1332,19.4326]],[[-103.3496,20.6597]]]}`, ""},
+		{`{"type":"Feature","geometry":{"type":"LineString","coordinates":[[-99.1332,19.4326],[-103.3496,20.6597]]}}`, ""},
+
+		{"", "empty route"},
+		{" \t\n", "empty route"},
+		{"19.4326;-99.1332", "is not lat,lon"},
+		{"19.4326,-99.1332;20.6597,-10-3", "point 1"},
+		{"91,0", "out of range"},
+		{"0,-181", "out of range"},
+		{encodePolyline([]Point{{0, 0}, {95, 0}}, 5), "point 1 (95, 0) out of range"},
+		{"_p~iF~ps|U_ulL", "truncated"},
+		{`{"type":"LineString","coordinates":[[-99.1332]]}`, "position with 1 values"},
+		{`{"type":"Point","coordinates":[-99.1332,19.4326]}`, "unsupported GeoJSON type"},
+		{`{"type":"Feature"}`, "without geometry"},
+		{`{"type":`, "GeoJSON"},
+	} {
+		pts, err := ParseRoute(c.in, 5)
+		switch {
+		case c.err == "" && err != nil:
+			t.Errorf("%q: %v", c.in, err)
+		case c.err == "" && !samePoints(pts, two):
+			t.Errorf("%q = %v, want %v", c.in, pts, two)
+		case c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)):
+			t.Errorf("%q: error %v, want %q", c.in, err, c.err)
+		}
+	}
+}
+
+func TestSegments(t *testing.T) {
+	rows := [][]string{{"a"}, {"a", "b"}, {}, {"a"}, {"b"}, {"b"}, {"a"}}
+	got := segments(rows, func(s *string) string { return *s })
+	want := []RouteSegment[string]{
+		{Entry: 0, Exit: 1, Row: "a"},
+		{Entry: 3, Exit: 3, Row: "a"}, // a point without an answer ends the run
+		{Entry: 4, Exit: 5, Row: "b"},
+		{Entry: 6, Exit: 6, Row: "a"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("segments = %+v, want %+v", got, want)
+	}
+	if got := segments[string](nil, nil); got == nil || len(got) != 0 {
+		t.Errorf("segments of no points = %#v, want an empty slice", got)
+	}
+}
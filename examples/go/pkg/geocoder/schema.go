@@ -0,0 +1,99 @@
+package geocoder
+
+/*
+	Schema versions ("migrate" subcommand).
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	The loaders (load_geonames.py and the load subcommand) record the
+	version of the schema they created in the schema_version table, and
+	the snapshot subcommand in its snapshots. Every command that queries
+	the data checks it when it connects, and so can library users with
+	CheckSchema, so that an old database fails with
+
+	    database loaded with schema v1; this binary requires v2 — run migrate
+
+	rather than with a missing column halfway through a query. Databases
+	loaded before the table existed hold schema v1. A database newer than
+	the binary is refused as well.
+
+	migrate upgrades a database in place, one version at a time, with the
+	steps of schemaUpgrades, and records the new version; --dry-run only
+	reports what it would do. A database at the current version is left
+	as is (an unversioned v1 database gets its schema_version row).
+
+	Bump SchemaVersion with every change to the schema that the queries
+	depend on, in migrations/ and load_geonames.py, and add the step that
+	brings a database of the previous version up to date.
+
+	The command-line side is in cmd/reverse_geocode/schema.go.
+*/
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// SchemaVersion is the version of the schema this binary creates and
+// queries.
+const SchemaVersion = 1
+
+// ErrSchemaVersion is returned by CheckSchema for a database this binary
+// cannot query.
+var ErrSchemaVersion = errors.New("incompatible database schema")
+
+// DatabaseSchemaVersion returns the schema version of the GeoNames data in
+// db: 0 when there is none, 1 for loads that predate schema_version.
+func DatabaseSchemaVersion(db *gorm.DB) (int, error) {
+	var v *int
+	err := db.Raw("SELECT MAX(version) FROM " + TableName(db, "schema_version")).
+		Scan(&v).Error
+	if err == nil && v != nil {
+		return *v, nil
+	}
+	// No (or an empty) schema_version: tell an unversioned load from an
+	// empty database.
+	var n int64
+	if db.Raw("SELECT COUNT(*) FROM "+TableName(db, "geoname")+" WHERE 1 = 0").
+		Scan(&n).Error != nil {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+// CheckSchema reports whether this binary can query the GeoNames data in
+// db, with an error saying what to do when it cannot.
+func CheckSchema(db *gorm.DB) error {
+	v, err := DatabaseSchemaVersion(db)
+	switch {
+	case err != nil:
+		return err
+	case v == 0:
+		return fmt.Errorf("%w: the database has no GeoNames tables — run load",
+			ErrSchemaVersion)
+	case v < SchemaVersion:
+		return fmt.Errorf("%w: database loaded with schema v%d; this binary requires v%d — run migrate",
+			ErrSchemaVersion, v, SchemaVersion)
+	case v > SchemaVersion:
+		return fmt.Errorf("%w: database loaded with schema v%d; this binary supports up to v%d — upgrade the binary",
+			ErrSchemaVersion, v, SchemaVersion)
+	}
+	return nil
+}
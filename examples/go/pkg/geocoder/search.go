@@ -0,0 +1,262 @@
+package geocoder
+
+/*
+	Forward geocoding: place search by name.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Name matching is case-insensitive and uses LOWER(...) LIKE, which works
+	identically on PostgreSQL, MySQL/MariaDB and SQLite. Substring matches
+	cannot use the B-tree name indexes, so broad searches scan the table.
+	--fuzzy tolerates misspellings instead (see fuzzy.go).
+
+	Results come most populous first (best match first with --fuzzy).
+	--bias-country and --bias-point promote places in that country, or near
+	that point, without excluding the others: the best candidates are
+	fetched as usual — ten per result wanted, at least 100 — and re-ranked
+	in Go by their base score plus
+	    + 0.50                        place is in BiasCountry
+	    + 0.50 · exp(−d / 500 km)     place is d km from BiasPoint
+	where the base score is log10(population + 1) / 8 (0 … ~1), or the
+	similarity with --fuzzy.
+
+	The command-line side is in cmd/reverse_geocode/search.go.
+*/
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rgglez/geonames-loader/examples/go/internal/sqlbuild"
+	"gorm.io/gorm"
+)
+
+// SearchOptions selects places by name. Name filters are combined with AND;
+// empty fields do not restrict the results.
+type SearchOptions struct {
+	// Query matches a substring of name, asciiname or alternatenames.
+	Query string
+	// Name matches a substring of name or asciiname.
+	Name string
+	// NameEquals matches name or asciiname exactly.
+	NameEquals string
+	// NameStartsWith matches a prefix of name or asciiname.
+	NameStartsWith string
+
+	Country      string
+	FeatureClass string
+	FeatureCode  string
+
+	Limit  int
+	Offset int
+
+	// BiasCountry and BiasPoint rank places in this country, or near
+	// this point, higher without excluding others.
+	BiasCountry string
+	BiasPoint   *Point
+}
+
+// likeEscape escapes LIKE wildcards in s; patterns use ESCAPE '!', which
+// needs no quoting on any supported dialect.
+func likeEscape(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(
+		strings.ToLower(s),
+	)
+}
+
+// filter adds the conditions of o on the geoname table (alias g) to q.
+func (o SearchOptions) filter(q *sqlbuild.Select) *sqlbuild.Select {
+	q.Where("g.latitude IS NOT NULL").Where("g.longitude IS NOT NULL")
+	if o.Query != "" {
+		p := "%" + likeEscape(o.Query) + "%"
+		q.Where("(LOWER(g.name) LIKE ? ESCAPE '!'"+
+			" OR LOWER(g.asciiname) LIKE ? ESCAPE '!'"+
+			" OR LOWER(g.alternatenames) LIKE ? ESCAPE '!')", p, p, p)
+	}
+	if o.Name != "" {
+		p := "%" + likeEscape(o.Name) + "%"
+		q.Where("(LOWER(g.name) LIKE ? ESCAPE '!'"+
+			" OR LOWER(g.asciiname) LIKE ? ESCAPE '!')", p, p)
+	}
+	if o.NameEquals != "" {
+		v := strings.ToLower(o.NameEquals)
+		q.Where("(LOWER(g.name) = ? OR LOWER(g.asciiname) = ?)", v, v)
+	}
+	if o.NameStartsWith != "" {
+		p := likeEscape(o.NameStartsWith) + "%"
+		q.Where("(LOWER(g.name) LIKE ? ESCAPE '!'"+
+			" OR LOWER(g.asciiname) LIKE ? ESCAPE '!')", p, p)
+	}
+	return q.
+		WhereIf(o.Country != "", "g.country = ?", o.Country).
+		WhereIf(o.FeatureClass != "", "g.fclass = ?", o.FeatureClass).
+		WhereIf(o.FeatureCode != "", "g.fcode = ?", o.FeatureCode)
+}
+
+// searchSQL returns the query for a page of the places of the geoname
+// table (named table) matching opts, most populous first.
+func searchSQL(table string, opts SearchOptions) (string, []any) {
+	q := new(sqlbuild.Select).
+		Columns("g", "geonameid", "name", "fclass", "fcode", "country",
+			"admin1", "admin2", "population", "latitude", "longitude").
+		From(table, "g")
+	return opts.filter(q).
+		OrderBy(sqlbuild.E("COALESCE(g.population, 0) DESC, g.geonameid")).
+		Limit(opts.Limit).
+		Offset(opts.Offset).
+		Build()
+}
+
+// SearchPlaces returns places matching opts, most populous first, or by
+// biased score (set in Score) when opts has a bias.
+func SearchPlaces(db *gorm.DB, opts SearchOptions) ([]Place, error) {
+	page := opts
+	if opts.Biased() {
+		opts = opts.candidates()
+	}
+	var rows []Place
+	rawSQL, args := searchSQL(TableName(db, "geoname"), opts)
+	if err := db.Raw(rawSQL, args...).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	if page.Biased() {
+		for i := range rows {
+			r := &rows[i]
+			r.Score = math.Log10(float64(r.Population)+1)/8 + page.biasBoost(r)
+			r.Score = math.Round(r.Score*1e4) / 1e4
+		}
+		rows = page.rerank(rows, func(r *Place) float64 { return r.Score })
+	}
+	return rows, nil
+}
+
+// ---------------------------------------------------------------------------
+// Biasing
+// ---------------------------------------------------------------------------
+
+const (
+	biasCandidates    = 10  // candidates fetched per result wanted
+	biasMinCandidates = 100 // ... and at least this many
+	biasScaleKm       = 500 // distance at which the BiasPoint boost drops to 1/e
+)
+
+// Biased reports whether o ranks by bias.
+func (o SearchOptions) Biased() bool {
+	return o.BiasCountry != "" || o.BiasPoint != nil
+}
+
+// candidates returns o fetching, from the first match, the candidates a
+// biased ranking chooses the page of o from.
+func (o SearchOptions) candidates() SearchOptions {
+	o.Limit = max(biasMinCandidates, biasCandidates*(o.Offset+o.Limit))
+	o.Offset = 0
+	return o
+}
+
+// biasBoost returns what o's biases add to the score of r.
+func (o SearchOptions) biasBoost(r *Place) float64 {
+	var b float64
+	if o.BiasCountry != "" && r.Country == o.BiasCountry {
+		b += 0.5
+	}
+	if p := o.BiasPoint; p != nil {
+		d := haversineKm(p.Lat, p.Lon, r.Latitude, r.Longitude)
+		b += 0.5 * math.Exp(-d/biasScaleKm)
+	}
+	return b
+}
+
+// rerank sorts rows by score plus the bias boost, best first, and returns
+// the page o asks for.
+func (o SearchOptions) rerank(rows []Place, score func(*Place) float64) []Place {
+	key := make(map[int64]float64, len(rows))
+	for i := range rows {
+		key[rows[i].Geonameid] = score(&rows[i]) + o.biasBoost(&rows[i])
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if key[a.Geonameid] != key[b.Geonameid] {
+			return key[a.Geonameid] > key[b.Geonameid]
+		}
+		return a.Population > b.Population
+	})
+	if o.Offset >= len(rows) {
+		return nil
+	}
+	rows = rows[o.Offset:]
+	if len(rows) > o.Limit {
+		rows = rows[:o.Limit]
+	}
+	return rows
+}
+
+// parseLatLon parses a "lat,lon" pair.
+func parseLatLon(s string) (*Point, error) {
+	lat, lon, ok := strings.Cut(s, ",")
+	if !ok {
+		return nil, fmt.Errorf("want lat,lon")
+	}
+	var p Point
+	var err error
+	if p.Lat, err = strconv.ParseFloat(strings.TrimSpace(lat), 64); err != nil {
+		return nil, fmt.Errorf("%q is not a number", lat)
+	}
+	if p.Lon, err = strconv.ParseFloat(strings.TrimSpace(lon), 64); err != nil {
+		return nil, fmt.Errorf("%q is not a number", lon)
+	}
+	if p.Lat < -90 || p.Lat > 90 || p.Lon < -180 || p.Lon > 180 {
+		return nil, fmt.Errorf("coordinates out of range")
+	}
+	return &p, nil
+}
+
+// PlaceByID returns the geoname entry geonameid, or an error matching
+// ErrPlaceNotFound when there is none with coordinates.
+func PlaceByID(db *gorm.DB, geonameid int64) (*Place, error) {
+	var rows []Place
+	rawSQL, args := new(sqlbuild.Select).
+		Columns("g", "geonameid", "name", "fclass", "fcode", "country",
+			"admin1", "admin2", "population", "latitude", "longitude").
+		From(TableName(db, "geoname"), "g").
+		Where("g.geonameid = ?", geonameid).
+		Where("g.latitude IS NOT NULL").
+		Where("g.longitude IS NOT NULL").
+		Build()
+	if err := db.Raw(rawSQL, args...).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: geonameid %d", ErrPlaceNotFound, geonameid)
+	}
+	return &rows[0], nil
+}
+
+// CountPlaces returns the total number of places matching opts, ignoring
+// Limit and Offset.
+func CountPlaces(db *gorm.DB, opts SearchOptions) (int64, error) {
+	var n int64
+	rawSQL, args := opts.filter(new(sqlbuild.Select).
+		Column(sqlbuild.E("count(*)")).
+		From(TableName(db, "geoname"), "g")).
+		Build()
+	res := db.Raw(rawSQL, args...).Scan(&n)
+	return n, res.Error
+}
@@ -0,0 +1,201 @@
+package geocoder
+
+/*
+	Read-only SQLite snapshots ("snapshot" subcommand) and the R*Tree
+	strategy that queries them.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Copies what reverse geocoding needs from any source database into a new
+	SQLite file meant to be shipped read-only inside an application image:
+
+	  geoname            rows of --country / --fclass, with the columns the
+	                     queries read (alternatenames only with
+	                     --with-alternates)
+	  postalcodes        rows of --country, without the derived columns
+	  admin1codesascii,  rows of --country
+	  admin2codesascii,
+	  timezones
+	  countryinfo,       all rows
+	  featurecodes,
+	  continentcodes,
+	  meta
+	  alternatename      only with --with-alternates, for the places copied
+
+	The tables keep the loader's schema (migrations/sqlite/schema.sql), so
+	every subcommand works against a snapshot; columns that are not copied
+	are NULL and cost one byte per row. Instead of the loader's indexes the
+	snapshot gets the few indexes lookups need plus two R*Tree tables,
+	geoname_rtree and postalcodes_rtree, and is VACUUMed.
+
+	Open it read-only, e.g. from a read-only container filesystem:
+	    --url 'sqlite://file:/app/geonames-mx.db?immutable=1'
+
+	Strategy "rtree" (picked automatically when the R*Tree tables exist)
+	answers a query by growing a bounding box around the point until it
+	provably holds the nearest rows: every point outside a box built for
+	angular radius d is farther than d, so once the limit-th candidate in
+	the box is within d the answer is exact. The first box has a 10 km
+	radius; an over-full box shrinks the next one to the distance of its
+	limit-th row.
+
+	The command-line side is in cmd/reverse_geocode/snapshot.go.
+*/
+
+import (
+	"math"
+	"slices"
+
+	"github.com/rgglez/geonames-loader/examples/go/internal/sqlbuild"
+	"gorm.io/gorm"
+)
+
+// ---------------------------------------------------------------------------
+// R*Tree queries (SQLite snapshots)
+// ---------------------------------------------------------------------------
+
+// rtreeStartKm is the radius of the first box an R*Tree query tries.
+const rtreeStartKm = 10.0
+
+// rtreeBox returns the [minlat, maxlat, minlon, maxlon] box, in degrees,
+// holding every point within angular distance d (radians) of (lat, lon),
+// and whether it covers the whole globe.
+func rtreeBox(lat, lon, d float64) ([4]float64, bool) {
+	deg := 180 / math.Pi
+	minLat, maxLat := lat-d*deg, lat+d*deg
+	if minLat <= -90 || maxLat >= 90 {
+		// A pole is within reach: every longitude is.
+		return [4]float64{max(minLat, -90), min(maxLat, 90), -180, 180},
+			minLat <= -90 && maxLat >= 90
+	}
+	s := math.Sin(d) / math.Cos(lat/deg)
+	if s >= 1 {
+		return [4]float64{minLat, maxLat, -180, 180}, false
+	}
+	dLon := math.Asin(s) * deg
+	if lon-dLon < -180 || lon+dLon > 180 {
+		// Crossing the antimeridian: widen rather than split the box.
+		return [4]float64{minLat, maxLat, -180, 180}, false
+	}
+	return [4]float64{minLat, maxLat, lon - dLon, lon + dLon}, false
+}
+
+// rtreeNearest grows a box around (lat, lon) until query, which returns
+// the rows inside a box sorted by distance, provably returns the nearest
+// limit rows.
+func rtreeNearest[T any](
+	lat, lon float64, limit int,
+	query func(box [4]float64) ([]T, error), dist func(*T) float64,
+) ([]T, error) {
+	d := rtreeStartKm / earthRadiusKm
+	for {
+		box, world := rtreeBox(lat, lon, d)
+		rows, err := query(box)
+		if err != nil || world {
+			return rows, err
+		}
+		if len(rows) >= limit {
+			kth := dist(&rows[limit-1]) / earthRadiusKm
+			if kth <= d {
+				return rows, nil
+			}
+			// The limit-th row bounds the answer: one more query with
+			// that radius is exact.
+			d = kth
+			continue
+		}
+		d = math.Min(d*4, math.Pi)
+	}
+}
+
+// rtreeSQL returns the query for the rows of table (alias alias) whose
+// R*Tree entry, in rtree aliased r, overlaps box (min lat, max lat, min
+// lon, max lon); overlap, since the R*Tree rounds stored coordinates
+// outwards. cols are the columns of table wanted.
+func rtreeSQL(
+	rtree, join, table, alias string, cols []string, lat, lon float64, box [4]float64,
+) *sqlbuild.Select {
+	return new(sqlbuild.Select).
+		Columns(alias, cols...).
+		Column(sqlbuild.E(sqlbuild.Haversine(lat, lon, alias)).As("distance_km")).
+		From(rtree, "r").
+		Join(sqlbuild.E("JOIN "+table+" "+alias+" ON "+join)).
+		Where("r.maxlat >= ? AND r.minlat <= ? AND r.maxlon >= ? AND r.minlon <= ?",
+			box[0], box[1], box[2], box[3])
+}
+
+func queryPostalRTree(
+	db *gorm.DB, lat, lon float64, opts QueryOptions,
+) ([]PostalCode, error) {
+	table := TableName(db, "postalcodes")
+	return rtreeNearest(lat, lon, opts.Limit,
+		func(box [4]float64) ([]PostalCode, error) {
+			var rows []PostalCode
+			q := rtreeSQL("postalcodes_rtree", "p.rowid = r.id", table, "p",
+				opts.columns(PostalFields), lat, lon, box)
+			rawSQL, args := opts.postalFilter(q, "p").
+				OrderBy(sqlbuild.E("distance_km")).
+				Limit(opts.Limit).
+				Build()
+			err := db.Raw(rawSQL, args...).Scan(&rows).Error
+			return rows, err
+		},
+		func(r *PostalCode) float64 { return r.DistanceKm })
+}
+
+func queryGeonameRTree(
+	db *gorm.DB, lat, lon float64, opts QueryOptions,
+) ([]Place, error) {
+	cols := opts
+	if opts.selects("postalcode") && !opts.selects("country") {
+		// The nearest-postal lookup below needs the place's country.
+		cols.Fields = append(slices.Clone(opts.Fields), "country")
+	}
+	table := TableName(db, "geoname")
+	rows, err := rtreeNearest(lat, lon, opts.Limit,
+		func(box [4]float64) ([]Place, error) {
+			var rows []Place
+			q := rtreeSQL("geoname_rtree", "g.geonameid = r.id", table, "g",
+				cols.columns(PlaceFields), lat, lon, box)
+			rawSQL, args := opts.geonameFilter(q, "g").
+				OrderBy(sqlbuild.E("distance_km")).
+				Limit(opts.Limit).
+				Build()
+			err := db.Raw(rawSQL, args...).Scan(&rows).Error
+			return rows, err
+		},
+		func(r *Place) float64 { return r.DistanceKm })
+	if err != nil || !opts.selects("postalcode") {
+		return rows, err
+	}
+
+	// Nearest postal code of each place, within the pre-filter radius the
+	// other strategies use.
+	for i := range rows {
+		g := &rows[i]
+		postal, err := queryPostalRTree(db, g.Latitude, g.Longitude,
+			QueryOptions{Limit: 1, Country: g.Country})
+		if err != nil {
+			return nil, err
+		}
+		if len(postal) > 0 && postal[0].DistanceKm <= geoRadiusM/1000 {
+			g.Postalcode = postal[0].Postalcode
+		}
+	}
+	return rows, nil
+}
@@ -0,0 +1,125 @@
+package geocoder
+
+/*
+	Staged search radius of the SQL strategies.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	A nearest-row query has to compute the distance of every row its
+	pre-filter lets through — and on MySQL and SQLite, of a geoname
+	query, run the nearest-postal subquery for each. With one fixed
+	radius that is every row within 500 km on PostgreSQL (earth_box,
+	ST_DWithin) and the whole table with Haversine, although in a city
+	the answer is a few hundred metres away.
+
+	So the single-point queries of the postgis, earthdistance and
+	haversine strategies try the radii of radiusStagesKm in turn (5 km,
+	50 km, then 500 km) and stop at the first that provably holds the
+	answer: the limit-th row is within the radius, so every row outside
+	the pre-filter is farther. A radius holding more than limit rows, the
+	limit-th of them beyond it (in the corners of a box), is followed by
+	one query with the radius of that row, which is exact. The last stage
+	is the query as it was: the 500 km radius (or MaxDistanceKm) on
+	PostgreSQL and, with Haversine, no pre-filter at all unless
+	MaxDistanceKm is set, so remote points find the same rows as before.
+
+	Haversine gets its radius as a latitude/longitude box (rtreeBox), which
+	uses the latitude indexes of geoname and postalcodes. A query thus
+	runs at most len(radiusStagesKm) + 1 statements: dense areas answer at
+	the first, and remote ones pay a few index range scans on top of the
+	full one. Stages at or beyond MaxDistanceKm are skipped. The batch
+	queries, POI queries, the memory strategy and the R*Tree strategy
+	(which grows its own boxes, see snapshot.go) are unchanged.
+
+	The stages share a time budget, stageBudget, checked before each
+	stage after the first. Once it is spent the query skips to its final
+	statement, so a slow database pays for at most one stage on top of
+	the plain query, and the answer is still the nearest limit rows: the
+	rows of a stage are never returned unless they are provably so.
+*/
+
+import "time"
+
+// radiusStagesKm are the pre-filter radii a single-point SQL query tries
+// before its final one.
+var radiusStagesKm = []float64{5, 50, 500}
+
+// stageSlackKm widens the exact radius after an over-full stage, so that
+// rounding cannot drop the limit-th row from the pre-filter.
+const stageSlackKm = 0.001
+
+// stageBudget is the time the stages of one query may take before it
+// skips to the final statement.
+var stageBudget = 250 * time.Millisecond
+
+// stagedQuery runs query with the radii of radiusStagesKm as
+// opts.MaxDistanceKm until one provably returns the nearest opts.Limit
+// rows, then with opts itself. dist returns the distance of a row. Past
+// stageBudget it runs the final query instead of trying further radii.
+func stagedQuery[T any](
+	strategy string, opts QueryOptions,
+	query func(QueryOptions) ([]T, error), dist func(*T) float64,
+) ([]T, error) {
+	// The radius of the final query; 0 is none (a Haversine full scan).
+	final := opts.MaxDistanceKm
+	if strategy != StrategyHaversine {
+		final = opts.radiusM() / 1000
+	}
+	start := time.Now()
+	for i, r := range radiusStagesKm {
+		if opts.Limit <= 0 || final > 0 && r >= final {
+			break
+		}
+		if i > 0 && time.Since(start) > stageBudget {
+			break
+		}
+		o := opts
+		o.MaxDistanceKm = r
+		rows, err := query(o)
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) < opts.Limit {
+			continue
+		}
+		kth := dist(&rows[opts.Limit-1])
+		if kth <= r {
+			return rows, nil
+		}
+		if final > 0 && kth+stageSlackKm >= final {
+			break
+		}
+		o.MaxDistanceKm = kth + stageSlackKm
+		return query(o)
+	}
+	return query(opts)
+}
+
+// haversineBox returns the box (min lon, min lat, max lon, max lat)
+// holding every point within opts.MaxDistanceKm of (lat, lon), or nil
+// when there is no radius or the box is the whole globe.
+func (o QueryOptions) haversineBox(lat, lon float64) []float64 {
+	if o.MaxDistanceKm <= 0 {
+		return nil
+	}
+	b, world := rtreeBox(lat, lon, o.MaxDistanceKm/earthRadiusKm)
+	if world {
+		return nil
+	}
+	return []float64{b[2], b[0], b[3], b[1]}
+}
@@ -0,0 +1,107 @@
+package geocoder
+
+/*
+	Tests of the staged radius search.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestStagedQuery(t *testing.T) {
+	defer func(b time.Duration) { stageBudget = b }(stageBudget)
+	dist := func(d *float64) float64 { return *d }
+
+	for _, c := range []struct {
+		name     string
+		strategy string
+		opts     QueryOptions
+		// rows are the distances of the table's rows, in order. box
+		// widens the pre-filter like the corners of a box: a radius r
+		// lets through the rows within r*box.
+		rows   []float64
+		box    float64
+		budget time.Duration
+		// want are the rows returned and radii the MaxDistanceKm of
+		// each query run.
+		want, radii string
+	}{
+		{"first stage", StrategyHaversine, QueryOptions{Limit: 3},
+			[]float64{0.1, 0.5, 1, 10}, 1, time.Hour, "[0.1 0.5 1]", "[5]"},
+		{"second stage", StrategyHaversine, QueryOptions{Limit: 3},
+			[]float64{3, 20, 30, 300}, 1, time.Hour, "[3 20 30]", "[5 50]"},
+		{"last stage", StrategyHaversine, QueryOptions{Limit: 3},
+			[]float64{100, 200, 300, 600}, 1, time.Hour, "[100 200 300]", "[5 50 500]"},
+		{"beyond the stages", StrategyHaversine, QueryOptions{Limit: 3},
+			[]float64{600, 700, 800, 900}, 1, time.Hour, "[600 700 800]", "[5 50 500 0]"},
+		{"over-full stage", StrategyHaversine, QueryOptions{Limit: 3},
+			[]float64{1, 2, 5.5, 7}, 1.2, time.Hour, "[1 2 5.5]", "[5 5.501]"},
+		{"max distance", StrategyHaversine, QueryOptions{Limit: 3, MaxDistanceKm: 30},
+			[]float64{10, 20, 25, 40}, 1, time.Hour, "[10 20 25]", "[5 30]"},
+		{"postgis radius", StrategyPostGIS, QueryOptions{Limit: 3},
+			[]float64{100, 200, 300}, 1, time.Hour, "[100 200 300]", "[5 50 0]"},
+		{"no limit", StrategyHaversine, QueryOptions{},
+			[]float64{1, 2}, 1, time.Hour, "[1 2]", "[0]"},
+		// The budget is checked before every stage after the first: once
+		// spent, the final query answers rather than a stage's rows.
+		{"budget spent", StrategyHaversine, QueryOptions{Limit: 3},
+			[]float64{3, 20, 30, 300}, 1, 0, "[3 20 30]", "[5 0]"},
+		{"budget spent, first stage", StrategyHaversine, QueryOptions{Limit: 3},
+			[]float64{0.1, 0.5, 1, 10}, 1, 0, "[0.1 0.5 1]", "[5]"},
+	} {
+		stageBudget = c.budget
+		var radii []float64
+		rows, err := stagedQuery(c.strategy, c.opts, func(o QueryOptions) ([]float64, error) {
+			radii = append(radii, o.MaxDistanceKm)
+			var out []float64
+			for _, d := range c.rows {
+				if o.MaxDistanceKm == 0 || d <= o.MaxDistanceKm*c.box {
+					out = append(out, d)
+				}
+			}
+			if o.Limit > 0 && len(out) > o.Limit {
+				out = out[:o.Limit]
+			}
+			return out, nil
+		}, dist)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if got := fmt.Sprint(rows); got != c.want {
+			t.Errorf("%s: rows %s, want %s", c.name, got, c.want)
+		}
+		if got := fmt.Sprint(radii); got != c.radii {
+			t.Errorf("%s: radii %s, want %s", c.name, got, c.radii)
+		}
+	}
+}
+
+func TestStagedQueryError(t *testing.T) {
+	errQuery := errors.New("query failed")
+	calls := 0
+	_, err := stagedQuery(StrategyHaversine, QueryOptions{Limit: 1},
+		func(QueryOptions) ([]float64, error) { calls++; return nil, errQuery },
+		func(d *float64) float64 { return *d })
+	if !errors.Is(err, errQuery) || calls != 1 {
+		t.Errorf("got %v after %d queries, want the error after 1", err, calls)
+	}
+}
@@ -0,0 +1,136 @@
+package geocoder
+
+/*
+	Distance strategy selection.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	By default the query dispatchers pick a strategy from the dialect and
+	the installed extensions (see query.go). --strategy, the "strategy" key
+	of the server config and the WithStrategy Geocoder option force one
+	instead, for benchmarking or when auto-detection guesses wrong — e.g.
+	the geography type exists but the GIST index was never built, so the
+	PostGIS path scans the whole table and earthdistance would be faster.
+
+	  auto           detect (default)
+	  postgis        ST_DWithin / ST_Distance on geography; PostgreSQL with
+	                 PostGIS or Ganos
+	  earthdistance  earth_box / earth_distance; PostgreSQL with cube and
+	                 earthdistance
+	  haversine      Haversine formula in SQL; any dialect
+	  memory         in-process k-d tree loaded on first use; any dialect
+	                 (see memory.go)
+	  rtree          expanding R*Tree box search; SQLite snapshots (see
+	                 snapshot.go)
+*/
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Distance strategies accepted by --strategy and WithStrategy.
+const (
+	StrategyAuto          = "auto"
+	StrategyPostGIS       = "postgis"
+	StrategyEarthdistance = "earthdistance"
+	StrategyHaversine     = "haversine"
+	StrategyMemory        = "memory"
+	StrategyRTree         = "rtree"
+)
+
+// detectStrategy returns the strategy auto-detection picks for db.
+func detectStrategy(db *gorm.DB) string {
+	if !IsPostgres(db) {
+		if capabilitiesFor(db).RTree {
+			return StrategyRTree
+		}
+		return StrategyHaversine
+	}
+	if hasGeographyType(db) {
+		return StrategyPostGIS
+	}
+	return StrategyEarthdistance
+}
+
+// ResolveStrategy returns s, or the detected strategy when s is empty or
+// auto.
+func ResolveStrategy(db *gorm.DB, s string) string {
+	if s == "" || s == StrategyAuto {
+		return detectStrategy(db)
+	}
+	return s
+}
+
+// CheckStrategy reports whether s is a known strategy usable with db.
+// Strategies the dialect cannot run yield ErrUnsupportedDialect.
+func CheckStrategy(db *gorm.DB, s string) error {
+	if err := SupportedDialect(db); err != nil {
+		return err
+	}
+	switch s {
+	case "", StrategyAuto, StrategyHaversine, StrategyMemory:
+		return nil
+	case StrategyEarthdistance:
+		if !IsPostgres(db) {
+			return fmt.Errorf("%w: strategy %q requires PostgreSQL",
+				ErrUnsupportedDialect, s)
+		}
+		if c := capabilitiesFor(db); c.Complete() && !c.Earthdistance {
+			return fmt.Errorf(
+				"strategy %q requires the earthdistance extension", s)
+		}
+		return nil
+	case StrategyPostGIS:
+		if !IsPostgres(db) || !hasGeographyType(db) {
+			return fmt.Errorf(
+				"%w: strategy %q requires PostgreSQL with the geography "+
+					"type (PostGIS or Ganos)", ErrUnsupportedDialect, s)
+		}
+		return nil
+	case StrategyRTree:
+		if db.Dialector.Name() != "sqlite" || !capabilitiesFor(db).RTree {
+			return fmt.Errorf(
+				"%w: strategy %q requires a SQLite snapshot with R*Tree "+
+					"tables", ErrUnsupportedDialect, s)
+		}
+		return nil
+	}
+	return fmt.Errorf(
+		"unknown strategy %q (want auto, postgis, earthdistance, "+
+			"haversine, memory or rtree)", s)
+}
+
+// DescribeStrategy returns a human-readable name for strategy s on db.
+func DescribeStrategy(db *gorm.DB, s string) string {
+	switch ResolveStrategy(db, s) {
+	case StrategyPostGIS:
+		if HasGanos(db) {
+			return "Ganos/ganos_spatialref (GIST index)"
+		}
+		return "PostGIS (GIST index)"
+	case StrategyEarthdistance:
+		return "earthdistance (GIST index)"
+	case StrategyMemory:
+		return "in-memory k-d tree"
+	case StrategyRTree:
+		return "R*Tree (SQLite snapshot)"
+	}
+	return "Haversine (full scan)"
+}
@@ -0,0 +1,275 @@
+package geocoder
+
+/*
+	Autocomplete: ranked place suggestions for a typed prefix.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Suggest matches the prefix against name and asciiname, and against
+	alternate names in the alternatename table, fetching the most populous
+	candidates of each through a prefix index. Candidates are then ranked in
+	Go by:
+	    log10(population + 1) / 8         0 … ~1
+	    + 0.50  matched text equals the prefix
+	    + 0.25  matched on the main name rather than an alternate
+	    + 0.50  place is in BiasCountry
+	    + 0.10  capital or first-level seat (PPLC, PPLA)
+
+	Keeping typeahead under ~50 ms needs prefix indexes the loader does not
+	create; SuggestIndexDDL returns them per dialect and --create-indexes
+	applies them:
+	  - PostgreSQL: lower(name|asciiname|alternatename) text_pattern_ops
+	    B-tree indexes, matched with lower(col) LIKE 'prefix%'.
+	  - MySQL/MariaDB: none; the loader's name indexes use the
+	    case-insensitive collation, so col LIKE 'prefix%' already uses them.
+	  - SQLite: COLLATE NOCASE indexes, which LIKE (case-insensitive by
+	    default) can use for a fixed prefix.
+
+	The command-line side is in cmd/reverse_geocode/suggest.go.
+*/
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/rgglez/geonames-loader/examples/go/internal/textutil"
+	"gorm.io/gorm"
+)
+
+// SuggestOptions tunes Suggest.
+type SuggestOptions struct {
+	// Limit is the number of suggestions returned (default 10).
+	Limit int
+	// Country restricts suggestions to one ISO 3166-1 alpha-2 code.
+	Country string
+	// BiasCountry ranks places in this country higher without excluding
+	// others.
+	BiasCountry string
+	// FeatureClasses restricts the feature classes considered (default
+	// P and A: populated places and administrative divisions).
+	FeatureClasses []string
+	// Alternates also matches alternate names (default true via
+	// DefaultSuggestOptions).
+	Alternates bool
+}
+
+// DefaultSuggestOptions returns the options used when none are given.
+func DefaultSuggestOptions() SuggestOptions {
+	return SuggestOptions{Limit: 10, FeatureClasses: []string{"P", "A"}, Alternates: true}
+}
+
+// Suggestion is one ranked autocomplete entry.
+type Suggestion struct {
+	Geonameid  int64   `gorm:"column:geonameid"  json:"geonameid"`
+	Name       string  `gorm:"column:name"       json:"name"`
+	Matched    string  `gorm:"column:matched"    json:"matched"`
+	Label      string  `gorm:"-"                 json:"label"`
+	Fclass     string  `gorm:"column:fclass"     json:"fclass"`
+	Fcode      string  `gorm:"column:fcode"      json:"fcode"`
+	Country    string  `gorm:"column:country"    json:"country"`
+	Admin1     string  `gorm:"column:admin1"     json:"admin1,omitempty"`
+	Population int64   `gorm:"column:population" json:"population"`
+	Latitude   float64 `gorm:"column:latitude"   json:"latitude"`
+	Longitude  float64 `gorm:"column:longitude"  json:"longitude"`
+	Score      float64 `gorm:"-"                 json:"score"`
+}
+
+// SuggestIndexDDL returns the statements creating the prefix indexes
+// Suggest relies on for the dialect of db.
+func SuggestIndexDDL(db *gorm.DB) []string {
+	geoname, alt := TableName(db, "geoname"), TableName(db, "alternatename")
+	switch db.Dialector.Name() {
+	case "postgres":
+		return []string{
+			"CREATE INDEX IF NOT EXISTS geoname_lower_name_prefix_idx" +
+				" ON " + geoname + " (lower(name) text_pattern_ops)",
+			"CREATE INDEX IF NOT EXISTS geoname_lower_asciiname_prefix_idx" +
+				" ON " + geoname + " (lower(asciiname) text_pattern_ops)",
+			"CREATE INDEX IF NOT EXISTS alternatename_lower_prefix_idx" +
+				" ON " + alt + " (lower(alternatename) text_pattern_ops)",
+		}
+	case "sqlite":
+		return []string{
+			"CREATE INDEX IF NOT EXISTS geoname_name_nocase_idx" +
+				" ON " + geoname + " (name COLLATE NOCASE)",
+			"CREATE INDEX IF NOT EXISTS geoname_asciiname_nocase_idx" +
+				" ON " + geoname + " (asciiname COLLATE NOCASE)",
+			"CREATE INDEX IF NOT EXISTS alternatename_nocase_idx" +
+				" ON " + alt + " (alternatename COLLATE NOCASE)",
+		}
+	}
+	return nil
+}
+
+// prefixMatch returns a case-insensitive "col starts with ?" predicate in
+// the form the dialect's prefix index can serve.
+func prefixMatch(dialect, col string) string {
+	if dialect == "postgres" {
+		return "lower(" + col + ") LIKE ? ESCAPE '!'"
+	}
+	return col + " LIKE ? ESCAPE '!'"
+}
+
+// Suggest returns up to opts.Limit places whose name, ASCII name or (with
+// opts.Alternates) alternate name starts with prefix, best first.
+func (g *Geocoder) Suggest(prefix string, opts SuggestOptions) ([]Suggestion, error) {
+	prefix = strings.TrimSpace(prefix)
+	if prefix == "" {
+		return nil, nil
+	}
+	key := fmt.Sprintf("suggest|%s|%+v", prefix, opts)
+	return guarded(g, key, func() ([]Suggestion, error) {
+		return g.suggest(prefix, opts)
+	})
+}
+
+func (g *Geocoder) suggest(prefix string, opts SuggestOptions) ([]Suggestion, error) {
+	if opts.Limit <= 0 {
+		opts.Limit = 10
+	}
+	if len(opts.FeatureClasses) == 0 {
+		opts.FeatureClasses = []string{"P", "A"}
+	}
+	dialect := g.db.Dialector.Name()
+	pattern := likeEscape(prefix) + "%"
+
+	// Filters shared by both candidate queries.
+	filter := "g.fclass IN (?" + strings.Repeat(", ?", len(opts.FeatureClasses)-1) + ")"
+	var filterArgs []interface{}
+	for _, fc := range opts.FeatureClasses {
+		filterArgs = append(filterArgs, fc)
+	}
+	if opts.Country != "" {
+		filter += " AND g.country = ?"
+		filterArgs = append(filterArgs, opts.Country)
+	}
+	// Fetch more candidates than needed so that the biased ranking can
+	// promote less populous places.
+	candidates := opts.Limit * 5
+
+	const cols = `g.geonameid, g.name, g.fclass, g.fcode, g.country,
+		       g.admin1, COALESCE(g.population, 0) AS population,
+		       g.latitude, g.longitude`
+
+	var byName []Suggestion
+	args := append([]interface{}{pattern, pattern}, filterArgs...)
+	args = append(args, candidates)
+	err := g.db.Raw(fmt.Sprintf(`
+		SELECT %s, g.name AS matched
+		FROM %s g
+		WHERE (%s OR %s)
+		  AND %s
+		ORDER BY COALESCE(g.population, 0) DESC
+		LIMIT ?`,
+		cols, TableName(g.db, "geoname"), prefixMatch(dialect, "g.name"), prefixMatch(dialect, "g.asciiname"),
+		filter,
+	), args...).Scan(&byName).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var byAlt []Suggestion
+	if opts.Alternates {
+		args := append([]interface{}{pattern, false}, filterArgs...)
+		args = append(args, candidates)
+		err := g.db.Raw(fmt.Sprintf(`
+			SELECT %s, a.alternatename AS matched
+			FROM %s a
+			JOIN %s g ON g.geonameid = a.geonameid
+			WHERE %s
+			  AND (a.isolanguage IS NULL
+			       OR a.isolanguage NOT IN ('link', 'post', 'wkdt', 'unlc'))
+			  AND (a.ishistoric IS NULL OR a.ishistoric = ?)
+			  AND %s
+			ORDER BY COALESCE(g.population, 0) DESC
+			LIMIT ?`,
+			cols, TableName(g.db, "alternatename"), TableName(g.db, "geoname"), prefixMatch(dialect, "a.alternatename"), filter,
+		), args...).Scan(&byAlt).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	lower := strings.ToLower(prefix)
+	best := map[int64]Suggestion{}
+	add := func(s Suggestion, primary bool) {
+		s.Score = math.Log10(float64(s.Population)+1) / 8
+		if strings.ToLower(s.Matched) == lower {
+			s.Score += 0.5
+		}
+		if primary {
+			s.Score += 0.25
+		}
+		if opts.BiasCountry != "" && s.Country == opts.BiasCountry {
+			s.Score += 0.5
+		}
+		if s.Fcode == "PPLC" || s.Fcode == "PPLA" {
+			s.Score += 0.1
+		}
+		s.Score = math.Round(s.Score*1e4) / 1e4
+		if prev, ok := best[s.Geonameid]; !ok || s.Score > prev.Score {
+			best[s.Geonameid] = s
+		}
+	}
+	for _, s := range byName {
+		add(s, true)
+	}
+	for _, s := range byAlt {
+		add(s, false)
+	}
+
+	out := make([]Suggestion, 0, len(best))
+	for _, s := range best {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].Geonameid < out[j].Geonameid
+	})
+	if len(out) > opts.Limit {
+		out = out[:opts.Limit]
+	}
+
+	for i := range out {
+		s := &out[i]
+		names, err := g.AdminNames(s.Country, s.Admin1, "")
+		if err != nil {
+			return nil, err
+		}
+		parts := []string{s.Name}
+		if s.Fclass != "A" || !strings.HasPrefix(s.Fcode, "PCL") {
+			parts = append(parts, names.Admin1, names.Country)
+		}
+		s.Label = textutil.JoinDistinct(parts)
+	}
+	return out, nil
+}
+
+// CreateSuggestIndexes applies SuggestIndexDDL for db's dialect.
+func CreateSuggestIndexes(db *gorm.DB) error {
+	for _, stmt := range SuggestIndexDDL(db) {
+		if err := db.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("%s: %w", stmt, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package geocoder
+
+/*
+	Table names.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Config:
+	    database:
+	      url: postgres://geo:secret@db/warehouse
+	      tables:
+	        schema: tenant_a          # qualifies every unqualified name
+	        geoname: geo.places       # renamed and in another schema
+	        postalcodes: postal
+
+	The queries name the GeoNames tables by their usual names (geoname,
+	postalcodes, countryinfo, ...); TableName maps them to the names in the
+	database, for data that lives next to other datasets under a prefix or
+	in a schema of its own. Each entry of tables renames one table, with or
+	without a schema; schema is put in front of every name without one.
+	Tables not listed keep their names.
+
+	The mapping belongs to the connection, like the capability probe (see
+	capabilities.go): openDB applies the config's, and WithTables sets it
+	for a Geocoder and everything else sharing its gorm.Open. A process
+	serving several tenants opens one connection per tenant.
+
+	Names must be plain identifiers, optionally schema-qualified; they go
+	into the SQL unquoted, so PostgreSQL folds them to lower case. The
+	loader and the snapshot command always write the default names — load
+	into a schema of its own (e.g. with search_path in the connection URL)
+	and rename or move the tables afterwards. The R*Tree tables of SQLite
+	snapshots are not renamed either.
+*/
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/rgglez/geonames-loader/examples/go/internal/sqlbuild"
+	"gorm.io/gorm"
+)
+
+// geonamesTables lists the tables the queries refer to.
+var geonamesTables = []string{
+	"geoname", "postalcodes", "alternatename", "admin1codesascii",
+	"admin2codesascii", "countryinfo", "timezones", "featurecodes",
+	"continentcodes", "iso_languagecodes", "meta", "schema_version",
+	"poi",
+}
+
+// Tables renames the GeoNames tables of a database.
+type Tables struct {
+	// Schema qualifies every name that has no schema of its own.
+	Schema string `yaml:"schema"`
+	// Names maps a default table name to the name in the database.
+	Names map[string]string `yaml:",inline"`
+}
+
+// Check reports unknown tables and names that are not identifiers.
+func (t Tables) Check() error {
+	if t.Schema != "" && (!sqlbuild.Identifier.MatchString(t.Schema) || strings.Contains(t.Schema, ".")) {
+		return fmt.Errorf("tables: invalid schema %q", t.Schema)
+	}
+	for _, k := range slices.Sorted(maps.Keys(t.Names)) {
+		if !slices.Contains(geonamesTables, k) {
+			return fmt.Errorf("tables: unknown table %q (want one of %s)",
+				k, strings.Join(geonamesTables, ", "))
+		}
+		if !sqlbuild.Identifier.MatchString(t.Names[k]) {
+			return fmt.Errorf("tables: invalid name %q for %s", t.Names[k], k)
+		}
+	}
+	return nil
+}
+
+// name returns the database name of table.
+func (t Tables) name(table string) string {
+	n := table
+	if v := t.Names[table]; v != "" {
+		n = v
+	}
+	if t.Schema != "" && !strings.Contains(n, ".") {
+		n = t.Schema + "." + n
+	}
+	return n
+}
+
+var tableCache sync.Map // *gorm.Config → Tables
+
+// SetTables sets the table names of the connection of db.
+func SetTables(db *gorm.DB, t Tables) {
+	tableCache.Store(db.Config, t)
+}
+
+// tablesFor returns the table names of the connection of db.
+func tablesFor(db *gorm.DB) Tables {
+	if v, ok := tableCache.Load(db.Config); ok {
+		return v.(Tables)
+	}
+	return Tables{}
+}
+
+// TableName returns the name in db of the GeoNames table table.
+func TableName(db *gorm.DB, table string) string {
+	return tablesFor(db).name(table)
+}
+
+// WithTables maps the GeoNames tables to the names in the database (see
+// tables.go). Like WithCapabilities it applies to the whole connection;
+// t must pass Check.
+func WithTables(t Tables) Option {
+	return func(g *Geocoder) { SetTables(g.db, t) }
+}
@@ -0,0 +1,7 @@
+SELECT geonameid, isolanguage, alternatename
+FROM alternatename
+WHERE geonameid IN (?, ?)
+  AND isolanguage IN (?, ?)
+ORDER BY geonameid, alternatenameid
+
+-- args: [3530597 4005539 link wkdt]
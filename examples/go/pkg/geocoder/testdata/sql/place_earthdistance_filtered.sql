@@ -0,0 +1,25 @@
+SELECT g.geonameid, g.name, g.fclass, g.fcode, g.country, g.admin1, g.admin2, g.population, g.latitude, g.longitude,
+       earth_distance(ll_to_earth(g.latitude, g.longitude), ll_to_earth(?, ?)) / 1000.0 AS distance_km,
+       pc.postalcode
+FROM geoname g
+LEFT JOIN LATERAL (
+    SELECT postalcode FROM postalcodes
+    WHERE countrycode = g.country
+      AND latitude IS NOT NULL AND longitude IS NOT NULL
+      AND latitude BETWEEN g.latitude - 4.4916 AND g.latitude + 4.4916
+      AND longitude BETWEEN g.longitude - 4.4916 AND g.longitude + 4.4916
+    ORDER BY ll_to_earth(latitude, longitude) <-> ll_to_earth(g.latitude, g.longitude)
+    LIMIT 1
+) pc ON true
+WHERE g.latitude IS NOT NULL
+  AND g.longitude IS NOT NULL
+  AND earth_box(ll_to_earth(?, ?), ?) @> ll_to_earth(g.latitude, g.longitude)
+  AND g.country = ?
+  AND g.fclass = ?
+  AND g.population >= ?
+  AND g.latitude BETWEEN ? AND ?
+  AND g.longitude BETWEEN ? AND ?
+ORDER BY distance_km
+LIMIT ?
+
+-- args: [19.4326 -99.1332 19.4326 -99.1332 500000 MX P 10000 19.1 19.7 -99.4 -98.9 5]
@@ -0,0 +1,20 @@
+SELECT g.geonameid, g.name, g.fclass, g.fcode, g.country, g.admin1, g.admin2, g.population, g.latitude, g.longitude,
+       2.0 * 6371.0000000000 * ASIN(SQRT(SIN((g.latitude - -16.5000000000) * 0.0174532925 / 2.0) * SIN((g.latitude - -16.5000000000) * 0.0174532925 / 2.0) + 0.9588197349 * COS(g.latitude * 0.0174532925) * SIN((g.longitude - 179.9000000000) * 0.0174532925 / 2.0) * SIN((g.longitude - 179.9000000000) * 0.0174532925 / 2.0))) AS distance_km,
+       (SELECT postalcode FROM (
+            SELECT p.postalcode, 2.0 * 6371.0000000000 * ASIN(SQRT(SIN((p.latitude  - g.latitude)  * 0.0174532925 / 2.0) * SIN((p.latitude  - g.latitude)  * 0.0174532925 / 2.0) + COS(g.latitude * 0.0174532925) * COS(p.latitude * 0.0174532925) * SIN((p.longitude - g.longitude) * 0.0174532925 / 2.0) * SIN((p.longitude - g.longitude) * 0.0174532925 / 2.0))) AS d
+            FROM postalcodes p
+            WHERE p.countrycode = g.country
+              AND p.latitude IS NOT NULL AND p.longitude IS NOT NULL
+              AND p.latitude BETWEEN g.latitude - 4.4916 AND g.latitude + 4.4916
+              AND p.longitude BETWEEN g.longitude - 4.4916 AND g.longitude + 4.4916)
+        ORDER BY d
+        LIMIT 1) AS postalcode
+FROM geoname g
+WHERE g.latitude IS NOT NULL
+  AND g.longitude IS NOT NULL
+  AND g.latitude BETWEEN ? AND ?
+  AND (g.longitude >= ? OR g.longitude <= ?)
+ORDER BY distance_km
+LIMIT ?
+
+-- args: [-20 -10 170 -170 3]
@@ -0,0 +1,11 @@
+SELECT g.geonameid, g.name, g.fclass, g.fcode, g.country, g.admin1, g.admin2, g.population, g.latitude, g.longitude,
+       2.0 * 6371.0000000000 * ASIN(SQRT(SIN((g.latitude - 19.4326000000) * 0.0174532925 / 2.0) * SIN((g.latitude - 19.4326000000) * 0.0174532925 / 2.0) + 0.9430335131 * COS(g.latitude * 0.0174532925) * SIN((g.longitude - -99.1332000000) * 0.0174532925 / 2.0) * SIN((g.longitude - -99.1332000000) * 0.0174532925 / 2.0))) AS distance_km
+FROM geoname g
+WHERE g.latitude IS NOT NULL
+  AND g.longitude IS NOT NULL
+  AND g.fclass = ?
+  AND g.fcode IN (?, ?, ?)
+ORDER BY distance_km
+LIMIT ?
+
+-- args: [T MT PK VLC 1]
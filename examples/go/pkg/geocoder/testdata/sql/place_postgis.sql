@@ -0,0 +1,20 @@
+SELECT g.geonameid, g.name, g.fclass, g.fcode, g.country, g.admin1, g.admin2, g.population, g.latitude, g.longitude,
+       ST_Distance(ST_MakePoint(g.longitude, g.latitude)::geography, ST_MakePoint(?, ?)::geography) / 1000.0 AS distance_km,
+       pc.postalcode
+FROM geoname g
+LEFT JOIN LATERAL (
+    SELECT postalcode FROM postalcodes
+    WHERE countrycode = g.country
+      AND latitude IS NOT NULL AND longitude IS NOT NULL
+      AND latitude BETWEEN g.latitude - 4.4916 AND g.latitude + 4.4916
+      AND longitude BETWEEN g.longitude - 4.4916 AND g.longitude + 4.4916
+    ORDER BY ST_MakePoint(longitude, latitude)::geography <-> ST_MakePoint(g.longitude, g.latitude)::geography
+    LIMIT 1
+) pc ON true
+WHERE g.latitude IS NOT NULL
+  AND g.longitude IS NOT NULL
+  AND ST_DWithin(ST_MakePoint(g.longitude, g.latitude)::geography, ST_MakePoint(?, ?)::geography, ?)
+ORDER BY distance_km
+LIMIT ?
+
+-- args: [-99.1332 19.4326 -99.1332 19.4326 500000 3]
@@ -0,0 +1,10 @@
+SELECT g.geonameid, g.name, g.fclass, g.fcode, g.country, g.admin1, g.admin2, g.population, g.latitude, g.longitude,
+       ST_Distance(ST_MakePoint(g.longitude, g.latitude)::geography, ST_MakePoint(?, ?)::geography) / 1000.0 AS distance_km
+FROM geoname g
+WHERE g.latitude IS NOT NULL
+  AND g.longitude IS NOT NULL
+  AND ST_DWithin(ST_MakePoint(g.longitude, g.latitude)::geography, ST_MakePoint(?, ?)::geography, ?)
+ORDER BY distance_km
+LIMIT ?
+
+-- args: [-99.1332 19.4326 -99.1332 19.4326 500000 3]
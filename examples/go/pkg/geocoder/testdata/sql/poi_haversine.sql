@@ -0,0 +1,7 @@
+SELECT id, name, category, latitude, longitude, metadata,
+       2.0 * 6371.0000000000 * ASIN(SQRT(SIN((latitude - 19.4326000000) * 0.0174532925 / 2.0) * SIN((latitude - 19.4326000000) * 0.0174532925 / 2.0) + 0.9430335131 * COS(latitude * 0.0174532925) * SIN((longitude - -99.1332000000) * 0.0174532925 / 2.0) * SIN((longitude - -99.1332000000) * 0.0174532925 / 2.0))) AS distance_km
+FROM poi
+ORDER BY distance_km
+LIMIT ?
+
+-- args: [2]
@@ -0,0 +1,9 @@
+SELECT id, name, category, latitude, longitude, metadata,
+       ST_Distance(ST_MakePoint(longitude, latitude)::geography, ST_MakePoint(?, ?)::geography) / 1000.0 AS distance_km
+FROM poi
+WHERE ST_DWithin(ST_MakePoint(longitude, latitude)::geography, ST_MakePoint(?, ?)::geography, ?)
+  AND category = ?
+ORDER BY distance_km
+LIMIT ?
+
+-- args: [-99.1332 19.4326 -99.1332 19.4326 500000 store 2]
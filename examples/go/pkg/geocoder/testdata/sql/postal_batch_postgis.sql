@@ -0,0 +1,15 @@
+SELECT q.i AS point_index, n.*
+FROM (VALUES (?::int, ?::float8, ?::float8), (?::int, ?::float8, ?::float8)) AS q(i, lat, lon)
+CROSS JOIN LATERAL (
+    SELECT countrycode, postalcode, placename, admin1name, admin2name, admin3name, accuracy, latitude, longitude,
+           ST_Distance(ST_MakePoint(longitude, latitude)::geography, ST_MakePoint(q.lon, q.lat)::geography) / 1000.0 AS distance_km
+    FROM postalcodes
+    WHERE latitude IS NOT NULL
+      AND longitude IS NOT NULL
+      AND ST_DWithin(ST_MakePoint(longitude, latitude)::geography, ST_MakePoint(q.lon, q.lat)::geography, ?)
+      AND countrycode = ?
+    ORDER BY ST_MakePoint(longitude, latitude)::geography <-> ST_MakePoint(q.lon, q.lat)::geography
+    LIMIT ?
+) n
+
+-- args: [0 19.4326 -99.1332 1 20.67 -103.35 25000 DE 1]
@@ -0,0 +1,11 @@
+SELECT countrycode, postalcode, placename, admin1name, admin2name, admin3name, accuracy, latitude, longitude,
+       earth_distance(ll_to_earth(latitude, longitude), ll_to_earth(?, ?)) / 1000.0 AS distance_km
+FROM geo.postal
+WHERE latitude IS NOT NULL
+  AND longitude IS NOT NULL
+  AND earth_box(ll_to_earth(?, ?), ?) @> ll_to_earth(latitude, longitude)
+  AND countrycode = ?
+ORDER BY distance_km
+LIMIT ?
+
+-- args: [19.4326 -99.1332 19.4326 -99.1332 500000 MX 3]
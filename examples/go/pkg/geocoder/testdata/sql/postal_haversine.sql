@@ -0,0 +1,9 @@
+SELECT countrycode, postalcode, placename, admin1name, admin2name, admin3name, accuracy, latitude, longitude,
+       2.0 * 6371.0000000000 * ASIN(SQRT(SIN((latitude - 19.4326000000) * 0.0174532925 / 2.0) * SIN((latitude - 19.4326000000) * 0.0174532925 / 2.0) + 0.9430335131 * COS(latitude * 0.0174532925) * SIN((longitude - -99.1332000000) * 0.0174532925 / 2.0) * SIN((longitude - -99.1332000000) * 0.0174532925 / 2.0))) AS distance_km
+FROM postalcodes
+WHERE latitude IS NOT NULL
+  AND longitude IS NOT NULL
+ORDER BY distance_km
+LIMIT ?
+
+-- args: [3]
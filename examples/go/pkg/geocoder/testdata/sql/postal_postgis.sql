@@ -0,0 +1,10 @@
+SELECT countrycode, postalcode, placename, admin1name, admin2name, admin3name, accuracy, latitude, longitude,
+       ST_Distance(ST_MakePoint(longitude, latitude)::geography, ST_MakePoint(?, ?)::geography) / 1000.0 AS distance_km
+FROM postalcodes
+WHERE latitude IS NOT NULL
+  AND longitude IS NOT NULL
+  AND ST_DWithin(ST_MakePoint(longitude, latitude)::geography, ST_MakePoint(?, ?)::geography, ?)
+ORDER BY distance_km
+LIMIT ?
+
+-- args: [-99.1332 19.4326 -99.1332 19.4326 500000 3]
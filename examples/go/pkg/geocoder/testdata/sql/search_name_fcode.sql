@@ -0,0 +1,11 @@
+SELECT g.geonameid, g.name, g.fclass, g.fcode, g.country, g.admin1, g.admin2, g.population, g.latitude, g.longitude
+FROM geoname g
+WHERE g.latitude IS NOT NULL
+  AND g.longitude IS NOT NULL
+  AND (LOWER(g.name) LIKE ? ESCAPE '!' OR LOWER(g.asciiname) LIKE ? ESCAPE '!')
+  AND g.country = ?
+  AND g.fcode = ?
+ORDER BY COALESCE(g.population, 0) DESC, g.geonameid
+LIMIT ? OFFSET ?
+
+-- args: [%san!_jose% %san!_jose% CR PPLA 10 20]
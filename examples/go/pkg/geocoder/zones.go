@@ -0,0 +1,557 @@
+package geocoder
+
+/*
+	Geofences: named polygons and point-in-zone checks.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Zones are user-defined areas — delivery areas, sales territories —
+	registered on a Geocoder by name:
+
+	    g.RegisterZone("centro", "POLYGON((-99.2 19.3, -99.0 19.3, ...))")
+	    names, err := g.WithinZones(19.4326, -99.1332)
+
+	A zone is given as WKT (POLYGON or MULTIPOLYGON, optionally prefixed
+	with SRID=4326;) or GeoJSON (a Polygon or MultiPolygon geometry, a
+	Feature, or a FeatureCollection whose polygons form one zone), with
+	longitude/latitude coordinates in WGS 84. Rings after the first of a
+	polygon are holes.
+
+	WithinZones returns the zones containing the point, in registration
+	order. Zones whose bounding box excludes the point are discarded in Go;
+	the rest are tested with ST_Contains on PostgreSQL with PostGIS, and
+	by ray casting (even-odd rule) everywhere else. Both treat coordinates
+	as planar, so edges follow parallels and meridians rather than great
+	circles. Points on an edge may fall on either side: in Go a point on
+	the western or southern side of a rectangle is inside and one on its
+	eastern or northern side outside, so adjacent rectangles share no
+	point, while ST_Contains leaves every boundary point out.
+
+	An edge spanning more than 180° of longitude is taken to cross the
+	antimeridian the short way: POLYGON((170 -20, -170 -20, -170 -10,
+	170 -10, 170 -20)) is the 20° band around Fiji, not the 340° one
+	around the rest of the world. The western longitudes of such a
+	polygon are shifted by 360° (-170 becomes 190), and points are
+	tested at their longitude and, west of the meridian, 360° east of it.
+
+	The server reads zones from the "zones" list of its config section
+	and reports them alongside /reverse and /reverse/full results, and on
+	their own at GET /zones?lat=..&lon=..
+
+	The command-line side is in cmd/reverse_geocode/zones.go.
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// ---------------------------------------------------------------------------
+// Zones
+// ---------------------------------------------------------------------------
+
+// ring is a closed sequence of [lon, lat] vertices.
+type ring [][2]float64
+
+// Zone is a named area made of one or more polygons.
+type Zone struct {
+	Name string
+	// polygons holds each polygon's outer ring followed by its holes.
+	polygons [][]ring
+	// bbox is [minlon, minlat, maxlon, maxlat].
+	bbox [4]float64
+	// wkt is the zone as a MULTIPOLYGON, for ST_Contains.
+	wkt string
+}
+
+// ParseZone parses geometry, in WKT or GeoJSON, as the zone name.
+func ParseZone(name, geometry string) (*Zone, error) {
+	if name == "" {
+		return nil, fmt.Errorf("zone: empty name")
+	}
+	var polygons [][]ring
+	var err error
+	if s := strings.TrimSpace(geometry); strings.HasPrefix(s, "{") {
+		polygons, err = parseGeoJSONPolygons([]byte(s))
+	} else {
+		polygons, err = parseWKTPolygons(s)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("zone %s: %w", name, err)
+	}
+	if len(polygons) == 0 {
+		return nil, fmt.Errorf("zone %s: no polygon", name)
+	}
+
+	z := &Zone{
+		Name:     name,
+		bbox:     [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)},
+		polygons: make([][]ring, len(polygons)),
+	}
+	for i, poly := range polygons {
+		z.polygons[i] = make([]ring, len(poly))
+		for j, r := range poly {
+			if r, err = closeRing(r); err != nil {
+				return nil, fmt.Errorf("zone %s: polygon %d ring %d: %w",
+					name, i+1, j+1, err)
+			}
+			z.polygons[i][j] = r
+		}
+		if crossesAntimeridian(z.polygons[i]) {
+			z.polygons[i] = shiftWest(z.polygons[i])
+		}
+		for _, r := range z.polygons[i] {
+			for _, p := range r {
+				z.bbox[0], z.bbox[1] = math.Min(z.bbox[0], p[0]), math.Min(z.bbox[1], p[1])
+				z.bbox[2], z.bbox[3] = math.Max(z.bbox[2], p[0]), math.Max(z.bbox[3], p[1])
+			}
+		}
+	}
+	z.wkt = z.multiPolygonWKT()
+	return z, nil
+}
+
+// crossesAntimeridian reports whether an edge of poly spans more than
+// 180° of longitude.
+func crossesAntimeridian(poly []ring) bool {
+	for _, r := range poly {
+		for i := 1; i < len(r); i++ {
+			if math.Abs(r[i][0]-r[i-1][0]) > 180 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// shiftWest returns poly with its negative longitudes moved 360° east.
+func shiftWest(poly []ring) []ring {
+	out := make([]ring, len(poly))
+	for i, r := range poly {
+		out[i] = make(ring, len(r))
+		for j, p := range r {
+			if p[0] < 0 {
+				p[0] += 360
+			}
+			out[i][j] = p
+		}
+	}
+	return out
+}
+
+// closeRing validates r and appends its first vertex if it is not closed.
+func closeRing(r ring) (ring, error) {
+	for _, p := range r {
+		if math.Abs(p[0]) > 180 || math.Abs(p[1]) > 90 {
+			return nil, fmt.Errorf("coordinate (%g %g) out of range "+
+				"(want longitude latitude)", p[0], p[1])
+		}
+	}
+	if len(r) > 0 && r[0] != r[len(r)-1] {
+		r = append(r, r[0])
+	}
+	if len(r) < 4 {
+		return nil, fmt.Errorf("a ring needs at least 3 distinct vertices")
+	}
+	return r, nil
+}
+
+func (z *Zone) multiPolygonWKT() string {
+	var b strings.Builder
+	b.WriteString("MULTIPOLYGON(")
+	for i, poly := range z.polygons {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('(')
+		for j, r := range poly {
+			if j > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteByte('(')
+			for k, p := range r {
+				if k > 0 {
+					b.WriteByte(',')
+				}
+				b.WriteString(strconv.FormatFloat(p[0], 'f', -1, 64))
+				b.WriteByte(' ')
+				b.WriteString(strconv.FormatFloat(p[1], 'f', -1, 64))
+			}
+			b.WriteByte(')')
+		}
+		b.WriteByte(')')
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// contains reports whether (lat, lon) lies inside z.
+func (z *Zone) contains(lat, lon float64) bool {
+	if lon < 0 && z.containsAt(lat, lon+360) {
+		return true
+	}
+	return z.containsAt(lat, lon)
+}
+
+// containsAt tests (lat, lon) as is, with lon possibly past 180.
+func (z *Zone) containsAt(lat, lon float64) bool {
+	if !z.inBBoxAt(lat, lon) {
+		return false
+	}
+	for _, poly := range z.polygons {
+		// Even-odd rule over the outer ring and its holes.
+		inside := false
+		for _, r := range poly {
+			for i, j := 0, len(r)-1; i < len(r); j, i = i, i+1 {
+				xi, yi, xj, yj := r[i][0], r[i][1], r[j][0], r[j][1]
+				if (yi > lat) != (yj > lat) &&
+					lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+					inside = !inside
+				}
+			}
+		}
+		if inside {
+			return true
+		}
+	}
+	return false
+}
+
+// inBBox reports whether (lat, lon) lies in the bounding box of z, at
+// its longitude or 360° east of it.
+func (z *Zone) inBBox(lat, lon float64) bool {
+	return z.inBBoxAt(lat, lon) || lon < 0 && z.inBBoxAt(lat, lon+360)
+}
+
+func (z *Zone) inBBoxAt(lat, lon float64) bool {
+	return lon >= z.bbox[0] && lat >= z.bbox[1] && lon <= z.bbox[2] && lat <= z.bbox[3]
+}
+
+// ---------------------------------------------------------------------------
+// WKT and GeoJSON
+// ---------------------------------------------------------------------------
+
+// parseWKTPolygons parses a WKT POLYGON or MULTIPOLYGON.
+func parseWKTPolygons(s string) ([][]ring, error) {
+	s, err := stripSRID(s)
+	if err != nil {
+		return nil, err
+	}
+	kind, body, _ := strings.Cut(strings.TrimSpace(s), "(")
+	body = "(" + body
+	kind = strings.ToUpper(strings.TrimSpace(kind))
+	// Z and M coordinates are accepted and ignored.
+	kind = strings.TrimSpace(strings.TrimRight(kind, "ZM "))
+
+	p := &wktParser{s: body}
+	var polygons [][]ring
+	switch kind {
+	case "POLYGON":
+		poly, err := p.polygon()
+		if err != nil {
+			return nil, err
+		}
+		polygons = append(polygons, poly)
+	case "MULTIPOLYGON":
+		if err := p.list(func() error {
+			poly, err := p.polygon()
+			polygons = append(polygons, poly)
+			return err
+		}); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported WKT type %q (want POLYGON or MULTIPOLYGON)", kind)
+	}
+	if p.skip(); p.i < len(p.s) {
+		return nil, fmt.Errorf("WKT: unexpected %q at offset %d", p.s[p.i:], p.i)
+	}
+	return polygons, nil
+}
+
+// wktParser reads the parenthesised coordinate lists of WKT.
+type wktParser struct {
+	s string
+	i int
+}
+
+func (p *wktParser) skip() {
+	for p.i < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.i]) >= 0 {
+		p.i++
+	}
+}
+
+// list parses "(item, item, ...)", calling item for each element.
+func (p *wktParser) list(item func() error) error {
+	if p.skip(); p.i >= len(p.s) || p.s[p.i] != '(' {
+		return fmt.Errorf("WKT: expected '(' at offset %d", p.i)
+	}
+	p.i++
+	for {
+		if err := item(); err != nil {
+			return err
+		}
+		p.skip()
+		if p.i >= len(p.s) {
+			return fmt.Errorf("WKT: unexpected end")
+		}
+		switch p.s[p.i] {
+		case ',':
+			p.i++
+		case ')':
+			p.i++
+			return nil
+		default:
+			return fmt.Errorf("WKT: unexpected %q at offset %d", p.s[p.i], p.i)
+		}
+	}
+}
+
+func (p *wktParser) polygon() ([]ring, error) {
+	var rings []ring
+	err := p.list(func() error {
+		var r ring
+		err := p.list(func() error {
+			p.skip()
+			end := p.i
+			for end < len(p.s) && p.s[end] != ',' && p.s[end] != ')' {
+				end++
+			}
+			f := strings.Fields(p.s[p.i:end])
+			if len(f) < 2 || len(f) > 4 {
+				return fmt.Errorf("WKT: bad point %q", p.s[p.i:end])
+			}
+			lon, err1 := strconv.ParseFloat(f[0], 64)
+			lat, err2 := strconv.ParseFloat(f[1], 64)
+			if err1 != nil || err2 != nil {
+				return fmt.Errorf("WKT: bad point %q", p.s[p.i:end])
+			}
+			r = append(r, [2]float64{lon, lat})
+			p.i = end
+			return nil
+		})
+		rings = append(rings, r)
+		return err
+	})
+	return rings, err
+}
+
+// geoJSON is the subset of a GeoJSON object ParseZone reads.
+type geoJSON struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	Geometry    *geoJSON        `json:"geometry"`
+	Features    []geoJSON       `json:"features"`
+}
+
+// parseGeoJSONPolygons parses a GeoJSON Polygon, MultiPolygon, Feature or
+// FeatureCollection.
+func parseGeoJSONPolygons(data []byte) ([][]ring, error) {
+	var g geoJSON
+	if err := json.Unmarshal(data, &g); err != nil {
+		return nil, fmt.Errorf("GeoJSON: %w", err)
+	}
+	return g.polygons()
+}
+
+func (g *geoJSON) polygons() ([][]ring, error) {
+	toRings := func(rs [][][]float64) ([]ring, error) {
+		out := make([]ring, len(rs))
+		for i, r := range rs {
+			for _, p := range r {
+				if len(p) < 2 {
+					return nil, fmt.Errorf("GeoJSON: position with %d values", len(p))
+				}
+				out[i] = append(out[i], [2]float64{p[0], p[1]})
+			}
+		}
+		return out, nil
+	}
+	switch g.Type {
+	case "Polygon":
+		var c [][][]float64
+		if err := json.Unmarshal(g.Coordinates, &c); err != nil {
+			return nil, fmt.Errorf("GeoJSON Polygon: %w", err)
+		}
+		poly, err := toRings(c)
+		return [][]ring{poly}, err
+	case "MultiPolygon":
+		var c [][][][]float64
+		if err := json.Unmarshal(g.Coordinates, &c); err != nil {
+			return nil, fmt.Errorf("GeoJSON MultiPolygon: %w", err)
+		}
+		out := make([][]ring, len(c))
+		for i, rs := range c {
+			var err error
+			if out[i], err = toRings(rs); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case "Feature":
+		if g.Geometry == nil {
+			return nil, fmt.Errorf("GeoJSON Feature without geometry")
+		}
+		return g.Geometry.polygons()
+	case "FeatureCollection":
+		var out [][]ring
+		for i := range g.Features {
+			polys, err := g.Features[i].polygons()
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, polys...)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("unsupported GeoJSON type %q (want Polygon, "+
+		"MultiPolygon, Feature or FeatureCollection)", g.Type)
+}
+
+// ---------------------------------------------------------------------------
+// Geocoder
+// ---------------------------------------------------------------------------
+
+// zoneSet holds the zones of a Geocoder, in registration order.
+type zoneSet struct {
+	mu    sync.RWMutex
+	zones []*Zone
+}
+
+// AddZone registers z, replacing any zone of the same name.
+func (g *Geocoder) AddZone(z *Zone) {
+	g.zones.mu.Lock()
+	defer g.zones.mu.Unlock()
+	for i, old := range g.zones.zones {
+		if old.Name == z.Name {
+			g.zones.zones[i] = z
+			return
+		}
+	}
+	g.zones.zones = append(g.zones.zones, z)
+}
+
+// RegisterZone parses geometry (WKT or GeoJSON) and registers it as the
+// zone name.
+func (g *Geocoder) RegisterZone(name, geometry string) error {
+	z, err := ParseZone(name, geometry)
+	if err != nil {
+		return err
+	}
+	g.AddZone(z)
+	return nil
+}
+
+// RemoveZone unregisters the zone name and reports whether it existed.
+func (g *Geocoder) RemoveZone(name string) bool {
+	g.zones.mu.Lock()
+	defer g.zones.mu.Unlock()
+	for i, z := range g.zones.zones {
+		if z.Name == name {
+			g.zones.zones = append(g.zones.zones[:i], g.zones.zones[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ZoneNames returns the names of the registered zones.
+func (g *Geocoder) ZoneNames() []string {
+	g.zones.mu.RLock()
+	defer g.zones.mu.RUnlock()
+	names := make([]string, len(g.zones.zones))
+	for i, z := range g.zones.zones {
+		names[i] = z.Name
+	}
+	return names
+}
+
+// WithinZones returns the names of the registered zones containing
+// (lat, lon), in registration order.
+func (g *Geocoder) WithinZones(lat, lon float64) ([]string, error) {
+	g.zones.mu.RLock()
+	var candidates []*Zone
+	for _, z := range g.zones.zones {
+		if z.inBBox(lat, lon) {
+			candidates = append(candidates, z)
+		}
+	}
+	g.zones.mu.RUnlock()
+
+	names := []string{}
+	if len(candidates) == 0 {
+		return names, nil
+	}
+	if IsPostgres(g.db) && capabilitiesFor(g.db).PostGIS {
+		ids := make([]string, len(candidates))
+		for i, z := range candidates {
+			ids[i] = z.Name
+		}
+		key := fmt.Sprintf("zones|%g|%g|%s", lat, lon, strings.Join(ids, "\x00"))
+		return guarded(g, key, func() ([]string, error) {
+			return zonesPostGIS(g.db, lat, lon, candidates)
+		})
+	}
+	for _, z := range candidates {
+		if z.contains(lat, lon) {
+			names = append(names, z.Name)
+		}
+	}
+	return names, nil
+}
+
+// zonesPostGIS tests zones with ST_Contains.
+func zonesPostGIS(db *gorm.DB, lat, lon float64, zones []*Zone) ([]string, error) {
+	values := make([]string, len(zones))
+	args := make([]interface{}, 0, 2*len(zones)+2)
+	for i, z := range zones {
+		values[i] = "(?::int, ?::text)"
+		args = append(args, i, z.wkt)
+	}
+	// The point 360° east too, for the zones shifted across the
+	// antimeridian.
+	east := lon
+	if lon < 0 {
+		east += 360
+	}
+	args = append(args, lon, lat, east, lat)
+
+	var hits []int
+	err := db.Raw(fmt.Sprintf(`
+		SELECT z.i
+		FROM (VALUES %s) AS z(i, wkt)
+		WHERE ST_Contains(ST_GeomFromText(z.wkt, 4326),
+		                  ST_SetSRID(ST_MakePoint(?, ?), 4326))
+		   OR ST_Contains(ST_GeomFromText(z.wkt, 4326),
+		                  ST_SetSRID(ST_MakePoint(?, ?), 4326))
+		ORDER BY z.i`, strings.Join(values, ", ")), args...).Scan(&hits).Error
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(hits))
+	for i, h := range hits {
+		names[i] = zones[h].Name
+	}
+	return names, nil
+}
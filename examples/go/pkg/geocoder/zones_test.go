@@ -0,0 +1,120 @@
+package geocoder
+
+/*
+	Tests of the zones.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestZoneContains(t *testing.T) {
+	const (
+		square = "POLYGON((0 0, 10 0, 10 10, 0 10, 0 0))"
+		// A U open to the north: the notch 3..7 × 3..10 is outside.
+		concave = "POLYGON((0 0, 10 0, 10 10, 7 10, 7 3, 3 3, 3 10, 0 10, 0 0))"
+		donut   = "POLYGON((0 0, 10 0, 10 10, 0 10, 0 0), (3 3, 7 3, 7 7, 3 7, 3 3))"
+		two     = "MULTIPOLYGON(((0 0, 1 0, 1 1, 0 1, 0 0)), ((5 5, 6 5, 6 6, 5 6, 5 5)))"
+		// The band around Fiji, 170°E to 170°W.
+		fiji     = "POLYGON((170 -20, -170 -20, -170 -10, 170 -10, 170 -20))"
+		fijiJSON = `{"type":"Polygon","coordinates":[[[170,-20],[-170,-20],[-170,-10],[170,-10],[170,-20]]]}`
+		// A triangle whose edges cross the antimeridian both ways.
+		triangle = "POLYGON((175 0, -175 0, 175 10, 175 0))"
+	)
+	for _, c := range []struct {
+		zone     string
+		lon, lat float64
+		want     bool
+	}{
+		{square, 5, 5, true},
+		{square, 0.001, 9.999, true},
+		{square, 15, 5, false},
+		{square, 5, -5, false},
+		{square, -5, -5, false},
+		// Vertices and sides: the western and southern ones are inside,
+		// the eastern and northern ones outside.
+		{square, 0, 0, true},
+		{square, 0, 5, true},
+		{square, 5, 0, true},
+		{square, 10, 5, false},
+		{square, 5, 10, false},
+		{square, 10, 10, false},
+		{square, 10, 0, false},
+		{square, 0, 10, false},
+
+		{concave, 5, 1, true},   // the base
+		{concave, 1.5, 8, true}, // the western arm
+		{concave, 8.5, 8, true}, // the eastern arm
+		{concave, 5, 5, false},  // the notch
+		{concave, 5, 9.9, false},
+
+		{donut, 1, 1, true},
+		{donut, 5, 5, false}, // in the hole
+		{donut, 8, 5, true},
+
+		{two, 0.5, 0.5, true},
+		{two, 5.5, 5.5, true},
+		{two, 3, 3, false}, // between the polygons, inside the bounding box
+
+		{fiji, 178, -15, true},
+		{fiji, -178, -15, true},
+		{fiji, 180, -15, true},
+		{fiji, -180, -15, true},
+		{fiji, 0, -15, false}, // not the band around the rest of the world
+		{fiji, 160, -15, false},
+		{fiji, -160, -15, false},
+		{fiji, 178, -25, false},
+		{fijiJSON, -178, -15, true},
+		{fijiJSON, 0, -15, false},
+		{triangle, 179, 1, true},
+		{triangle, -179, 1, true},
+		{triangle, -179, 8, false},
+		{triangle, 0, 1, false},
+	} {
+		z, err := ParseZone("z", c.zone)
+		if err != nil {
+			t.Fatalf("%s: %v", c.zone, err)
+		}
+		if got := z.contains(c.lat, c.lon); got != c.want {
+			t.Errorf("%s contains (%g %g) = %v, want %v", c.zone, c.lon, c.lat, got, c.want)
+		}
+		if got := z.contains(c.lat, c.lon) && !z.inBBox(c.lat, c.lon); got {
+			t.Errorf("%s: (%g %g) inside the zone but not its bounding box", c.zone, c.lon, c.lat)
+		}
+	}
+}
+
+func TestParseZoneErrors(t *testing.T) {
+	for _, c := range []struct {
+		name, geometry string
+		// err is a substring of the error.
+		err string
+	}{
+		{"", "POLYGON((0 0, 1 0, 1 1, 0 0))", "empty name"},
+		{"z", "POLYGON((0 0, 1 0, 0 0))", "at least 3 distinct vertices"},
+		{"z", "POLYGON((0 0, 1 0, 1 95, 0 0))", "out of range"},
+		{"z", "POINT(0 0)", "unsupported WKT type"},
+		{"z", `{"type":"Point","coordinates":[0,0]}`, "z"},
+	} {
+		_, err := ParseZone(c.name, c.geometry)
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("ParseZone(%q, %q): error %v, want %q", c.name, c.geometry, err, c.err)
+		}
+	}
+}
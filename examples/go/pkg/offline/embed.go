@@ -0,0 +1,32 @@
+//go:build geonames_embed
+
+package offline
+
+/*
+	The dataset compiled into the binary with the geonames_embed tag.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	cities.tsv.gz is not in the repository: "go generate ./pkg/offline"
+	writes it (see gen.go) before the first build with the tag.
+*/
+
+import _ "embed"
+
+//go:embed cities.tsv.gz
+var embeddedCities []byte
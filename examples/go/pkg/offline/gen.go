@@ -0,0 +1,171 @@
+//go:build ignore
+
+package main
+
+/*
+	gen writes cities.tsv.gz, the dataset of the geonames_embed build.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage (from pkg/offline):
+	    go generate                           # cities15000
+	    go run gen.go --cities 5000           # cities5000, more places
+	    go run gen.go --src MX.zip            # a country file, already downloaded
+	    go run gen.go --src allCountries.txt --countries MX,GT
+
+	The source is a zip or text file in the geoname format, downloaded
+	from download.geonames.org unless --src names one. The alternatenames
+	column, most of the size of the dumps, is emptied; the other columns
+	are kept, so offline.Read reads the output as it reads the dumps.
+*/
+
+import (
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const dumpURL = "https://download.geonames.org/export/dump"
+
+func main() {
+	cities := flag.Int("cities", 15000, "Population threshold of the GeoNames cities file: 500, 1000, 5000 or 15000")
+	src := flag.String("src", "", "Zip or text file in the geoname format to read instead of downloading")
+	countries := flag.String("countries", "", "Comma-separated country codes to keep (default: all)")
+	out := flag.String("out", "cities.tsv.gz", "Output file")
+	flag.Parse()
+
+	if *src == "" {
+		if !slices.Contains([]int{500, 1000, 5000, 15000}, *cities) {
+			log.Fatalf("--cities: %d is not 500, 1000, 5000 or 15000", *cities)
+		}
+		path, err := fetch(fmt.Sprintf("%s/cities%d.zip", dumpURL, *cities))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer os.Remove(path)
+		*src = path
+	}
+	var keep []string
+	if *countries != "" {
+		keep = strings.Split(strings.ToUpper(*countries), ",")
+	}
+	n, err := convert(*src, *out, keep)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("wrote %d places to %s", n, *out)
+}
+
+// fetch downloads url to a temporary file and returns its path.
+func fetch(url string) (string, error) {
+	log.Printf("downloading %s ...", url)
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	f, err := os.CreateTemp("", "geonames-*.zip")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("%s: %w", url, err)
+	}
+	return f.Name(), nil
+}
+
+// open returns the geoname file of src, the first .txt member of a zip.
+func open(src string) (io.ReadCloser, error) {
+	if !strings.EqualFold(filepath.Ext(src), ".zip") {
+		return os.Open(src)
+	}
+	zr, err := zip.OpenReader(src)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range zr.File {
+		if strings.HasSuffix(f.Name, ".txt") && f.Name != "readme.txt" {
+			rc, err := f.Open()
+			if err != nil {
+				zr.Close()
+				return nil, err
+			}
+			return struct {
+				io.Reader
+				io.Closer
+			}{rc, zr}, nil
+		}
+	}
+	zr.Close()
+	return nil, fmt.Errorf("%s: no geoname file in the archive", src)
+}
+
+// convert copies the places of src in the countries of keep (all when
+// empty) to dst, gzipped, without their alternate names.
+func convert(src, dst string, keep []string) (int, error) {
+	in, err := open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	f, err := os.Create(dst)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	zw, _ := gzip.NewWriterLevel(f, gzip.BestCompression)
+	w := bufio.NewWriter(zw)
+
+	n := 0
+	sc := bufio.NewScanner(in)
+	sc.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for sc.Scan() {
+		cols := strings.Split(sc.Text(), "\t")
+		if len(cols) != 19 || len(keep) > 0 && !slices.Contains(keep, cols[8]) {
+			continue
+		}
+		cols[3] = ""
+		w.WriteString(strings.Join(cols, "\t"))
+		w.WriteByte('\n')
+		n++
+	}
+	if err := sc.Err(); err != nil {
+		return 0, err
+	}
+	if err := w.Flush(); err != nil {
+		return 0, err
+	}
+	if err := zw.Close(); err != nil {
+		return 0, err
+	}
+	return n, f.Close()
+}
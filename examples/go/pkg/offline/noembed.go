@@ -0,0 +1,6 @@
+//go:build !geonames_embed
+
+package offline
+
+// embeddedCities is empty without the geonames_embed tag (see embed.go).
+var embeddedCities []byte
@@ -0,0 +1,239 @@
+// Package offline is a reverse geocoder over places held in memory, read
+// from a GeoNames dump file or embedded in the binary. Unlike package
+// geocoder it needs no database: it has no cgo and no dependencies beyond
+// the standard library, so it also builds for WebAssembly.
+//
+//	idx, err := offline.Embedded() // or offline.Read(f)
+//	places := idx.Nearest(19.4326, -99.1332, offline.Options{Limit: 3})
+package offline
+
+/*
+	In-memory reverse geocoder without a database.
+
+	Copyright (C) 2026 Rodolfo González González <code@rodolfo.gg>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+	---------------------------------------------------------------------------
+
+	Usage:
+	    go generate ./pkg/offline              # writes cities.tsv.gz (gen.go)
+	    go build -tags geonames_embed ./...
+	    GOOS=js GOARCH=wasm go build -tags geonames_embed \
+	        -o geocode.wasm ./cmd/offline_geocode
+
+	The memory strategy of package geocoder loads geoname into a k-d tree
+	through gorm, which brings in the SQL drivers and, for SQLite, cgo.
+	This package answers the same question with the same tree
+	(internal/kdtree) over a file in the format of the GeoNames dumps —
+	cities15000.txt, a country file, allCountries.txt, gzipped or not —
+	so it runs where no database can: in a browser, in a serverless
+	WebAssembly runtime, or as one static binary.
+
+	Read parses such a file. Embedded parses the file compiled into the
+	binary with the geonames_embed build tag (embed.go): cities.tsv.gz,
+	which go generate fetches from download.geonames.org (gen.go) — the
+	cities with at least 15000 inhabitants by default, without their
+	alternate names. Without the tag Embedded returns ErrNotEmbedded and the
+	dataset has to be read at run time.
+
+	Nearest returns the same places, with the same distances, as the
+	memory strategy over the same rows; the places carry the codes of
+	their country and divisions but not their names, nor postal codes,
+	which the dump files do not hold.
+*/
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rgglez/geonames-loader/examples/go/internal/kdtree"
+)
+
+//go:generate go run gen.go
+
+// earthRadiusKm is the mean Earth radius, as in package geocoder.
+const earthRadiusKm = 6371.0
+
+// Place is a GeoNames feature. Its JSON is that of geocoder.Place.
+type Place struct {
+	Geonameid  int64   `json:"geonameid"`
+	Name       string  `json:"name"`
+	Fclass     string  `json:"fclass"`
+	Fcode      string  `json:"fcode"`
+	Country    string  `json:"country"`
+	Admin1     string  `json:"admin1,omitempty"`
+	Admin2     string  `json:"admin2,omitempty"`
+	Population int64   `json:"population"`
+	Timezone   string  `json:"timezone,omitempty"`
+	Latitude   float64 `json:"latitude"`
+	Longitude  float64 `json:"longitude"`
+	// DistanceKm is the great-circle distance from the query point.
+	DistanceKm float64 `json:"distance_km"`
+}
+
+// Options narrows Nearest. Empty fields do not restrict the results.
+type Options struct {
+	Limit   int
+	Country string // ISO 3166-1 alpha-2 code, in either case
+	// FeatureClass is a GeoNames feature class (A, H, L, P, R, S, T, U or V),
+	// in either case.
+	FeatureClass  string
+	MinPopulation int64
+}
+
+// Index is the places of a dataset with their k-d tree. It is read-only
+// and safe for concurrent use.
+type Index struct {
+	places []Place
+	tree   *kdtree.Tree
+}
+
+// New returns the index of places.
+func New(places []Place) *Index {
+	pts := make([][3]float64, len(places))
+	for i, p := range places {
+		pts[i] = kdtree.UnitVector(p.Latitude, p.Longitude)
+	}
+	return &Index{places: places, tree: kdtree.New(pts)}
+}
+
+// Len returns the number of places of the index.
+func (x *Index) Len() int { return len(x.places) }
+
+// Nearest returns up to opts.Limit places nearest to (lat, lon) that pass
+// opts, nearest first; a Limit of 0 or less returns none.
+func (x *Index) Nearest(lat, lon float64, opts Options) []Place {
+	country := strings.ToUpper(opts.Country)
+	fclass := strings.ToUpper(opts.FeatureClass)
+	hits := x.tree.Nearest(kdtree.UnitVector(lat, lon), opts.Limit, func(i int32) bool {
+		p := &x.places[i]
+		return (country == "" || p.Country == country) &&
+			(fclass == "" || p.Fclass == fclass) &&
+			p.Population >= opts.MinPopulation
+	})
+	out := make([]Place, len(hits))
+	for i, h := range hits {
+		out[i] = x.places[h.I]
+		out[i].DistanceKm = earthRadiusKm * kdtree.ChordAngle(h.Dist)
+	}
+	return out
+}
+
+// ---------------------------------------------------------------------------
+// GeoNames dump files
+// ---------------------------------------------------------------------------
+
+// geonameColumns is the number of columns of the geoname dump format.
+const geonameColumns = 19
+
+// Read returns the index of a file in the format of the GeoNames dumps
+// (geonameid, name, asciiname, alternatenames, latitude, longitude, ...),
+// gzip-compressed or not. Empty lines and lines starting with # are
+// skipped.
+func Read(r io.Reader) (*Index, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		br = bufio.NewReader(zr)
+	}
+
+	var places []Place
+	sc := bufio.NewScanner(br)
+	// alternatenames runs to tens of kilobytes for the largest places.
+	sc.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		p, err := parsePlace(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		places = append(places, p)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return New(places), nil
+}
+
+// parsePlace parses one line of a dump file.
+func parsePlace(line string) (Place, error) {
+	f := strings.Split(line, "\t")
+	if len(f) != geonameColumns {
+		return Place{}, fmt.Errorf("%d columns, want %d", len(f), geonameColumns)
+	}
+	var (
+		p   = Place{Name: f[1], Fclass: f[6], Fcode: f[7], Country: f[8], Admin1: f[10], Admin2: f[11], Timezone: f[17]}
+		err error
+	)
+	if p.Geonameid, err = strconv.ParseInt(f[0], 10, 64); err != nil {
+		return Place{}, fmt.Errorf("geonameid: %w", err)
+	}
+	if p.Latitude, err = strconv.ParseFloat(f[4], 64); err != nil || p.Latitude < -90 || p.Latitude > 90 {
+		return Place{}, fmt.Errorf("latitude %q out of range", f[4])
+	}
+	if p.Longitude, err = strconv.ParseFloat(f[5], 64); err != nil || p.Longitude < -180 || p.Longitude > 180 {
+		return Place{}, fmt.Errorf("longitude %q out of range", f[5])
+	}
+	if f[14] != "" {
+		if p.Population, err = strconv.ParseInt(f[14], 10, 64); err != nil {
+			return Place{}, fmt.Errorf("population: %w", err)
+		}
+	}
+	return p, nil
+}
+
+// ---------------------------------------------------------------------------
+// Embedded dataset
+// ---------------------------------------------------------------------------
+
+// ErrNotEmbedded is returned by Embedded in binaries built without the
+// geonames_embed tag.
+var ErrNotEmbedded = errors.New("offline: no embedded dataset (build with -tags geonames_embed)")
+
+var embedded struct {
+	once sync.Once
+	idx  *Index
+	err  error
+}
+
+// Embedded returns the index of the dataset compiled into the binary,
+// parsing it on first use.
+func Embedded() (*Index, error) {
+	embedded.once.Do(func() {
+		if embeddedCities == nil {
+			embedded.err = ErrNotEmbedded
+			return
+		}
+		embedded.idx, embedded.err = Read(bytes.NewReader(embeddedCities))
+		if embedded.err != nil {
+			embedded.err = fmt.Errorf("offline: embedded dataset: %w", embedded.err)
+		}
+	})
+	return embedded.idx, embedded.err
+}
@@ -0,0 +1,123 @@
+package offline
+
+import (
+	"bytes"
+	"compress/gzip"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// samplePath is the dump of the integration tests: places of Mexico,
+// Germany and Fiji, which straddles the antimeridian.
+const samplePath = "../../cmd/reverse_geocode/testdata/integration/geoname.txt"
+
+func readSample(t *testing.T) *Index {
+	t.Helper()
+	f, err := os.Open(samplePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	idx, err := Read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return idx
+}
+
+// haversineKm is the distance Nearest must agree with.
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const rad = math.Pi / 180
+	dLat, dLon := (lat2-lat1)*rad, (lon2-lon1)*rad
+	a := math.Pow(math.Sin(dLat/2), 2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Pow(math.Sin(dLon/2), 2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+func TestNearest(t *testing.T) {
+	idx := readSample(t)
+	points := [][2]float64{{19.4326, -99.1332}, {52.52, 13.405}, {-17.8, 179.9}, {-17.8, -179.9}, {0, 0}}
+	for _, p := range points {
+		got := idx.Nearest(p[0], p[1], Options{Limit: 3})
+		if len(got) != 3 {
+			t.Fatalf("%v: %d places, want 3", p, len(got))
+		}
+		// Brute force: no other place may be nearer than the third.
+		for _, pl := range idx.places {
+			d := haversineKm(p[0], p[1], pl.Latitude, pl.Longitude)
+			found := slices.ContainsFunc(got, func(g Place) bool { return g.Geonameid == pl.Geonameid })
+			if !found && d < got[2].DistanceKm-1e-6 {
+				t.Errorf("%v: %s (%.3f km) is nearer than %s", p, pl.Name, d, got[2].Name)
+			}
+		}
+		for i, pl := range got {
+			if d := haversineKm(p[0], p[1], pl.Latitude, pl.Longitude); math.Abs(d-pl.DistanceKm) > 1e-6 {
+				t.Errorf("%v: %s: distance %.6f km, want %.6f", p, pl.Name, pl.DistanceKm, d)
+			}
+			if i > 0 && pl.DistanceKm < got[i-1].DistanceKm {
+				t.Errorf("%v: places not sorted by distance", p)
+			}
+		}
+	}
+}
+
+func TestNearestOptions(t *testing.T) {
+	idx := readSample(t)
+	for _, pl := range idx.Nearest(19.4326, -99.1332, Options{Limit: 5, Country: "de"}) {
+		if pl.Country != "DE" {
+			t.Errorf("Country: got %s in %s", pl.Name, pl.Country)
+		}
+	}
+	for _, fclass := range []string{"S", "s"} {
+		got := idx.Nearest(19.4326, -99.1332, Options{Limit: 5, FeatureClass: fclass})
+		if len(got) == 0 {
+			t.Errorf("FeatureClass %q: no places", fclass)
+		}
+		for _, pl := range got {
+			if pl.Fclass != "S" {
+				t.Errorf("FeatureClass %q: got %s of class %s", fclass, pl.Name, pl.Fclass)
+			}
+		}
+	}
+	got := idx.Nearest(19.4326, -99.1332, Options{Limit: 5, MinPopulation: 5000000})
+	if len(got) != 1 || got[0].Name != "Mexico City" {
+		t.Errorf("MinPopulation: got %v, want Mexico City only", got)
+	}
+	if got := idx.Nearest(0, 0, Options{}); len(got) != 0 {
+		t.Errorf("Limit 0: got %d places", len(got))
+	}
+}
+
+func TestReadGzip(t *testing.T) {
+	data, err := os.ReadFile(samplePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	idx, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := readSample(t).Len(); idx.Len() != want {
+		t.Errorf("got %d places, want %d", idx.Len(), want)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	for _, line := range []string{
+		"1\tshort",
+		"x\tA\tA\t\t19\t-99\tP\tPPL\tMX\t\t09\t\t\t\t0\t\t\tAmerica/Mexico_City\t2024-01-01",
+		"1\tA\tA\t\t91\t-99\tP\tPPL\tMX\t\t09\t\t\t\t0\t\t\tAmerica/Mexico_City\t2024-01-01",
+	} {
+		if _, err := Read(strings.NewReader("# header\n" + line + "\n")); err == nil ||
+			!strings.HasPrefix(err.Error(), "line 2:") {
+			t.Errorf("%q: got %v, want an error on line 2", line, err)
+		}
+	}
+}
@@ -28,6 +28,20 @@
 
     Usage:
         python load_geonames.py [--config CONFIG_FILE] [--skip-indexes] [-o]
+                                [--countries MX,US,CA] [--feature-classes P,A]
+        python load_geonames.py --prune --countries MX,US,CA
+                                [--feature-classes P,A]
+
+    --countries / --feature-classes restrict the load to the given ISO 3166-1
+    alpha-2 codes and GeoNames feature classes: only matching geoname rows,
+    their alternate names, and the postal codes, admin codes and time zones
+    of those countries are inserted. countryinfo, featurecodes and the other
+    reference tables are always loaded in full.
+
+    --prune applies the same selection to an already-loaded database in
+    place, deleting every other row, then reclaims the space (VACUUM FULL on
+    PostgreSQL, VACUUM on SQLite, OPTIMIZE TABLE on MySQL/MariaDB). No data
+    files are needed.
 
     The config 'database' section accepts either a SQLAlchemy URL:
 
@@ -48,7 +62,7 @@ import argparse
 import csv
 import sys
 import unicodedata
-from collections.abc import Iterator
+from collections.abc import Callable, Iterator
 from datetime import datetime, timezone
 from pathlib import Path
 
@@ -57,7 +71,7 @@ import yaml
 from sqlalchemy import (
     BigInteger, Boolean, CHAR, Column, Date, DateTime, Float, Index,
     Integer, MetaData, Numeric, SmallInteger, String, Table, Text,
-    create_engine, func, text, update,
+    create_engine, delete, func, or_, select, text, update,
 )
 from sqlalchemy.engine import Engine
 
@@ -67,6 +81,11 @@ from sqlalchemy.engine import Engine
 
 metadata = MetaData()
 
+# Version of the schema below, recorded in schema_version. The Go
+# geocoder (examples/go/pkg/geocoder/schema.go) refuses databases of
+# another version; bump both together.
+SCHEMA_VERSION = 1
+
 t_geoname = Table(
     "geoname", metadata,
     Column("geonameid",      Integer,      nullable=True),
@@ -207,12 +226,18 @@ t_meta = Table(
     Column("date_accessed", DateTime, nullable=True),
 )
 
+t_schema_version = Table(
+    "schema_version", metadata,
+    Column("version",    Integer,  nullable=False),
+    Column("applied_at", DateTime, nullable=True),
+)
+
 # Drop order that respects FK dependencies (dependents first)
 _DROP_ORDER = [
     t_alternatename, t_countryinfo, t_geoname,
     t_postalcodes, t_admin1codesascii, t_admin2codesascii,
     t_iso_languagecodes, t_featurecodes, t_timezones,
-    t_continentcodes, t_meta,
+    t_continentcodes, t_meta, t_schema_version,
 ]
 
 
@@ -291,8 +316,15 @@ def drop_and_create_tables(engine: Engine) -> None:
 _CHUNK_SIZE = 10_000
 
 
-def _iter_tsv_rows(filepath: Path, columns: list[str]) -> Iterator[dict]:
-    """Stream a tab-delimited file as dicts, skipping comment/blank lines."""
+# A row filter receives the raw fields of a data-file line and returns
+# whether the row should be loaded.
+RowFilter = Callable[[list[str]], bool]
+
+
+def _iter_tsv_rows(filepath: Path, columns: list[str],
+                   keep: RowFilter | None = None) -> Iterator[dict]:
+    """Stream a tab-delimited file as dicts, skipping comment/blank lines
+    and, when given, lines rejected by keep."""
     with open(filepath, "r", encoding="utf-8", errors="replace") as f:
         reader = csv.reader(f, delimiter="\t", quotechar="\x01")
         for line in reader:
@@ -300,6 +332,8 @@ def _iter_tsv_rows(filepath: Path, columns: list[str]) -> Iterator[dict]:
                 continue
             if len(line) == 1 and line[0].startswith("#"):
                 continue
+            if keep is not None and not keep(line):
+                continue
             if len(line) < len(columns):
                 line += [""] * (len(columns) - len(line))
             yield {
@@ -312,8 +346,52 @@ def _iter_tsv_rows(filepath: Path, columns: list[str]) -> Iterator[dict]:
 # -----------------------------------------------------------------------------
 
 
+class _LineFilter:
+    """Read-only file object passing on only the lines of f accepted by keep.
+
+    Reads f in blocks, so a tqdm wrapper around f.read keeps reporting
+    progress over the whole file.
+    """
+
+    _BLOCK = 1 << 16
+
+    def __init__(self, f, keep: RowFilter):
+        self._f = f
+        self._keep = keep
+        self._tail = ""   # incomplete last line of the previous block
+        self._out = ""    # accepted text not yet returned
+        self._eof = False
+
+    def _fill(self) -> None:
+        block = self._f.read(self._BLOCK)
+        if not block:
+            self._eof = True
+            lines = [self._tail] if self._tail else []
+            self._tail = ""
+        else:
+            lines = (self._tail + block).split("\n")
+            self._tail = lines.pop()
+            lines = [line + "\n" for line in lines]
+        for line in lines:
+            fields = line.rstrip("\r\n").split("\t")
+            if self._keep(fields):
+                self._out += line if line.endswith("\n") else line + "\n"
+
+    def read(self, size: int = -1) -> str:
+        while not self._eof and (size < 0 or len(self._out) < size):
+            self._fill()
+        if size < 0:
+            size = len(self._out)
+        out, self._out = self._out[:size], self._out[size:]
+        return out
+# _LineFilter
+
+
+# -----------------------------------------------------------------------------
+
+
 def _copy_pg(engine: Engine, table: Table, columns: list[str],
-             filepath: Path) -> None:
+             filepath: Path, keep: RowFilter | None = None) -> None:
     """Fast COPY-based bulk load using PostgreSQL's native COPY protocol."""
     cols = ", ".join(columns)
     sql = (
@@ -330,7 +408,8 @@ def _copy_pg(engine: Engine, table: Table, columns: list[str],
                  desc=f"  {table.name}",
                  unit="B", unit_scale=True, unit_divisor=1024,
              ) as fwrapped:
-            cur.copy_expert(sql, fwrapped)
+            source = fwrapped if keep is None else _LineFilter(fwrapped, keep)
+            cur.copy_expert(sql, source)
         raw_conn.commit()
     finally:
         raw_conn.close()
@@ -341,13 +420,13 @@ def _copy_pg(engine: Engine, table: Table, columns: list[str],
 
 
 def _insert_chunks(engine: Engine, table: Table, columns: list[str],
-                   filepath: Path) -> int:
+                   filepath: Path, keep: RowFilter | None = None) -> int:
     """Chunked INSERT bulk load for non-PostgreSQL engines (streams the file)."""
     count = 0
     chunk: list[dict] = []
     with engine.begin() as conn, \
          tqdm.tqdm(
-             _iter_tsv_rows(filepath, columns),
+             _iter_tsv_rows(filepath, columns, keep),
              desc=f"  {table.name}",
              unit=" rows", unit_scale=True, mininterval=0.5,
          ) as pbar:
@@ -368,15 +447,152 @@ def _insert_chunks(engine: Engine, table: Table, columns: list[str],
 
 
 def load_file(engine: Engine, table: Table, columns: list[str],
-              filepath: Path) -> None:
-    """Load a TSV data file into a table using the best method for the dialect."""
+              filepath: Path, keep: RowFilter | None = None) -> None:
+    """Load a TSV data file into a table using the best method for the dialect.
+    Rows rejected by keep are skipped."""
     if is_postgresql(engine):
-        _copy_pg(engine, table, columns, filepath)
+        _copy_pg(engine, table, columns, filepath, keep)
     else:
-        _insert_chunks(engine, table, columns, filepath)
+        _insert_chunks(engine, table, columns, filepath, keep)
 # load_file
 
 
+# ---------------------------------------------------------------------------
+# Country / feature-class selection
+# ---------------------------------------------------------------------------
+
+_FEATURE_CLASSES = set("AHLPRSTUV")
+
+
+def parse_code_list(value: str | None, length: int,
+                    allowed: set[str] | None = None) -> list[str]:
+    """Parse a comma-separated list of codes ("mx, US") into sorted,
+    de-duplicated upper-case codes. Raises ValueError on a malformed code."""
+    if not value:
+        return []
+    codes = set()
+    for code in value.split(","):
+        code = code.strip().upper()
+        if not code:
+            continue
+        if len(code) != length or not code.isalpha() \
+                or (allowed is not None and code not in allowed):
+            raise ValueError(f"invalid code {code!r}")
+        codes.add(code)
+    return sorted(codes)
+# parse_code_list
+
+
+# -----------------------------------------------------------------------------
+
+
+class Selection:
+    """Countries and feature classes to keep; empty lists keep everything.
+
+    The geoname filter records the geonameid of every accepted row so the
+    alternate names of those places can be selected afterwards.
+    """
+
+    def __init__(self, countries: list[str], feature_classes: list[str]):
+        self.countries = set(countries)
+        self.feature_classes = set(feature_classes)
+        self.geonameids: set[str] = set()
+
+    def __bool__(self) -> bool:
+        return bool(self.countries or self.feature_classes)
+
+    def _country_ok(self, code: str) -> bool:
+        return not self.countries or code in self.countries
+
+    def geoname(self, fields: list[str]) -> bool:
+        # allCountries.txt: geonameid, ..., fclass (6), fcode, country (8)
+        if len(fields) < 9:
+            return False
+        if not self._country_ok(fields[8]):
+            return False
+        if self.feature_classes and fields[6] not in self.feature_classes:
+            return False
+        self.geonameids.add(fields[0])
+        return True
+
+    def alternatename(self, fields: list[str]) -> bool:
+        return len(fields) > 1 and fields[1] in self.geonameids
+
+    def by_country(self, fields: list[str]) -> bool:
+        # postalcodes and timeZones: country code is the first column
+        return self._country_ok(fields[0])
+
+    def by_admin_code(self, fields: list[str]) -> bool:
+        # admin1CodesASCII / admin2Codes: "CC.ADM1[.ADM2]"
+        return self._country_ok(fields[0].split(".", 1)[0])
+# Selection
+
+
+# -----------------------------------------------------------------------------
+
+
+def prune_database(engine: Engine, selection: Selection) -> dict[str, int]:
+    """Delete the rows outside selection from an existing database.
+    Returns the number of rows deleted per table."""
+    deleted: dict[str, int] = {}
+    countries = sorted(selection.countries)
+    with engine.begin() as conn:
+        g = t_geoname.c
+        conds = []
+        if countries:
+            conds += [g.country.not_in(countries), g.country.is_(None)]
+        if selection.feature_classes:
+            conds += [
+                g.fclass.not_in(sorted(selection.feature_classes)),
+                g.fclass.is_(None),
+            ]
+        deleted["geoname"] = conn.execute(
+            delete(t_geoname).where(or_(*conds))
+        ).rowcount
+
+        a = t_alternatename.c
+        deleted["alternatename"] = conn.execute(
+            delete(t_alternatename).where(
+                ~select(g.geonameid)
+                .where(g.geonameid == a.geonameid)
+                .exists()
+            )
+        ).rowcount
+
+        if countries:
+            for tbl, col in (
+                (t_postalcodes, t_postalcodes.c.countrycode),
+                (t_admin1codesascii, t_admin1codesascii.c.countrycode),
+                (t_admin2codesascii, t_admin2codesascii.c.countrycode),
+                (t_timezones, t_timezones.c.countrycode),
+            ):
+                deleted[tbl.name] = conn.execute(
+                    delete(tbl).where(or_(col.not_in(countries), col.is_(None)))
+                ).rowcount
+    return deleted
+# prune_database
+
+
+# -----------------------------------------------------------------------------
+
+
+def reclaim_space(engine: Engine, tables: list[str]) -> None:
+    """Return the space freed by deletions to the operating system."""
+    dialect = engine.dialect.name
+    with engine.connect() as conn:
+        # VACUUM cannot run inside a transaction block
+        conn.execution_options(isolation_level="AUTOCOMMIT")
+        if dialect == "postgresql":
+            for tbl in tables:
+                conn.execute(text(f"VACUUM FULL ANALYZE {tbl}"))
+        elif dialect == "sqlite":
+            conn.execute(text("VACUUM"))
+        elif dialect in ("mysql", "mariadb"):
+            for tbl in tables:
+                conn.execute(text(f"OPTIMIZE TABLE {tbl}"))
+# reclaim_space
+
+
 # ---------------------------------------------------------------------------
 # Admin-codes enrichment
 # ---------------------------------------------------------------------------
@@ -712,8 +928,36 @@ def main() -> None:
         action="store_true",
         help="Drop and recreate all tables before loading (overwrite existing data)",
     )
+    parser.add_argument(
+        "--countries",
+        help="Comma-separated ISO 3166-1 alpha-2 codes to load or keep "
+             "(e.g. MX,US,CA; default: all)",
+    )
+    parser.add_argument(
+        "--feature-classes",
+        help="Comma-separated GeoNames feature classes to load or keep "
+             "(e.g. P,A; default: all)",
+    )
+    parser.add_argument(
+        "--prune",
+        action="store_true",
+        help="Instead of loading, delete everything outside --countries / "
+             "--feature-classes from the existing database",
+    )
     args = parser.parse_args()
 
+    try:
+        selection = Selection(
+            parse_code_list(args.countries, 2),
+            parse_code_list(args.feature_classes, 1, _FEATURE_CLASSES),
+        )
+    except ValueError as e:
+        parser.error(f"--countries / --feature-classes: {e}")
+    if args.prune and not selection:
+        parser.error("--prune needs --countries and/or --feature-classes")
+    if args.prune and args.overwrite:
+        parser.error("--prune cannot be combined with --overwrite")
+
     config = load_config(args.config)
     dl = config["download"]
     meta_cfg = config.get("meta", {})
@@ -730,8 +974,29 @@ def main() -> None:
     print(f"  Host    : {db_url.host}:{db_url.port}")
     print(f"  Database: {db_url.database}")
     print(f"  Data dir: {data_dir.resolve()}")
+    if selection.countries:
+        print(f"  Countries: {', '.join(sorted(selection.countries))}")
+    if selection.feature_classes:
+        print(f"  Classes : {', '.join(sorted(selection.feature_classes))}")
     print("=" * 60)
 
+    if args.prune:
+        try:
+            print("\nPruning ...")
+            deleted = prune_database(engine, selection)
+            for tbl, n in deleted.items():
+                print(f"  {tbl:<18} {n:>12,} rows deleted")
+            print("\nReclaiming space ...", end=" ", flush=True)
+            reclaim_space(engine, list(deleted))
+            print("done")
+        except Exception as e:
+            print(f"\nError: {e}")
+            sys.exit(1)
+        finally:
+            engine.dispose()
+        print("\nPrune complete.")
+        return
+
     # Verify required files exist
     required = {
         "allCountries.txt":             data_dir / "allCountries.txt",
@@ -786,17 +1051,22 @@ def main() -> None:
              "admin2", "admin3", "admin4", "population", "elevation",
              "gtopo30", "timezone", "moddate"],
             data_dir / "allCountries.txt",
+            selection.geoname if selection else None,
         )
         load_file(
             engine, t_alternatename,
             ["alternatenameid", "geonameid", "isolanguage", "alternatename",
              "ispreferredname", "isshortname", "iscolloquial", "ishistoric"],
             data_dir / "alternateNames.txt",
+            selection.alternatename if selection else None,
         )
+        by_country = selection.by_country if selection.countries else None
+        by_admin_code = selection.by_admin_code if selection.countries else None
         load_file(
             engine, t_timezones,
             ["countrycode", "timezoneid", "gmt_offset", "dst_offset", "raw_offset"],
             data_dir / "timeZones.txt.tmp",
+            by_country,
         )
         load_file(
             engine, t_featurecodes,
@@ -807,11 +1077,13 @@ def main() -> None:
             engine, t_admin1codesascii,
             ["code", "name", "nameascii", "geonameid"],
             data_dir / "admin1CodesASCII.txt",
+            by_admin_code,
         )
         load_file(
             engine, t_admin2codesascii,
             ["code", "name", "nameascii", "geonameid"],
             data_dir / "admin2Codes.txt",
+            by_admin_code,
         )
         load_file(
             engine, t_iso_languagecodes,
@@ -832,6 +1104,7 @@ def main() -> None:
              "admin2name", "admin2code", "admin3name", "admin3code",
              "latitude", "longitude", "accuracy"],
             postal_dir / "allCountries.txt",
+            by_country,
         )
 
         # Continent codes are static — insert directly
@@ -865,6 +1138,10 @@ def main() -> None:
                 data_version=meta_cfg.get("data_version", ""),
                 date_accessed=download_timestamp,
             ))
+            conn.execute(t_schema_version.insert().values(
+                version=SCHEMA_VERSION,
+                applied_at=datetime.now(timezone.utc),
+            ))
         print("  Metadata inserted.")
 
         # ---------------------------------------------------------------- #
@@ -162,7 +162,7 @@ class TestDropAndCreateTables:
         expected = {
             "geoname", "alternatename", "countryinfo", "iso_languagecodes",
             "admin1codesascii", "admin2codesascii", "featurecodes", "timezones",
-            "continentcodes", "postalcodes", "meta",
+            "continentcodes", "postalcodes", "meta", "schema_version",
         }
         with engine.connect() as conn:
             existing = {
@@ -292,6 +292,167 @@ class TestEnrichAdminCodes:
         assert row.admin1nameascii == "Oaxaca"
 
 
+# ---------------------------------------------------------------------------
+# parse_code_list
+# ---------------------------------------------------------------------------
+
+class TestParseCodeList:
+    def test_none_and_empty_give_empty_list(self):
+        assert lg.parse_code_list(None, 2) == []
+        assert lg.parse_code_list("", 2) == []
+
+    def test_normalises_case_spaces_and_duplicates(self):
+        assert lg.parse_code_list("mx, US,MX,", 2) == ["MX", "US"]
+
+    def test_rejects_wrong_length(self):
+        with pytest.raises(ValueError):
+            lg.parse_code_list("MEX", 2)
+
+    def test_rejects_code_outside_allowed_set(self):
+        with pytest.raises(ValueError):
+            lg.parse_code_list("P,X", 1, lg._FEATURE_CLASSES)
+
+
+# ---------------------------------------------------------------------------
+# Selection
+# ---------------------------------------------------------------------------
+
+def _geoname_fields(geonameid: str, country: str, fclass: str) -> list[str]:
+    return [geonameid, "Name", "Name", "", "0", "0", fclass, "PPL", country]
+
+
+class TestSelection:
+    def test_empty_selection_is_false(self):
+        assert not lg.Selection([], [])
+        assert lg.Selection(["MX"], [])
+
+    def test_geoname_filters_country_and_class(self):
+        sel = lg.Selection(["MX"], ["P"])
+        assert sel.geoname(_geoname_fields("1", "MX", "P"))
+        assert not sel.geoname(_geoname_fields("2", "US", "P"))
+        assert not sel.geoname(_geoname_fields("3", "MX", "A"))
+
+    def test_alternatename_follows_accepted_geonames(self):
+        sel = lg.Selection(["MX"], [])
+        sel.geoname(_geoname_fields("1", "MX", "P"))
+        sel.geoname(_geoname_fields("2", "US", "P"))
+        assert sel.alternatename(["100", "1", "es", "Ciudad"])
+        assert not sel.alternatename(["101", "2", "en", "Town"])
+
+    def test_by_country_and_admin_code(self):
+        sel = lg.Selection(["MX", "CA"], [])
+        assert sel.by_country(["CA", "H0H"])
+        assert not sel.by_country(["US", "90210"])
+        assert sel.by_admin_code(["MX.09.015"])
+        assert not sel.by_admin_code(["US.CA"])
+
+
+# ---------------------------------------------------------------------------
+# _LineFilter  (feeds COPY on PostgreSQL)
+# ---------------------------------------------------------------------------
+
+class TestLineFilter:
+    def _read_all(self, f, size: int) -> str:
+        out = ""
+        while chunk := f.read(size):
+            out += chunk
+        return out
+
+    def test_keeps_only_accepted_lines(self, tmp_path):
+        src = tmp_path / "data.txt"
+        _write_tsv(src, ["MX\t1", "US\t2", "MX\t3"])
+        with open(src, encoding="utf-8") as fh:
+            f = lg._LineFilter(fh, lambda fields: fields[0] == "MX")
+            assert f.read() == "MX\t1\nMX\t3\n"
+
+    def test_lines_split_across_blocks(self, tmp_path, monkeypatch):
+        monkeypatch.setattr(lg._LineFilter, "_BLOCK", 4)
+        src = tmp_path / "data.txt"
+        src.write_text("MX\tlong value\nUS\tx\nMX\tlast", encoding="utf-8")
+        with open(src, encoding="utf-8") as fh:
+            f = lg._LineFilter(fh, lambda fields: fields[0] == "MX")
+            assert self._read_all(f, 3) == "MX\tlong value\nMX\tlast\n"
+
+
+# ---------------------------------------------------------------------------
+# _insert_chunks with a row filter  (SQLite path)
+# ---------------------------------------------------------------------------
+
+class TestInsertChunksFiltered:
+    def test_skips_rejected_rows(self, tmp_path, sqlite_engine):
+        f = tmp_path / "data.txt"
+        _write_tsv(f, ["MX\tAmerica/Mexico_City", "US\tAmerica/New_York"])
+        count = lg._insert_chunks(
+            sqlite_engine, lg.t_timezones, ["countrycode", "timezoneid"], f,
+            lg.Selection(["MX"], []).by_country,
+        )
+        assert count == 1
+        with sqlite_engine.connect() as conn:
+            row = conn.execute(select(lg.t_timezones)).fetchone()
+        assert row.countrycode == "MX"
+
+
+# ---------------------------------------------------------------------------
+# prune_database  (SQLite path)
+# ---------------------------------------------------------------------------
+
+class TestPruneDatabase:
+    @pytest.fixture
+    def loaded(self, sqlite_engine):
+        with sqlite_engine.begin() as conn:
+            conn.execute(lg.t_geoname.insert(), [
+                {"geonameid": 1, "name": "Mexico City", "country": "MX", "fclass": "P"},
+                {"geonameid": 2, "name": "Jalisco", "country": "MX", "fclass": "A"},
+                {"geonameid": 3, "name": "Boston", "country": "US", "fclass": "P"},
+            ])
+            conn.execute(lg.t_alternatename.insert(), [
+                {"alternatenameid": 10, "geonameid": 1, "alternatename": "CDMX"},
+                {"alternatenameid": 11, "geonameid": 3, "alternatename": "Beantown"},
+            ])
+            conn.execute(lg.t_postalcodes.insert(), [
+                _postal_row("MX", "06000", "09"),
+                _postal_row("US", "02108", "MA"),
+            ])
+            conn.execute(lg.t_admin1codesascii.insert(), [
+                {"code": "MX.09", "name": "CDMX", "countrycode": "MX"},
+                {"code": "US.MA", "name": "Massachusetts", "countrycode": "US"},
+            ])
+            conn.execute(lg.t_timezones.insert(), [
+                {"countrycode": "MX", "timezoneid": "America/Mexico_City"},
+                {"countrycode": "US", "timezoneid": "America/New_York"},
+            ])
+        return sqlite_engine
+
+    def _count(self, engine, table) -> int:
+        with engine.connect() as conn:
+            return conn.execute(
+                text(f"SELECT count(*) FROM {table.name}")
+            ).scalar()
+
+    def test_prune_by_country(self, loaded):
+        deleted = lg.prune_database(loaded, lg.Selection(["MX"], []))
+        assert deleted["geoname"] == 1
+        assert deleted["alternatename"] == 1
+        assert self._count(loaded, lg.t_geoname) == 2
+        assert self._count(loaded, lg.t_postalcodes) == 1
+        assert self._count(loaded, lg.t_admin1codesascii) == 1
+        assert self._count(loaded, lg.t_timezones) == 1
+
+    def test_prune_by_feature_class_keeps_other_tables(self, loaded):
+        lg.prune_database(loaded, lg.Selection([], ["P"]))
+        with loaded.connect() as conn:
+            ids = {r.geonameid for r in conn.execute(select(lg.t_geoname))}
+        assert ids == {1, 3}
+        assert self._count(loaded, lg.t_alternatename) == 2
+        assert self._count(loaded, lg.t_postalcodes) == 2
+
+    def test_reclaim_space_runs_on_sqlite(self, tmp_path):
+        engine = create_engine(f"sqlite:///{tmp_path / 'db.sqlite'}")
+        lg.metadata.create_all(engine)
+        lg.reclaim_space(engine, ["geoname"])
+        engine.dispose()
+
+
 # ---------------------------------------------------------------------------
 # Shared helper
 # ---------------------------------------------------------------------------